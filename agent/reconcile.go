@@ -68,6 +68,8 @@ func (ar *AgentReconciler) Run(a *Agent, stop <-chan struct{}) {
 // Reconcile drives the local Agent's state towards the desired state
 // stored in the Registry.
 func (ar *AgentReconciler) Reconcile(a *Agent) {
+	ar.refreshMetadataOverlay(a)
+
 	dAgentState, err := desiredAgentState(a, ar.reg)
 	if err != nil {
 		log.Errorf("Unable to determine agent's desired state: %v", err)
@@ -84,6 +86,23 @@ func (ar *AgentReconciler) Reconcile(a *Agent) {
 	ar.launchTasks(tasks, a)
 }
 
+// refreshMetadataOverlay overlays the metadata set through the API on that
+// of the local Machine, if the Machine supports it, so that it is taken
+// into account without restarting the Agent.
+func (ar *AgentReconciler) refreshMetadataOverlay(a *Agent) {
+	mo, ok := a.Machine.(machine.MetadataOverlayer)
+	if !ok {
+		return
+	}
+
+	metadata, err := ar.reg.MachineMetadata(a.Machine.State().ID)
+	if err != nil {
+		log.Errorf("Failed fetching metadata of local Machine from Registry: %v", err)
+		return
+	}
+	mo.SetMetadataOverlay(metadata)
+}
+
 // Purge attempts to unload all Units that have been loaded locally
 func (ar *AgentReconciler) Purge(a *Agent) {
 	for {
@@ -128,6 +147,15 @@ func desiredAgentState(a *Agent, reg registry.Registry) (*AgentState, error) {
 	}
 
 	ms := a.Machine.State()
+	// the taints set through the API are only merged into the Machines
+	// read from the Registry, not into the state of the local Machine
+	taints, err := reg.MachineTaints(ms.ID)
+	if err != nil {
+		log.Errorf("Failed fetching taints of local Machine from Registry: %v", err)
+		return nil, err
+	}
+	ms.Taints = machine.OverlayTaints(ms.Taints, taints)
+
 	as := AgentState{
 		MState: &ms,
 		Units:  make(map[string]*job.Unit),
@@ -141,13 +169,25 @@ func desiredAgentState(a *Agent, reg registry.Registry) (*AgentState, error) {
 
 	for _, u := range units {
 		u := u
-		md := u.RequiredTargetMetadata()
+		md := u.MetadataRequirements()
 
 		if u.IsGlobal() {
-			if !machine.HasMetadata(&ms, md) {
+			if !machine.MatchesMetadata(&ms, md) {
 				log.Debugf("Agent unable to run global unit %s: missing required metadata", u.Name)
 				continue
 			}
+			if taint, found := machine.UntoleratedTaint(&ms, u.Tolerations()); found {
+				log.Debugf("Agent unable to run global unit %s: taint %s not tolerated", u.Name, taint)
+				continue
+			}
+			if u.TargetState != job.JobStateInactive && !u.RolloutAdmits(ms.ID) {
+				log.Debugf("Agent not yet admitted to run global unit %s by its rollout", u.Name)
+				if as.deferred == nil {
+					as.deferred = make(map[string]bool)
+				}
+				as.deferred[u.Name] = true
+				continue
+			}
 		}
 
 		if !u.IsGlobal() {
@@ -161,6 +201,12 @@ func desiredAgentState(a *Agent, reg registry.Registry) (*AgentState, error) {
 			continue
 		}
 
+		// the engine holds launched units until the units they
+		// StartAfter are active, only load them in the meantime
+		if u.Held && u.TargetState == job.JobStateLaunched {
+			u.TargetState = job.JobStateLoaded
+		}
+
 		as.Units[u.Name] = &u
 	}
 
@@ -220,6 +266,10 @@ func (ar *AgentReconciler) calculateTasksForUnit(dState *AgentState, cState unit
 		cJState = &us.state
 		cJHash = us.hash
 	}
+	if dState != nil && dState.deferred[jName] {
+		log.Debugf("Job(%s) awaiting its rollout to the local machine, leaving it alone", jName)
+		return nil
+	}
 	if dJob == nil && cJState == nil {
 		log.Errorf("Desired state and current state of Job(%s) nil, not sure what to do", jName)
 		return nil
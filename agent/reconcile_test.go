@@ -170,6 +170,42 @@ MachineMetadata=dog=woof`),
 			},
 			map[string]*job.Unit{},
 		},
+		// Global Unit with a metadata expression we satisfy
+		{
+			map[string]string{"dog": "woof"},
+			[]job.Job{
+				job.Job{
+					Name: "global.mount",
+					Unit: newUF(t, `
+[X-Fleet]
+Global=true
+MachineMetadata=dog in (bark,woof)`),
+				},
+			},
+			map[string]*job.Unit{
+				"global.mount": &job.Unit{
+					Name: "global.mount",
+					Unit: newUF(t, `
+[X-Fleet]
+Global=true
+MachineMetadata=dog in (bark,woof)`),
+				},
+			},
+		},
+		// Global Unit with a metadata expression we don't satisfy
+		{
+			map[string]string{"dog": "woof"},
+			[]job.Job{
+				job.Job{
+					Name: "global.mount",
+					Unit: newUF(t, `
+[X-Fleet]
+Global=true
+MachineMetadata=dog!=woof`),
+				},
+			},
+			map[string]*job.Unit{},
+		},
 		// Mix it up a bit!
 		{
 			nil,
@@ -271,6 +307,167 @@ MachineMetadata=dog=woof`),
 	}
 }
 
+func TestDesiredAgentStateHeldUnit(t *testing.T) {
+	reg := registry.NewFakeRegistry()
+	reg.SetJobs([]job.Job{
+		job.Job{
+			Name:            "foo.service",
+			Unit:            newUF(t, "[X-Fleet]\nStartAfter=db.service"),
+			TargetState:     job.JobStateLaunched,
+			TargetMachineID: "this_machine",
+		},
+	})
+	a := makeAgentWithMetadata(nil)
+
+	for _, held := range []bool{true, false} {
+		if held {
+			reg.HoldUnit("foo.service")
+		} else {
+			reg.ReleaseUnit("foo.service")
+		}
+
+		as, err := desiredAgentState(a, reg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		u := as.Units["foo.service"]
+		if u == nil {
+			t.Fatalf("expected foo.service in AgentState")
+		}
+
+		want := job.JobStateLaunched
+		if held {
+			want = job.JobStateLoaded
+		}
+		if u.TargetState != want {
+			t.Errorf("held=%t: expected target state %s, got %s", held, want, u.TargetState)
+		}
+	}
+}
+
+func TestDesiredAgentStateGlobalRollout(t *testing.T) {
+	uf := newUF(t, "[X-Fleet]\nGlobal=true\nGlobalMaxParallel=1")
+	reg := registry.NewFakeRegistry()
+	reg.SetJobs([]job.Job{
+		job.Job{
+			Name:        "foo.service",
+			Unit:        uf,
+			TargetState: job.JobStateLaunched,
+		},
+	})
+	a := makeAgentWithMetadata(nil)
+
+	as, err := desiredAgentState(a, reg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if as.Units["foo.service"] != nil {
+		t.Fatalf("expected foo.service to await its rollout")
+	}
+	// a version of the unit that is already loaded is left alone
+	cState := unitStates{"foo.service": unitState{state: job.JobStateLaunched, hash: "abc"}}
+	ar := NewReconciler(reg, nil)
+	if tasks := ar.calculateTasksForUnits(as, cState); len(tasks) != 0 {
+		t.Errorf("expected no tasks, got %v", tasks)
+	}
+
+	reg.AdmitGlobalUnit("foo.service", "other", "this_machine")
+	reg.AdmitGlobalUnit("foo.service", uf.Hash().String(), "this_machine")
+	as, err = desiredAgentState(a, reg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if as.Units["foo.service"] == nil {
+		t.Fatalf("expected foo.service in AgentState once admitted")
+	}
+}
+
+func TestDesiredAgentStateTaints(t *testing.T) {
+	reg := registry.NewFakeRegistry()
+	reg.SetJobs([]job.Job{
+		job.Job{
+			Name:        "plain.service",
+			Unit:        newUF(t, "[X-Fleet]\nGlobal=true"),
+			TargetState: job.JobStateLaunched,
+		},
+		job.Job{
+			Name:        "tolerant.service",
+			Unit:        newUF(t, "[X-Fleet]\nGlobal=true\nTolerates=dedicated"),
+			TargetState: job.JobStateLaunched,
+		},
+	})
+	a := makeAgentWithMetadata(nil)
+	a.Machine.(*machine.FakeMachine).MachineState.Taints = map[string]string{"dedicated": "db"}
+
+	as, err := desiredAgentState(a, reg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if as.Units["plain.service"] != nil {
+		t.Errorf("expected plain.service to be repelled by the taint")
+	}
+	if as.Units["tolerant.service"] == nil {
+		t.Errorf("expected tolerant.service in AgentState")
+	}
+}
+
+func TestReconcileRegistryTaints(t *testing.T) {
+	uf := newUF(t, "[X-Fleet]\nGlobal=true")
+	reg := registry.NewFakeRegistry()
+	reg.SetJobs([]job.Job{
+		job.Job{
+			Name:        "plain.service",
+			Unit:        uf,
+			TargetState: job.JobStateLaunched,
+		},
+	})
+	reg.SetMachineTaints("this_machine", map[string]string{"dedicated": "db"})
+	a := makeAgentWithMetadata(nil)
+
+	as, err := desiredAgentState(a, reg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if as.Units["plain.service"] != nil {
+		t.Fatalf("expected plain.service to be repelled by the taint set through the registry")
+	}
+
+	// the unit already running on the Machine is unloaded
+	cState := unitStates{"plain.service": unitState{state: job.JobStateLaunched, hash: uf.Hash().String()}}
+	ar := NewReconciler(reg, nil)
+	tasks := ar.calculateTasksForUnits(as, cState)
+	if len(tasks) == 0 || tasks[0].typ != taskTypeUnloadUnit || tasks[0].unit.Name != "plain.service" {
+		t.Errorf("expected plain.service to be unloaded, got %v", tasks)
+	}
+}
+
+func TestRefreshMetadataOverlay(t *testing.T) {
+	reg := registry.NewFakeRegistry()
+	reg.SetMachines([]machine.MachineState{{ID: "this_machine"}})
+	reg.SetMachineMetadata("this_machine", "region", "us-west")
+	reg.SetMachineMetadata("this_machine", "rack", "r12")
+	a := &Agent{
+		Machine: machine.NewCoreOSMachine(machine.MachineState{
+			ID:       "this_machine",
+			Metadata: map[string]string{"region": "us-east", "ssd": "true"},
+		}, nil),
+	}
+	ar := &AgentReconciler{reg: reg}
+
+	ar.refreshMetadataOverlay(a)
+	want := map[string]string{"region": "us-west", "rack": "r12", "ssd": "true"}
+	if got := a.Machine.State().Metadata; !reflect.DeepEqual(want, got) {
+		t.Errorf("expected metadata %v, got %v", want, got)
+	}
+
+	reg.DeleteMachineMetadata("this_machine", "region")
+	ar.refreshMetadataOverlay(a)
+	want = map[string]string{"region": "us-east", "rack": "r12", "ssd": "true"}
+	if got := a.Machine.State().Metadata; !reflect.DeepEqual(want, got) {
+		t.Errorf("expected metadata %v, got %v", want, got)
+	}
+}
+
 func TestAbleToRun(t *testing.T) {
 	tests := []struct {
 		dState *AgentState
@@ -319,6 +516,27 @@ func TestAbleToRun(t *testing.T) {
 			want:   false,
 		},
 
+		// match negated MachineMetadata
+		{
+			dState: NewAgentState(&machine.MachineState{ID: "123", Metadata: map[string]string{"role": "web"}}),
+			job:    newTestJobWithXFleetValues(t, "MachineMetadata=role!=db"),
+			want:   true,
+		},
+
+		// mismatch negated MachineMetadata
+		{
+			dState: NewAgentState(&machine.MachineState{ID: "123", Metadata: map[string]string{"role": "db"}}),
+			job:    newTestJobWithXFleetValues(t, "MachineMetadata=role!=db"),
+			want:   false,
+		},
+
+		// mismatch numeric MachineMetadata
+		{
+			dState: NewAgentState(&machine.MachineState{ID: "123", Metadata: map[string]string{"disk_gb": "250"}}),
+			job:    newTestJobWithXFleetValues(t, "MachineMetadata=disk_gb>=500"),
+			want:   false,
+		},
+
 		// peer scheduled locally
 		{
 			dState: &AgentState{
@@ -21,11 +21,16 @@ import (
 	"github.com/nickswift/fleet/job"
 	"github.com/nickswift/fleet/log"
 	"github.com/nickswift/fleet/machine"
+	"github.com/nickswift/fleet/resource"
 )
 
 type AgentState struct {
 	MState *machine.MachineState
 	Units  map[string]*job.Unit
+
+	// global units the local machine is not yet admitted to run by
+	// their rollout; whatever version of them is loaded is left alone
+	deferred map[string]bool
 }
 
 func NewAgentState(ms *machine.MachineState) *AgentState {
@@ -116,26 +121,104 @@ func globMatches(pattern, target string) bool {
 	return matched
 }
 
+// AvailableResources returns the resources of the Agent's machine which are
+// neither reserved for the host nor by any of the Units scheduled to the
+// Agent. The Unit identified by the given name is not taken into account,
+// which allows an already-scheduled Unit to be checked against the Agent.
+func (as *AgentState) AvailableResources(ignore string) resource.ResourceTuple {
+	reserved := []resource.ResourceTuple{resource.HostResources}
+	for _, u := range as.Units {
+		if u.Name == ignore {
+			continue
+		}
+		reserved = append(reserved, u.Resources())
+	}
+	var total resource.ResourceTuple
+	if as.MState.TotalResources != nil {
+		total = *as.MState.TotalResources
+	}
+	return resource.Sub(total, resource.Sum(reserved...))
+}
+
+// hasResources determines whether the Agent has enough free capacity to run
+// the given Job. Machines which do not advertise their capacity are assumed
+// to be able to run anything.
+func (as *AgentState) hasResources(j *job.Job) (bool, resource.ResourceTuple, resource.ResourceTuple) {
+	want := j.Resources()
+	if want.Empty() || as.MState.TotalResources == nil || as.MState.TotalResources.Empty() {
+		return true, want, resource.ResourceTuple{}
+	}
+
+	available := as.AvailableResources(j.Name)
+	return want.Fits(available), want, available
+}
+
+// PreferenceScore returns the sum of the weights of the Job's soft scheduling
+// requirements which the Agent satisfies. The Job itself is ignored when
+// looking for collocated units.
+func (as *AgentState) PreferenceScore(j *job.Job) int {
+	score := 0
+	for _, pref := range j.MetadataPreferences() {
+		if pref.Requirement.Matches(as.MState.Metadata) {
+			score += pref.Weight
+		}
+	}
+
+	for _, pref := range j.CollocationPreferences() {
+		collocated := false
+		for name := range as.Units {
+			if name != j.Name && globMatches(pref.Pattern, name) {
+				collocated = true
+				break
+			}
+		}
+		if !collocated {
+			score += pref.Weight
+		}
+	}
+
+	return score
+}
+
 // AbleToRun determines if an Agent can run the provided Job based on
 // the Agent's current state. A boolean indicating whether this is the
 // case or not is returned. The following criteria is used:
 //   - Agent must meet the Job's machine target requirement (if any)
 //   - Agent must have all of the Job's required metadata (if any)
+//   - Job must tolerate all of the Agent's taints (if any), unless it is
+//     already scheduled to the Agent
 //   - Agent must have all required Peers of the Job scheduled locally (if any)
 //   - Job must not conflict with any other Units scheduled to the agent
 //   - Job must specially handle replaced units to be rescheduled
+//   - Agent must have enough free resources for the Job (if any are required)
 func (as *AgentState) AbleToRun(j *job.Job) (bool, string) {
+	// A cordoned Machine keeps the units already scheduled to it, but
+	// does not accept new ones.
+	if as.MState.Cordoned && j.TargetMachineID != as.MState.ID {
+		return false, "local Machine is cordoned"
+	}
+
+	// Units which failed here before and were moved away do not return
+	if j.FailedOn(as.MState.ID) && j.TargetMachineID != as.MState.ID {
+		return false, "Unit previously failed on local Machine"
+	}
+
 	if tgt, ok := j.RequiredTarget(); ok && !as.MState.MatchID(tgt) {
 		return false, fmt.Sprintf("agent ID %q does not match required %q", as.MState.ID, tgt)
 	}
 
-	metadata := j.RequiredTargetMetadata()
-	if len(metadata) != 0 {
-		if !machine.HasMetadata(as.MState, metadata) {
-			return false, "local Machine metadata insufficient"
+	for _, req := range j.MetadataRequirements() {
+		if !req.Matches(as.MState.Metadata) {
+			return false, fmt.Sprintf("local Machine metadata insufficient: requirement %s not satisfied", req)
 		}
 	}
 
+	// Like a cordon, a taint only repels new units; those already
+	// scheduled to the Machine stay until they are drained.
+	if taint, found := machine.UntoleratedTaint(as.MState, j.Tolerations()); found && j.TargetMachineID != as.MState.ID {
+		return false, fmt.Sprintf("local Machine taint %s not tolerated", taint)
+	}
+
 	peers := j.Peers()
 	if len(peers) != 0 {
 		for _, peer := range peers {
@@ -155,5 +238,9 @@ func (as *AgentState) AbleToRun(j *job.Job) (bool, string) {
 		return false, job.JobReschedule
 	}
 
+	if ok, want, available := as.hasResources(j); !ok {
+		return false, fmt.Sprintf("local Machine resources insufficient: want %s, available %s", want, available)
+	}
+
 	return true, ""
 }
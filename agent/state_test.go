@@ -20,6 +20,7 @@ import (
 
 	"github.com/nickswift/fleet/job"
 	"github.com/nickswift/fleet/machine"
+	"github.com/nickswift/fleet/resource"
 	"github.com/nickswift/fleet/unit"
 )
 
@@ -175,6 +176,136 @@ func TestHasReplaces(t *testing.T) {
 	}
 }
 
+func TestAbleToRunResources(t *testing.T) {
+	capacity := &resource.ResourceTuple{Cores: 400, Memory: 4096, Disk: 10240}
+
+	tests := []struct {
+		cState *AgentState
+		job    *job.Job
+		want   bool
+	}{
+		// machine without advertised capacity runs anything
+		{
+			cState: NewAgentState(&machine.MachineState{ID: "XXX"}),
+			job:    &job.Job{Name: "foo.service", Unit: fleetUnit(t, "Memory=1T")},
+			want:   true,
+		},
+
+		// job without resource requirements always fits
+		{
+			cState: NewAgentState(&machine.MachineState{ID: "XXX", TotalResources: capacity}),
+			job:    &job.Job{Name: "foo.service", Unit: fleetUnit(t)},
+			want:   true,
+		},
+
+		// job fits on an empty machine
+		{
+			cState: NewAgentState(&machine.MachineState{ID: "XXX", TotalResources: capacity}),
+			job:    &job.Job{Name: "foo.service", Unit: fleetUnit(t, "Cores=2", "Memory=2048")},
+			want:   true,
+		},
+
+		// host reservation is taken into account
+		{
+			cState: NewAgentState(&machine.MachineState{ID: "XXX", TotalResources: capacity}),
+			job:    &job.Job{Name: "foo.service", Unit: fleetUnit(t, "Memory=4096")},
+			want:   false,
+		},
+
+		// units already scheduled to the machine consume resources
+		{
+			cState: &AgentState{
+				MState: &machine.MachineState{ID: "XXX", TotalResources: capacity},
+				Units: map[string]*job.Unit{
+					"bar.service": &job.Unit{
+						Name: "bar.service",
+						Unit: fleetUnit(t, "Memory=3G"),
+					},
+				},
+			},
+			job:  &job.Job{Name: "foo.service", Unit: fleetUnit(t, "Memory=1024")},
+			want: false,
+		},
+
+		// a job does not compete with itself for resources
+		{
+			cState: &AgentState{
+				MState: &machine.MachineState{ID: "XXX", TotalResources: capacity},
+				Units: map[string]*job.Unit{
+					"foo.service": &job.Unit{
+						Name: "foo.service",
+						Unit: fleetUnit(t, "Memory=3G"),
+					},
+				},
+			},
+			job:  &job.Job{Name: "foo.service", Unit: fleetUnit(t, "Memory=3G")},
+			want: true,
+		},
+	}
+
+	for i, tt := range tests {
+		got, reason := tt.cState.AbleToRun(tt.job)
+		if got != tt.want {
+			t.Errorf("case %d: expected %t, got %t (reason %q)", i, tt.want, got, reason)
+		}
+	}
+}
+
+func TestPreferenceScore(t *testing.T) {
+	tests := []struct {
+		cState *AgentState
+		job    *job.Job
+		want   int
+	}{
+		// no preferences
+		{
+			cState: NewAgentState(&machine.MachineState{ID: "XXX"}),
+			job:    &job.Job{Name: "foo.service", Unit: fleetUnit(t)},
+			want:   0,
+		},
+		// metadata preferences are summed up
+		{
+			cState: NewAgentState(&machine.MachineState{ID: "XXX", Metadata: map[string]string{"ssd": "true", "zone": "a"}}),
+			job:    &job.Job{Name: "foo.service", Unit: fleetUnit(t, "PreferMachineMetadata=ssd=true:50", "PreferMachineMetadata=zone=a:5", "PreferMachineMetadata=zone=b:7")},
+			want:   55,
+		},
+		// collocation preference satisfied on an empty machine
+		{
+			cState: NewAgentState(&machine.MachineState{ID: "XXX"}),
+			job:    &job.Job{Name: "foo.service", Unit: fleetUnit(t, "PreferNotCollocatedWith=cache@*:20")},
+			want:   20,
+		},
+		// collocation preference not satisfied
+		{
+			cState: &AgentState{
+				MState: &machine.MachineState{ID: "XXX"},
+				Units: map[string]*job.Unit{
+					"cache@1.service": &job.Unit{Name: "cache@1.service"},
+				},
+			},
+			job:  &job.Job{Name: "foo.service", Unit: fleetUnit(t, "PreferNotCollocatedWith=cache@*:20")},
+			want: 0,
+		},
+		// a job is not collocated with itself
+		{
+			cState: &AgentState{
+				MState: &machine.MachineState{ID: "XXX"},
+				Units: map[string]*job.Unit{
+					"cache@1.service": &job.Unit{Name: "cache@1.service"},
+				},
+			},
+			job:  &job.Job{Name: "cache@1.service", Unit: fleetUnit(t, "PreferNotCollocatedWith=cache@*:20")},
+			want: 20,
+		},
+	}
+
+	for i, tt := range tests {
+		if got := tt.cState.PreferenceScore(tt.job); got != tt.want {
+			t.Errorf("case %d: expected score %d, got %d", i, tt.want, got)
+		}
+	}
+}
+
 func TestGlobMatches(t *testing.T) {
 	tests := []struct {
 		pattern  string
@@ -198,3 +329,81 @@ func TestGlobMatches(t *testing.T) {
 		}
 	}
 }
+
+func TestAbleToRunCordoned(t *testing.T) {
+	tests := []struct {
+		cState *AgentState
+		job    *job.Job
+		want   bool
+	}{
+		// schedulable machine accepts new units
+		{
+			cState: NewAgentState(&machine.MachineState{ID: "XXX"}),
+			job:    &job.Job{Name: "foo.service", Unit: fleetUnit(t)},
+			want:   true,
+		},
+
+		// cordoned machine refuses new units
+		{
+			cState: NewAgentState(&machine.MachineState{ID: "XXX", Cordoned: true}),
+			job:    &job.Job{Name: "foo.service", Unit: fleetUnit(t)},
+			want:   false,
+		},
+
+		// cordoned machine refuses units scheduled elsewhere
+		{
+			cState: NewAgentState(&machine.MachineState{ID: "XXX", Cordoned: true}),
+			job:    &job.Job{Name: "foo.service", Unit: fleetUnit(t), TargetMachineID: "YYY"},
+			want:   false,
+		},
+
+		// cordoned machine keeps units already scheduled to it
+		{
+			cState: NewAgentState(&machine.MachineState{ID: "XXX", Cordoned: true}),
+			job:    &job.Job{Name: "foo.service", Unit: fleetUnit(t), TargetMachineID: "XXX"},
+			want:   true,
+		},
+
+		// tainted machine repels units without tolerations
+		{
+			cState: NewAgentState(&machine.MachineState{ID: "XXX", Taints: map[string]string{"dedicated": "db"}}),
+			job:    &job.Job{Name: "foo.service", Unit: fleetUnit(t), TargetMachineID: "YYY"},
+			want:   false,
+		},
+
+		// tainted machine keeps units already scheduled to it
+		{
+			cState: NewAgentState(&machine.MachineState{ID: "XXX", Taints: map[string]string{"dedicated": "db"}}),
+			job:    &job.Job{Name: "foo.service", Unit: fleetUnit(t), TargetMachineID: "XXX"},
+			want:   true,
+		},
+
+		// tolerating the taint by key and value
+		{
+			cState: NewAgentState(&machine.MachineState{ID: "XXX", Taints: map[string]string{"dedicated": "db"}}),
+			job:    &job.Job{Name: "foo.service", Unit: fleetUnit(t, "Tolerates=dedicated=db")},
+			want:   true,
+		},
+
+		// tolerating another value of the taint is not enough
+		{
+			cState: NewAgentState(&machine.MachineState{ID: "XXX", Taints: map[string]string{"dedicated": "db"}}),
+			job:    &job.Job{Name: "foo.service", Unit: fleetUnit(t, "Tolerates=dedicated=web")},
+			want:   false,
+		},
+
+		// every taint must be tolerated, here by key
+		{
+			cState: NewAgentState(&machine.MachineState{ID: "XXX", Taints: map[string]string{"dedicated": "db", "gpu": ""}}),
+			job:    &job.Job{Name: "foo.service", Unit: fleetUnit(t, "Tolerates=dedicated gpu")},
+			want:   true,
+		},
+	}
+
+	for i, tt := range tests {
+		got, _ := tt.cState.AbleToRun(tt.job)
+		if got != tt.want {
+			t.Errorf("case %d: expected %t, got %t", i, tt.want, got)
+		}
+	}
+}
@@ -15,9 +15,12 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"path"
+	"strings"
 
 	"github.com/nickswift/fleet/client"
 	"github.com/nickswift/fleet/log"
@@ -27,16 +30,191 @@ import (
 
 func wireUpMachinesResource(mux *http.ServeMux, prefix string, tokenLimit int, cAPI client.API) {
 	res := path.Join(prefix, "machines")
-	mr := machinesResource{cAPI, uint16(tokenLimit)}
+	mr := machinesResource{cAPI, res, uint16(tokenLimit)}
 	mux.Handle(res, &mr)
+	mux.Handle(res+"/", &mr)
 }
 
 type machinesResource struct {
 	cAPI       client.API
+	basePath   string
 	tokenLimit uint16
 }
 
 func (mr *machinesResource) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if isCollectionPath(mr.basePath, req.URL.Path) {
+		mr.list(rw, req)
+	} else if item, ok := isCordonPath(mr.basePath, req.URL.Path); ok {
+		switch req.Method {
+		case "PUT":
+			mr.cordon(rw, item, true)
+		case "DELETE":
+			mr.cordon(rw, item, false)
+		default:
+			sendError(rw, http.StatusMethodNotAllowed, errors.New("only PUT and DELETE supported against this resource"))
+		}
+	} else if item, ok := isTaintsPath(mr.basePath, req.URL.Path); ok {
+		switch req.Method {
+		case "PUT":
+			mr.setTaints(rw, req, item)
+		default:
+			sendError(rw, http.StatusMethodNotAllowed, errors.New("only PUT supported against this resource"))
+		}
+	} else if item, key, ok := isMetadataPath(mr.basePath, req.URL.Path); ok {
+		switch req.Method {
+		case "PUT":
+			mr.setMetadata(rw, req, item, key)
+		case "DELETE":
+			mr.deleteMetadata(rw, item, key)
+		default:
+			sendError(rw, http.StatusMethodNotAllowed, errors.New("only PUT and DELETE supported against this resource"))
+		}
+	} else {
+		sendError(rw, http.StatusNotFound, nil)
+	}
+}
+
+// isTaintsPath determines whether the given path refers to the taints of a
+// single Machine, i.e. <base>/<machine>/taints.
+func isTaintsPath(base, p string) (item string, matched bool) {
+	if path.Base(p) != "taints" {
+		return
+	}
+	return isItemPath(base, path.Dir(p))
+}
+
+// isMetadataPath determines whether the given path refers to a single
+// metadata value of a single Machine, i.e. <base>/<machine>/metadata/<key>.
+func isMetadataPath(base, p string) (item, key string, matched bool) {
+	if strings.HasSuffix(p, "/") || path.Base(path.Dir(p)) != "metadata" {
+		return
+	}
+	item, matched = isItemPath(base, path.Dir(path.Dir(p)))
+	if matched {
+		key = path.Base(p)
+	}
+	return
+}
+
+// isCordonPath determines whether the given path refers to the cordon of a
+// single Machine, i.e. <base>/<machine>/cordon.
+func isCordonPath(base, p string) (item string, matched bool) {
+	if path.Base(p) != "cordon" {
+		return
+	}
+	return isItemPath(base, path.Dir(p))
+}
+
+// machineExists determines whether the given Machine is known, sending an
+// error response if it is not or the Machines cannot be fetched.
+func (mr *machinesResource) machineExists(rw http.ResponseWriter, machID string) bool {
+	machines, err := mr.cAPI.Machines()
+	if err != nil {
+		log.Errorf("Failed fetching Machines: %v", err)
+		sendError(rw, http.StatusInternalServerError, nil)
+		return false
+	}
+
+	for _, ms := range machines {
+		if ms.ID == machID {
+			return true
+		}
+	}
+	sendError(rw, http.StatusNotFound, errors.New("machine does not exist"))
+	return false
+}
+
+func (mr *machinesResource) cordon(rw http.ResponseWriter, machID string, cordon bool) {
+	if !mr.machineExists(rw, machID) {
+		return
+	}
+
+	var err error
+	if cordon {
+		err = mr.cAPI.CordonMachine(machID)
+	} else {
+		err = mr.cAPI.UncordonMachine(machID)
+	}
+	if err != nil {
+		log.Errorf("Failed changing cordon of Machine(%s): %v", machID, err)
+		sendError(rw, http.StatusInternalServerError, nil)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (mr *machinesResource) setTaints(rw http.ResponseWriter, req *http.Request, machID string) {
+	if err := validateContentType(req); err != nil {
+		sendError(rw, http.StatusUnsupportedMediaType, err)
+		return
+	}
+
+	var mt schema.MachineTaints
+	if err := json.NewDecoder(req.Body).Decode(&mt); err != nil {
+		sendError(rw, http.StatusBadRequest, fmt.Errorf("unable to decode body: %v", err))
+		return
+	}
+	for key := range mt.Taints {
+		if key == "" {
+			sendError(rw, http.StatusBadRequest, errors.New("taint keys must not be empty"))
+			return
+		}
+	}
+
+	if !mr.machineExists(rw, machID) {
+		return
+	}
+
+	if err := mr.cAPI.SetMachineTaints(machID, mt.Taints); err != nil {
+		log.Errorf("Failed setting taints of Machine(%s): %v", machID, err)
+		sendError(rw, http.StatusInternalServerError, nil)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (mr *machinesResource) setMetadata(rw http.ResponseWriter, req *http.Request, machID, key string) {
+	if err := validateContentType(req); err != nil {
+		sendError(rw, http.StatusUnsupportedMediaType, err)
+		return
+	}
+
+	var mv schema.MachineMetadataValue
+	if err := json.NewDecoder(req.Body).Decode(&mv); err != nil {
+		sendError(rw, http.StatusBadRequest, fmt.Errorf("unable to decode body: %v", err))
+		return
+	}
+
+	if !mr.machineExists(rw, machID) {
+		return
+	}
+
+	if err := mr.cAPI.SetMachineMetadata(machID, key, mv.Value); err != nil {
+		log.Errorf("Failed setting metadata %q of Machine(%s): %v", key, machID, err)
+		sendError(rw, http.StatusInternalServerError, nil)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (mr *machinesResource) deleteMetadata(rw http.ResponseWriter, machID, key string) {
+	if !mr.machineExists(rw, machID) {
+		return
+	}
+
+	if err := mr.cAPI.DeleteMachineMetadata(machID, key); err != nil {
+		log.Errorf("Failed deleting metadata %q of Machine(%s): %v", key, machID, err)
+		sendError(rw, http.StatusInternalServerError, nil)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (mr *machinesResource) list(rw http.ResponseWriter, req *http.Request) {
 	if req.Method != "GET" {
 		sendError(rw, http.StatusBadRequest, fmt.Errorf("only HTTP GET supported against this resource"))
 		return
@@ -19,6 +19,7 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/nickswift/fleet/client"
@@ -66,7 +67,7 @@ func TestMachinesList(t *testing.T) {
 func TestMachinesListBadNextPageToken(t *testing.T) {
 	fr := registry.NewFakeRegistry()
 	fAPI := &client.RegistryClient{Registry: fr}
-	resource := &machinesResource{fAPI, testTokenLimit}
+	resource := &machinesResource{fAPI, "/machines", testTokenLimit}
 	rw := httptest.NewRecorder()
 	req, err := http.NewRequest("GET", "http://example.com/machines?nextPageToken=EwBMLg==", nil)
 	if err != nil {
@@ -136,3 +137,132 @@ func TestExtractMachinePage(t *testing.T) {
 		}
 	}
 }
+
+func TestMachinesCordon(t *testing.T) {
+	fr := registry.NewFakeRegistry()
+	fr.SetMachines([]machine.MachineState{{ID: "XXX"}, {ID: "YYY"}})
+	fAPI := &client.RegistryClient{Registry: fr}
+	resource := &machinesResource{fAPI, "/machines", testTokenLimit}
+
+	tests := []struct {
+		method   string
+		path     string
+		code     int
+		cordoned []string
+	}{
+		{"PUT", "/machines/XXX/cordon", http.StatusNoContent, []string{"XXX"}},
+		{"PUT", "/machines/YYY/cordon", http.StatusNoContent, []string{"XXX", "YYY"}},
+		{"DELETE", "/machines/XXX/cordon", http.StatusNoContent, []string{"YYY"}},
+		{"PUT", "/machines/ZZZ/cordon", http.StatusNotFound, []string{"YYY"}},
+		{"GET", "/machines/XXX/cordon", http.StatusMethodNotAllowed, []string{"YYY"}},
+		{"PUT", "/machines/XXX", http.StatusNotFound, []string{"YYY"}},
+	}
+
+	for i, tt := range tests {
+		rw := httptest.NewRecorder()
+		req, err := http.NewRequest(tt.method, "http://example.com"+tt.path, nil)
+		if err != nil {
+			t.Fatalf("Failed creating http.Request: %v", err)
+		}
+
+		resource.ServeHTTP(rw, req)
+		if rw.Code != tt.code {
+			t.Errorf("case %d: expected %d, got %d", i, tt.code, rw.Code)
+		}
+
+		machines, _ := fr.Machines()
+		var cordoned []string
+		for _, ms := range machines {
+			if ms.Cordoned {
+				cordoned = append(cordoned, ms.ID)
+			}
+		}
+		if !reflect.DeepEqual(tt.cordoned, cordoned) {
+			t.Errorf("case %d: expected cordoned Machines %v, got %v", i, tt.cordoned, cordoned)
+		}
+	}
+}
+
+func TestMachinesSetTaints(t *testing.T) {
+	fr := registry.NewFakeRegistry()
+	fr.SetMachines([]machine.MachineState{{ID: "XXX", Taints: map[string]string{"gpu": ""}}, {ID: "YYY"}})
+	fAPI := &client.RegistryClient{Registry: fr}
+	resource := &machinesResource{fAPI, "/machines", testTokenLimit}
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		code   int
+		taints map[string]string
+	}{
+		{"PUT", "/machines/XXX/taints", `{"taints":{"dedicated":"db"}}`, http.StatusNoContent, map[string]string{"dedicated": "db", "gpu": ""}},
+		{"PUT", "/machines/XXX/taints", `{"taints":{"gpu":"big"}}`, http.StatusNoContent, map[string]string{"gpu": "big"}},
+		{"PUT", "/machines/XXX/taints", `{"taints":{"":"db"}}`, http.StatusBadRequest, map[string]string{"gpu": "big"}},
+		{"PUT", "/machines/XXX/taints", `{}`, http.StatusNoContent, map[string]string{"gpu": ""}},
+		{"PUT", "/machines/ZZZ/taints", `{}`, http.StatusNotFound, map[string]string{"gpu": ""}},
+		{"GET", "/machines/XXX/taints", ``, http.StatusMethodNotAllowed, map[string]string{"gpu": ""}},
+	}
+
+	for i, tt := range tests {
+		rw := httptest.NewRecorder()
+		req, err := http.NewRequest(tt.method, "http://example.com"+tt.path, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatalf("Failed creating http.Request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resource.ServeHTTP(rw, req)
+		if rw.Code != tt.code {
+			t.Errorf("case %d: expected %d, got %d", i, tt.code, rw.Code)
+		}
+
+		machines, _ := fr.Machines()
+		if !reflect.DeepEqual(tt.taints, machines[0].Taints) {
+			t.Errorf("case %d: expected taints %v, got %v", i, tt.taints, machines[0].Taints)
+		}
+	}
+}
+
+func TestMachinesMetadata(t *testing.T) {
+	fr := registry.NewFakeRegistry()
+	fr.SetMachines([]machine.MachineState{{ID: "XXX", Metadata: map[string]string{"region": "us-east"}}, {ID: "YYY"}})
+	fAPI := &client.RegistryClient{Registry: fr}
+	resource := &machinesResource{fAPI, "/machines", testTokenLimit}
+
+	tests := []struct {
+		method   string
+		path     string
+		body     string
+		code     int
+		metadata map[string]string
+	}{
+		{"PUT", "/machines/XXX/metadata/rack", `{"value":"r12"}`, http.StatusNoContent, map[string]string{"region": "us-east", "rack": "r12"}},
+		{"PUT", "/machines/XXX/metadata/region", `{"value":"us-west"}`, http.StatusNoContent, map[string]string{"region": "us-west", "rack": "r12"}},
+		{"PUT", "/machines/XXX/metadata/region", `{"value":`, http.StatusBadRequest, map[string]string{"region": "us-west", "rack": "r12"}},
+		{"PUT", "/machines/ZZZ/metadata/region", `{"value":"eu"}`, http.StatusNotFound, map[string]string{"region": "us-west", "rack": "r12"}},
+		{"DELETE", "/machines/XXX/metadata/region", ``, http.StatusNoContent, map[string]string{"region": "us-east", "rack": "r12"}},
+		{"DELETE", "/machines/XXX/metadata/rack", ``, http.StatusNoContent, map[string]string{"region": "us-east"}},
+		{"GET", "/machines/XXX/metadata/rack", ``, http.StatusMethodNotAllowed, map[string]string{"region": "us-east"}},
+		{"PUT", "/machines/XXX/metadata/", `{"value":"x"}`, http.StatusNotFound, map[string]string{"region": "us-east"}},
+	}
+
+	for i, tt := range tests {
+		rw := httptest.NewRecorder()
+		req, err := http.NewRequest(tt.method, "http://example.com"+tt.path, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatalf("Failed creating http.Request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resource.ServeHTTP(rw, req)
+		if rw.Code != tt.code {
+			t.Errorf("case %d: expected %d, got %d", i, tt.code, rw.Code)
+		}
+
+		machines, _ := fr.Machines()
+		if !reflect.DeepEqual(tt.metadata, machines[0].Metadata) {
+			t.Errorf("case %d: expected metadata %v, got %v", i, tt.metadata, machines[0].Metadata)
+		}
+	}
+}
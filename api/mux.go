@@ -18,6 +18,7 @@ import (
 	"net/http"
 
 	"github.com/nickswift/fleet/client"
+	"github.com/nickswift/fleet/engine"
 	"github.com/nickswift/fleet/log"
 	"github.com/nickswift/fleet/registry"
 	"github.com/nickswift/fleet/version"
@@ -27,12 +28,13 @@ import (
 
 func NewServeMux(reg registry.Registry, tokenLimit int) http.Handler {
 	sm := http.NewServeMux()
-	cAPI := &client.RegistryClient{Registry: reg}
+	cAPI := &client.RegistryClient{Registry: reg, Explainer: engine.Explainer{}, RolloutReporter: engine.RolloutReporter{}}
 
 	for _, prefix := range []string{"/v1-alpha", "/fleet/v1"} {
 		wireUpDiscoveryResource(sm, prefix)
 
 		wireUpMachinesResource(sm, prefix, tokenLimit, cAPI)
+		wireUpReplicaSetsResource(sm, prefix, tokenLimit, cAPI)
 		wireUpStateResource(sm, prefix, tokenLimit, cAPI)
 		wireUpUnitsResource(sm, prefix, tokenLimit, cAPI)
 		sm.HandleFunc(prefix, methodNotAllowedHandler)
@@ -0,0 +1,208 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/nickswift/fleet/client"
+	"github.com/nickswift/fleet/log"
+	"github.com/nickswift/fleet/schema"
+	"github.com/nickswift/fleet/unit"
+)
+
+func wireUpReplicaSetsResource(mux *http.ServeMux, prefix string, tokenLimit int, cAPI client.API) {
+	base := path.Join(prefix, "replica-sets")
+	rr := replicaSetsResource{cAPI, base, uint16(tokenLimit)}
+	mux.Handle(base, &rr)
+	mux.Handle(base+"/", &rr)
+}
+
+type replicaSetsResource struct {
+	cAPI       client.API
+	basePath   string
+	tokenLimit uint16
+}
+
+func (rr *replicaSetsResource) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if isCollectionPath(rr.basePath, req.URL.Path) {
+		switch req.Method {
+		case "GET":
+			rr.list(rw, req)
+		default:
+			sendError(rw, http.StatusMethodNotAllowed, errors.New("only GET supported against this resource"))
+		}
+	} else if item, ok := isItemPath(rr.basePath, req.URL.Path); ok {
+		switch req.Method {
+		case "GET":
+			rr.get(rw, req, item)
+		case "DELETE":
+			rr.destroy(rw, req, item)
+		case "PUT":
+			rr.set(rw, req, item)
+		default:
+			sendError(rw, http.StatusMethodNotAllowed, errors.New("only GET, PUT and DELETE supported against this resource"))
+		}
+	} else {
+		sendError(rw, http.StatusNotFound, nil)
+	}
+}
+
+// ValidateReplicaSet ensures that a ReplicaSet refers to a valid template
+// Unit and requests a non-negative number of replicas.
+func ValidateReplicaSet(rs *schema.ReplicaSet) error {
+	if err := ValidateName(rs.Template); err != nil {
+		return err
+	}
+	if !unit.NewUnitNameInfo(rs.Template).IsTemplate() {
+		return fmt.Errorf("unit %q is not a template", rs.Template)
+	}
+	if rs.Replicas < 0 {
+		return fmt.Errorf("replica count cannot be negative: %d", rs.Replicas)
+	}
+	return nil
+}
+
+func (rr *replicaSetsResource) set(rw http.ResponseWriter, req *http.Request, item string) {
+	if err := validateContentType(req); err != nil {
+		sendError(rw, http.StatusUnsupportedMediaType, err)
+		return
+	}
+
+	var srs schema.ReplicaSet
+	dec := json.NewDecoder(req.Body)
+	if err := dec.Decode(&srs); err != nil {
+		sendError(rw, http.StatusBadRequest, fmt.Errorf("unable to decode body: %v", err))
+		return
+	}
+	if srs.Template == "" {
+		srs.Template = item
+	}
+	if item != srs.Template {
+		sendError(rw, http.StatusBadRequest, fmt.Errorf("name in URL %q differs from template name in request body %q", item, srs.Template))
+		return
+	}
+	if err := ValidateReplicaSet(&srs); err != nil {
+		sendError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	u, err := rr.cAPI.Unit(srs.Template)
+	if err != nil {
+		log.Errorf("Failed fetching Unit(%s) from Registry: %v", srs.Template, err)
+		sendError(rw, http.StatusInternalServerError, nil)
+		return
+	}
+	if u == nil {
+		sendError(rw, http.StatusConflict, errors.New("template unit does not exist"))
+		return
+	}
+
+	if err := rr.cAPI.SetReplicaSet(&srs); err != nil {
+		log.Errorf("Failed setting ReplicaSet(%s): %v", srs.Template, err)
+		sendError(rw, http.StatusInternalServerError, nil)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (rr *replicaSetsResource) destroy(rw http.ResponseWriter, req *http.Request, item string) {
+	rs, err := rr.cAPI.ReplicaSet(item)
+	if err != nil {
+		log.Errorf("Failed fetching ReplicaSet(%s): %v", item, err)
+		sendError(rw, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if rs == nil {
+		sendError(rw, http.StatusNotFound, errors.New("replica set does not exist"))
+		return
+	}
+
+	if err := rr.cAPI.DestroyReplicaSet(item); err != nil {
+		log.Errorf("Failed destroying ReplicaSet(%s): %v", item, err)
+		sendError(rw, http.StatusInternalServerError, nil)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (rr *replicaSetsResource) get(rw http.ResponseWriter, req *http.Request, item string) {
+	rs, err := rr.cAPI.ReplicaSet(item)
+	if err != nil {
+		log.Errorf("Failed fetching ReplicaSet(%s) from Registry: %v", item, err)
+		sendError(rw, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if rs == nil {
+		sendError(rw, http.StatusNotFound, errors.New("replica set does not exist"))
+		return
+	}
+
+	sendResponse(rw, http.StatusOK, *rs)
+}
+
+func (rr *replicaSetsResource) list(rw http.ResponseWriter, req *http.Request) {
+	token, err := findNextPageToken(req.URL, rr.tokenLimit)
+	if err != nil {
+		sendError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	if token == nil {
+		def := DefaultPageToken(rr.tokenLimit)
+		token = &def
+	}
+
+	page, err := getReplicaSetPage(rr.cAPI, *token)
+	if err != nil {
+		log.Errorf("Failed fetching page of ReplicaSets: %v", err)
+		sendError(rw, http.StatusInternalServerError, nil)
+		return
+	}
+
+	sendResponse(rw, http.StatusOK, page)
+}
+
+func getReplicaSetPage(cAPI client.API, tok PageToken) (*schema.ReplicaSetPage, error) {
+	all, err := cAPI.ReplicaSets()
+	if err != nil {
+		return nil, err
+	}
+
+	total := len(all)
+	startIndex := int((tok.Page - 1) * tok.Limit)
+	stopIndex := int(tok.Page * tok.Limit)
+
+	page := schema.ReplicaSetPage{}
+	if startIndex < total {
+		if stopIndex > total {
+			stopIndex = total
+		} else {
+			page.NextPageToken = tok.Next().Encode()
+		}
+
+		page.ReplicaSets = all[startIndex:stopIndex]
+	}
+
+	return &page, nil
+}
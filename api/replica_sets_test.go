@@ -0,0 +1,154 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nickswift/fleet/client"
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/registry"
+	"github.com/nickswift/fleet/schema"
+)
+
+func TestReplicaSetsList(t *testing.T) {
+	fr := registry.NewFakeRegistry()
+	fr.SetReplicaSet(job.ReplicaSet{Template: "b@.service", Replicas: 2})
+	fr.SetReplicaSet(job.ReplicaSet{Template: "a@.service", Replicas: 1})
+	fAPI := &client.RegistryClient{Registry: fr}
+	resource := &replicaSetsResource{fAPI, "/replica-sets", testTokenLimit}
+	rw := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "http://example.com/replica-sets", nil)
+	if err != nil {
+		t.Fatalf("Failed creating http.Request: %v", err)
+	}
+
+	resource.ServeHTTP(rw, req)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rw.Code)
+	}
+
+	var page schema.ReplicaSetPage
+	if err := json.Unmarshal(rw.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Received unparseable body: %v", err)
+	}
+
+	if len(page.ReplicaSets) != 2 || page.ReplicaSets[0].Template != "a@.service" || page.ReplicaSets[1].Replicas != 2 {
+		t.Errorf("Received incorrect ReplicaSetPage entity: %v", page)
+	}
+}
+
+func TestReplicaSetsGet(t *testing.T) {
+	fr := registry.NewFakeRegistry()
+	fr.SetReplicaSet(job.ReplicaSet{Template: "a@.service", Replicas: 3})
+	fAPI := &client.RegistryClient{Registry: fr}
+	resource := &replicaSetsResource{fAPI, "/replica-sets", testTokenLimit}
+
+	tests := []struct {
+		item string
+		code int
+	}{
+		{"a@.service", http.StatusOK},
+		{"b@.service", http.StatusNotFound},
+	}
+
+	for i, tt := range tests {
+		rw := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "http://example.com/replica-sets/"+tt.item, nil)
+		if err != nil {
+			t.Fatalf("Failed creating http.Request: %v", err)
+		}
+
+		resource.ServeHTTP(rw, req)
+		if rw.Code != tt.code {
+			t.Errorf("case %d: expected %d, got %d", i, tt.code, rw.Code)
+		}
+	}
+}
+
+func TestReplicaSetsSet(t *testing.T) {
+	tests := []struct {
+		item string
+		body schema.ReplicaSet
+		code int
+	}{
+		{"a@.service", schema.ReplicaSet{Replicas: 3}, http.StatusNoContent},
+		{"a@.service", schema.ReplicaSet{Template: "a@.service", Replicas: 0}, http.StatusNoContent},
+		// template name mismatch
+		{"a@.service", schema.ReplicaSet{Template: "b@.service", Replicas: 3}, http.StatusBadRequest},
+		// negative replica count
+		{"a@.service", schema.ReplicaSet{Replicas: -1}, http.StatusBadRequest},
+		// not a template
+		{"a.service", schema.ReplicaSet{Replicas: 1}, http.StatusBadRequest},
+		{"a@1.service", schema.ReplicaSet{Replicas: 1}, http.StatusBadRequest},
+		// template not submitted
+		{"b@.service", schema.ReplicaSet{Replicas: 1}, http.StatusConflict},
+	}
+
+	for i, tt := range tests {
+		fr := registry.NewFakeRegistry()
+		fr.SetJobs([]job.Job{{Name: "a@.service"}})
+		fAPI := &client.RegistryClient{Registry: fr}
+		resource := &replicaSetsResource{fAPI, "/replica-sets", testTokenLimit}
+
+		enc, err := json.Marshal(tt.body)
+		if err != nil {
+			t.Fatalf("case %d: unable to JSON-encode request: %v", i, err)
+		}
+		req, err := http.NewRequest("PUT", "http://example.com/replica-sets/"+tt.item, bytes.NewBuffer(enc))
+		if err != nil {
+			t.Fatalf("case %d: failed creating http.Request: %v", i, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rw := httptest.NewRecorder()
+		resource.ServeHTTP(rw, req)
+		if rw.Code != tt.code {
+			t.Errorf("case %d: expected %d, got %d: %s", i, tt.code, rw.Code, rw.Body.String())
+			continue
+		}
+
+		if tt.code == http.StatusNoContent {
+			rs, err := fr.ReplicaSet(tt.item)
+			if err != nil || rs == nil || int64(rs.Replicas) != tt.body.Replicas {
+				t.Errorf("case %d: ReplicaSet not stored correctly: %v, %v", i, rs, err)
+			}
+		}
+	}
+}
+
+func TestReplicaSetsDestroy(t *testing.T) {
+	fr := registry.NewFakeRegistry()
+	fr.SetReplicaSet(job.ReplicaSet{Template: "a@.service", Replicas: 3})
+	fAPI := &client.RegistryClient{Registry: fr}
+	resource := &replicaSetsResource{fAPI, "/replica-sets", testTokenLimit}
+
+	for i, code := range []int{http.StatusNoContent, http.StatusNotFound} {
+		rw := httptest.NewRecorder()
+		req, err := http.NewRequest("DELETE", "http://example.com/replica-sets/a@.service", nil)
+		if err != nil {
+			t.Fatalf("Failed creating http.Request: %v", err)
+		}
+
+		resource.ServeHTTP(rw, req)
+		if rw.Code != code {
+			t.Errorf("attempt %d: expected %d, got %d", i, code, rw.Code)
+		}
+	}
+}
@@ -64,11 +64,75 @@ func (ur *unitsResource) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		default:
 			sendError(rw, http.StatusMethodNotAllowed, errors.New("only GET, PUT and DELETE supported against this resource"))
 		}
+	} else if item, ok := isExplanationPath(ur.basePath, req.URL.Path); ok {
+		switch req.Method {
+		case "GET":
+			ur.explain(rw, req, item)
+		default:
+			sendError(rw, http.StatusMethodNotAllowed, errors.New("only GET supported against this resource"))
+		}
+	} else if item, ok := isEventsPath(ur.basePath, req.URL.Path); ok {
+		switch req.Method {
+		case "GET":
+			ur.history(rw, req, item)
+		default:
+			sendError(rw, http.StatusMethodNotAllowed, errors.New("only GET supported against this resource"))
+		}
+	} else if item, ok := isRevisionsPath(ur.basePath, req.URL.Path); ok {
+		switch req.Method {
+		case "GET":
+			ur.revisions(rw, req, item)
+		default:
+			sendError(rw, http.StatusMethodNotAllowed, errors.New("only GET supported against this resource"))
+		}
+	} else if item, ok := isRolloutPath(ur.basePath, req.URL.Path); ok {
+		switch req.Method {
+		case "GET":
+			ur.rollout(rw, req, item)
+		default:
+			sendError(rw, http.StatusMethodNotAllowed, errors.New("only GET supported against this resource"))
+		}
 	} else {
 		sendError(rw, http.StatusNotFound, nil)
 	}
 }
 
+// isExplanationPath determines whether the given path refers to the
+// scheduling explanation of a single Unit, i.e. <base>/<unit>/explanation.
+func isExplanationPath(base, p string) (item string, matched bool) {
+	if path.Base(p) != "explanation" {
+		return
+	}
+	return isItemPath(base, path.Dir(p))
+}
+
+// isEventsPath determines whether the given path refers to the scheduling
+// events of a single Unit, i.e. <base>/<unit>/events.
+func isEventsPath(base, p string) (item string, matched bool) {
+	if path.Base(p) != "events" {
+		return
+	}
+	return isItemPath(base, path.Dir(p))
+}
+
+// isRevisionsPath determines whether the given path refers to the unit file
+// revisions of a single Unit, i.e. <base>/<unit>/revisions.
+func isRevisionsPath(base, p string) (item string, matched bool) {
+	if path.Base(p) != "revisions" {
+		return
+	}
+	return isItemPath(base, path.Dir(p))
+}
+
+// isRolloutPath determines whether the given path refers to the rollout
+// progress of a single global Unit, i.e. <base>/<unit>/rollout.
+func isRolloutPath(base, p string) (item string, matched bool) {
+	if path.Base(p) != "rollout" {
+		return
+	}
+	return isItemPath(base, path.Dir(p))
+}
+
 func (ur *unitsResource) set(rw http.ResponseWriter, req *http.Request, item string) {
 	if err := validateContentType(req); err != nil {
 		sendError(rw, http.StatusUnsupportedMediaType, err)
@@ -94,12 +158,16 @@ func (ur *unitsResource) set(rw http.ResponseWriter, req *http.Request, item str
 		return
 	}
 
-	eu, err := ur.cAPI.Unit(su.Name)
+	eu, etag, err := ur.cAPI.UnitWithETag(su.Name)
 	if err != nil {
 		log.Errorf("Failed fetching Unit(%s) from Registry: %v", su.Name, err)
 		sendError(rw, http.StatusInternalServerError, nil)
 		return
 	}
+	if !checkPreconditions(req, etag) {
+		sendError(rw, http.StatusPreconditionFailed, errors.New("unit does not match the given ETag"))
+		return
+	}
 
 	newUnit := false
 	if eu == nil {
@@ -126,10 +194,32 @@ func (ur *unitsResource) set(rw http.ResponseWriter, req *http.Request, item str
 		a := schema.MapSchemaUnitOptionsToUnitFile(su.Options)
 		b := schema.MapSchemaUnitOptionsToUnitFile(eu.Options)
 		newUnit = !unit.MatchUnitFiles(a, b)
+		if newUnit {
+			if err := ValidateOptions(su.Options); err != nil {
+				sendError(rw, http.StatusBadRequest, err)
+				return
+			}
+		}
 	}
 
 	if newUnit {
-		ur.create(rw, su.Name, &su)
+		if hasStartAfter(su.Name, su.Options) {
+			units, err := ur.cAPI.Units()
+			if err != nil {
+				log.Errorf("Failed fetching Units from Registry: %v", err)
+				sendError(rw, http.StatusInternalServerError, nil)
+				return
+			}
+			if err := ValidateStartAfter(su.Name, su.Options, units); err != nil {
+				sendError(rw, http.StatusConflict, err)
+				return
+			}
+		}
+		if hasPreconditions(req) {
+			ur.createIfMatch(rw, &su, etag)
+		} else {
+			ur.create(rw, su.Name, &su)
+		}
 		return
 	}
 
@@ -146,7 +236,11 @@ func (ur *unitsResource) set(rw http.ResponseWriter, req *http.Request, item str
 		return
 	}
 
-	ur.update(rw, su.Name, su.DesiredState)
+	if hasPreconditions(req) {
+		ur.updateIfMatch(rw, su.Name, su.DesiredState, etag)
+	} else {
+		ur.update(rw, su.Name, su.DesiredState)
+	}
 }
 
 const (
@@ -234,6 +328,12 @@ func ValidateOptions(opts []*schema.UnitOption) error {
 	j := &job.Job{
 		Unit: *uf,
 	}
+	if err := j.ValidateMetadataRequirements(); err != nil {
+		return err
+	}
+	if err := j.ValidateResources(); err != nil {
+		return err
+	}
 	conflicts := pkg.NewUnsafeSet(j.Conflicts()...)
 	replaces := pkg.NewUnsafeSet(j.Replaces()...)
 	peers := pkg.NewUnsafeSet(j.Peers()...)
@@ -280,6 +380,37 @@ func ValidateOptions(opts []*schema.UnitOption) error {
 	return nil
 }
 
+// ValidateStartAfter ensures that the StartAfter requirements of the named
+// Unit would not form a cycle with those of the given existing Units. If
+// they would, an error naming the Units in the cycle is returned.
+func ValidateStartAfter(name string, opts []*schema.UnitOption, units []*schema.Unit) error {
+	u := job.Unit{
+		Name: name,
+		Unit: *schema.MapSchemaUnitOptionsToUnitFile(opts),
+	}
+	others := make([]job.Unit, 0, len(units))
+	for _, su := range units {
+		others = append(others, job.Unit{
+			Name: su.Name,
+			Unit: *schema.MapSchemaUnitOptionsToUnitFile(su.Options),
+		})
+	}
+
+	if cycle := job.StartAfterCycle(u, others); cycle != nil {
+		return fmt.Errorf("StartAfter requirements form a cycle: %s", strings.Join(cycle, " -> "))
+	}
+	return nil
+}
+
+// hasStartAfter returns whether the named Unit has StartAfter requirements.
+func hasStartAfter(name string, opts []*schema.UnitOption) bool {
+	u := job.Unit{
+		Name: name,
+		Unit: *schema.MapSchemaUnitOptionsToUnitFile(opts),
+	}
+	return len(u.StartAfter()) > 0
+}
+
 func (ur *unitsResource) create(rw http.ResponseWriter, name string, u *schema.Unit) {
 	if err := ur.cAPI.CreateUnit(u); err != nil {
 		log.Errorf("Failed creating Unit(%s) in Registry: %v", u.Name, err)
@@ -290,6 +421,61 @@ func (ur *unitsResource) create(rw http.ResponseWriter, name string, u *schema.U
 	rw.WriteHeader(http.StatusCreated)
 }
 
+// createIfMatch creates or replaces the Unit only if it was not changed since
+// its ETag was checked, which is empty if the Unit did not exist.
+func (ur *unitsResource) createIfMatch(rw http.ResponseWriter, u *schema.Unit, etag string) {
+	if err := ur.cAPI.CreateUnitIfMatch(u, etag); err != nil {
+		if client.IsErrorPreconditionFailed(err) {
+			sendError(rw, http.StatusPreconditionFailed, errors.New("unit does not match the given ETag"))
+			return
+		}
+		if client.IsErrorPreconditionUnsupported(err) {
+			sendError(rw, http.StatusNotImplemented, err)
+			return
+		}
+		log.Errorf("Failed creating Unit(%s) in Registry: %v", u.Name, err)
+		sendError(rw, http.StatusInternalServerError, nil)
+		return
+	}
+
+	rw.WriteHeader(http.StatusCreated)
+}
+
+// hasPreconditions returns whether the request carries an If-Match or
+// If-None-Match header.
+func hasPreconditions(req *http.Request) bool {
+	return req.Header.Get("If-Match") != "" || req.Header.Get("If-None-Match") != ""
+}
+
+// checkPreconditions evaluates the If-Match and If-None-Match headers of the
+// request against the ETag of a Unit, which is empty if it does not exist.
+func checkPreconditions(req *http.Request, etag string) bool {
+	if h := req.Header.Get("If-Match"); h != "" && !matchETag(h, etag) {
+		return false
+	}
+	if h := req.Header.Get("If-None-Match"); h != "" && matchETag(h, etag) {
+		return false
+	}
+	return true
+}
+
+// matchETag returns whether the ETag is in the comma-separated list of the
+// header, or exists at all if the header is "*".
+func matchETag(header, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, t := range strings.Split(header, ",") {
+		if strings.TrimSpace(t) == etag {
+			return true
+		}
+	}
+	return false
+}
+
 func (ur *unitsResource) update(rw http.ResponseWriter, item, ds string) {
 	if err := ur.cAPI.SetUnitTargetState(item, ds); err != nil {
 		log.Errorf("Failed setting target state of Unit(%s): %v", item, err)
@@ -300,21 +486,59 @@ func (ur *unitsResource) update(rw http.ResponseWriter, item, ds string) {
 	rw.WriteHeader(http.StatusNoContent)
 }
 
+// updateIfMatch sets the target state of the Unit only if it was not changed
+// since its ETag was checked.
+func (ur *unitsResource) updateIfMatch(rw http.ResponseWriter, item, ds, etag string) {
+	if err := ur.cAPI.SetUnitTargetStateIfMatch(item, ds, etag); err != nil {
+		if client.IsErrorPreconditionFailed(err) {
+			sendError(rw, http.StatusPreconditionFailed, errors.New("unit does not match the given ETag"))
+			return
+		}
+		if client.IsErrorPreconditionUnsupported(err) {
+			sendError(rw, http.StatusNotImplemented, err)
+			return
+		}
+		log.Errorf("Failed setting target state of Unit(%s): %v", item, err)
+		sendError(rw, http.StatusInternalServerError, nil)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
 func (ur *unitsResource) destroy(rw http.ResponseWriter, req *http.Request, item string) {
-	u, err := ur.cAPI.Unit(item)
+	u, etag, err := ur.cAPI.UnitWithETag(item)
 	if err != nil {
 		log.Errorf("Failed fetching Unit(%s): %v", item, err)
 		sendError(rw, http.StatusInternalServerError, nil)
 		return
 	}
 
+	if !checkPreconditions(req, etag) {
+		sendError(rw, http.StatusPreconditionFailed, errors.New("unit does not match the given ETag"))
+		return
+	}
+
 	if u == nil {
 		sendError(rw, http.StatusNotFound, errors.New("unit does not exist"))
 		return
 	}
 
-	err = ur.cAPI.DestroyUnit(item)
+	if hasPreconditions(req) {
+		// only destroy the Unit the preconditions were checked against
+		err = ur.cAPI.DestroyUnitIfMatch(item, etag)
+	} else {
+		err = ur.cAPI.DestroyUnit(item)
+	}
 	if err != nil {
+		if client.IsErrorPreconditionFailed(err) {
+			sendError(rw, http.StatusPreconditionFailed, errors.New("unit does not match the given ETag"))
+			return
+		}
+		if client.IsErrorPreconditionUnsupported(err) {
+			sendError(rw, http.StatusNotImplemented, err)
+			return
+		}
 		log.Errorf("Failed destroying Unit(%s): %v", item, err)
 		sendError(rw, http.StatusInternalServerError, nil)
 		return
@@ -324,7 +548,7 @@ func (ur *unitsResource) destroy(rw http.ResponseWriter, req *http.Request, item
 }
 
 func (ur *unitsResource) get(rw http.ResponseWriter, req *http.Request, item string) {
-	u, err := ur.cAPI.Unit(item)
+	u, etag, err := ur.cAPI.UnitWithETag(item)
 	if err != nil {
 		log.Errorf("Failed fetching Unit(%s) from Registry: %v", item, err)
 		sendError(rw, http.StatusInternalServerError, nil)
@@ -336,9 +560,74 @@ func (ur *unitsResource) get(rw http.ResponseWriter, req *http.Request, item str
 		return
 	}
 
+	rw.Header().Set("ETag", etag)
 	sendResponse(rw, http.StatusOK, *u)
 }
 
+func (ur *unitsResource) explain(rw http.ResponseWriter, req *http.Request, item string) {
+	exp, err := ur.cAPI.ExplainUnit(item)
+	if err != nil {
+		log.Errorf("Failed explaining scheduling of Unit(%s): %v", item, err)
+		sendError(rw, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if exp == nil {
+		sendError(rw, http.StatusNotFound, errors.New("unit does not exist"))
+		return
+	}
+
+	sendResponse(rw, http.StatusOK, *exp)
+}
+
+func (ur *unitsResource) history(rw http.ResponseWriter, req *http.Request, item string) {
+	h, err := ur.cAPI.UnitHistory(item)
+	if err != nil {
+		log.Errorf("Failed fetching events of Unit(%s): %v", item, err)
+		sendError(rw, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if h == nil {
+		sendError(rw, http.StatusNotFound, errors.New("unit does not exist"))
+		return
+	}
+
+	sendResponse(rw, http.StatusOK, *h)
+}
+
+func (ur *unitsResource) revisions(rw http.ResponseWriter, req *http.Request, item string) {
+	revs, err := ur.cAPI.UnitRevisions(item)
+	if err != nil {
+		log.Errorf("Failed fetching revisions of Unit(%s): %v", item, err)
+		sendError(rw, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if revs == nil {
+		sendError(rw, http.StatusNotFound, errors.New("unit does not exist"))
+		return
+	}
+
+	sendResponse(rw, http.StatusOK, *revs)
+}
+
+func (ur *unitsResource) rollout(rw http.ResponseWriter, req *http.Request, item string) {
+	ro, err := ur.cAPI.UnitRollout(item)
+	if err != nil {
+		log.Errorf("Failed determining rollout of Unit(%s): %v", item, err)
+		sendError(rw, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if ro == nil {
+		sendError(rw, http.StatusNotFound, errors.New("global unit does not exist"))
+		return
+	}
+
+	sendResponse(rw, http.StatusOK, *ro)
+}
+
 func (ur *unitsResource) list(rw http.ResponseWriter, req *http.Request) {
 	token, err := findNextPageToken(req.URL, ur.tokenLimit)
 	if err != nil {
@@ -23,10 +23,14 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/nickswift/fleet/client"
+	"github.com/nickswift/fleet/engine"
 	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/machine"
 	"github.com/nickswift/fleet/registry"
 	"github.com/nickswift/fleet/schema"
 	"github.com/nickswift/fleet/unit"
@@ -317,6 +321,36 @@ func TestUnitsSetDesiredState(t *testing.T) {
 			code:        http.StatusCreated,
 			finalStates: map[string]job.JobState{"YYY.service": "loaded"},
 		},
+		// Create a new Unit starting after another one
+		{
+			initJobs:   []job.Job{job.Job{Name: "XXX.service", Unit: newUnit(t, "[X-Fleet]\nStartAfter=db.service")}},
+			initStates: map[string]job.JobState{"XXX.service": "inactive"},
+			item:       "YYY.service",
+			arg: schema.Unit{
+				Name:         "YYY.service",
+				DesiredState: "loaded",
+				Options: []*schema.UnitOption{
+					&schema.UnitOption{Section: "X-Fleet", Name: "StartAfter", Value: "XXX.service"},
+				},
+			},
+			code:        http.StatusCreated,
+			finalStates: map[string]job.JobState{"XXX.service": "inactive", "YYY.service": "loaded"},
+		},
+		// Creating a new Unit forming a StartAfter cycle fails
+		{
+			initJobs:   []job.Job{job.Job{Name: "XXX.service", Unit: newUnit(t, "[X-Fleet]\nStartAfter=YYY.service")}},
+			initStates: map[string]job.JobState{"XXX.service": "inactive"},
+			item:       "YYY.service",
+			arg: schema.Unit{
+				Name:         "YYY.service",
+				DesiredState: "loaded",
+				Options: []*schema.UnitOption{
+					&schema.UnitOption{Section: "X-Fleet", Name: "StartAfter", Value: "XXX.service"},
+				},
+			},
+			code:        http.StatusConflict,
+			finalStates: map[string]job.JobState{"XXX.service": "inactive"},
+		},
 		// Creating a new Unit without Options fails
 		{
 			initJobs:   []job.Job{},
@@ -371,6 +405,25 @@ func TestUnitsSetDesiredState(t *testing.T) {
 				"YYY.service": "inactive",
 			},
 		},
+		// Replacing a Unit with invalid options should fail
+		{
+			initJobs: []job.Job{
+				job.Job{Name: "XXX.service", Unit: newUnit(t, "[Service]\nFoo=Bar")},
+			},
+			initStates: map[string]job.JobState{
+				"XXX.service": "inactive",
+			},
+			item: "XXX.service",
+			arg: schema.Unit{
+				Name:         "XXX.service",
+				DesiredState: "loaded",
+				Options:      []*schema.UnitOption{makeMetadataUO("disk_gb>=lots")},
+			},
+			code: http.StatusBadRequest,
+			finalStates: map[string]job.JobState{
+				"XXX.service": "inactive",
+			},
+		},
 		// Attempts to load/launch a template unit should fail
 		{
 			initJobs: []job.Job{
@@ -470,6 +523,22 @@ func makePeerUO(name string) *schema.UnitOption {
 	}
 }
 
+func makeMetadataUO(expr string) *schema.UnitOption {
+	return &schema.UnitOption{
+		Section: "X-Fleet",
+		Name:    "MachineMetadata",
+		Value:   expr,
+	}
+}
+
+func makeResourceUO(name, value string) *schema.UnitOption {
+	return &schema.UnitOption{
+		Section: "X-Fleet",
+		Name:    name,
+		Value:   value,
+	}
+}
+
 func makeIDUO(name string) *schema.UnitOption {
 	return &schema.UnitOption{
 		Section: "X-Fleet",
@@ -721,6 +790,47 @@ func TestValidateOptions(t *testing.T) {
 			},
 			false,
 		},
+		// MachineMetadata expressions must parse
+		{
+			[]*schema.UnitOption{
+				makeMetadataUO("disk_gb>=250"),
+				makeMetadataUO("zone in (a,b)"),
+			},
+			true,
+		},
+		{
+			[]*schema.UnitOption{
+				makeMetadataUO("disk_gb>=lots"),
+			},
+			false,
+		},
+		{
+			[]*schema.UnitOption{
+				makeMetadataUO("zone in (a,,b)"),
+			},
+			false,
+		},
+		// so must resource requirements
+		{
+			[]*schema.UnitOption{
+				makeResourceUO("Cores", "0.5"),
+				makeResourceUO("Memory", "2G"),
+				makeResourceUO("Disk", "512"),
+			},
+			true,
+		},
+		{
+			[]*schema.UnitOption{
+				makeResourceUO("Memory", "lots"),
+			},
+			false,
+		},
+		{
+			[]*schema.UnitOption{
+				makeResourceUO("Cores", "-1"),
+			},
+			false,
+		},
 	}
 	for i, tt := range testCases {
 		err := ValidateOptions(tt.opts)
@@ -823,3 +933,340 @@ func TestUnitsSetDesiredStateBadContentType(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestUnitsExplain(t *testing.T) {
+	fr := registry.NewFakeRegistry()
+	fr.SetJobs([]job.Job{{Name: "XXX.service", TargetState: job.JobStateLaunched}})
+	fr.SetMachines([]machine.MachineState{{ID: "XXX"}, {ID: "YYY"}})
+	fAPI := &client.RegistryClient{Registry: fr, Explainer: engine.Explainer{}}
+	resource := &unitsResource{fAPI, "/units", testTokenLimit}
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{"GET", "/units/XXX.service/explanation", http.StatusOK},
+		{"GET", "/units/YYY.service/explanation", http.StatusNotFound},
+		{"PUT", "/units/XXX.service/explanation", http.StatusMethodNotAllowed},
+		{"GET", "/units/XXX.service/explanation/foo", http.StatusNotFound},
+	}
+
+	for i, tt := range tests {
+		rw := httptest.NewRecorder()
+		req, err := http.NewRequest(tt.method, "http://example.com"+tt.path, nil)
+		if err != nil {
+			t.Fatalf("Failed creating http.Request: %v", err)
+		}
+
+		resource.ServeHTTP(rw, req)
+		if rw.Code != tt.code {
+			t.Errorf("case %d: expected %d, got %d", i, tt.code, rw.Code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+
+		var exp schema.UnitExplanation
+		if err := json.Unmarshal(rw.Body.Bytes(), &exp); err != nil {
+			t.Fatalf("case %d: received unparseable body: %v", i, err)
+		}
+		if exp.Name != "XXX.service" || len(exp.Machines) != 2 || !exp.Machines[0].Schedulable || exp.Machines[1].MachineID != "YYY" {
+			t.Errorf("case %d: received incorrect UnitExplanation entity: %v", i, exp)
+		}
+	}
+}
+
+func TestUnitsRollout(t *testing.T) {
+	uf := newUnit(t, "[X-Fleet]\nGlobal=true\nGlobalMaxParallel=1")
+	fr := registry.NewFakeRegistry()
+	fr.SetJobs([]job.Job{
+		{Name: "XXX.service", Unit: uf, TargetState: job.JobStateLaunched},
+		{Name: "YYY.service", TargetState: job.JobStateLaunched},
+	})
+	fr.SetMachines([]machine.MachineState{{ID: "XXX"}, {ID: "YYY"}})
+	fr.AdmitGlobalUnit("XXX.service", uf.Hash().String(), "XXX")
+	fAPI := &client.RegistryClient{Registry: fr, RolloutReporter: engine.RolloutReporter{}}
+	resource := &unitsResource{fAPI, "/units", testTokenLimit}
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{"GET", "/units/XXX.service/rollout", http.StatusOK},
+		{"GET", "/units/YYY.service/rollout", http.StatusNotFound},
+		{"GET", "/units/ZZZ.service/rollout", http.StatusNotFound},
+		{"PUT", "/units/XXX.service/rollout", http.StatusMethodNotAllowed},
+	}
+
+	for i, tt := range tests {
+		rw := httptest.NewRecorder()
+		req, err := http.NewRequest(tt.method, "http://example.com"+tt.path, nil)
+		if err != nil {
+			t.Fatalf("Failed creating http.Request: %v", err)
+		}
+
+		resource.ServeHTTP(rw, req)
+		if rw.Code != tt.code {
+			t.Errorf("case %d: expected %d, got %d", i, tt.code, rw.Code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+
+		var ro schema.UnitRollout
+		if err := json.Unmarshal(rw.Body.Bytes(), &ro); err != nil {
+			t.Fatalf("case %d: received unparseable body: %v", i, err)
+		}
+		if ro.Name != "XXX.service" || ro.MaxParallel != 1 || len(ro.Machines) != 2 ||
+			ro.Machines[0].Status != "in-progress" || ro.Machines[1].Status != "pending" {
+			t.Errorf("case %d: received incorrect UnitRollout entity: %v", i, ro)
+		}
+	}
+}
+
+func TestUnitsHistory(t *testing.T) {
+	fr := registry.NewFakeRegistry()
+	fr.SetJobs([]job.Job{{Name: "XXX.service", TargetState: job.JobStateLaunched}})
+	fr.RecordUnitEvent("XXX.service", job.UnitEvent{
+		Time:      time.Date(2015, time.March, 1, 3, 0, 0, 0, time.UTC),
+		MachineID: "XXX",
+		Type:      "UnscheduleUnit",
+		Reason:    "target Machine(XXX) went away",
+	})
+	fAPI := &client.RegistryClient{Registry: fr}
+	resource := &unitsResource{fAPI, "/units", testTokenLimit}
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{"GET", "/units/XXX.service/events", http.StatusOK},
+		{"GET", "/units/YYY.service/events", http.StatusNotFound},
+		{"DELETE", "/units/XXX.service/events", http.StatusMethodNotAllowed},
+	}
+
+	for i, tt := range tests {
+		rw := httptest.NewRecorder()
+		req, err := http.NewRequest(tt.method, "http://example.com"+tt.path, nil)
+		if err != nil {
+			t.Fatalf("Failed creating http.Request: %v", err)
+		}
+
+		resource.ServeHTTP(rw, req)
+		if rw.Code != tt.code {
+			t.Errorf("case %d: expected %d, got %d", i, tt.code, rw.Code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+
+		var h schema.UnitHistory
+		if err := json.Unmarshal(rw.Body.Bytes(), &h); err != nil {
+			t.Fatalf("case %d: received unparseable body: %v", i, err)
+		}
+		want := schema.UnitEvent{
+			Time:      "2015-03-01T03:00:00Z",
+			MachineID: "XXX",
+			Type:      "UnscheduleUnit",
+			Reason:    "target Machine(XXX) went away",
+		}
+		if h.Name != "XXX.service" || len(h.Events) != 1 || *h.Events[0] != want {
+			t.Errorf("case %d: received incorrect UnitHistory entity: %v", i, h)
+		}
+	}
+}
+
+func TestUnitsRevisions(t *testing.T) {
+	fr := registry.NewFakeRegistry()
+	fr.SetJobs(nil)
+	fAPI := &client.RegistryClient{Registry: fr}
+	resource := &unitsResource{fAPI, "/units", testTokenLimit}
+
+	for _, exec := range []string{"/bin/a", "/bin/b"} {
+		err := fAPI.CreateUnit(&schema.Unit{
+			Name:        "XXX.service",
+			Options:     []*schema.UnitOption{{Section: "Service", Name: "ExecStart", Value: exec}},
+			ChangeCause: "set ExecStart to " + exec,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error creating unit: %v", err)
+		}
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{"GET", "/units/XXX.service/revisions", http.StatusOK},
+		{"GET", "/units/YYY.service/revisions", http.StatusNotFound},
+		{"DELETE", "/units/XXX.service/revisions", http.StatusMethodNotAllowed},
+	}
+
+	for i, tt := range tests {
+		rw := httptest.NewRecorder()
+		req, err := http.NewRequest(tt.method, "http://example.com"+tt.path, nil)
+		if err != nil {
+			t.Fatalf("Failed creating http.Request: %v", err)
+		}
+
+		resource.ServeHTTP(rw, req)
+		if rw.Code != tt.code {
+			t.Errorf("case %d: expected %d, got %d", i, tt.code, rw.Code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+
+		var revs schema.UnitRevisions
+		if err := json.Unmarshal(rw.Body.Bytes(), &revs); err != nil {
+			t.Fatalf("case %d: received unparseable body: %v", i, err)
+		}
+		if revs.Name != "XXX.service" || len(revs.Revisions) != 2 {
+			t.Fatalf("case %d: received incorrect UnitRevisions entity: %v", i, revs)
+		}
+		for j, exec := range []string{"/bin/a", "/bin/b"} {
+			rev := revs.Revisions[j]
+			if rev.Revision != int64(j+1) || rev.Cause != "set ExecStart to "+exec || rev.Current != (j == 1) {
+				t.Errorf("case %d: received incorrect revision %d: %v", i, j, rev)
+			}
+			if len(rev.Options) != 1 || rev.Options[0].Value != exec {
+				t.Errorf("case %d: received incorrect options of revision %d: %v", i, j, rev.Options)
+			}
+		}
+	}
+}
+
+func TestUnitsPreconditions(t *testing.T) {
+	body := func(exec string) string {
+		return fmt.Sprintf(`{"name":"XXX.service","desiredState":"launched","options":[{"section":"Service","name":"ExecStart","value":%q}]}`, exec)
+	}
+
+	tests := []struct {
+		method string
+		name   string
+		body   string
+		// header set on the request, and whether its value is the ETag
+		// of the unit instead
+		header  string
+		value   string
+		current bool
+		code    int
+	}{
+		{"PUT", "XXX.service", body("/bin/b"), "If-Match", "", true, http.StatusCreated},
+		{"PUT", "XXX.service", body("/bin/b"), "If-Match", `"0000000-1"`, false, http.StatusPreconditionFailed},
+		{"PUT", "XXX.service", body("/bin/b"), "If-Match", "*", false, http.StatusCreated},
+		{"PUT", "XXX.service", body("/bin/b"), "If-None-Match", "*", false, http.StatusPreconditionFailed},
+		{"PUT", "XXX.service", body("/bin/b"), "If-None-Match", "", true, http.StatusPreconditionFailed},
+		{"PUT", "YYY.service", strings.Replace(body("/bin/b"), "XXX", "YYY", 1), "If-None-Match", "*", false, http.StatusCreated},
+		{"PUT", "YYY.service", strings.Replace(body("/bin/b"), "XXX", "YYY", 1), "If-Match", "*", false, http.StatusPreconditionFailed},
+		{"PUT", "XXX.service", `{"name":"XXX.service","desiredState":"launched"}`, "If-Match", "", true, http.StatusNoContent},
+		{"PUT", "XXX.service", `{"name":"XXX.service","desiredState":"launched"}`, "If-Match", `"0000000-1"`, false, http.StatusPreconditionFailed},
+		{"DELETE", "XXX.service", "", "If-Match", "", true, http.StatusNoContent},
+		{"DELETE", "XXX.service", "", "If-Match", `"0000000-1", "0000000-2"`, false, http.StatusPreconditionFailed},
+	}
+
+	for i, tt := range tests {
+		fr := registry.NewFakeRegistry()
+		fr.SetJobs(nil)
+		fAPI := &client.RegistryClient{Registry: fr}
+		resource := &unitsResource{fAPI, "/units", testTokenLimit}
+		if err := fAPI.CreateUnit(&schema.Unit{Name: "XXX.service", Options: []*schema.UnitOption{{Section: "Service", Name: "ExecStart", Value: "/bin/a"}}}); err != nil {
+			t.Fatalf("case %d: unexpected error creating unit: %v", i, err)
+		}
+
+		// the ETag of an existing unit is returned with it
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://example.com/units/XXX.service", nil)
+		resource.ServeHTTP(rw, req)
+		etag := rw.Header().Get("ETag")
+		if rw.Code != http.StatusOK || etag == "" {
+			t.Fatalf("case %d: expected unit with ETag, got %d with ETag %q", i, rw.Code, etag)
+		}
+
+		req, err := http.NewRequest(tt.method, "http://example.com/units/"+tt.name, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatalf("case %d: failed creating http.Request: %v", i, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		value := tt.value
+		if tt.current {
+			value = etag
+		}
+		req.Header.Set(tt.header, value)
+
+		rw = httptest.NewRecorder()
+		resource.ServeHTTP(rw, req)
+		if rw.Code != tt.code {
+			t.Errorf("case %d: expected %d, got %d", i, tt.code, rw.Code)
+		}
+		if tt.method != "PUT" || tt.name != "XXX.service" {
+			continue
+		}
+
+		// only a successful change of the unit changes the ETag
+		rw = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "http://example.com/units/XXX.service", nil)
+		resource.ServeHTTP(rw, req)
+		success := tt.code == http.StatusCreated || tt.code == http.StatusNoContent
+		if changed := rw.Header().Get("ETag") != etag; changed != success {
+			t.Errorf("case %d: expected ETag to change %t, got %q after %q", i, success, rw.Header().Get("ETag"), etag)
+		}
+	}
+}
+
+// unindexedRegistry hides the indexes of Units, like registries which do
+// not track them.
+type unindexedRegistry struct {
+	*registry.FakeRegistry
+}
+
+func (r unindexedRegistry) Unit(name string) (*job.Unit, error) {
+	u, err := r.FakeRegistry.Unit(name)
+	if u != nil {
+		u.Index = 0
+	}
+	return u, err
+}
+
+func TestUnitsPreconditionsUnsupported(t *testing.T) {
+	fr := registry.NewFakeRegistry()
+	fr.SetJobs(nil)
+	fAPI := &client.RegistryClient{Registry: unindexedRegistry{fr}}
+	resource := &unitsResource{fAPI, "/units", testTokenLimit}
+	if err := fAPI.CreateUnit(&schema.Unit{Name: "XXX.service", Options: []*schema.UnitOption{{Section: "Service", Name: "ExecStart", Value: "/bin/a"}}}); err != nil {
+		t.Fatalf("unexpected error creating unit: %v", err)
+	}
+	_, etag, _ := fAPI.UnitWithETag("XXX.service")
+
+	tests := []struct {
+		method string
+		body   string
+	}{
+		{"PUT", `{"name":"XXX.service","desiredState":"launched","options":[{"section":"Service","name":"ExecStart","value":"/bin/b"}]}`},
+		{"PUT", `{"name":"XXX.service","desiredState":"launched"}`},
+		{"DELETE", ""},
+	}
+	for i, tt := range tests {
+		req, _ := http.NewRequest(tt.method, "http://example.com/units/XXX.service", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", etag)
+		rw := httptest.NewRecorder()
+		resource.ServeHTTP(rw, req)
+		if rw.Code != http.StatusNotImplemented {
+			t.Errorf("case %d: expected %d, got %d", i, http.StatusNotImplemented, rw.Code)
+		}
+	}
+
+	u, err := fr.Unit("XXX.service")
+	if err != nil || u == nil || u.Unit.Contents["Service"]["ExecStart"][0] != "/bin/a" || u.TargetState != job.JobStateInactive {
+		t.Errorf("expected unit to be left alone, got %v, %v", u, err)
+	}
+}
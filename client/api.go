@@ -21,12 +21,30 @@ import (
 
 type API interface {
 	Machines() ([]machine.MachineState, error)
+	CordonMachine(string) error
+	UncordonMachine(string) error
+	SetMachineTaints(string, map[string]string) error
+	SetMachineMetadata(machID, key, value string) error
+	DeleteMachineMetadata(machID, key string) error
 
 	Unit(string) (*schema.Unit, error)
+	UnitWithETag(string) (*schema.Unit, string, error)
 	Units() ([]*schema.Unit, error)
 	UnitStates() ([]*schema.UnitState, error)
 
 	SetUnitTargetState(name, target string) error
+	SetUnitTargetStateIfMatch(name, target, etag string) error
 	CreateUnit(*schema.Unit) error
+	CreateUnitIfMatch(u *schema.Unit, etag string) error
 	DestroyUnit(string) error
+	DestroyUnitIfMatch(name, etag string) error
+	ExplainUnit(string) (*schema.UnitExplanation, error)
+	UnitRollout(string) (*schema.UnitRollout, error)
+	UnitHistory(string) (*schema.UnitHistory, error)
+	UnitRevisions(string) (*schema.UnitRevisions, error)
+
+	ReplicaSet(string) (*schema.ReplicaSet, error)
+	ReplicaSets() ([]*schema.ReplicaSet, error)
+	SetReplicaSet(*schema.ReplicaSet) error
+	DestroyReplicaSet(string) error
 }
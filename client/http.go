@@ -22,6 +22,7 @@ import (
 	"google.golang.org/api/googleapi"
 
 	"github.com/nickswift/fleet/machine"
+	"github.com/nickswift/fleet/registry"
 	"github.com/nickswift/fleet/schema"
 )
 
@@ -67,6 +68,26 @@ func (c *HTTPClient) Machines() ([]machine.MachineState, error) {
 	return machines, nil
 }
 
+func (c *HTTPClient) CordonMachine(machID string) error {
+	return c.svc.Machines.Cordon(machID).Do()
+}
+
+func (c *HTTPClient) UncordonMachine(machID string) error {
+	return c.svc.Machines.Uncordon(machID).Do()
+}
+
+func (c *HTTPClient) SetMachineTaints(machID string, taints map[string]string) error {
+	return c.svc.Machines.SetTaints(machID, &schema.MachineTaints{Taints: taints}).Do()
+}
+
+func (c *HTTPClient) SetMachineMetadata(machID, key, value string) error {
+	return c.svc.Machines.SetMetadata(machID, key, &schema.MachineMetadataValue{Value: value}).Do()
+}
+
+func (c *HTTPClient) DeleteMachineMetadata(machID, key string) error {
+	return c.svc.Machines.DeleteMetadata(machID, key).Do()
+}
+
 func (c *HTTPClient) Units() ([]*schema.Unit, error) {
 	var units []*schema.Unit
 	call := c.svc.Units.List()
@@ -96,6 +117,17 @@ func (c *HTTPClient) Unit(name string) (*schema.Unit, error) {
 	return u, nil
 }
 
+func (c *HTTPClient) UnitWithETag(name string) (*schema.Unit, string, error) {
+	u, err := c.svc.Units.Get(name).Do()
+	if err != nil {
+		if is404(err) {
+			err = nil
+		}
+		return nil, "", err
+	}
+	return u, u.Header.Get("ETag"), nil
+}
+
 func (c *HTTPClient) UnitStates() ([]*schema.UnitState, error) {
 	var states []*schema.UnitState
 	call := c.svc.UnitState.List()
@@ -121,10 +153,24 @@ func (c *HTTPClient) DestroyUnit(name string) error {
 	return c.svc.Units.Delete(name).Do()
 }
 
+func (c *HTTPClient) DestroyUnitIfMatch(name, etag string) error {
+	return c.svc.Units.Delete(name).IfMatch(etag).Do()
+}
+
 func (c *HTTPClient) CreateUnit(u *schema.Unit) error {
 	return c.svc.Units.Set(u.Name, u).Do()
 }
 
+func (c *HTTPClient) CreateUnitIfMatch(u *schema.Unit, etag string) error {
+	call := c.svc.Units.Set(u.Name, u)
+	if etag == "" {
+		call.IfNoneMatch("*")
+	} else {
+		call.IfMatch(etag)
+	}
+	return call.Do()
+}
+
 func (c *HTTPClient) SetUnitTargetState(name, target string) error {
 	u := schema.Unit{
 		Name:         name,
@@ -133,6 +179,83 @@ func (c *HTTPClient) SetUnitTargetState(name, target string) error {
 	return c.svc.Units.Set(name, &u).Do()
 }
 
+func (c *HTTPClient) SetUnitTargetStateIfMatch(name, target, etag string) error {
+	u := schema.Unit{
+		Name:         name,
+		DesiredState: target,
+	}
+	return c.svc.Units.Set(name, &u).IfMatch(etag).Do()
+}
+
+func (c *HTTPClient) ExplainUnit(name string) (*schema.UnitExplanation, error) {
+	exp, err := c.svc.Units.Explain(name).Do()
+	if err != nil && !is404(err) {
+		return nil, err
+	}
+	return exp, nil
+}
+
+func (c *HTTPClient) UnitHistory(name string) (*schema.UnitHistory, error) {
+	h, err := c.svc.Units.Events(name).Do()
+	if err != nil && !is404(err) {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (c *HTTPClient) UnitRevisions(name string) (*schema.UnitRevisions, error) {
+	revs, err := c.svc.Units.Revisions(name).Do()
+	if err != nil && !is404(err) {
+		return nil, err
+	}
+	return revs, nil
+}
+
+func (c *HTTPClient) UnitRollout(name string) (*schema.UnitRollout, error) {
+	ro, err := c.svc.Units.Rollout(name).Do()
+	if err != nil && !is404(err) {
+		return nil, err
+	}
+	return ro, nil
+}
+
+func (c *HTTPClient) ReplicaSets() ([]*schema.ReplicaSet, error) {
+	var rsets []*schema.ReplicaSet
+	call := c.svc.ReplicaSets.List()
+	for call != nil {
+		page, err := call.Do()
+		if err != nil {
+			return nil, err
+		}
+
+		rsets = append(rsets, page.ReplicaSets...)
+
+		if len(page.NextPageToken) > 0 {
+			call = c.svc.ReplicaSets.List()
+			call.NextPageToken(page.NextPageToken)
+		} else {
+			call = nil
+		}
+	}
+	return rsets, nil
+}
+
+func (c *HTTPClient) ReplicaSet(template string) (*schema.ReplicaSet, error) {
+	rs, err := c.svc.ReplicaSets.Get(template).Do()
+	if err != nil && !is404(err) {
+		return nil, err
+	}
+	return rs, nil
+}
+
+func (c *HTTPClient) SetReplicaSet(rs *schema.ReplicaSet) error {
+	return c.svc.ReplicaSets.Set(rs.Template, rs).Do()
+}
+
+func (c *HTTPClient) DestroyReplicaSet(template string) error {
+	return c.svc.ReplicaSets.Delete(template).Do()
+}
+
 func is404(err error) bool {
 	googerr, ok := err.(*googleapi.Error)
 	return ok && googerr.Code == http.StatusNotFound
@@ -141,3 +264,23 @@ func is404(err error) bool {
 func IsErrorUnitNotFound(err error) bool {
 	return is404(err)
 }
+
+// IsErrorPreconditionFailed returns whether the Unit was not changed as it
+// did not match the given ETag.
+func IsErrorPreconditionFailed(err error) bool {
+	if err == registry.ErrUnitModified {
+		return true
+	}
+	googerr, ok := err.(*googleapi.Error)
+	return ok && googerr.Code == http.StatusPreconditionFailed
+}
+
+// IsErrorPreconditionUnsupported returns whether the Unit was not changed as
+// the registry is unable to enforce the given ETag.
+func IsErrorPreconditionUnsupported(err error) bool {
+	if err == registry.ErrPreconditionUnsupported {
+		return true
+	}
+	googerr, ok := err.(*googleapi.Error)
+	return ok && googerr.Code == http.StatusNotImplemented
+}
@@ -15,13 +15,45 @@
 package client
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/nickswift/fleet/job"
 	"github.com/nickswift/fleet/registry"
 	"github.com/nickswift/fleet/schema"
+	"github.com/nickswift/fleet/unit"
 )
 
+// ErrExplainerMissing is returned when explaining the scheduling of a Unit
+// through a RegistryClient without an Explainer.
+var ErrExplainerMissing = errors.New("explaining the scheduling of units requires the engine")
+
+// ErrRolloutReporterMissing is returned when determining the rollout of a
+// Unit through a RegistryClient without a RolloutReporter.
+var ErrRolloutReporterMissing = errors.New("determining the rollout of units requires the engine")
+
+// Explainer determines how the engine would schedule Units. It is
+// implemented by the engine, which the client does not depend on.
+type Explainer interface {
+	// ExplainUnit determines whether the named Unit could be scheduled
+	// to each Machine, or returns nil if it does not exist.
+	ExplainUnit(reg registry.Registry, name string) (*schema.UnitExplanation, error)
+}
+
+// RolloutReporter determines the progress of the engine in rolling out
+// global Units. Like the Explainer, it is implemented by the engine.
+type RolloutReporter interface {
+	// UnitRollout determines the progress of rolling out the named global
+	// Unit, or returns nil if it does not exist.
+	UnitRollout(reg registry.Registry, name string) (*schema.UnitRollout, error)
+}
+
 type RegistryClient struct {
 	registry.Registry
+	// Explainer serves ExplainUnit
+	Explainer Explainer
+	// RolloutReporter serves UnitRollout
+	RolloutReporter RolloutReporter
 }
 
 func (rc *RegistryClient) Units() ([]*schema.Unit, error) {
@@ -50,9 +82,16 @@ func (rc *RegistryClient) Units() ([]*schema.Unit, error) {
 }
 
 func (rc *RegistryClient) Unit(name string) (*schema.Unit, error) {
+	u, _, err := rc.UnitWithETag(name)
+	return u, err
+}
+
+// UnitWithETag returns the Unit along with its ETag, which changes whenever
+// the Unit is created or replaced.
+func (rc *RegistryClient) UnitWithETag(name string) (*schema.Unit, string, error) {
 	rUnit, err := rc.Registry.Unit(name)
 	if err != nil || rUnit == nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	var sUnit *job.ScheduledUnit
@@ -60,30 +99,89 @@ func (rc *RegistryClient) Unit(name string) (*schema.Unit, error) {
 	if !rUnit.IsGlobal() {
 		sUnit, err = rc.Registry.ScheduledUnit(name)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 	}
 
-	return schema.MapUnitToSchemaUnit(rUnit, sUnit), nil
+	return schema.MapUnitToSchemaUnit(rUnit, sUnit), unitETag(rUnit), nil
+}
+
+// unitETag derives the ETag of a Unit from the hash of its unit file and
+// the registry index it was stored at.
+func unitETag(u *job.Unit) string {
+	return fmt.Sprintf(`"%s-%d"`, u.Unit.Hash(), u.Index)
 }
 
 func (rc *RegistryClient) CreateUnit(u *schema.Unit) error {
+	rUnit, err := newRegistryUnit(u)
+	if err != nil {
+		return err
+	}
+
+	return rc.Registry.CreateUnit(rUnit)
+}
+
+// CreateUnitIfMatch creates or replaces the Unit only if the ETag of the
+// stored Unit is etag, or if etag is empty, only if no such Unit exists.
+func (rc *RegistryClient) CreateUnitIfMatch(u *schema.Unit, etag string) error {
+	rUnit, err := newRegistryUnit(u)
+	if err != nil {
+		return err
+	}
+
+	cur, err := rc.Registry.Unit(u.Name)
+	if err != nil {
+		return err
+	}
+	if etag == "" {
+		if cur != nil {
+			return registry.ErrUnitModified
+		}
+		return rc.Registry.ReplaceUnit(rUnit, 0)
+	}
+	if cur == nil || unitETag(cur) != etag {
+		return registry.ErrUnitModified
+	}
+	if cur.Index == 0 {
+		return registry.ErrPreconditionUnsupported
+	}
+	return rc.Registry.ReplaceUnit(rUnit, cur.Index)
+}
+
+// DestroyUnitIfMatch destroys the Unit only if the ETag of the stored Unit
+// is etag.
+func (rc *RegistryClient) DestroyUnitIfMatch(name, etag string) error {
+	cur, err := rc.Registry.Unit(name)
+	if err != nil {
+		return err
+	}
+	if cur == nil || unitETag(cur) != etag {
+		return registry.ErrUnitModified
+	}
+	if cur.Index == 0 {
+		return registry.ErrPreconditionUnsupported
+	}
+	return rc.Registry.DestroyUnitAt(name, cur.Index)
+}
+
+func newRegistryUnit(u *schema.Unit) (*job.Unit, error) {
 	rUnit := job.Unit{
 		Name:        u.Name,
 		Unit:        *schema.MapSchemaUnitOptionsToUnitFile(u.Options),
 		TargetState: job.JobStateInactive,
+		ChangeCause: u.ChangeCause,
 	}
 
 	if len(u.DesiredState) > 0 {
 		ts, err := job.ParseJobState(u.DesiredState)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		rUnit.TargetState = ts
 	}
 
-	return rc.Registry.CreateUnit(&rUnit)
+	return &rUnit, nil
 }
 
 func (rc *RegistryClient) UnitStates() ([]*schema.UnitState, error) {
@@ -103,3 +201,102 @@ func (rc *RegistryClient) UnitStates() ([]*schema.UnitState, error) {
 func (rc *RegistryClient) SetUnitTargetState(name, target string) error {
 	return rc.Registry.SetUnitTargetState(name, job.JobState(target))
 }
+
+// SetUnitTargetStateIfMatch sets the target state of the Unit only if the
+// ETag of the stored Unit is etag. The Unit is stored again along with its
+// new target state, so its ETag changes.
+func (rc *RegistryClient) SetUnitTargetStateIfMatch(name, target, etag string) error {
+	ts, err := job.ParseJobState(target)
+	if err != nil {
+		return err
+	}
+
+	cur, err := rc.Registry.Unit(name)
+	if err != nil {
+		return err
+	}
+	if cur == nil || unitETag(cur) != etag {
+		return registry.ErrUnitModified
+	}
+	if cur.Index == 0 {
+		return registry.ErrPreconditionUnsupported
+	}
+	cur.TargetState = ts
+	return rc.Registry.ReplaceUnit(cur, cur.Index)
+}
+
+func (rc *RegistryClient) ReplicaSets() ([]*schema.ReplicaSet, error) {
+	rsets, err := rc.Registry.ReplicaSets()
+	if err != nil {
+		return nil, err
+	}
+
+	srsets := make([]*schema.ReplicaSet, len(rsets))
+	for i, rs := range rsets {
+		srsets[i] = schema.MapReplicaSetToSchemaReplicaSet(&rs)
+	}
+
+	return srsets, nil
+}
+
+func (rc *RegistryClient) ReplicaSet(template string) (*schema.ReplicaSet, error) {
+	rs, err := rc.Registry.ReplicaSet(template)
+	if err != nil || rs == nil {
+		return nil, err
+	}
+
+	return schema.MapReplicaSetToSchemaReplicaSet(rs), nil
+}
+
+func (rc *RegistryClient) SetReplicaSet(rs *schema.ReplicaSet) error {
+	return rc.Registry.SetReplicaSet(*schema.MapSchemaReplicaSetToReplicaSet(rs))
+}
+
+func (rc *RegistryClient) ExplainUnit(name string) (*schema.UnitExplanation, error) {
+	if rc.Explainer == nil {
+		return nil, ErrExplainerMissing
+	}
+	return rc.Explainer.ExplainUnit(rc.Registry, name)
+}
+
+func (rc *RegistryClient) UnitHistory(name string) (*schema.UnitHistory, error) {
+	u, err := rc.Registry.Unit(name)
+	if err != nil || u == nil {
+		return nil, err
+	}
+
+	events, err := rc.Registry.UnitEvents(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return schema.MapUnitEventsToSchemaUnitHistory(name, events), nil
+}
+
+func (rc *RegistryClient) UnitRevisions(name string) (*schema.UnitRevisions, error) {
+	u, err := rc.Registry.Unit(name)
+	if err != nil || u == nil {
+		return nil, err
+	}
+
+	files := make(map[unit.Hash]*unit.UnitFile, len(u.Revisions))
+	for _, rev := range u.Revisions {
+		if _, ok := files[rev.UnitHash]; ok {
+			continue
+		}
+		uf, err := rc.Registry.UnitFile(rev.UnitHash)
+		if err != nil {
+			return nil, err
+		}
+		files[rev.UnitHash] = uf
+	}
+
+	return schema.MapUnitRevisionsToSchemaUnitRevisions(u, files), nil
+}
+
+func (rc *RegistryClient) UnitRollout(name string) (*schema.UnitRollout, error) {
+	if rc.RolloutReporter == nil {
+		return nil, ErrRolloutReporterMissing
+	}
+	return rc.RolloutReporter.UnitRollout(rc.Registry, name)
+}
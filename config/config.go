@@ -21,27 +21,35 @@ import (
 )
 
 type Config struct {
-	EtcdServers             []string
-	EtcdUsername            string
-	EtcdPassword            string
-	EtcdKeyPrefix           string
-	EtcdKeyFile             string
-	EtcdCertFile            string
-	EtcdCAFile              string
-	EtcdRequestTimeout      float64
-	EngineReconcileInterval float64
-	PublicIP                string
-	Verbosity               int
-	RawMetadata             string
-	AgentTTL                string
-	TokenLimit              int
-	DisableEngine           bool
-	DisableWatches          bool
-	EnableGRPC              bool
-	VerifyUnits             bool
-	UnitsDirectory          string
-	SystemdUser             bool
-	AuthorizedKeysFile      string
+	EtcdServers                []string
+	EtcdUsername               string
+	EtcdPassword               string
+	EtcdKeyPrefix              string
+	EtcdKeyFile                string
+	EtcdCertFile               string
+	EtcdCAFile                 string
+	EtcdRequestTimeout         float64
+	EtcdAPIVersion             string
+	RegistryBackend            string
+	LocalRegistryFile          string
+	EngineReconcileInterval    float64
+	SchedulerStrategy          string
+	RebalanceThreshold         int
+	RebalanceResourceThreshold int
+	RebalanceMaxMoves          int
+	PublicIP                   string
+	Verbosity                  int
+	RawMetadata                string
+	RawTaints                  string
+	AgentTTL                   string
+	TokenLimit                 int
+	DisableEngine              bool
+	DisableWatches             bool
+	EnableGRPC                 bool
+	VerifyUnits                bool
+	UnitsDirectory             string
+	SystemdUser                bool
+	AuthorizedKeysFile         string
 }
 
 func (c *Config) Capabilities() machine.Capabilities {
@@ -68,3 +76,7 @@ func (c *Config) Metadata() map[string]string {
 
 	return meta
 }
+
+func (c *Config) Taints() map[string]string {
+	return machine.ParseTaints(c.RawTaints)
+}
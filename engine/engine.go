@@ -18,12 +18,14 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/nickswift/fleet/job"
 	"github.com/nickswift/fleet/log"
 	"github.com/nickswift/fleet/machine"
 	"github.com/nickswift/fleet/metrics"
 	"github.com/nickswift/fleet/pkg"
 	"github.com/nickswift/fleet/pkg/lease"
 	"github.com/nickswift/fleet/registry"
+	"github.com/nickswift/fleet/unit"
 )
 
 const (
@@ -52,8 +54,8 @@ type CompleteRegistry interface {
 	registry.ClusterRegistry
 }
 
-func New(reg CompleteRegistry, lManager lease.Manager, rStream pkg.EventStream, mach machine.Machine, updateEngineState func(newEngine machine.MachineState)) *Engine {
-	rec := NewReconciler()
+func New(reg CompleteRegistry, lManager lease.Manager, rStream pkg.EventStream, mach machine.Machine, sched Scheduler, rebal *Rebalancer, updateEngineState func(newEngine machine.MachineState)) *Engine {
+	rec := NewReconciler(sched, rebal)
 	return &Engine{
 		rec:               rec,
 		registry:          reg,
@@ -245,25 +247,37 @@ func renewLeadership(l lease.Lease, ttl time.Duration) lease.Lease {
 }
 
 func (e *Engine) clusterState() (*clusterState, error) {
-	units, err := e.registry.Units()
+	return loadClusterState(e.registry)
+}
+
+func loadClusterState(reg registry.Registry) (*clusterState, error) {
+	units, err := reg.Units()
 	if err != nil {
 		log.Errorf("Failed fetching Units from Registry: %v", err)
 		return nil, err
 	}
 
-	sUnits, err := e.registry.Schedule()
+	sUnits, err := reg.Schedule()
 	if err != nil {
 		log.Errorf("Failed fetching schedule from Registry: %v", err)
 		return nil, err
 	}
 
-	machines, err := e.registry.Machines()
+	machines, err := reg.Machines()
 	if err != nil {
 		log.Errorf("Failed fetching Machines from Registry: %v", err)
 		return nil, err
 	}
 
-	return newClusterState(units, sUnits, machines), nil
+	states, err := reg.UnitStates()
+	if err != nil {
+		log.Errorf("Failed fetching UnitStates from Registry: %v", err)
+		return nil, err
+	}
+
+	clust := newClusterState(units, sUnits, machines)
+	clust.setUnitStates(states)
+	return clust, nil
 }
 
 func (e *Engine) unscheduleUnit(name, machID string) (err error) {
@@ -289,3 +303,105 @@ func (e *Engine) attemptScheduleUnit(name, machID string) bool {
 	log.Infof("Scheduled Unit(%s) to Machine(%s)", name, machID)
 	return true
 }
+
+// recordUnitEvent persists the scheduling decision made by the given task
+// in the history of its Unit. Failing to do so does not fail the task.
+func (e *Engine) recordUnitEvent(t *task) {
+	ev := job.UnitEvent{
+		Time:      e.rec.clock.Now(),
+		MachineID: t.MachineID,
+		Type:      t.Type,
+		Reason:    t.Reason,
+	}
+	if err := e.registry.RecordUnitEvent(t.JobName, ev); err != nil {
+		log.Errorf("Failed recording event of Unit(%s): %v", t.JobName, err)
+	}
+}
+
+func (e *Engine) holdUnit(name string) (err error) {
+	err = e.registry.HoldUnit(name)
+	if err != nil {
+		log.Errorf("Failed holding Unit(%s): %v", name, err)
+	}
+	return
+}
+
+func (e *Engine) releaseUnit(name string) (err error) {
+	err = e.registry.ReleaseUnit(name)
+	if err != nil {
+		log.Errorf("Failed releasing Unit(%s): %v", name, err)
+	}
+	return
+}
+
+func (e *Engine) recordUnitFailure(name, machID string) (err error) {
+	err = e.registry.RecordUnitFailure(name, machID)
+	if err != nil {
+		log.Errorf("Failed recording failure of Unit(%s) on Machine(%s): %v", name, machID, err)
+	}
+	return
+}
+
+func (e *Engine) clearUnitFailures(name string) (err error) {
+	err = e.registry.ClearUnitFailures(name)
+	if err != nil {
+		log.Errorf("Failed clearing failures of Unit(%s): %v", name, err)
+	}
+	return
+}
+
+// admitGlobalUnit allows the given Machine to run the current version of
+// the global Unit.
+func (e *Engine) admitGlobalUnit(name, machID string) (err error) {
+	u, err := e.registry.Unit(name)
+	if err == nil && u == nil {
+		err = fmt.Errorf("Unit(%s) does not exist", name)
+	}
+	if err == nil {
+		err = e.registry.AdmitGlobalUnit(name, u.Unit.Hash().String(), machID)
+	}
+	if err != nil {
+		log.Errorf("Failed admitting Machine(%s) to run Unit(%s): %v", machID, name, err)
+	} else {
+		log.Infof("Admitted Machine(%s) to run Unit(%s)", machID, name)
+	}
+	return
+}
+
+// createReplica creates the named instance of a template Unit with a target
+// state of launched, using the template's unit file.
+func (e *Engine) createReplica(name string) error {
+	tmpl := unit.NewUnitNameInfo(name).Template
+	tu, err := e.registry.Unit(tmpl)
+	if err != nil {
+		log.Errorf("Failed fetching Unit(%s) from Registry: %v", tmpl, err)
+		return err
+	}
+	if tu == nil {
+		return fmt.Errorf("template Unit(%s) does not exist", tmpl)
+	}
+
+	u := job.Unit{
+		Name:        name,
+		Unit:        tu.Unit,
+		TargetState: job.JobStateLaunched,
+	}
+	if err = e.registry.CreateUnit(&u); err != nil {
+		log.Errorf("Failed creating Unit(%s): %v", name, err)
+		return err
+	}
+
+	log.Infof("Created replica Unit(%s)", name)
+	return nil
+}
+
+// destroyReplica removes the named instance of a template Unit.
+func (e *Engine) destroyReplica(name string) error {
+	if err := e.registry.DestroyUnit(name); err != nil {
+		log.Errorf("Failed destroying Unit(%s): %v", name, err)
+		return err
+	}
+
+	log.Infof("Destroyed replica Unit(%s)", name)
+	return nil
+}
@@ -15,9 +15,13 @@
 package engine
 
 import (
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/jonboulle/clockwork"
+
+	"github.com/nickswift/fleet/job"
 	"github.com/nickswift/fleet/registry"
 )
 
@@ -162,3 +166,34 @@ func TestIsLeader(t *testing.T) {
 		}
 	}
 }
+
+func TestDoTaskRecordsUnitEvents(t *testing.T) {
+	reg := registry.NewFakeRegistry()
+	reg.SetJobs([]job.Job{{Name: "foo.service", TargetState: job.JobStateLaunched}})
+	clock := clockwork.NewFakeClock()
+	e := &Engine{rec: NewReconciler(nil, nil), registry: reg}
+	e.rec.clock = clock
+
+	tasks := []*task{
+		{Type: taskTypeAttemptScheduleUnit, Reason: "target state launched and unit not scheduled", JobName: "foo.service", MachineID: "XXX"},
+		{Type: taskTypeUnscheduleUnit, Reason: "target Machine(XXX) went away", JobName: "foo.service", MachineID: "XXX"},
+		{Type: taskTypeHoldUnit, Reason: "waiting for db.service", JobName: "foo.service"},
+	}
+	for _, tsk := range tasks {
+		if err := doTask(tsk, e); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	events, err := reg.UnitEvents("foo.service")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []job.UnitEvent{
+		{Time: clock.Now(), MachineID: "XXX", Type: taskTypeAttemptScheduleUnit, Reason: tasks[0].Reason},
+		{Time: clock.Now(), MachineID: "XXX", Type: taskTypeUnscheduleUnit, Reason: tasks[1].Reason},
+	}
+	if !reflect.DeepEqual(want, events) {
+		t.Errorf("expected events %v, got %v", want, events)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/nickswift/fleet/agent"
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/registry"
+	"github.com/nickswift/fleet/schema"
+)
+
+// Explanation describes whether a Unit could be scheduled to each of the
+// Machines in the cluster, and if not, why.
+type Explanation struct {
+	Name            string
+	TargetState     job.JobState
+	TargetMachineID string
+	Machines        []MachineExplanation
+}
+
+// MachineExplanation describes whether a Unit could be scheduled to a
+// single Machine. Reason is only set if the Unit could not be scheduled.
+type MachineExplanation struct {
+	MachineID   string
+	Schedulable bool
+	Reason      string
+}
+
+// Explain determines for each Machine in the cluster whether the named Unit
+// could be scheduled to it, using the same checks as the engine. If the
+// Unit does not exist, nil is returned.
+func Explain(reg registry.Registry, name string) (*Explanation, error) {
+	clust, err := loadClusterState(reg)
+	if err != nil {
+		return nil, err
+	}
+	return explain(clust, name), nil
+}
+
+// Explainer implements client.Explainer with the checks of the engine.
+type Explainer struct{}
+
+// ExplainUnit maps the Explanation of the named Unit to the API schema.
+func (Explainer) ExplainUnit(reg registry.Registry, name string) (*schema.UnitExplanation, error) {
+	exp, err := Explain(reg, name)
+	if err != nil || exp == nil {
+		return nil, err
+	}
+
+	sexp := schema.UnitExplanation{
+		Name:         exp.Name,
+		DesiredState: string(exp.TargetState),
+		MachineID:    exp.TargetMachineID,
+		Machines:     make([]*schema.MachineExplanation, len(exp.Machines)),
+	}
+	for i, mexp := range exp.Machines {
+		sexp.Machines[i] = &schema.MachineExplanation{
+			MachineID:   mexp.MachineID,
+			Schedulable: mexp.Schedulable,
+			Reason:      mexp.Reason,
+		}
+	}
+	return &sexp, nil
+}
+
+func explain(clust *clusterState, name string) *Explanation {
+	var j *job.Job
+	gu, global := clust.gUnits[name]
+	if global {
+		j = &job.Job{
+			Name:        gu.Name,
+			Unit:        gu.Unit,
+			TargetState: gu.TargetState,
+		}
+	} else if j = clust.jobs[name]; j == nil {
+		return nil
+	}
+
+	exp := &Explanation{
+		Name:            j.Name,
+		TargetState:     j.TargetState,
+		TargetMachineID: j.TargetMachineID,
+	}
+
+	// Consider the Job as if it was about to be (re)scheduled, so it does
+	// not count against the Machine it currently runs on.
+	clust.unschedule(j.Name)
+
+	agents := clust.agents()
+	ids := make([]string, 0, len(agents))
+	for id := range agents {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	able := make([]*agent.AgentState, 0, len(ids))
+	reasons := make(map[string]string, len(ids))
+	for _, id := range ids {
+		as := agents[id]
+		if ok, reason := as.AbleToRun(j); !ok {
+			if reason == job.JobReschedule {
+				reason = "Unit would replace a locally-scheduled Unit"
+			}
+			reasons[id] = reason
+			continue
+		}
+		able = append(able, as)
+	}
+
+	// Global Units are not placed by the engine, so SpreadBy does not
+	// apply to them.
+	if key, ok := j.SpreadBy(); ok && !global {
+		spread := make(map[string]bool)
+		for _, as := range spreadAgents(clust, j, able) {
+			spread[as.MState.ID] = true
+		}
+		for _, as := range able {
+			if spread[as.MState.ID] {
+				continue
+			}
+			if _, ok := as.MState.Metadata[key]; !ok {
+				reasons[as.MState.ID] = fmt.Sprintf("local Machine metadata lacks SpreadBy key %q", key)
+			} else {
+				reasons[as.MState.ID] = fmt.Sprintf("SpreadBy %q domain %q is not among the least populated", key, as.MState.Metadata[key])
+			}
+		}
+	}
+
+	for _, id := range ids {
+		reason, unable := reasons[id]
+		exp.Machines = append(exp.Machines, MachineExplanation{
+			MachineID:   id,
+			Schedulable: !unable,
+			Reason:      reason,
+		})
+	}
+
+	return exp
+}
@@ -0,0 +1,106 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/machine"
+	"github.com/nickswift/fleet/resource"
+)
+
+func TestExplain(t *testing.T) {
+	machines := []machine.MachineState{
+		{ID: "A", Metadata: map[string]string{"region": "us"}, TotalResources: &resource.ResourceTuple{Cores: 400, Memory: 1024}},
+		{ID: "B", Metadata: map[string]string{"region": "eu"}},
+		{ID: "C", Metadata: map[string]string{"region": "us"}},
+	}
+	sUnits := []job.ScheduledUnit{
+		{Name: "db.service", TargetMachineID: "C"},
+		{Name: "web.service", TargetMachineID: "A"},
+	}
+
+	tests := []struct {
+		unit   job.Unit
+		target string
+		// reason prefix per machine, empty if schedulable
+		want map[string]string
+	}{
+		{
+			unit: job.Unit{Name: "foo.service", Unit: newUnitWithOptions(t, "MachineMetadata=region=us", "Conflicts=db.service", "Memory=2G")},
+			want: map[string]string{
+				"A": "local Machine resources insufficient",
+				"B": "local Machine metadata insufficient: requirement region=us not satisfied",
+				"C": "found conflict with locally-scheduled Unit(db.service)",
+			},
+		},
+		{
+			unit: job.Unit{Name: "foo.service", Unit: newUnitWithOptions(t, "MachineOf=db.service")},
+			want: map[string]string{
+				"A": "required peer Unit(db.service) is not scheduled locally",
+				"B": "required peer Unit(db.service) is not scheduled locally",
+				"C": "",
+			},
+		},
+		// an already scheduled Unit does not count against its own Machine
+		{
+			unit:   job.Unit{Name: "web.service", Unit: newUnitWithOptions(t, "Memory=512M")},
+			target: "A",
+			want: map[string]string{
+				"A": "",
+				"B": "",
+				"C": "",
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		units := []job.Unit{
+			{Name: "db.service", TargetState: job.JobStateLaunched},
+			{Name: "web.service", TargetState: job.JobStateLaunched},
+		}
+		if tt.unit.Name == "web.service" {
+			units[1].Unit = tt.unit.Unit
+		} else {
+			tt.unit.TargetState = job.JobStateLaunched
+			units = append(units, tt.unit)
+		}
+
+		exp := explain(newClusterState(units, sUnits, machines), tt.unit.Name)
+		if exp == nil {
+			t.Errorf("case %d: unexpected nil Explanation", i)
+			continue
+		}
+		if exp.TargetMachineID != tt.target {
+			t.Errorf("case %d: expected target Machine %q, got %q", i, tt.target, exp.TargetMachineID)
+		}
+		if len(exp.Machines) != len(tt.want) {
+			t.Errorf("case %d: expected %d Machines, got %d", i, len(tt.want), len(exp.Machines))
+			continue
+		}
+		for _, mexp := range exp.Machines {
+			want := tt.want[mexp.MachineID]
+			if mexp.Schedulable != (want == "") || !strings.HasPrefix(mexp.Reason, want) {
+				t.Errorf("case %d: Machine(%s) got schedulable=%t reason=%q, want reason %q", i, mexp.MachineID, mexp.Schedulable, mexp.Reason, want)
+			}
+		}
+	}
+
+	if exp := explain(newClusterState(nil, nil, machines), "missing.service"); exp != nil {
+		t.Errorf("expected nil Explanation for missing Unit, got %v", exp)
+	}
+}
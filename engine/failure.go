@@ -0,0 +1,69 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nickswift/fleet/agent"
+	"github.com/nickswift/fleet/job"
+)
+
+// failure records since when a Job has been seen failing on a machine.
+type failure struct {
+	machineID string
+	since     time.Time
+}
+
+// failedTooLong determines whether a scheduled Job with RescheduleOnFailure
+// has been failing on its machine for longer than its backoff, and should be
+// moved to another machine able to run it. Failing Jobs are recorded in the
+// given map, keeping the time they were first seen failing in the previous
+// reconciliation.
+func (r *Reconciler) failedTooLong(clust *clusterState, agents map[string]*agent.AgentState, j *job.Job, failing map[string]failure, now time.Time) (string, bool) {
+	if !j.RescheduleOnFailure() {
+		return "", false
+	}
+	if us := clust.unitState(j.Name, j.TargetMachineID); us == nil || us.ActiveState != "failed" {
+		return "", false
+	}
+
+	f, ok := r.failing[j.Name]
+	if !ok || f.machineID != j.TargetMachineID {
+		f = failure{machineID: j.TargetMachineID, since: now}
+	}
+	failing[j.Name] = f
+
+	attempts, limit := len(j.FailedMachines), j.RescheduleAttempts()
+	if attempts >= limit || now.Sub(f.since) < j.RescheduleBackoff(attempts) {
+		return "", false
+	}
+
+	cand := *j
+	cand.TargetMachineID = ""
+	cand.FailedMachines = append(append([]string(nil), j.FailedMachines...), j.TargetMachineID)
+	others := make([]*agent.AgentState, 0, len(agents))
+	for id, as := range agents {
+		if id != j.TargetMachineID {
+			others = append(others, as)
+		}
+	}
+	if len(ableAgents(clust, others, &cand)) == 0 {
+		return "", false
+	}
+
+	return fmt.Sprintf("unit failed on Machine(%s), rescheduling attempt %d of %d", j.TargetMachineID, attempts+1, limit), true
+}
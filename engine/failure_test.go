@@ -0,0 +1,94 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/machine"
+	"github.com/nickswift/fleet/unit"
+)
+
+func TestRescheduleOnFailure(t *testing.T) {
+	machines := []machine.MachineState{{ID: "A"}, {ID: "B"}, {ID: "C"}}
+	uf := newUnitWithOptions(t, "RescheduleOnFailure=true", "RescheduleAttempts=2", "RescheduleBackoff=10s")
+
+	clock := clockwork.NewFakeClock()
+	r := NewReconciler(nil, nil)
+	r.clock = clock
+
+	// reconcile app.service failing on the given machine, returning the
+	// type and machine of every task
+	reconcile := func(uf unit.UnitFile, ts job.JobState, machID string, failed []string) [][2]string {
+		units := []job.Unit{{Name: "app.service", Unit: uf, TargetState: ts, FailedMachines: failed}}
+		sUnits := []job.ScheduledUnit{{Name: "app.service", TargetMachineID: machID}}
+		clust := newClusterState(units, sUnits, machines)
+		clust.setUnitStates([]*unit.UnitState{{UnitName: "app.service", ActiveState: "failed", MachineID: machID}})
+
+		var tasks [][2]string
+		for tsk := range r.calculateClusterTasks(clust, make(chan struct{})) {
+			tasks = append(tasks, [2]string{tsk.Type, tsk.MachineID})
+		}
+		return tasks
+	}
+
+	steps := []struct {
+		unit    unit.UnitFile
+		state   job.JobState
+		advance time.Duration
+		machine string
+		failed  []string
+		want    [][2]string
+	}{
+		// wait for the backoff before moving the unit
+		{uf, job.JobStateLaunched, 0, "A", nil, nil},
+		{uf, job.JobStateLaunched, 5 * time.Second, "A", nil, nil},
+		{uf, job.JobStateLaunched, 5 * time.Second, "A", nil, [][2]string{
+			{taskTypeRecordUnitFailure, "A"},
+			{taskTypeUnscheduleUnit, "A"},
+			{taskTypeAttemptScheduleUnit, "B"},
+		}},
+		// the backoff doubles, and the machines it failed on are excluded
+		{uf, job.JobStateLaunched, 0, "B", []string{"A"}, nil},
+		{uf, job.JobStateLaunched, 15 * time.Second, "B", []string{"A"}, nil},
+		{uf, job.JobStateLaunched, 5 * time.Second, "B", []string{"A"}, [][2]string{
+			{taskTypeRecordUnitFailure, "B"},
+			{taskTypeUnscheduleUnit, "B"},
+			{taskTypeAttemptScheduleUnit, "C"},
+		}},
+		// no attempts left
+		{uf, job.JobStateLaunched, 0, "C", []string{"A", "B"}, nil},
+		{uf, job.JobStateLaunched, time.Hour, "C", []string{"A", "B"}, nil},
+		// failures are forgotten once the unit is stopped
+		{uf, job.JobStateInactive, 0, "", []string{"A", "B"}, [][2]string{
+			{taskTypeClearUnitFailures, ""},
+		}},
+		// units without RescheduleOnFailure stay where they are
+		{newUnitWithOptions(t), job.JobStateLaunched, 0, "A", nil, nil},
+		{newUnitWithOptions(t), job.JobStateLaunched, time.Hour, "A", nil, nil},
+	}
+
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		if got := reconcile(step.unit, step.state, step.machine, step.failed); !reflect.DeepEqual(step.want, got) {
+			t.Errorf("step %d: expected tasks %v, got %v", i, step.want, got)
+		}
+	}
+}
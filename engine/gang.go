@@ -0,0 +1,214 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nickswift/fleet/agent"
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/log"
+	"github.com/nickswift/fleet/metrics"
+)
+
+// machineOfGroups finds the groups of unscheduled Jobs bound to each other
+// through MachineOf, which must be scheduled to the same machine. The
+// returned map holds the members of every group of more than one Job under
+// each member's name, ordered so that every member comes after the peers
+// it is bound to.
+func machineOfGroups(clust *clusterState) map[string][]*job.Job {
+	pending := make(map[string]*job.Job)
+	for name, j := range clust.jobs {
+		if !j.Scheduled() && j.TargetState != job.JobStateInactive {
+			pending[name] = j
+		}
+	}
+
+	// union-find over the MachineOf relations between pending Jobs
+	parent := make(map[string]string, len(pending))
+	var find func(string) string
+	find = func(name string) string {
+		if p, ok := parent[name]; ok && p != name {
+			root := find(p)
+			parent[name] = root
+			return root
+		}
+		return name
+	}
+	for name, j := range pending {
+		for _, peer := range j.Peers() {
+			if _, ok := pending[peer]; ok {
+				parent[find(name)] = find(peer)
+			}
+		}
+	}
+
+	members := make(map[string][]*job.Job)
+	for name, j := range pending {
+		root := find(name)
+		members[root] = append(members[root], j)
+	}
+
+	groups := make(map[string][]*job.Job)
+	for _, group := range members {
+		if len(group) < 2 {
+			continue
+		}
+		group = orderByPeers(group)
+		for _, j := range group {
+			groups[j.Name] = group
+		}
+	}
+	return groups
+}
+
+// orderByPeers orders the members of a MachineOf group so that every member
+// comes after the members it is bound to. Members bound to each other in a
+// cycle are appended last; such a group can never be scheduled.
+func orderByPeers(group []*job.Job) []*job.Job {
+	sort.Sort(sortableJobsByName(group))
+
+	inGroup := make(map[string]bool, len(group))
+	for _, j := range group {
+		inGroup[j.Name] = true
+	}
+
+	ordered := make([]*job.Job, 0, len(group))
+	placed := make(map[string]bool, len(group))
+	for len(ordered) < len(group) {
+		progress := false
+		for _, j := range group {
+			if placed[j.Name] {
+				continue
+			}
+			ready := true
+			for _, peer := range j.Peers() {
+				if inGroup[peer] && !placed[peer] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered = append(ordered, j)
+				placed[j.Name] = true
+				progress = true
+			}
+		}
+		if !progress {
+			for _, j := range group {
+				if !placed[j.Name] {
+					ordered = append(ordered, j)
+					placed[j.Name] = true
+				}
+			}
+		}
+	}
+	return ordered
+}
+
+// decideGroup asks the Scheduler for a machine for the first member of a
+// MachineOf group, skipping machines which are unable to run every other
+// member as well. Returns an error if no machine can run the whole group.
+func (r *Reconciler) decideGroup(clust *clusterState, group []*job.Job) (*decision, error) {
+	agents := clust.agents()
+	excluded := make(map[string]bool)
+	var reasons []string
+	for len(excluded) < len(clust.machines) {
+		dec, err := decidePlacement(r.sched, clust.without(excluded), group[0])
+		if err != nil {
+			break
+		}
+
+		ok, reason := groupFits(agents[dec.machineID], group, dec.evict)
+		if ok {
+			return dec, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("Machine(%s): %s", dec.machineID, reason))
+		excluded[dec.machineID] = true
+	}
+
+	if len(reasons) == 0 {
+		return nil, fmt.Errorf("no agents able to run Unit(%s)", group[0].Name)
+	}
+	return nil, fmt.Errorf("no agents able to run all members: %s", strings.Join(reasons, "; "))
+}
+
+// groupFits determines whether the agent is able to run every member of a
+// MachineOf group once the given Jobs have been evicted from it.
+func groupFits(as *agent.AgentState, group []*job.Job, evict []string) (bool, string) {
+	trial := agent.NewAgentState(as.MState)
+	for name, u := range as.Units {
+		trial.Units[name] = u
+	}
+	for _, name := range evict {
+		delete(trial.Units, name)
+	}
+
+	for _, j := range group {
+		if ok, reason := trial.AbleToRun(j); !ok {
+			return false, fmt.Sprintf("unable to run Unit(%s): %s", j.Name, reason)
+		}
+		trial.Units[j.Name] = &job.Unit{
+			Name:        j.Name,
+			Unit:        j.Unit,
+			TargetState: j.TargetState,
+		}
+	}
+	return true, ""
+}
+
+// scheduleGroup schedules all members of a MachineOf group to the same
+// machine, or none of them. It returns false if sending a task failed.
+func (r *Reconciler) scheduleGroup(clust *clusterState, group []*job.Job, send func(typ, reason, jName, machID string) bool) bool {
+	names := make([]string, 0, len(group))
+	for _, j := range group {
+		names = append(names, j.Name)
+	}
+
+	dec, err := r.decideGroup(clust, group)
+	if err != nil {
+		log.Infof("Unable to schedule MachineOf group (%s): %v", strings.Join(names, ", "), err)
+		metrics.ReportEngineReconcileFailure(metrics.ScheduleFailure)
+		return true
+	}
+	log.Infof("Scheduling MachineOf group (%s) to Machine(%s)", strings.Join(names, ", "), dec.machineID)
+
+	for _, name := range dec.evict {
+		reason := fmt.Sprintf("preempted by higher-priority Unit(%s)", group[0].Name)
+		if !send(taskTypeUnscheduleUnit, reason, name, dec.machineID) {
+			return false
+		}
+		clust.unschedule(name)
+	}
+
+	for _, j := range group {
+		reason := fmt.Sprintf("target state %s and unit not scheduled, placing MachineOf group (%s) together", j.TargetState, strings.Join(names, ", "))
+		if !send(taskTypeAttemptScheduleUnit, reason, j.Name, dec.machineID) {
+			metrics.ReportEngineReconcileFailure(metrics.ScheduleFailure)
+			return false
+		}
+		clust.schedule(j.Name, dec.machineID)
+	}
+	return true
+}
+
+// sortableJobsByName orders Jobs by name.
+type sortableJobsByName []*job.Job
+
+func (sj sortableJobsByName) Len() int           { return len(sj) }
+func (sj sortableJobsByName) Swap(i, j int)      { sj[i], sj[j] = sj[j], sj[i] }
+func (sj sortableJobsByName) Less(i, j int) bool { return sj[i].Name < sj[j].Name }
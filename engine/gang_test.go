@@ -0,0 +1,112 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/machine"
+	"github.com/nickswift/fleet/resource"
+	"github.com/nickswift/fleet/unit"
+)
+
+func TestMachineOfGroupScheduling(t *testing.T) {
+	type unitSpec struct {
+		name    string
+		machine string
+		unit    unit.UnitFile
+	}
+
+	// room for exactly 2G of units on each machine
+	capacity := resource.Sum(resource.HostResources, resource.ResourceTuple{Cores: 400, Memory: 2048})
+	machines := []machine.MachineState{
+		{ID: "A", TotalResources: &capacity},
+		{ID: "B", TotalResources: &capacity},
+	}
+
+	tests := []struct {
+		units []unitSpec
+		// pairs of unit name and machine
+		want [][2]string
+	}{
+		// the least loaded machine fits the leader but not the follower
+		{
+			units: []unitSpec{
+				{"a.service", "A", newUnitWithOptions(t, "Memory=1536M")},
+				{"b1.service", "B", newUnitWithOptions(t, "Memory=256M")},
+				{"b2.service", "B", newUnitWithOptions(t, "Memory=256M")},
+				{"leader.service", "", newUnitWithOptions(t, "Memory=512M")},
+				{"follower.service", "", newUnitWithOptions(t, "Memory=512M", "MachineOf=leader.service")},
+			},
+			want: [][2]string{{"leader.service", "B"}, {"follower.service", "B"}},
+		},
+		// members are scheduled after the peers they are bound to
+		{
+			units: []unitSpec{
+				{"c.service", "", newUnitWithOptions(t, "MachineOf=b.service")},
+				{"b.service", "", newUnitWithOptions(t, "MachineOf=a.service")},
+				{"a.service", "", newUnitWithOptions(t)},
+			},
+			want: [][2]string{{"a.service", "A"}, {"b.service", "A"}, {"c.service", "A"}},
+		},
+		// no machine fits the whole group, so none of it is scheduled
+		{
+			units: []unitSpec{
+				{"leader.service", "", newUnitWithOptions(t, "Memory=1G")},
+				{"follower.service", "", newUnitWithOptions(t, "Memory=1536M", "MachineOf=leader.service")},
+			},
+			want: nil,
+		},
+		// members bound to each other in a cycle are never scheduled
+		{
+			units: []unitSpec{
+				{"a.service", "", newUnitWithOptions(t, "MachineOf=b.service")},
+				{"b.service", "", newUnitWithOptions(t, "MachineOf=a.service")},
+			},
+			want: nil,
+		},
+		// followers of a scheduled unit are scheduled one by one
+		{
+			units: []unitSpec{
+				{"leader.service", "B", newUnitWithOptions(t)},
+				{"follower.service", "", newUnitWithOptions(t, "MachineOf=leader.service")},
+			},
+			want: [][2]string{{"follower.service", "B"}},
+		},
+	}
+
+	for i, tt := range tests {
+		var units []job.Unit
+		var sUnits []job.ScheduledUnit
+		for _, us := range tt.units {
+			units = append(units, job.Unit{Name: us.name, Unit: us.unit, TargetState: job.JobStateLaunched})
+			sUnits = append(sUnits, job.ScheduledUnit{Name: us.name, TargetMachineID: us.machine})
+		}
+
+		r := NewReconciler(nil, nil)
+		var got [][2]string
+		for tsk := range r.calculateClusterTasks(newClusterState(units, sUnits, machines), make(chan struct{})) {
+			if tsk.Type == taskTypeAttemptScheduleUnit {
+				got = append(got, [2]string{tsk.JobName, tsk.MachineID})
+			}
+		}
+
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("case %d: expected decisions %v, got %v", i, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"sort"
+
+	"github.com/nickswift/fleet/agent"
+	"github.com/nickswift/fleet/job"
+)
+
+// decidePlacement asks the Scheduler for a machine for the Job. If no agent
+// is able to run it, whatever the strategy, lower-priority units are
+// preempted to make room for it where possible.
+func decidePlacement(sched Scheduler, clust *clusterState, j *job.Job) (*decision, error) {
+	dec, err := sched.Decide(clust, j)
+	if err == nil {
+		return dec, nil
+	}
+
+	agents := make(sortableAgentStates, 0, len(clust.machines))
+	for _, as := range clust.agents() {
+		agents = append(agents, as)
+	}
+	sort.Sort(agents)
+
+	if dec := preempt(clust, agents, j); dec != nil {
+		return dec, nil
+	}
+	return nil, err
+}
+
+// preempt finds an agent on which the Job could run if some lower-priority
+// units scheduled there were evicted, honouring the Job's spread
+// requirements. Agents are considered in the given order and the one
+// requiring the fewest evictions wins. Global units and units another unit
+// is bound to via MachineOf are never evicted. Returns nil if no such agent
+// exists.
+func preempt(clust *clusterState, agents []*agent.AgentState, j *job.Job) *decision {
+	prio := j.Priority()
+
+	peered := make(map[string]bool)
+	for _, cj := range clust.jobs {
+		for _, peer := range cj.Peers() {
+			peered[peer] = true
+		}
+	}
+
+	var best *decision
+	for _, as := range agents {
+		var victims []*job.Job
+		for name := range as.Units {
+			cj, ok := clust.jobs[name]
+			if !ok || peered[name] || cj.Priority() >= prio {
+				continue
+			}
+			victims = append(victims, cj)
+		}
+		if len(victims) == 0 {
+			continue
+		}
+		sort.Sort(sortableJobsByPriority(victims))
+
+		evict := evictionSet(as, j, victims)
+		if len(evict) == 0 || !spreadAllows(clust, j, as, evict) {
+			continue
+		}
+		if best == nil || len(evict) < len(best.evict) {
+			best = &decision{machineID: as.MState.ID, evict: evict}
+		}
+	}
+
+	return best
+}
+
+// evictionSet returns the names of the victims which must be evicted from
+// the agent for the Job to run there. Victims are evicted in the given order
+// until the Job fits, then put back again wherever the Job still fits
+// without them. Returns nil if evicting all victims is not enough, or if the
+// Job is able to run without evicting anything.
+func evictionSet(as *agent.AgentState, j *job.Job, victims []*job.Job) []string {
+	trial := agent.NewAgentState(as.MState)
+	for name, u := range as.Units {
+		trial.Units[name] = u
+	}
+
+	var removed int
+	for ; removed < len(victims); removed++ {
+		if ok, _ := trial.AbleToRun(j); ok {
+			break
+		}
+		delete(trial.Units, victims[removed].Name)
+	}
+	if removed == 0 {
+		return nil
+	}
+	if ok, _ := trial.AbleToRun(j); !ok {
+		return nil
+	}
+
+	var evict []string
+	for i := removed - 1; i >= 0; i-- {
+		name := victims[i].Name
+		trial.Units[name] = as.Units[name]
+		if ok, _ := trial.AbleToRun(j); !ok {
+			delete(trial.Units, name)
+			evict = append(evict, name)
+		}
+	}
+	sort.Strings(evict)
+
+	return evict
+}
+
+// spreadAllows determines whether the Job's spread requirements allow it to
+// run on the agent once the given Jobs have been evicted from it.
+func spreadAllows(clust *clusterState, j *job.Job, as *agent.AgentState, evict []string) bool {
+	trial := &clusterState{
+		jobs:     make(map[string]*job.Job, len(clust.jobs)),
+		machines: clust.machines,
+	}
+	for name, cj := range clust.jobs {
+		trial.jobs[name] = cj
+	}
+	for _, name := range evict {
+		evicted := *clust.jobs[name]
+		evicted.TargetMachineID = ""
+		trial.jobs[name] = &evicted
+	}
+	return len(spreadAgents(trial, j, []*agent.AgentState{as})) != 0
+}
+
+// prioritizedJobs returns all Jobs in the cluster sorted by descending
+// priority, so that high-priority Jobs are scheduled first.
+func prioritizedJobs(clust *clusterState) []*job.Job {
+	jobs := make([]*job.Job, 0, len(clust.jobs))
+	for _, j := range clust.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Sort(sortableJobsByPriorityDesc(jobs))
+	return jobs
+}
+
+// sortableJobsByPriority orders Jobs by ascending priority, then by name.
+type sortableJobsByPriority []*job.Job
+
+func (sj sortableJobsByPriority) Len() int      { return len(sj) }
+func (sj sortableJobsByPriority) Swap(i, j int) { sj[i], sj[j] = sj[j], sj[i] }
+
+func (sj sortableJobsByPriority) Less(i, j int) bool {
+	pi, pj := sj[i].Priority(), sj[j].Priority()
+	return pi < pj || (pi == pj && sj[i].Name < sj[j].Name)
+}
+
+// sortableJobsByPriorityDesc orders Jobs by descending priority, then by name.
+type sortableJobsByPriorityDesc []*job.Job
+
+func (sj sortableJobsByPriorityDesc) Len() int      { return len(sj) }
+func (sj sortableJobsByPriorityDesc) Swap(i, j int) { sj[i], sj[j] = sj[j], sj[i] }
+
+func (sj sortableJobsByPriorityDesc) Less(i, j int) bool {
+	pi, pj := sj[i].Priority(), sj[j].Priority()
+	return pi > pj || (pi == pj && sj[i].Name < sj[j].Name)
+}
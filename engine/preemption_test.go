@@ -0,0 +1,159 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/machine"
+	"github.com/nickswift/fleet/resource"
+	"github.com/nickswift/fleet/unit"
+)
+
+func TestPreemption(t *testing.T) {
+	type unitSpec struct {
+		name    string
+		machine string
+		unit    unit.UnitFile
+	}
+
+	// room for exactly 2G of units on each machine
+	capacity := resource.Sum(resource.HostResources, resource.ResourceTuple{Cores: 400, Memory: 2048})
+	high := newUnitWithOptions(t, "Memory=2G", "Priority=10")
+
+	tests := []struct {
+		units    []unitSpec
+		cordoned bool
+		// type, unit name, machine and reason of each task
+		want [][4]string
+	}{
+		// units with the same priority are never preempted
+		{
+			units: []unitSpec{
+				{"a.service", "A", newUnitWithOptions(t, "Memory=2G", "Priority=10")},
+				{"b.service", "B", newUnitWithOptions(t, "Memory=2G", "Priority=10")},
+				{"high.service", "", high},
+			},
+			want: nil,
+		},
+		// evict the lowest-priority unit which makes room
+		{
+			units: []unitSpec{
+				{"a1.service", "A", newUnitWithOptions(t, "Memory=1G", "Priority=5")},
+				{"a2.service", "A", newUnitWithOptions(t, "Memory=1G")},
+				{"b.service", "B", newUnitWithOptions(t, "Memory=2G", "Priority=8")},
+				{"mid.service", "", newUnitWithOptions(t, "Memory=1G", "Priority=8")},
+			},
+			want: [][4]string{
+				{taskTypeUnscheduleUnit, "a2.service", "A", "preempted by higher-priority Unit(mid.service)"},
+				{taskTypeAttemptScheduleUnit, "mid.service", "A", "target state launched and unit not scheduled"},
+			},
+		},
+		// preempted units may in turn preempt units of even lower priority
+		{
+			units: []unitSpec{
+				{"mid.service", "A", newUnitWithOptions(t, "Memory=2G", "Priority=5")},
+				{"low.service", "B", newUnitWithOptions(t, "Memory=2G")},
+				{"high.service", "", high},
+			},
+			want: [][4]string{
+				{taskTypeUnscheduleUnit, "mid.service", "A", "preempted by higher-priority Unit(high.service)"},
+				{taskTypeAttemptScheduleUnit, "high.service", "A", "target state launched and unit not scheduled"},
+				{taskTypeUnscheduleUnit, "low.service", "B", "preempted by higher-priority Unit(mid.service)"},
+				{taskTypeAttemptScheduleUnit, "mid.service", "B", "target state launched and unit not scheduled"},
+			},
+		},
+		// units other units are bound to are never preempted
+		{
+			units: []unitSpec{
+				{"db.service", "A", newUnitWithOptions(t, "Memory=2G")},
+				{"app.service", "A", newUnitWithOptions(t, "MachineOf=db.service")},
+				{"b.service", "B", newUnitWithOptions(t, "Memory=2G", "Priority=10")},
+				{"high.service", "", high},
+			},
+			want: nil,
+		},
+		// units are not preempted where the spread of the unit forbids it
+		{
+			units: []unitSpec{
+				{"web@1.service", "A", newUnitWithOptions(t, "Priority=10")},
+				{"low.service", "A", newUnitWithOptions(t, "Memory=2G")},
+				{"b.service", "B", newUnitWithOptions(t, "Memory=2G", "Priority=10")},
+				{"web@2.service", "", newUnitWithOptions(t, "Memory=2G", "Priority=10", "SpreadBy=zone", "MaxSkew=1")},
+			},
+			want: nil,
+		},
+		// nor on cordoned machines
+		{
+			units: []unitSpec{
+				{"low.service", "A", newUnitWithOptions(t, "Memory=2G")},
+				{"b.service", "B", newUnitWithOptions(t, "Memory=2G", "Priority=10")},
+				{"high.service", "", high},
+			},
+			cordoned: true,
+			want:     nil,
+		},
+	}
+
+	for _, strategy := range SchedulerStrategies() {
+		for i, tt := range tests {
+			machines := []machine.MachineState{
+				{ID: "A", TotalResources: &capacity, Metadata: map[string]string{"zone": "a"}, Cordoned: tt.cordoned},
+				{ID: "B", TotalResources: &capacity, Metadata: map[string]string{"zone": "b"}},
+			}
+			var units []job.Unit
+			var sUnits []job.ScheduledUnit
+			for _, us := range tt.units {
+				units = append(units, job.Unit{Name: us.name, Unit: us.unit, TargetState: job.JobStateLaunched})
+				sUnits = append(sUnits, job.ScheduledUnit{Name: us.name, TargetMachineID: us.machine})
+			}
+
+			sched, err := NewScheduler(strategy)
+			if err != nil {
+				t.Fatalf("unexpected error creating %s scheduler: %v", strategy, err)
+			}
+			r := NewReconciler(sched, nil)
+			var got [][4]string
+			for tsk := range r.calculateClusterTasks(newClusterState(units, sUnits, machines), make(chan struct{})) {
+				got = append(got, [4]string{tsk.Type, tsk.JobName, tsk.MachineID, tsk.Reason})
+			}
+
+			if !reflect.DeepEqual(tt.want, got) {
+				t.Errorf("%s case %d: expected tasks %v, got %v", strategy, i, tt.want, got)
+			}
+		}
+	}
+}
+
+func TestPrioritizedJobs(t *testing.T) {
+	units := []job.Unit{
+		{Name: "b.service", Unit: newUnitWithOptions(t)},
+		{Name: "a.service", Unit: newUnitWithOptions(t)},
+		{Name: "c.service", Unit: newUnitWithOptions(t, "Priority=-1")},
+		{Name: "d.service", Unit: newUnitWithOptions(t, "Priority=3")},
+	}
+	clust := newClusterState(units, nil, nil)
+
+	var got []string
+	for _, j := range prioritizedJobs(clust) {
+		got = append(got, j.Name)
+	}
+	want := []string{"d.service", "a.service", "b.service", "c.service"}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/nickswift/fleet/agent"
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/resource"
+)
+
+// DefaultRebalanceMaxMoves is the number of units moved per reconcile round
+// if a Rebalancer does not set MaxMoves.
+const DefaultRebalanceMaxMoves = 1
+
+// Rebalancer moves scheduled units from the most to the least loaded
+// Machines when the difference between them grows too large, e.g. after a
+// new Machine joined the cluster. Units that are Pinned, bound to a specific
+// Machine through MachineID or MachineOf, or the target of another unit's
+// MachineOf are never moved. Cordoned Machines never receive units, and if
+// the least loaded Machine is unable to run any of the units, the next least
+// loaded one is tried.
+type Rebalancer struct {
+	// Threshold is the difference in the number of units between the most
+	// and least loaded Machines above which units are moved. Zero disables
+	// rebalancing by unit count.
+	Threshold int
+	// ResourceThreshold is the difference in resource utilization, in
+	// percent, between the most and least utilized Machines above which units
+	// are moved. Only Machines advertising their resources are considered.
+	// Zero disables rebalancing by resource use.
+	ResourceThreshold int
+	// MaxMoves caps the number of units moved in a single reconcile round.
+	MaxMoves int
+}
+
+// move describes the rescheduling of a Job from one Machine to another.
+type move struct {
+	jobName string
+	from    string
+	to      string
+	reason  string
+}
+
+func (rb *Rebalancer) maxMoves() int {
+	if rb.MaxMoves < 1 {
+		return DefaultRebalanceMaxMoves
+	}
+	return rb.MaxMoves
+}
+
+// nextMove determines the next Job to move in order to rebalance the
+// cluster, or nil if the cluster is balanced or no Job can be moved.
+func (rb *Rebalancer) nextMove(clust *clusterState) *move {
+	agents := clust.agents()
+
+	ids := make([]string, 0, len(agents))
+	for id := range agents {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// cordoned Machines do not accept new units, so they are only ever
+	// the source of a move
+	var open []string
+	for _, id := range ids {
+		if !agents[id].MState.Cordoned {
+			open = append(open, id)
+		}
+	}
+
+	if rb.Threshold > 0 {
+		counts := make(map[string]int, len(ids))
+		for _, j := range clust.jobs {
+			if j.Scheduled() && j.TargetState != job.JobStateInactive {
+				counts[j.TargetMachineID]++
+			}
+		}
+
+		src, dsts := candidates(ids, open, counts, rb.Threshold)
+		for _, dst := range dsts {
+			accept := func(*job.Job) bool { return true }
+			if name := rb.pick(clust, agents, src, dst, accept); name != "" {
+				reason := fmt.Sprintf("rebalancing: Machine(%s) runs %d units, Machine(%s) runs %d", src, counts[src], dst, counts[dst])
+				return &move{jobName: name, from: src, to: dst, reason: reason}
+			}
+		}
+	}
+
+	if rb.ResourceThreshold > 0 {
+		utils := make(map[string]int, len(ids))
+		var rids, ropen []string
+		for _, id := range ids {
+			if total := agents[id].MState.TotalResources; total != nil && !total.Empty() {
+				utils[id] = utilization(*total, resource.Sub(*total, agents[id].AvailableResources("")))
+				rids = append(rids, id)
+				if !agents[id].MState.Cordoned {
+					ropen = append(ropen, id)
+				}
+			}
+		}
+
+		src, dsts := candidates(rids, ropen, utils, rb.ResourceThreshold)
+		for _, dst := range dsts {
+			as := agents[dst]
+			used := resource.Sub(*as.MState.TotalResources, as.AvailableResources(""))
+			accept := func(j *job.Job) bool {
+				want := j.Resources()
+				if want.Empty() {
+					return false
+				}
+				// the move must not leave the destination more utilized
+				// than the source was
+				return utilization(*as.MState.TotalResources, resource.Sum(used, want)) < utils[src]
+			}
+			if name := rb.pick(clust, agents, src, dst, accept); name != "" {
+				reason := fmt.Sprintf("rebalancing: Machine(%s) is %d%% utilized, Machine(%s) is %d%%", src, utils[src], dst, utils[dst])
+				return &move{jobName: name, from: src, to: dst, reason: reason}
+			}
+		}
+	}
+
+	return nil
+}
+
+// pick returns the name of the first movable Job, in name order, scheduled
+// to the src Machine that the dst Machine is able to run and that is
+// accepted by the given function. An empty string is returned if there is
+// no such Job.
+func (rb *Rebalancer) pick(clust *clusterState, agents map[string]*agent.AgentState, src, dst string, accept func(*job.Job) bool) string {
+	peered := make(map[string]bool)
+	var names []string
+	for name, j := range clust.jobs {
+		for _, peer := range j.Peers() {
+			peered[peer] = true
+		}
+		if j.TargetMachineID == src && j.TargetState != job.JobStateInactive {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		j := clust.jobs[name]
+		if j.Pinned() || peered[name] || len(j.Peers()) > 0 {
+			continue
+		}
+		if _, ok := j.RequiredTarget(); ok {
+			continue
+		}
+		if !accept(j) {
+			continue
+		}
+		as := agents[dst]
+		if ok, _ := as.AbleToRun(j); !ok {
+			continue
+		}
+		if len(spreadAgents(clust, j, []*agent.AgentState{as})) == 0 {
+			continue
+		}
+		return name
+	}
+
+	return ""
+}
+
+// candidates returns the ID with the highest value, preferring the first ID
+// in the given order on ties, along with the destinations whose value is
+// lower by more than the threshold. The destinations are ordered by value,
+// lowest first, and otherwise keep the given order.
+func candidates(ids, dsts []string, values map[string]int, threshold int) (src string, lower []string) {
+	if len(ids) < 2 {
+		return "", nil
+	}
+
+	src = ids[0]
+	for _, id := range ids[1:] {
+		if values[id] > values[src] {
+			src = id
+		}
+	}
+
+	for _, id := range dsts {
+		if values[src]-values[id] > threshold {
+			lower = append(lower, id)
+		}
+	}
+	sort.Stable(idsByValue{lower, values})
+
+	return src, lower
+}
+
+// idsByValue orders IDs by their value, lowest first.
+type idsByValue struct {
+	ids    []string
+	values map[string]int
+}
+
+func (bv idsByValue) Len() int           { return len(bv.ids) }
+func (bv idsByValue) Swap(i, j int)      { bv.ids[i], bv.ids[j] = bv.ids[j], bv.ids[i] }
+func (bv idsByValue) Less(i, j int) bool { return bv.values[bv.ids[i]] < bv.values[bv.ids[j]] }
+
+// utilization returns the highest utilization, in percent, of any of the
+// resources of the given total.
+func utilization(total, used resource.ResourceTuple) int {
+	pct := 0
+	for _, dim := range [][2]int{
+		{used.Cores, total.Cores},
+		{used.Memory, total.Memory},
+		{used.Disk, total.Disk},
+	} {
+		if dim[1] <= 0 {
+			continue
+		}
+		if p := dim[0] * 100 / dim[1]; p > pct {
+			pct = p
+		}
+	}
+	return pct
+}
@@ -0,0 +1,180 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/machine"
+	"github.com/nickswift/fleet/resource"
+	"github.com/nickswift/fleet/unit"
+)
+
+func TestRebalancer(t *testing.T) {
+	type unitSpec struct {
+		name    string
+		machine string
+		unit    unit.UnitFile
+	}
+
+	plain := newUnitWithOptions(t)
+	countMachines := []machine.MachineState{{ID: "A"}, {ID: "B"}, {ID: "C"}}
+	loadedA := []unitSpec{
+		{"a1.service", "A", plain},
+		{"a2.service", "A", plain},
+		{"a3.service", "A", plain},
+		{"a4.service", "A", plain},
+		{"b1.service", "B", plain},
+	}
+
+	tests := []struct {
+		rebal    Rebalancer
+		machines []machine.MachineState
+		units    []unitSpec
+		// pairs of unit name and destination machine
+		want [][2]string
+	}{
+		// balanced enough
+		{
+			rebal:    Rebalancer{Threshold: 4, MaxMoves: 5},
+			machines: countMachines,
+			units:    loadedA,
+			want:     nil,
+		},
+		// move until the threshold is no longer exceeded
+		{
+			rebal:    Rebalancer{Threshold: 2, MaxMoves: 5},
+			machines: countMachines,
+			units:    loadedA,
+			want:     [][2]string{{"a1.service", "C"}},
+		},
+		// capped number of moves
+		{
+			rebal:    Rebalancer{Threshold: 1, MaxMoves: 1},
+			machines: countMachines,
+			units:    loadedA,
+			want:     [][2]string{{"a1.service", "C"}},
+		},
+		{
+			rebal:    Rebalancer{Threshold: 1, MaxMoves: 5},
+			machines: countMachines,
+			units:    loadedA,
+			want:     [][2]string{{"a1.service", "C"}, {"a2.service", "B"}},
+		},
+		// pinned and bound units are never moved
+		{
+			rebal:    Rebalancer{Threshold: 1, MaxMoves: 1},
+			machines: countMachines,
+			units: []unitSpec{
+				{"a1.service", "A", newUnitWithOptions(t, "Pinned=true")},
+				{"a2.service", "A", newUnitWithOptions(t, "MachineID=A")},
+				{"a3.service", "A", newUnitWithOptions(t, "MachineOf=a4.service")},
+				{"a4.service", "A", plain},
+				{"a5.service", "A", plain},
+			},
+			want: [][2]string{{"a5.service", "B"}},
+		},
+		// units are only moved to machines able to run them
+		{
+			rebal:    Rebalancer{Threshold: 1, MaxMoves: 1},
+			machines: []machine.MachineState{{ID: "A"}, {ID: "B", Metadata: map[string]string{"disk": "ssd"}}},
+			units: []unitSpec{
+				{"a1.service", "A", newUnitWithOptions(t, "MachineMetadata=disk=hdd")},
+				{"a2.service", "A", plain},
+				{"a3.service", "A", plain},
+			},
+			want: [][2]string{{"a2.service", "B"}},
+		},
+		// cordoned machines never receive units
+		{
+			rebal:    Rebalancer{Threshold: 1, MaxMoves: 1},
+			machines: []machine.MachineState{{ID: "A"}, {ID: "B"}, {ID: "C", Cordoned: true}},
+			units:    loadedA,
+			want:     [][2]string{{"a1.service", "B"}},
+		},
+		// the next least loaded machine is tried if the least loaded one
+		// is unable to run any of the units
+		{
+			rebal:    Rebalancer{Threshold: 1, MaxMoves: 1},
+			machines: []machine.MachineState{{ID: "A"}, {ID: "B"}, {ID: "C", Taints: map[string]string{"dedicated": "db"}}},
+			units:    loadedA,
+			want:     [][2]string{{"a1.service", "B"}},
+		},
+		// rebalance by resource utilization
+		{
+			rebal: Rebalancer{ResourceThreshold: 20, MaxMoves: 5},
+			machines: []machine.MachineState{
+				{ID: "A", TotalResources: &resource.ResourceTuple{Cores: 400, Memory: 2048}},
+				{ID: "B", TotalResources: &resource.ResourceTuple{Cores: 400, Memory: 2048}},
+			},
+			units: []unitSpec{
+				{"m1.service", "A", newUnitWithOptions(t, "Memory=512M")},
+				{"m2.service", "A", newUnitWithOptions(t, "Memory=512M")},
+				{"m3.service", "A", plain},
+			},
+			want: [][2]string{{"m1.service", "B"}},
+		},
+		// a cordoned machine is not a destination for rebalancing by
+		// resource utilization either
+		{
+			rebal: Rebalancer{ResourceThreshold: 20, MaxMoves: 5},
+			machines: []machine.MachineState{
+				{ID: "A", TotalResources: &resource.ResourceTuple{Cores: 400, Memory: 2048}},
+				{ID: "B", TotalResources: &resource.ResourceTuple{Cores: 400, Memory: 2048}, Cordoned: true},
+			},
+			units: []unitSpec{
+				{"m1.service", "A", newUnitWithOptions(t, "Memory=512M")},
+				{"m2.service", "A", newUnitWithOptions(t, "Memory=512M")},
+			},
+			want: nil,
+		},
+		// moving would just swap the utilization
+		{
+			rebal: Rebalancer{ResourceThreshold: 20, MaxMoves: 5},
+			machines: []machine.MachineState{
+				{ID: "A", TotalResources: &resource.ResourceTuple{Cores: 400, Memory: 2048}},
+				{ID: "B", TotalResources: &resource.ResourceTuple{Cores: 400, Memory: 2048}},
+			},
+			units: []unitSpec{
+				{"m1.service", "A", newUnitWithOptions(t, "Memory=1G")},
+			},
+			want: nil,
+		},
+	}
+
+	for i, tt := range tests {
+		var units []job.Unit
+		var sUnits []job.ScheduledUnit
+		for _, us := range tt.units {
+			units = append(units, job.Unit{Name: us.name, Unit: us.unit, TargetState: job.JobStateLaunched})
+			sUnits = append(sUnits, job.ScheduledUnit{Name: us.name, TargetMachineID: us.machine})
+		}
+
+		rebal := tt.rebal
+		r := NewReconciler(nil, &rebal)
+		var got [][2]string
+		for tsk := range r.calculateClusterTasks(newClusterState(units, sUnits, tt.machines), make(chan struct{})) {
+			if tsk.Type == taskTypeAttemptScheduleUnit {
+				got = append(got, [2]string{tsk.JobName, tsk.MachineID})
+			}
+		}
+
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("case %d: expected moves %v, got %v", i, tt.want, got)
+		}
+	}
+}
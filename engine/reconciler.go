@@ -18,6 +18,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/jonboulle/clockwork"
+
 	"github.com/nickswift/fleet/job"
 	"github.com/nickswift/fleet/log"
 	"github.com/nickswift/fleet/metrics"
@@ -26,6 +28,13 @@ import (
 const (
 	taskTypeUnscheduleUnit      = "UnscheduleUnit"
 	taskTypeAttemptScheduleUnit = "AttemptScheduleUnit"
+	taskTypeCreateUnit          = "CreateUnit"
+	taskTypeDestroyUnit         = "DestroyUnit"
+	taskTypeHoldUnit            = "HoldUnit"
+	taskTypeReleaseUnit         = "ReleaseUnit"
+	taskTypeRecordUnitFailure   = "RecordUnitFailure"
+	taskTypeClearUnitFailures   = "ClearUnitFailures"
+	taskTypeAdmitGlobalUnit     = "AdmitGlobalUnit"
 )
 
 type task struct {
@@ -39,14 +48,33 @@ func (t *task) String() string {
 	return fmt.Sprintf("{Type: %s, JobName: %s, MachineID: %s, Reason: %q}", t.Type, t.JobName, t.MachineID, t.Reason)
 }
 
-func NewReconciler() *Reconciler {
+// NewReconciler creates a Reconciler placing units with the given Scheduler,
+// or the least-loaded Scheduler if nil. If rebal is non-nil, the Reconciler
+// also moves units between machines to keep the cluster balanced.
+func NewReconciler(sched Scheduler, rebal *Rebalancer) *Reconciler {
+	if sched == nil {
+		sched = &leastLoadedScheduler{}
+	}
 	return &Reconciler{
-		sched: &leastLoadedScheduler{},
+		sched:   sched,
+		rebal:   rebal,
+		clock:   clockwork.NewRealClock(),
+		failing: make(map[string]failure),
+
+		unitFileCandidates: make(map[string]unitFileCandidate),
 	}
 }
 
 type Reconciler struct {
 	sched Scheduler
+	rebal *Rebalancer
+	clock clockwork.Clock
+	// units with RescheduleOnFailure seen failing on their machine
+	failing map[string]failure
+	// unit files seen unreferenced by any Unit, and when they were last
+	// collected
+	unitFileCandidates map[string]unitFileCandidate
+	lastUnitFileGC     time.Time
 }
 
 func (r *Reconciler) Reconcile(e *Engine, stop chan struct{}) {
@@ -54,6 +82,9 @@ func (r *Reconciler) Reconcile(e *Engine, stop chan struct{}) {
 
 	start := time.Now()
 
+	r.reconcileReplicaSets(e)
+	r.reconcileStartAfter(e)
+
 	clust, err := e.clusterState()
 	if err != nil {
 		log.Errorf("Failed getting current cluster state: %v", err)
@@ -67,9 +98,58 @@ func (r *Reconciler) Reconcile(e *Engine, stop chan struct{}) {
 		}
 	}
 
+	r.reconcileUnitFiles(e)
+
 	metrics.ReportEngineReconcileSuccess(start)
 }
 
+// reconcileReplicaSets creates and destroys instances of templates that
+// have a ReplicaSet so the cluster state fetched afterwards reflects them.
+func (r *Reconciler) reconcileReplicaSets(e *Engine) {
+	rsets, err := e.registry.ReplicaSets()
+	if err != nil {
+		log.Errorf("Failed fetching ReplicaSets from Registry: %v", err)
+		return
+	}
+	if len(rsets) == 0 {
+		return
+	}
+
+	units, err := e.registry.Units()
+	if err != nil {
+		log.Errorf("Failed fetching Units from Registry: %v", err)
+		return
+	}
+
+	for _, t := range calculateReplicaSetTasks(rsets, units) {
+		if err := doTask(t, e); err != nil {
+			log.Errorf("Failed resolving task: task=%s err=%v", t, err)
+		}
+	}
+}
+
+// reconcileStartAfter holds and releases Units depending on whether the
+// units they StartAfter are active.
+func (r *Reconciler) reconcileStartAfter(e *Engine) {
+	units, err := e.registry.Units()
+	if err != nil {
+		log.Errorf("Failed fetching Units from Registry: %v", err)
+		return
+	}
+
+	states, err := e.registry.UnitStates()
+	if err != nil {
+		log.Errorf("Failed fetching UnitStates from Registry: %v", err)
+		return
+	}
+
+	for _, t := range calculateStartAfterTasks(units, states) {
+		if err := doTask(t, e); err != nil {
+			log.Errorf("Failed resolving task: task=%s err=%v", t, err)
+		}
+	}
+}
+
 func (r *Reconciler) calculateClusterTasks(clust *clusterState, stopchan chan struct{}) (taskchan chan *task) {
 	taskchan = make(chan *task)
 
@@ -88,7 +168,19 @@ func (r *Reconciler) calculateClusterTasks(clust *clusterState, stopchan chan st
 		defer close(taskchan)
 
 		agents := clust.agents()
+		now := r.clock.Now()
+
+		for _, j := range clust.jobs {
+			if j.TargetState != job.JobStateInactive || len(j.FailedMachines) == 0 {
+				continue
+			}
+			if !send(taskTypeClearUnitFailures, "target state inactive", j.Name, "") {
+				return
+			}
+			j.FailedMachines = nil
+		}
 
+		failing := make(map[string]failure)
 		for _, j := range clust.jobs {
 			if !j.Scheduled() {
 				continue
@@ -127,7 +219,14 @@ func (r *Reconciler) calculateClusterTasks(clust *clusterState, stopchan chan st
 
 			unschedule, reason := decide()
 			if !unschedule {
-				continue
+				if reason, unschedule = r.failedTooLong(clust, agents, j, failing, now); !unschedule {
+					continue
+				}
+				if !send(taskTypeRecordUnitFailure, reason, j.Name, j.TargetMachineID) {
+					return
+				}
+				j.FailedMachines = append(append([]string(nil), j.FailedMachines...), j.TargetMachineID)
+				delete(failing, j.Name)
 			}
 
 			if !send(taskTypeUnscheduleUnit, reason, j.Name, j.TargetMachineID) {
@@ -136,19 +235,46 @@ func (r *Reconciler) calculateClusterTasks(clust *clusterState, stopchan chan st
 
 			clust.unschedule(j.Name)
 		}
+		r.failing = failing
 
-		for _, j := range clust.jobs {
-			if j.Scheduled() || j.TargetState == job.JobStateInactive {
+		for _, t := range calculateRolloutTasks(clust, agents) {
+			if !send(t.Type, t.Reason, t.JobName, t.MachineID) {
+				return
+			}
+		}
+
+		groups := machineOfGroups(clust)
+		grouped := make(map[string]bool)
+		for _, j := range prioritizedJobs(clust) {
+			if j.Scheduled() || j.TargetState == job.JobStateInactive || grouped[j.Name] {
+				continue
+			}
+
+			if group, ok := groups[j.Name]; ok {
+				for _, member := range group {
+					grouped[member.Name] = true
+				}
+				if !r.scheduleGroup(clust, group, send) {
+					return
+				}
 				continue
 			}
 
-			dec, err := r.sched.Decide(clust, j)
+			dec, err := decidePlacement(r.sched, clust, j)
 			if err != nil {
 				log.Debugf("Unable to schedule Job(%s): %v", j.Name, err)
 				metrics.ReportEngineReconcileFailure(metrics.ScheduleFailure)
 				continue
 			}
 
+			for _, name := range dec.evict {
+				reason := fmt.Sprintf("preempted by higher-priority Unit(%s)", j.Name)
+				if !send(taskTypeUnscheduleUnit, reason, name, dec.machineID) {
+					return
+				}
+				clust.unschedule(name)
+			}
+
 			reason := fmt.Sprintf("target state %s and unit not scheduled", j.TargetState)
 			if !send(taskTypeAttemptScheduleUnit, reason, j.Name, dec.machineID) {
 				metrics.ReportEngineReconcileFailure(metrics.ScheduleFailure)
@@ -157,6 +283,27 @@ func (r *Reconciler) calculateClusterTasks(clust *clusterState, stopchan chan st
 
 			clust.schedule(j.Name, dec.machineID)
 		}
+
+		if r.rebal == nil {
+			return
+		}
+
+		for i := 0; i < r.rebal.maxMoves(); i++ {
+			mv := r.rebal.nextMove(clust)
+			if mv == nil {
+				break
+			}
+
+			if !send(taskTypeUnscheduleUnit, mv.reason, mv.jobName, mv.from) {
+				return
+			}
+			clust.unschedule(mv.jobName)
+
+			if !send(taskTypeAttemptScheduleUnit, mv.reason, mv.jobName, mv.to) {
+				return
+			}
+			clust.schedule(mv.jobName, mv.to)
+		}
 	}()
 
 	return
@@ -166,9 +313,35 @@ func doTask(t *task, e *Engine) (err error) {
 	switch t.Type {
 	case taskTypeUnscheduleUnit:
 		err = e.unscheduleUnit(t.JobName, t.MachineID)
+		if err == nil {
+			e.recordUnitEvent(t)
+		}
 		metrics.ReportEngineTask(t.Type)
 	case taskTypeAttemptScheduleUnit:
-		e.attemptScheduleUnit(t.JobName, t.MachineID)
+		if e.attemptScheduleUnit(t.JobName, t.MachineID) {
+			e.recordUnitEvent(t)
+		}
+		metrics.ReportEngineTask(t.Type)
+	case taskTypeCreateUnit:
+		err = e.createReplica(t.JobName)
+		metrics.ReportEngineTask(t.Type)
+	case taskTypeDestroyUnit:
+		err = e.destroyReplica(t.JobName)
+		metrics.ReportEngineTask(t.Type)
+	case taskTypeHoldUnit:
+		err = e.holdUnit(t.JobName)
+		metrics.ReportEngineTask(t.Type)
+	case taskTypeReleaseUnit:
+		err = e.releaseUnit(t.JobName)
+		metrics.ReportEngineTask(t.Type)
+	case taskTypeRecordUnitFailure:
+		err = e.recordUnitFailure(t.JobName, t.MachineID)
+		metrics.ReportEngineTask(t.Type)
+	case taskTypeClearUnitFailures:
+		err = e.clearUnitFailures(t.JobName)
+		metrics.ReportEngineTask(t.Type)
+	case taskTypeAdmitGlobalUnit:
+		err = e.admitGlobalUnit(t.JobName, t.MachineID)
 		metrics.ReportEngineTask(t.Type)
 	default:
 		err = fmt.Errorf("unrecognized task type %q", t.Type)
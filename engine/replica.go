@@ -0,0 +1,76 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/nickswift/fleet/job"
+)
+
+// calculateReplicaSetTasks determines the tasks necessary to converge the
+// instances of each ReplicaSet's template towards the desired replica count.
+// Missing replicas are created using the lowest free indices, and excess
+// replicas are destroyed starting with the highest index. ReplicaSets whose
+// template Unit does not exist are ignored.
+func calculateReplicaSetTasks(rsets []job.ReplicaSet, units []job.Unit) []*task {
+	names := make(map[string]bool, len(units))
+	for _, u := range units {
+		names[u.Name] = true
+	}
+
+	var tasks []*task
+	for _, rs := range rsets {
+		if !names[rs.Template] {
+			continue
+		}
+
+		var indices []int
+		for _, u := range units {
+			if idx, ok := job.ReplicaIndex(rs.Template, u.Name); ok {
+				indices = append(indices, idx)
+			}
+		}
+		sort.Ints(indices)
+
+		existing := make(map[int]bool, len(indices))
+		for _, idx := range indices {
+			existing[idx] = true
+		}
+
+		for idx := 1; len(existing) < rs.Replicas; idx++ {
+			if existing[idx] {
+				continue
+			}
+			existing[idx] = true
+			tasks = append(tasks, &task{
+				Type:    taskTypeCreateUnit,
+				Reason:  fmt.Sprintf("ReplicaSet(%s) wants %d replicas", rs.Template, rs.Replicas),
+				JobName: job.ReplicaName(rs.Template, idx),
+			})
+		}
+
+		for i := len(indices) - 1; i >= rs.Replicas; i-- {
+			tasks = append(tasks, &task{
+				Type:    taskTypeDestroyUnit,
+				Reason:  fmt.Sprintf("ReplicaSet(%s) wants %d replicas", rs.Template, rs.Replicas),
+				JobName: job.ReplicaName(rs.Template, indices[i]),
+			})
+		}
+	}
+
+	return tasks
+}
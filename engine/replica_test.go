@@ -0,0 +1,100 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nickswift/fleet/job"
+)
+
+func TestCalculateReplicaSetTasks(t *testing.T) {
+	tests := []struct {
+		rsets []job.ReplicaSet
+		units []string
+		want  []task
+	}{
+		// no ReplicaSets, nothing to do
+		{
+			rsets: nil,
+			units: []string{"app@.service", "app@1.service"},
+			want:  nil,
+		},
+		// template does not exist, ignore the ReplicaSet
+		{
+			rsets: []job.ReplicaSet{{Template: "app@.service", Replicas: 2}},
+			units: []string{"app@1.service"},
+			want:  nil,
+		},
+		// scale up from nothing
+		{
+			rsets: []job.ReplicaSet{{Template: "app@.service", Replicas: 2}},
+			units: []string{"app@.service"},
+			want: []task{
+				{Type: taskTypeCreateUnit, JobName: "app@1.service"},
+				{Type: taskTypeCreateUnit, JobName: "app@2.service"},
+			},
+		},
+		// scale up filling gaps first
+		{
+			rsets: []job.ReplicaSet{{Template: "app@.service", Replicas: 4}},
+			units: []string{"app@.service", "app@2.service", "app@4.service", "app@foo.service"},
+			want: []task{
+				{Type: taskTypeCreateUnit, JobName: "app@1.service"},
+				{Type: taskTypeCreateUnit, JobName: "app@3.service"},
+			},
+		},
+		// scale down destroying the highest indices
+		{
+			rsets: []job.ReplicaSet{{Template: "app@.service", Replicas: 1}},
+			units: []string{"app@.service", "app@1.service", "app@3.service", "app@10.service"},
+			want: []task{
+				{Type: taskTypeDestroyUnit, JobName: "app@10.service"},
+				{Type: taskTypeDestroyUnit, JobName: "app@3.service"},
+			},
+		},
+		// scale to zero, leaving other units alone
+		{
+			rsets: []job.ReplicaSet{{Template: "app@.service", Replicas: 0}},
+			units: []string{"app@.service", "app@1.service", "app@foo.service", "db@1.service"},
+			want: []task{
+				{Type: taskTypeDestroyUnit, JobName: "app@1.service"},
+			},
+		},
+		// converged
+		{
+			rsets: []job.ReplicaSet{{Template: "app@.service", Replicas: 2}},
+			units: []string{"app@.service", "app@1.service", "app@2.service"},
+			want:  nil,
+		},
+	}
+
+	for i, tt := range tests {
+		units := make([]job.Unit, len(tt.units))
+		for j, name := range tt.units {
+			units[j] = job.Unit{Name: name}
+		}
+
+		var got []task
+		for _, tsk := range calculateReplicaSetTasks(tt.rsets, units) {
+			got = append(got, task{Type: tsk.Type, JobName: tsk.JobName})
+		}
+
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("case %d: calculated incorrect tasks\nexpected=%v\nreceived=%v", i, tt.want, got)
+		}
+	}
+}
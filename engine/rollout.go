@@ -0,0 +1,187 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/nickswift/fleet/agent"
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/registry"
+	"github.com/nickswift/fleet/schema"
+)
+
+const (
+	// the Machine has not been admitted to run the current version yet
+	RolloutPending = "pending"
+	// the Machine has been admitted but does not run the current version
+	// in its target state yet
+	RolloutInProgress = "in-progress"
+	// the Machine runs the current version in its target state
+	RolloutDone = "done"
+)
+
+// Rollout describes the progress of rolling out the current version of a
+// global Unit with GlobalMaxParallel to the Machines able to run it.
+type Rollout struct {
+	Name        string
+	UnitHash    string
+	MaxParallel int
+	HealthGate  bool
+	Machines    []MachineRollout
+}
+
+// MachineRollout describes the progress of a rollout on a single Machine.
+type MachineRollout struct {
+	MachineID string
+	Status    string
+}
+
+// UnitRollout determines the progress of rolling out the named global Unit.
+// If the Unit does not exist or is not a global Unit, nil is returned.
+func UnitRollout(reg registry.Registry, name string) (*Rollout, error) {
+	clust, err := loadClusterState(reg)
+	if err != nil {
+		return nil, err
+	}
+	gu, ok := clust.gUnits[name]
+	if !ok {
+		return nil, nil
+	}
+	return rollout(clust, clust.agents(), gu), nil
+}
+
+// RolloutReporter implements client.RolloutReporter with the rollouts of
+// the engine.
+type RolloutReporter struct{}
+
+// UnitRollout maps the Rollout of the named global Unit to the API schema.
+func (RolloutReporter) UnitRollout(reg registry.Registry, name string) (*schema.UnitRollout, error) {
+	ro, err := UnitRollout(reg, name)
+	if err != nil || ro == nil {
+		return nil, err
+	}
+
+	sro := schema.UnitRollout{
+		Name:        ro.Name,
+		Hash:        ro.UnitHash,
+		MaxParallel: int64(ro.MaxParallel),
+		HealthGate:  ro.HealthGate,
+		Machines:    make([]*schema.MachineRollout, len(ro.Machines)),
+	}
+	for i, mr := range ro.Machines {
+		sro.Machines[i] = &schema.MachineRollout{
+			MachineID: mr.MachineID,
+			Status:    mr.Status,
+		}
+	}
+	return &sro, nil
+}
+
+// rollout determines the status of the given global Unit on each Machine
+// able to run it, ordered by Machine ID.
+func rollout(clust *clusterState, agents map[string]*agent.AgentState, gu *job.Unit) *Rollout {
+	ro := &Rollout{
+		Name:        gu.Name,
+		UnitHash:    gu.Unit.Hash().String(),
+		MaxParallel: gu.GlobalMaxParallel(),
+		HealthGate:  gu.GlobalHealthGate(),
+	}
+
+	var ids []string
+	for id, as := range agents {
+		if as.Units[gu.Name] != nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		status := RolloutPending
+		if gu.RolloutAdmits(id) {
+			status = RolloutInProgress
+			if rolledOut(clust, gu, ro.UnitHash, id) {
+				status = RolloutDone
+			}
+		}
+		ro.Machines = append(ro.Machines, MachineRollout{MachineID: id, Status: status})
+	}
+
+	return ro
+}
+
+// rolledOut returns whether the given Machine reports the version of the
+// global Unit with the given hash, and with GlobalHealthGate, whether the
+// Unit reached its target state there.
+func rolledOut(clust *clusterState, gu *job.Unit, hash, machID string) bool {
+	us := clust.unitState(gu.Name, machID)
+	if us == nil || us.UnitHash != hash {
+		return false
+	}
+	if !gu.GlobalHealthGate() {
+		return true
+	}
+	switch gu.TargetState {
+	case job.JobStateLaunched:
+		return us.ActiveState == "active"
+	case job.JobStateLoaded:
+		return us.LoadState == "loaded"
+	}
+	return true
+}
+
+// calculateRolloutTasks admits further Machines to run the global Units
+// with GlobalMaxParallel, so that at most that many Machines are in
+// progress at once.
+func calculateRolloutTasks(clust *clusterState, agents map[string]*agent.AgentState) []*task {
+	var names sort.StringSlice
+	for name, gu := range clust.gUnits {
+		if gu.GlobalMaxParallel() > 0 && gu.TargetState != job.JobStateInactive {
+			names = append(names, name)
+		}
+	}
+	names.Sort()
+
+	var tasks []*task
+	for _, name := range names {
+		ro := rollout(clust, agents, clust.gUnits[name])
+
+		inProgress := 0
+		for _, mr := range ro.Machines {
+			if mr.Status == RolloutInProgress {
+				inProgress++
+			}
+		}
+
+		for _, mr := range ro.Machines {
+			if inProgress >= ro.MaxParallel {
+				break
+			}
+			if mr.Status != RolloutPending {
+				continue
+			}
+			tasks = append(tasks, &task{
+				Type:      taskTypeAdmitGlobalUnit,
+				Reason:    fmt.Sprintf("rolling out to %d of at most %d Machines at once", inProgress+1, ro.MaxParallel),
+				JobName:   name,
+				MachineID: mr.MachineID,
+			})
+			inProgress++
+		}
+	}
+
+	return tasks
+}
@@ -0,0 +1,95 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/machine"
+	"github.com/nickswift/fleet/unit"
+)
+
+func TestGlobalRollout(t *testing.T) {
+	machines := []machine.MachineState{{ID: "A"}, {ID: "B"}, {ID: "C"}, {ID: "D"}}
+	uf := newUnitWithOptions(t, "Global=true", "GlobalMaxParallel=2", "GlobalHealthGate=true")
+	hash := uf.Hash().String()
+
+	tests := []struct {
+		rollout *job.Rollout
+		states  []*unit.UnitState
+		want    []string
+		admit   []string
+	}{
+		// nothing rolled out yet
+		{
+			rollout: nil,
+			want:    []string{RolloutPending, RolloutPending, RolloutPending, RolloutPending},
+			admit:   []string{"A", "B"},
+		},
+		// machines still running the previous version are in progress
+		{
+			rollout: &job.Rollout{UnitHash: hash, Machines: []string{"A", "B"}},
+			states: []*unit.UnitState{
+				{UnitName: "app.service", MachineID: "A", UnitHash: "old", ActiveState: "active"},
+				{UnitName: "app.service", MachineID: "B", UnitHash: hash, ActiveState: "active"},
+			},
+			want:  []string{RolloutInProgress, RolloutDone, RolloutPending, RolloutPending},
+			admit: []string{"C"},
+		},
+		// the health gate holds back the rollout until the unit is active
+		{
+			rollout: &job.Rollout{UnitHash: hash, Machines: []string{"A", "B"}},
+			states: []*unit.UnitState{
+				{UnitName: "app.service", MachineID: "A", UnitHash: hash, ActiveState: "failed"},
+				{UnitName: "app.service", MachineID: "B", UnitHash: hash, ActiveState: "activating"},
+			},
+			want: []string{RolloutInProgress, RolloutInProgress, RolloutPending, RolloutPending},
+		},
+		// a rollout of a previous version admits nothing
+		{
+			rollout: &job.Rollout{UnitHash: "old", Machines: []string{"A", "B", "C", "D"}},
+			want:    []string{RolloutPending, RolloutPending, RolloutPending, RolloutPending},
+			admit:   []string{"A", "B"},
+		},
+	}
+
+	for i, tt := range tests {
+		units := []job.Unit{{Name: "app.service", Unit: uf, TargetState: job.JobStateLaunched, Rollout: tt.rollout}}
+		clust := newClusterState(units, nil, machines)
+		clust.setUnitStates(tt.states)
+		agents := clust.agents()
+
+		var got []string
+		for _, mr := range rollout(clust, agents, clust.gUnits["app.service"]).Machines {
+			got = append(got, mr.Status)
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("case %d: expected statuses %v, got %v", i, tt.want, got)
+		}
+
+		var admit []string
+		for _, tsk := range calculateRolloutTasks(clust, agents) {
+			if tsk.Type != taskTypeAdmitGlobalUnit || tsk.JobName != "app.service" {
+				t.Errorf("case %d: unexpected task %v", i, tsk)
+			}
+			admit = append(admit, tsk.MachineID)
+		}
+		if !reflect.DeepEqual(tt.admit, admit) {
+			t.Errorf("case %d: expected to admit %v, got %v", i, tt.admit, admit)
+		}
+	}
+}
@@ -16,20 +16,111 @@ package engine
 
 import (
 	"fmt"
+	"hash/fnv"
+	"math/rand"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/nickswift/fleet/agent"
 	"github.com/nickswift/fleet/job"
 )
 
+const (
+	// StrategyLeastLoaded places a Job on the agent with the fewest
+	// scheduled units, spreading load evenly across the cluster.
+	StrategyLeastLoaded = "least-loaded"
+	// StrategyBinPacking places a Job on the agent with the most
+	// scheduled units, filling up machines before using empty ones.
+	StrategyBinPacking = "bin-packing"
+	// StrategyRandomSpread places a Job on a random eligible agent.
+	StrategyRandomSpread = "random-spread"
+	// StrategyHash places a Job on an eligible agent chosen
+	// deterministically from the Job's name.
+	StrategyHash = "hash"
+
+	DefaultSchedulerStrategy = StrategyLeastLoaded
+)
+
+var schedulerStrategies = map[string]func() Scheduler{
+	StrategyLeastLoaded: func() Scheduler { return &leastLoadedScheduler{} },
+	StrategyBinPacking:  func() Scheduler { return &binPackingScheduler{} },
+	StrategyRandomSpread: func() Scheduler {
+		return &randomSpreadScheduler{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	},
+	StrategyHash: func() Scheduler { return &hashScheduler{} },
+}
+
+// NewScheduler returns the Scheduler implementing the named strategy. An
+// empty name selects the DefaultSchedulerStrategy.
+func NewScheduler(strategy string) (Scheduler, error) {
+	if strategy == "" {
+		strategy = DefaultSchedulerStrategy
+	}
+	fn, ok := schedulerStrategies[strategy]
+	if !ok {
+		return nil, fmt.Errorf("unknown scheduler strategy %q, must be one of: %s", strategy, strings.Join(SchedulerStrategies(), ", "))
+	}
+	return fn(), nil
+}
+
+// SchedulerStrategies returns the names of all available scheduler
+// strategies in alphabetical order.
+func SchedulerStrategies() []string {
+	names := make([]string, 0, len(schedulerStrategies))
+	for name := range schedulerStrategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 type decision struct {
 	machineID string
+	// names of lower-priority Jobs to unschedule from the machine first
+	evict []string
 }
 
 type Scheduler interface {
 	Decide(*clusterState, *job.Job) (*decision, error)
 }
 
+// ableAgents returns the agents in the given list which are able to run
+// the Job, taking the Job's spread requirements and preferences into
+// account. The order of the agents is preserved.
+func ableAgents(clust *clusterState, agents []*agent.AgentState, j *job.Job) []*agent.AgentState {
+	able := make([]*agent.AgentState, 0, len(agents))
+	for _, as := range agents {
+		if ok, _ := as.AbleToRun(j); ok {
+			able = append(able, as)
+		}
+	}
+	return preferredAgents(j, spreadAgents(clust, j, able))
+}
+
+// preferredAgents returns the agents in the given list with the highest
+// preference score for the Job, preserving their order. If the Job has no
+// preferences, all agents are returned.
+func preferredAgents(j *job.Job, agents []*agent.AgentState) []*agent.AgentState {
+	if !j.HasPreferences() {
+		return agents
+	}
+
+	best := -1
+	preferred := make([]*agent.AgentState, 0)
+	for _, as := range agents {
+		score := as.PreferenceScore(j)
+		if score > best {
+			best = score
+			preferred = make([]*agent.AgentState, 0)
+		}
+		if score == best {
+			preferred = append(preferred, as)
+		}
+	}
+	return preferred
+}
+
 type leastLoadedScheduler struct{}
 
 func (lls *leastLoadedScheduler) Decide(clust *clusterState, j *job.Job) (*decision, error) {
@@ -39,23 +130,13 @@ func (lls *leastLoadedScheduler) Decide(clust *clusterState, j *job.Job) (*decis
 		return nil, fmt.Errorf("zero agents available")
 	}
 
-	var target *agent.AgentState
-	for _, as := range agents {
-		if able, _ := as.AbleToRun(j); !able {
-			continue
-		}
-
-		as := as
-		target = as
-		break
-	}
-
-	if target == nil {
+	able := ableAgents(clust, agents, j)
+	if len(able) == 0 {
 		return nil, fmt.Errorf("no agents able to run job")
 	}
 
 	dec := decision{
-		machineID: target.MState.ID,
+		machineID: able[0].MState.ID,
 	}
 
 	return &dec, nil
@@ -85,3 +166,108 @@ func (sas sortableAgentStates) Less(i, j int) bool {
 	njUnits := len(sas[j].Units)
 	return niUnits < njUnits || (niUnits == njUnits && sas[i].MState.ID < sas[j].MState.ID)
 }
+
+// binPackingScheduler prefers the most heavily loaded agent able to run a
+// Job, keeping as many machines as possible free of units.
+type binPackingScheduler struct{}
+
+func (bps *binPackingScheduler) Decide(clust *clusterState, j *job.Job) (*decision, error) {
+	agents := clust.agents()
+	if len(agents) == 0 {
+		return nil, fmt.Errorf("zero agents available")
+	}
+
+	sas := make(sortableAgentStatesByLoadDesc, 0, len(agents))
+	for _, as := range agents {
+		sas = append(sas, as)
+	}
+	sort.Sort(sas)
+
+	able := ableAgents(clust, sas, j)
+	if len(able) == 0 {
+		return nil, fmt.Errorf("no agents able to run job")
+	}
+
+	return &decision{machineID: able[0].MState.ID}, nil
+}
+
+type sortableAgentStatesByLoadDesc []*agent.AgentState
+
+func (sas sortableAgentStatesByLoadDesc) Len() int      { return len(sas) }
+func (sas sortableAgentStatesByLoadDesc) Swap(i, j int) { sas[i], sas[j] = sas[j], sas[i] }
+
+func (sas sortableAgentStatesByLoadDesc) Less(i, j int) bool {
+	niUnits := len(sas[i].Units)
+	njUnits := len(sas[j].Units)
+	return niUnits > njUnits || (niUnits == njUnits && sas[i].MState.ID < sas[j].MState.ID)
+}
+
+// randomSpreadScheduler places a Job on an agent chosen at random from all
+// agents able to run it.
+type randomSpreadScheduler struct {
+	rand *rand.Rand
+}
+
+func (rss *randomSpreadScheduler) Decide(clust *clusterState, j *job.Job) (*decision, error) {
+	agents := clust.agents()
+	if len(agents) == 0 {
+		return nil, fmt.Errorf("zero agents available")
+	}
+
+	sas := make(sortableAgentStates, 0, len(agents))
+	for _, as := range agents {
+		sas = append(sas, as)
+	}
+	sort.Sort(sas)
+
+	able := ableAgents(clust, sas, j)
+	if len(able) == 0 {
+		return nil, fmt.Errorf("no agents able to run job")
+	}
+
+	return &decision{machineID: able[rss.rand.Intn(len(able))].MState.ID}, nil
+}
+
+// hashScheduler places a Job using rendezvous hashing of the Job name and
+// machine IDs. The same Job is always placed on the same agent for a given
+// set of eligible agents, and adding or removing an agent only moves the
+// Jobs that hash to it.
+type hashScheduler struct{}
+
+func (hs *hashScheduler) Decide(clust *clusterState, j *job.Job) (*decision, error) {
+	agents := clust.agents()
+	if len(agents) == 0 {
+		return nil, fmt.Errorf("zero agents available")
+	}
+
+	sas := make([]*agent.AgentState, 0, len(agents))
+	for _, as := range agents {
+		sas = append(sas, as)
+	}
+
+	var (
+		target *agent.AgentState
+		best   uint64
+	)
+	for _, as := range ableAgents(clust, sas, j) {
+		score := rendezvousScore(j.Name, as.MState.ID)
+		if target == nil || score > best || (score == best && as.MState.ID < target.MState.ID) {
+			target = as
+			best = score
+		}
+	}
+
+	if target == nil {
+		return nil, fmt.Errorf("no agents able to run job")
+	}
+
+	return &decision{machineID: target.MState.ID}, nil
+}
+
+func rendezvousScore(jName, machID string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(jName))
+	h.Write([]byte{0})
+	h.Write([]byte(machID))
+	return h.Sum64()
+}
@@ -15,6 +15,7 @@
 package engine
 
 import (
+	"math/rand"
 	"reflect"
 	"sort"
 	"testing"
@@ -22,6 +23,7 @@ import (
 	"github.com/nickswift/fleet/agent"
 	"github.com/nickswift/fleet/job"
 	"github.com/nickswift/fleet/machine"
+	"github.com/nickswift/fleet/resource"
 )
 
 func TestSchedulerDecisions(t *testing.T) {
@@ -175,3 +177,183 @@ func TestAgentStateSorting(t *testing.T) {
 		}
 	}
 }
+
+// newLoadedClusterState returns a clusterState with machines A, B and C
+// running two, one and zero units, respectively.
+func newLoadedClusterState() *clusterState {
+	units := []job.Unit{
+		{Name: "1.service", TargetState: job.JobStateLaunched},
+		{Name: "2.service", TargetState: job.JobStateLaunched},
+		{Name: "3.service", TargetState: job.JobStateLaunched},
+	}
+	sUnits := []job.ScheduledUnit{
+		{Name: "1.service", TargetMachineID: "A"},
+		{Name: "2.service", TargetMachineID: "A"},
+		{Name: "3.service", TargetMachineID: "B"},
+	}
+	machines := []machine.MachineState{{ID: "B"}, {ID: "C"}, {ID: "A"}}
+	return newClusterState(units, sUnits, machines)
+}
+
+func TestNewScheduler(t *testing.T) {
+	tests := []struct {
+		strategy string
+		sched    Scheduler
+	}{
+		{"", &leastLoadedScheduler{}},
+		{StrategyLeastLoaded, &leastLoadedScheduler{}},
+		{StrategyBinPacking, &binPackingScheduler{}},
+		{StrategyRandomSpread, &randomSpreadScheduler{}},
+		{StrategyHash, &hashScheduler{}},
+		{"most-expensive", nil},
+	}
+
+	for i, tt := range tests {
+		sched, err := NewScheduler(tt.strategy)
+		if tt.sched == nil {
+			if err == nil {
+				t.Errorf("case %d: expected error for strategy %q", i, tt.strategy)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if reflect.TypeOf(sched) != reflect.TypeOf(tt.sched) {
+			t.Errorf("case %d: expected scheduler %T, got %T", i, tt.sched, sched)
+		}
+	}
+}
+
+func TestSchedulerStrategyDecisions(t *testing.T) {
+	tests := []struct {
+		sched Scheduler
+		job   *job.Job
+		want  string
+	}{
+		{&leastLoadedScheduler{}, &job.Job{Name: "foo.service"}, "C"},
+		{&binPackingScheduler{}, &job.Job{Name: "foo.service"}, "A"},
+		// bin-packing skips machines which are unable to run the job
+		{
+			&binPackingScheduler{},
+			&job.Job{Name: "foo.service", Unit: newUnitWithMetadata(t, "region=us")},
+			"",
+		},
+	}
+
+	for i, tt := range tests {
+		dec, err := tt.sched.Decide(newLoadedClusterState(), tt.job)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("case %d: expected error, got decision %#v", i, dec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if dec.machineID != tt.want {
+			t.Errorf("case %d: expected machine %s, got %s", i, tt.want, dec.machineID)
+		}
+	}
+}
+
+func TestSchedulerPreferences(t *testing.T) {
+	machines := []machine.MachineState{
+		{ID: "A", Metadata: map[string]string{"ssd": "true"}, TotalResources: &resource.ResourceTuple{Cores: 400, Memory: 1024}},
+		{ID: "B", Metadata: map[string]string{"ssd": "true"}},
+		{ID: "C", Metadata: map[string]string{}},
+	}
+	units := []job.Unit{
+		{Name: "cache@1.service", TargetState: job.JobStateLaunched},
+		{Name: "web@1.service", TargetState: job.JobStateLaunched},
+	}
+	sUnits := []job.ScheduledUnit{
+		{Name: "cache@1.service", TargetMachineID: "B"},
+		{Name: "web@1.service", TargetMachineID: "C"},
+	}
+
+	tests := []struct {
+		job  *job.Job
+		want string
+	}{
+		// without preferences the least loaded machine wins
+		{&job.Job{Name: "foo.service", Unit: newUnitWithOptions(t)}, "A"},
+		// preferences outweigh load
+		{&job.Job{Name: "foo.service", Unit: newUnitWithOptions(t, "PreferMachineMetadata=ssd exists:50", "PreferNotCollocatedWith=web@*:10", "PreferMachineMetadata=ssd!=true:40")}, "A"},
+		{&job.Job{Name: "foo.service", Unit: newUnitWithOptions(t, "PreferMachineMetadata=ssd!=true:50", "PreferNotCollocatedWith=cache@*:20")}, "C"},
+		{&job.Job{Name: "foo.service", Unit: newUnitWithOptions(t, "PreferMachineMetadata=ssd=true:50", "PreferNotCollocatedWith=cache@*:20")}, "A"},
+		// the preferred machine is full, so the next best is used
+		{&job.Job{Name: "foo.service", Unit: newUnitWithOptions(t, "PreferMachineMetadata=ssd=true:50", "PreferNotCollocatedWith=cache@*:20", "Memory=1G")}, "B"},
+	}
+
+	for i, tt := range tests {
+		clust := newClusterState(units, sUnits, machines)
+		dec, err := (&leastLoadedScheduler{}).Decide(clust, tt.job)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if dec.machineID != tt.want {
+			t.Errorf("case %d: expected machine %s, got %s", i, tt.want, dec.machineID)
+		}
+	}
+}
+
+func TestRandomSpreadScheduler(t *testing.T) {
+	sched := &randomSpreadScheduler{rand: rand.New(rand.NewSource(1))}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		dec, err := sched.Decide(newLoadedClusterState(), &job.Job{Name: "foo.service"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		seen[dec.machineID] = true
+	}
+
+	if len(seen) != 3 {
+		t.Errorf("expected decisions spread over 3 machines, got %v", seen)
+	}
+
+	if _, err := sched.Decide(newClusterState(nil, nil, nil), &job.Job{Name: "foo.service"}); err == nil {
+		t.Errorf("expected error with zero agents")
+	}
+}
+
+func TestHashScheduler(t *testing.T) {
+	sched := &hashScheduler{}
+
+	first := make(map[string]string)
+	for _, name := range []string{"a.service", "b.service", "c.service", "d.service", "e.service"} {
+		dec, err := sched.Decide(newLoadedClusterState(), &job.Job{Name: name})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		first[name] = dec.machineID
+
+		// placement is deterministic
+		again, err := sched.Decide(newLoadedClusterState(), &job.Job{Name: name})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if again.machineID != dec.machineID {
+			t.Errorf("job %s placed on %s, then on %s", name, dec.machineID, again.machineID)
+		}
+	}
+
+	// removing a machine only moves the jobs that were placed on it
+	clust := newLoadedClusterState()
+	delete(clust.machines, "C")
+	for name, machID := range first {
+		dec, err := sched.Decide(clust, &job.Job{Name: name})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if machID != "C" && dec.machineID != machID {
+			t.Errorf("job %s moved from %s to %s", name, machID, dec.machineID)
+		}
+	}
+}
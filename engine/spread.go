@@ -0,0 +1,101 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"github.com/nickswift/fleet/agent"
+	"github.com/nickswift/fleet/job"
+)
+
+// spreadAgents narrows down the given agents according to the Job's SpreadBy
+// and MaxSkew requirements. Only agents whose machine has the SpreadBy
+// metadata key are considered, and of those only the agents in the spread
+// domains (distinct values of the key) running the fewest Jobs of the Job's
+// spread group are returned. If a MaxSkew is set, agents in domains where
+// placing the Job would exceed it are never returned, even if that leaves no
+// agents at all. The order of the given agents is preserved.
+func spreadAgents(clust *clusterState, j *job.Job, agents []*agent.AgentState) []*agent.AgentState {
+	key, ok := j.SpreadBy()
+	if !ok {
+		return agents
+	}
+
+	counts := spreadCounts(clust, j, key)
+	min := -1
+	for _, c := range counts {
+		if min == -1 || c < min {
+			min = c
+		}
+	}
+
+	maxSkew, hard := j.MaxSkew()
+
+	best := -1
+	spread := make([]*agent.AgentState, 0)
+	for _, as := range agents {
+		domain, ok := as.MState.Metadata[key]
+		if !ok {
+			continue
+		}
+
+		c := counts[domain]
+		if hard && c+1-min > maxSkew {
+			continue
+		}
+
+		if best == -1 || c < best {
+			best = c
+			spread = make([]*agent.AgentState, 0)
+		}
+		if c == best {
+			spread = append(spread, as)
+		}
+	}
+
+	return spread
+}
+
+// spreadCounts returns the number of scheduled Jobs of the given Job's spread
+// group in each spread domain of the cluster, i.e. per distinct value of the
+// given metadata key. The Job itself is not counted. Domains without any
+// Jobs of the group are included with a count of zero.
+func spreadCounts(clust *clusterState, j *job.Job, key string) map[string]int {
+	counts := make(map[string]int)
+	for _, ms := range clust.machines {
+		if domain, ok := ms.Metadata[key]; ok {
+			counts[domain] += 0
+		}
+	}
+
+	group := j.SpreadGroup()
+	for _, oj := range clust.jobs {
+		if oj.Name == j.Name || !oj.Scheduled() || oj.TargetState == job.JobStateInactive {
+			continue
+		}
+		if oj.SpreadGroup() != group {
+			continue
+		}
+
+		ms, ok := clust.machines[oj.TargetMachineID]
+		if !ok {
+			continue
+		}
+		if domain, ok := ms.Metadata[key]; ok {
+			counts[domain]++
+		}
+	}
+
+	return counts
+}
@@ -0,0 +1,147 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nickswift/fleet/agent"
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/machine"
+	"github.com/nickswift/fleet/unit"
+)
+
+func newUnitWithOptions(t *testing.T, opts ...string) unit.UnitFile {
+	contents := "[X-Fleet]\n" + strings.Join(opts, "\n")
+	u, err := unit.NewUnitFile(contents)
+	if err != nil {
+		t.Fatalf("error creating unit from %q: %v", contents, err)
+	}
+	return *u
+}
+
+func TestSpreadAgents(t *testing.T) {
+	units := []job.Unit{
+		{Name: "app@1.service", TargetState: job.JobStateLaunched},
+		{Name: "app@2.service", TargetState: job.JobStateLaunched},
+		{Name: "app@3.service", TargetState: job.JobStateInactive},
+	}
+	sUnits := []job.ScheduledUnit{
+		{Name: "app@1.service", TargetMachineID: "A"},
+		{Name: "app@2.service", TargetMachineID: "B"},
+		{Name: "app@3.service", TargetMachineID: "C"},
+	}
+	machines := []machine.MachineState{
+		{ID: "A", Metadata: map[string]string{"zone": "a"}},
+		{ID: "B", Metadata: map[string]string{"zone": "a"}},
+		{ID: "C", Metadata: map[string]string{"zone": "b"}},
+		{ID: "D", Metadata: map[string]string{}},
+	}
+	clust := newClusterState(units, sUnits, machines)
+	agents := clust.agents()
+
+	tests := []struct {
+		job  *job.Job
+		in   []string
+		want []string
+	}{
+		// no spread requirement leaves the agents untouched
+		{
+			job:  &job.Job{Name: "app@4.service", Unit: newUnitWithOptions(t)},
+			in:   []string{"A", "B", "C", "D"},
+			want: []string{"A", "B", "C", "D"},
+		},
+		// only the least populated zone is used; inactive jobs are not counted
+		{
+			job:  &job.Job{Name: "app@4.service", Unit: newUnitWithOptions(t, "SpreadBy=zone")},
+			in:   []string{"A", "B", "C", "D"},
+			want: []string{"C"},
+		},
+		// without a max skew, other zones are used if necessary
+		{
+			job:  &job.Job{Name: "app@4.service", Unit: newUnitWithOptions(t, "SpreadBy=zone")},
+			in:   []string{"A", "B", "D"},
+			want: []string{"A", "B"},
+		},
+		// the max skew is a hard limit
+		{
+			job:  &job.Job{Name: "app@4.service", Unit: newUnitWithOptions(t, "SpreadBy=zone", "MaxSkew=2")},
+			in:   []string{"A", "B", "D"},
+			want: []string{},
+		},
+		{
+			job:  &job.Job{Name: "app@4.service", Unit: newUnitWithOptions(t, "SpreadBy=zone", "MaxSkew=3")},
+			in:   []string{"A", "B", "D"},
+			want: []string{"A", "B"},
+		},
+		// an already scheduled job does not count against itself
+		{
+			job:  &job.Job{Name: "app@1.service", Unit: newUnitWithOptions(t, "SpreadBy=zone", "MaxSkew=2")},
+			in:   []string{"A", "B"},
+			want: []string{"A", "B"},
+		},
+		// instances of other templates are not counted
+		{
+			job:  &job.Job{Name: "other@1.service", Unit: newUnitWithOptions(t, "SpreadBy=zone", "MaxSkew=1")},
+			in:   []string{"A", "B", "C", "D"},
+			want: []string{"A", "B", "C"},
+		},
+	}
+
+	for i, tt := range tests {
+		in := make([]*agent.AgentState, 0, len(tt.in))
+		for _, id := range tt.in {
+			in = append(in, agents[id])
+		}
+
+		got := make([]string, 0)
+		for _, as := range spreadAgents(clust, tt.job, in) {
+			got = append(got, as.MState.ID)
+		}
+
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("case %d: expected agents %v, got %v", i, tt.want, got)
+		}
+	}
+}
+
+func TestSchedulerSpreadsInstances(t *testing.T) {
+	machines := []machine.MachineState{
+		{ID: "A", Metadata: map[string]string{"zone": "a"}},
+		{ID: "B", Metadata: map[string]string{"zone": "a"}},
+		{ID: "C", Metadata: map[string]string{"zone": "b"}},
+	}
+	clust := newClusterState(nil, nil, machines)
+
+	sched := &leastLoadedScheduler{}
+	zones := make(map[string]int)
+	for _, name := range []string{"app@1.service", "app@2.service", "app@3.service", "app@4.service"} {
+		j := &job.Job{Name: name, TargetState: job.JobStateLaunched, Unit: newUnitWithOptions(t, "SpreadBy=zone", "MaxSkew=1")}
+		clust.jobs[name] = j
+
+		dec, err := sched.Decide(clust, j)
+		if err != nil {
+			t.Fatalf("unexpected error scheduling %s: %v", name, err)
+		}
+		clust.schedule(name, dec.machineID)
+		zones[clust.machines[dec.machineID].Metadata["zone"]]++
+	}
+
+	if zones["a"] != 2 || zones["b"] != 2 {
+		t.Errorf("expected instances spread evenly over zones, got %v", zones)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/unit"
+)
+
+// calculateStartAfterTasks determines which launched Units must be held
+// because a unit they StartAfter is not active on any machine, and which
+// held Units may be released. Units which are already active are never
+// held, so a dependency becoming inactive later does not stop them.
+func calculateStartAfterTasks(units []job.Unit, states []*unit.UnitState) []*task {
+	active := make(map[string]bool)
+	for _, us := range states {
+		if us.ActiveState == "active" {
+			active[us.UnitName] = true
+		}
+	}
+
+	var tasks []*task
+	for _, u := range units {
+		var waiting []string
+		if u.TargetState == job.JobStateLaunched && !active[u.Name] {
+			for _, dep := range u.StartAfter() {
+				if !active[dep] {
+					waiting = append(waiting, dep)
+				}
+			}
+		}
+
+		if len(waiting) > 0 && !u.Held {
+			tasks = append(tasks, &task{
+				Type:    taskTypeHoldUnit,
+				Reason:  fmt.Sprintf("waiting for Unit(%s) to become active", strings.Join(waiting, ", ")),
+				JobName: u.Name,
+			})
+		} else if len(waiting) == 0 && u.Held {
+			tasks = append(tasks, &task{
+				Type:    taskTypeReleaseUnit,
+				Reason:  "no longer waiting for units to become active",
+				JobName: u.Name,
+			})
+		}
+	}
+
+	return tasks
+}
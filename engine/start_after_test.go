@@ -0,0 +1,92 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/unit"
+)
+
+func TestCalculateStartAfterTasks(t *testing.T) {
+	app := newUnitWithOptions(t, "StartAfter=db.service cache.service")
+	active := func(names ...string) []*unit.UnitState {
+		var states []*unit.UnitState
+		for _, name := range names {
+			states = append(states, &unit.UnitState{UnitName: name, ActiveState: "active", MachineID: "XXX"})
+		}
+		return states
+	}
+
+	tests := []struct {
+		units  []job.Unit
+		states []*unit.UnitState
+		// pairs of task type and unit name
+		want [][2]string
+	}{
+		// hold until all dependencies are active
+		{
+			units:  []job.Unit{{Name: "app.service", Unit: app, TargetState: job.JobStateLaunched}},
+			states: active("db.service"),
+			want:   [][2]string{{taskTypeHoldUnit, "app.service"}},
+		},
+		{
+			units:  []job.Unit{{Name: "app.service", Unit: app, TargetState: job.JobStateLaunched, Held: true}},
+			states: active("db.service"),
+			want:   nil,
+		},
+		// release once they are
+		{
+			units:  []job.Unit{{Name: "app.service", Unit: app, TargetState: job.JobStateLaunched, Held: true}},
+			states: active("db.service", "cache.service"),
+			want:   [][2]string{{taskTypeReleaseUnit, "app.service"}},
+		},
+		// units which are only loaded are not held
+		{
+			units:  []job.Unit{{Name: "app.service", Unit: app, TargetState: job.JobStateLoaded}},
+			states: nil,
+			want:   nil,
+		},
+		{
+			units:  []job.Unit{{Name: "app.service", Unit: app, TargetState: job.JobStateInactive, Held: true}},
+			states: nil,
+			want:   [][2]string{{taskTypeReleaseUnit, "app.service"}},
+		},
+		// units which are already active are left alone
+		{
+			units:  []job.Unit{{Name: "app.service", Unit: app, TargetState: job.JobStateLaunched}},
+			states: active("app.service"),
+			want:   nil,
+		},
+		// units without dependencies are never held
+		{
+			units:  []job.Unit{{Name: "db.service", Unit: newUnitWithOptions(t), TargetState: job.JobStateLaunched}},
+			states: nil,
+			want:   nil,
+		},
+	}
+
+	for i, tt := range tests {
+		var got [][2]string
+		for _, tsk := range calculateStartAfterTasks(tt.units, tt.states) {
+			got = append(got, [2]string{tsk.Type, tsk.JobName})
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("case %d: expected tasks %v, got %v", i, tt.want, got)
+		}
+	}
+}
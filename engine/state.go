@@ -18,12 +18,15 @@ import (
 	"github.com/nickswift/fleet/agent"
 	"github.com/nickswift/fleet/job"
 	"github.com/nickswift/fleet/machine"
+	"github.com/nickswift/fleet/unit"
 )
 
 type clusterState struct {
 	jobs     map[string]*job.Job
 	gUnits   map[string]*job.Unit
 	machines map[string]*machine.MachineState
+	// published states of each unit, by unit name and machine ID
+	states map[string]map[string]*unit.UnitState
 }
 
 func newClusterState(units []job.Unit, sUnits []job.ScheduledUnit, machines []machine.MachineState) *clusterState {
@@ -41,9 +44,10 @@ func newClusterState(units []job.Unit, sUnits []job.ScheduledUnit, machines []ma
 			guMap[u.Name] = &u
 		} else {
 			j := job.Job{
-				Name:        u.Name,
-				Unit:        u.Unit,
-				TargetState: u.TargetState,
+				Name:           u.Name,
+				Unit:           u.Unit,
+				TargetState:    u.TargetState,
+				FailedMachines: u.FailedMachines,
 			}
 
 			if sUnit, ok := sUnitMap[u.Name]; ok {
@@ -65,9 +69,27 @@ func newClusterState(units []job.Unit, sUnits []job.ScheduledUnit, machines []ma
 		jobs:     jMap,
 		gUnits:   guMap,
 		machines: mMap,
+		states:   make(map[string]map[string]*unit.UnitState),
 	}
 }
 
+// setUnitStates records the states units are reported in on each machine.
+func (cs *clusterState) setUnitStates(states []*unit.UnitState) {
+	cs.states = make(map[string]map[string]*unit.UnitState)
+	for _, us := range states {
+		if cs.states[us.UnitName] == nil {
+			cs.states[us.UnitName] = make(map[string]*unit.UnitState)
+		}
+		cs.states[us.UnitName][us.MachineID] = us
+	}
+}
+
+// unitState returns the state the named unit is reported in on the given
+// machine, or nil if there is none.
+func (cs *clusterState) unitState(name, machID string) *unit.UnitState {
+	return cs.states[name][machID]
+}
+
 func (cs *clusterState) agents() map[string]*agent.AgentState {
 	agents := make(map[string]*agent.AgentState, len(cs.machines))
 	for _, ms := range cs.machines {
@@ -93,7 +115,11 @@ func (cs *clusterState) agents() map[string]*agent.AgentState {
 	for _, gu := range cs.gUnits {
 		gu := gu
 		for _, a := range agents {
-			if !machine.HasMetadata(a.MState, gu.RequiredTargetMetadata()) {
+			if !machine.MatchesMetadata(a.MState, gu.MetadataRequirements()) {
+				continue
+			}
+
+			if _, found := machine.UntoleratedTaint(a.MState, gu.Tolerations()); found {
 				continue
 			}
 
@@ -107,6 +133,23 @@ func (cs *clusterState) agents() map[string]*agent.AgentState {
 	return agents
 }
 
+// without returns a view of the cluster state lacking the given machines.
+// The Jobs and global Units are shared with the original.
+func (cs *clusterState) without(machIDs map[string]bool) *clusterState {
+	machines := make(map[string]*machine.MachineState, len(cs.machines))
+	for id, ms := range cs.machines {
+		if !machIDs[id] {
+			machines[id] = ms
+		}
+	}
+	return &clusterState{
+		jobs:     cs.jobs,
+		gUnits:   cs.gUnits,
+		machines: machines,
+		states:   cs.states,
+	}
+}
+
 func (cs *clusterState) schedule(jobName, targetMachineID string) {
 	j := cs.jobs[jobName]
 	if j == nil {
@@ -0,0 +1,113 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"time"
+
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/log"
+)
+
+const (
+	// unitFileGCInterval is the minimum amount of time between two
+	// collections of unreferenced unit files
+	unitFileGCInterval = 5 * time.Minute
+
+	// unitFileGCGracePeriod is how long a unit file must be unreferenced
+	// before it is removed, so that the unit file of a Unit which is being
+	// created is not removed before the Unit refers to it
+	unitFileGCGracePeriod = 10 * time.Minute
+)
+
+// unitFileCandidate is a unit file which no Unit refers to
+type unitFileCandidate struct {
+	// idx is the index the unit file was stored at when it was first
+	// seen unreferenced
+	idx uint64
+	// since is when the unit file was first seen unreferenced
+	since time.Time
+}
+
+// reconcileUnitFiles removes the unit files which neither a Unit nor one of
+// its revisions has referred to for the grace period. As unit files are stored by hash and may be shared
+// by Units, they are left behind when a Unit is destroyed or replaced.
+func (r *Reconciler) reconcileUnitFiles(e *Engine) {
+	now := r.clock.Now()
+	if now.Sub(r.lastUnitFileGC) < unitFileGCInterval {
+		return
+	}
+	r.lastUnitFileGC = now
+
+	// the Units are fetched first: a unit file stored for a Unit created
+	// in the meantime then has a new index and starts a new grace period
+	units, err := e.registry.Units()
+	if err != nil {
+		log.Errorf("Failed fetching Units from Registry: %v", err)
+		return
+	}
+	files, err := e.registry.UnitFiles()
+	if err != nil {
+		log.Errorf("Failed fetching unit files from Registry: %v", err)
+		return
+	}
+
+	for hash, idx := range collectUnitFiles(r.unitFileCandidates, units, files, now) {
+		if err := e.registry.DestroyUnitFile(hash, idx); err != nil {
+			log.Errorf("Failed removing unit file %s: %v", hash, err)
+			continue
+		}
+		log.Infof("Removed unreferenced unit file %s", hash)
+	}
+}
+
+// collectUnitFiles updates the candidates with the stored unit files, given
+// by hash with the index they were last stored at, which none of the Units
+// refers to. It returns the unit files which were unreferenced for the
+// grace period, along with the index they must still be stored at to be
+// removed.
+func collectUnitFiles(candidates map[string]unitFileCandidate, units []job.Unit, files map[string]uint64, now time.Time) map[string]uint64 {
+	referenced := make(map[string]bool, len(units))
+	for _, u := range units {
+		referenced[u.Unit.Hash().String()] = true
+		// older revisions are kept to roll the Unit back to
+		for _, rev := range u.Revisions {
+			referenced[rev.UnitHash.String()] = true
+		}
+	}
+
+	remove := make(map[string]uint64)
+	for hash := range candidates {
+		if _, ok := files[hash]; !ok || referenced[hash] {
+			delete(candidates, hash)
+		}
+	}
+	for hash, idx := range files {
+		if referenced[hash] {
+			continue
+		}
+
+		c, ok := candidates[hash]
+		if !ok || c.idx != idx {
+			candidates[hash] = unitFileCandidate{idx: idx, since: now}
+			continue
+		}
+		if now.Sub(c.since) >= unitFileGCGracePeriod {
+			remove[hash] = idx
+			delete(candidates, hash)
+		}
+	}
+	return remove
+}
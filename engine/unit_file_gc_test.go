@@ -0,0 +1,101 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/registry"
+	"github.com/nickswift/fleet/unit"
+)
+
+func TestReconcileUnitFiles(t *testing.T) {
+	newUnit := func(name, contents string) *job.Unit {
+		uf, err := unit.NewUnitFile(contents)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return &job.Unit{Name: name, Unit: *uf, TargetState: job.JobStateLaunched}
+	}
+	hash := func(u *job.Unit) string {
+		return u.Unit.Hash().String()
+	}
+
+	reg := registry.NewFakeRegistry()
+	clock := clockwork.NewFakeClock()
+	e := &Engine{rec: NewReconciler(nil, nil), registry: reg}
+	e.rec.clock = clock
+
+	fooA := newUnit("foo.service", "[Service]\nExecStart=/bin/a")
+	fooB := newUnit("foo.service", "[Service]\nExecStart=/bin/b")
+	barC := newUnit("bar.service", "[Service]\nExecStart=/bin/c")
+	bazA := newUnit("baz.service", "[Service]\nExecStart=/bin/a")
+	for _, u := range []*job.Unit{fooA, fooB, barC} {
+		if err := reg.CreateUnit(u); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	reg.DestroyUnit("bar.service")
+
+	stored := func() []string {
+		files, _ := reg.UnitFiles()
+		var hashes []string
+		for hash := range files {
+			hashes = append(hashes, hash)
+		}
+		sort.Strings(hashes)
+		return hashes
+	}
+	sorted := func(hashes ...string) []string {
+		sort.Strings(hashes)
+		return hashes
+	}
+
+	steps := []struct {
+		advance time.Duration
+		before  func()
+		want    []string
+	}{
+		// the unreferenced unit files become candidates
+		{0, nil, sorted(hash(fooA), hash(fooB), hash(barC))},
+		// a unit file stored again starts a new grace period
+		{unitFileGCInterval, func() {
+			reg.CreateUnit(bazA)
+			reg.DestroyUnit("baz.service")
+		}, sorted(hash(fooA), hash(fooB), hash(barC))},
+		{unitFileGCInterval, nil, sorted(hash(fooA), hash(fooB))},
+		// collections are not run more often than the interval
+		{unitFileGCInterval / 2, nil, sorted(hash(fooA), hash(fooB))},
+		// the replaced unit file of foo.service is kept as a revision
+		{unitFileGCInterval / 2, nil, sorted(hash(fooA), hash(fooB))},
+	}
+
+	for i, tt := range steps {
+		clock.Advance(tt.advance)
+		if tt.before != nil {
+			tt.before()
+		}
+		e.rec.reconcileUnitFiles(e)
+		if got := stored(); !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("step %d: expected unit files %v, got %v", i, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var cmdCordon = &cobra.Command{
+	Use:   "cordon MACHINE",
+	Short: "Prevent new units from being scheduled to a machine",
+	Long: `Mark a machine as unschedulable. Units already scheduled to the machine keep
+running, but no new units are scheduled to it until it is uncordoned.
+
+The machine may be identified by its full or short ID.
+
+Cordon a machine:
+	fleetctl cordon 113f16a7`,
+	Run: runWrapper(runCordonMachine),
+}
+
+func init() {
+	cmdFleet.AddCommand(cmdCordon)
+}
+
+func runCordonMachine(cCmd *cobra.Command, args []string) (exit int) {
+	if len(args) != 1 {
+		stderr("One machine must be provided")
+		return 1
+	}
+
+	ms, err := findMachine(args[0])
+	if err != nil {
+		stderr("%v", err)
+		return 1
+	}
+
+	if err := cAPI.CordonMachine(ms.ID); err != nil {
+		stderr("Error cordoning machine %s: %v", ms.ID, err)
+		return 1
+	}
+
+	stdout("Cordoned machine %s", ms.ID)
+	return 0
+}
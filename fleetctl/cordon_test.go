@@ -0,0 +1,97 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestRunCordonMachine(t *testing.T) {
+	tests := []struct {
+		cordon   bool
+		args     []string
+		exit     int
+		cordoned bool
+	}{
+		{true, []string{"c31e44e1-f858-436e-933e-59c642517860"}, 0, true},
+		{true, []string{"c31e44e1"}, 0, true},
+		{true, []string{"deadbeef"}, 1, false},
+		{true, []string{}, 1, false},
+		{false, []string{"c31e44e1"}, 0, false},
+	}
+
+	for i, tt := range tests {
+		cAPI = newFakeRegistryForCommands("j", 1, false)
+		if !tt.cordon {
+			cAPI.CordonMachine("c31e44e1-f858-436e-933e-59c642517860")
+		}
+
+		var exit int
+		if tt.cordon {
+			exit = runCordonMachine(cmdCordon, tt.args)
+		} else {
+			exit = runUncordonMachine(cmdUncordon, tt.args)
+		}
+		if exit != tt.exit {
+			t.Errorf("case %d: expected exit code %d, got %d", i, tt.exit, exit)
+		}
+
+		ms, err := findMachine("c31e44e1")
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		if ms.Cordoned != tt.cordoned {
+			t.Errorf("case %d: expected cordoned=%t, got %t", i, tt.cordoned, ms.Cordoned)
+		}
+	}
+}
+
+func TestRunDrainMachine(t *testing.T) {
+	// no units on the machine, it is just cordoned
+	cAPI = newFakeRegistryForCommands("j", 2, false)
+	if exit := runDrainMachine(cmdDrain, []string{"c31e44e1"}); exit != 0 {
+		t.Errorf("expected exit code 0, got %d", exit)
+	}
+	if ms, _ := findMachine("c31e44e1"); ms == nil || !ms.Cordoned {
+		t.Errorf("expected machine to be cordoned")
+	}
+
+	// the only other machine is cordoned, so the units stay in place
+	if exit := runDrainMachine(cmdDrain, []string{"595989bb"}); exit != 1 {
+		t.Errorf("expected exit code 1, got %d", exit)
+	}
+	u, _ := cAPI.Unit("j1.service")
+	if u == nil || u.MachineID != "595989bb-cbb7-49ce-8726-722d6e157b4e" {
+		t.Errorf("expected unit to remain untouched, got %v", u)
+	}
+}
+
+func TestRunDrainMachineRestoresDesiredState(t *testing.T) {
+	cAPI = newFakeRegistryForCommands("j", 1, false)
+	if err := cAPI.SetUnitTargetState("j1.service", "launched"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// nothing reschedules the unit, so moving it times out
+	sharedFlags.BlockAttempts = 1
+	defer func() { sharedFlags.BlockAttempts = 0 }()
+	if exit := runDrainMachine(cmdDrain, []string{"595989bb"}); exit != 1 {
+		t.Errorf("expected exit code 1, got %d", exit)
+	}
+	u, _ := cAPI.Unit("j1.service")
+	if u == nil || u.DesiredState != "launched" {
+		t.Errorf("expected desired state of unit to be restored, got %v", u)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickswift/fleet/job"
+)
+
+// defaultDrainBlockAttempts bounds the wait for each phase of moving a unit,
+// so that a unit which never moves or becomes active does not hang the drain
+const defaultDrainBlockAttempts = 120
+
+var cmdDrain = &cobra.Command{
+	Use:   "drain [--block-attempts=N] MACHINE",
+	Short: "Move all units off a machine",
+	Long: `Cordon a machine and move the non-global units scheduled to it to other
+machines, one at a time. Each unit is stopped, rescheduled by the engine, and
+fleetctl waits for its replacement to become active before moving the next one.
+
+Units which no other machine is able to run, e.g. because of MachineID or
+Conflicts requirements, are left in place. Global units are not affected. If a
+unit fails to move within --block-attempts attempts of each phase, its desired
+state is restored and the drain moves on to the next unit.
+
+The machine may be identified by its full or short ID.
+
+Drain a machine:
+	fleetctl drain 113f16a7`,
+	Run: runWrapper(runDrainMachine),
+}
+
+func init() {
+	cmdFleet.AddCommand(cmdDrain)
+
+	cmdDrain.Flags().IntVar(&sharedFlags.BlockAttempts, "block-attempts", defaultDrainBlockAttempts, "Wait for each unit to be moved, performing up to N attempts per phase before giving up. A value of 0 indicates no limit.")
+}
+
+func runDrainMachine(cCmd *cobra.Command, args []string) (exit int) {
+	if len(args) != 1 {
+		stderr("One machine must be provided")
+		return 1
+	}
+
+	ms, err := findMachine(args[0])
+	if err != nil {
+		stderr("%v", err)
+		return 1
+	}
+
+	if !ms.Cordoned {
+		if err := cAPI.CordonMachine(ms.ID); err != nil {
+			stderr("Error cordoning machine %s: %v", ms.ID, err)
+			return 1
+		}
+		stdout("Cordoned machine %s", ms.ID)
+	}
+
+	units, err := cAPI.Units()
+	if err != nil {
+		stderr("Error retrieving list of units from repository: %v", err)
+		return 1
+	}
+
+	attempts := sharedFlags.BlockAttempts
+	for _, u := range units {
+		if u.MachineID != ms.ID || suToGlobal(*u) || job.JobState(u.DesiredState) == job.JobStateInactive {
+			continue
+		}
+
+		dest, err := drainUnit(u.Name, ms.ID, job.JobState(u.DesiredState), attempts)
+		if err != nil {
+			stderr("Unable to move unit %s: %v", u.Name, err)
+			exit = 1
+			continue
+		}
+		stdout("Moved unit %s to %s", u.Name, dest)
+	}
+
+	if exit == 0 {
+		stdout("Drained machine %s", ms.ID)
+	}
+	return
+}
+
+// drainUnit moves the named unit off the given machine by deactivating it
+// until the engine unschedules it, and then restoring its desired state so
+// the engine schedules it elsewhere. It waits for the unit to reach its
+// desired state on the new machine, and for launched units also for systemd
+// to report it active. The ID of the new machine is returned. If the unit
+// fails to move, its desired state is restored.
+func drainUnit(name, machID string, ds job.JobState, attempts int) (dest string, err error) {
+	exp, err := cAPI.ExplainUnit(name)
+	if err != nil {
+		return "", err
+	}
+	if exp == nil {
+		return "", fmt.Errorf("unit not found")
+	}
+	movable := false
+	for _, mexp := range exp.Machines {
+		if mexp.MachineID != machID && mexp.Schedulable {
+			movable = true
+			break
+		}
+	}
+	if !movable {
+		return "", fmt.Errorf("no other machine is able to run it")
+	}
+
+	if err := cAPI.SetUnitTargetState(name, string(job.JobStateInactive)); err != nil {
+		return "", err
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if rerr := cAPI.SetUnitTargetState(name, string(ds)); rerr != nil {
+			err = fmt.Errorf("%v; restoring desired state %s failed: %v", err, ds, rerr)
+		}
+	}()
+
+	err = waitUntil(attempts, func() (bool, error) {
+		u, err := cAPI.Unit(name)
+		if err != nil || u == nil {
+			return false, err
+		}
+		return u.MachineID != machID, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("waiting for unit to be unscheduled: %v", err)
+	}
+
+	if err := cAPI.SetUnitTargetState(name, string(ds)); err != nil {
+		return "", err
+	}
+
+	err = waitUntil(attempts, func() (bool, error) {
+		u, err := cAPI.Unit(name)
+		if err != nil || u == nil {
+			return false, err
+		}
+		dest = u.MachineID
+		return dest != "" && dest != machID && job.JobState(u.CurrentState) == ds, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("waiting for unit to be rescheduled: %v", err)
+	}
+
+	if ds != job.JobStateLaunched {
+		return dest, nil
+	}
+
+	err = waitUntil(attempts, func() (bool, error) {
+		states, err := cAPI.UnitStates()
+		if err != nil {
+			return false, err
+		}
+		for _, state := range states {
+			if state.Name == name && state.MachineID == dest && state.SystemdActiveState == "active" {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("waiting for unit to become active: %v", err)
+	}
+
+	return dest, nil
+}
+
+// waitUntil polls the given condition until it is met, performing up to
+// maxAttempts attempts, or an unlimited number if maxAttempts is less than 1.
+// An error returned by the condition aborts the polling.
+func waitUntil(maxAttempts int, cond func() (bool, error)) error {
+	for attempt := 0; maxAttempts < 1 || attempt < maxAttempts; attempt++ {
+		ok, err := cond()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		time.Sleep(defaultSleepTime)
+	}
+	return fmt.Errorf("timed out after %d attempts", maxAttempts)
+}
@@ -0,0 +1,147 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickswift/fleet/schema"
+)
+
+// snapshotVersion is the version of the snapshot format written by export
+const snapshotVersion = 1
+
+// snapshot is the state of a cluster written by export and restored by
+// import.
+type snapshot struct {
+	Version     int                  `json:"version"`
+	Units       []*schema.Unit       `json:"units"`
+	ReplicaSets []*schema.ReplicaSet `json:"replicaSets,omitempty"`
+	Machines    []snapshotMachine    `json:"machines,omitempty"`
+}
+
+// snapshotMachine is the metadata of a single machine at the time of the
+// export.
+type snapshotMachine struct {
+	ID       string            `json:"id"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+var (
+	cmdExport = &cobra.Command{
+		Use:   "export [--metadata]",
+		Short: "Write a snapshot of the cluster to stdout",
+		Long: `Write the unit files, desired states and machines of all units, as well as
+all replica sets, to stdout as JSON. With --metadata, the metadata of every
+machine is included as well. The snapshot may be restored with fleetctl import.
+
+Back up a cluster:
+	fleetctl export --metadata > cluster.json`,
+		Run: runWrapper(runExport),
+	}
+
+	exportFlags = struct {
+		Metadata bool
+	}{}
+)
+
+func init() {
+	cmdFleet.AddCommand(cmdExport)
+
+	cmdExport.Flags().BoolVar(&exportFlags.Metadata, "metadata", false, "Include the metadata of every machine.")
+}
+
+func runExport(cCmd *cobra.Command, args []string) (exit int) {
+	if len(args) != 0 {
+		stderr("export does not take any arguments")
+		return 1
+	}
+
+	snap, err := buildSnapshot(exportFlags.Metadata)
+	if err != nil {
+		stderr("Error creating snapshot: %v", err)
+		return 1
+	}
+
+	if err := writeSnapshot(os.Stdout, snap); err != nil {
+		stderr("Error writing snapshot: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// buildSnapshot fetches the state of the cluster to export.
+func buildSnapshot(withMetadata bool) (*snapshot, error) {
+	units, err := cAPI.Units()
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving units: %v", err)
+	}
+	rsets, err := cAPI.ReplicaSets()
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving replica sets: %v", err)
+	}
+
+	snap := &snapshot{
+		Version:     snapshotVersion,
+		Units:       make([]*schema.Unit, 0, len(units)),
+		ReplicaSets: rsets,
+	}
+	for _, u := range units {
+		// the current state is left out, as it is not restored
+		snap.Units = append(snap.Units, &schema.Unit{
+			Name:         u.Name,
+			Options:      u.Options,
+			DesiredState: u.DesiredState,
+			MachineID:    u.MachineID,
+		})
+	}
+
+	if withMetadata {
+		machines, err := cAPI.Machines()
+		if err != nil {
+			return nil, fmt.Errorf("failed retrieving machines: %v", err)
+		}
+		for _, ms := range machines {
+			snap.Machines = append(snap.Machines, snapshotMachine{ID: ms.ID, Metadata: ms.Metadata})
+		}
+	}
+
+	return snap, nil
+}
+
+func writeSnapshot(w io.Writer, snap *snapshot) error {
+	b, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+func readSnapshot(r io.Reader) (*snapshot, error) {
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("failed decoding snapshot: %v", err)
+	}
+	if snap.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d, expected %d", snap.Version, snapshotVersion)
+	}
+	return &snap, nil
+}
@@ -38,6 +38,7 @@ import (
 
 	"github.com/nickswift/fleet/api"
 	"github.com/nickswift/fleet/client"
+	"github.com/nickswift/fleet/engine"
 	"github.com/nickswift/fleet/job"
 	"github.com/nickswift/fleet/log"
 	"github.com/nickswift/fleet/machine"
@@ -97,7 +98,8 @@ var (
 		SSHTimeout            float64
 		SSHUserName           string
 
-		EtcdKeyPrefix string
+		EtcdKeyPrefix  string
+		EtcdAPIVersion string
 	}{}
 
 	// flags used by multiple commands
@@ -107,6 +109,7 @@ var (
 		NoLegend      bool
 		NoBlock       bool
 		Replace       bool
+		ChangeCause   string
 		BlockAttempts int
 		Fields        string
 		SSHPort       int
@@ -147,6 +150,7 @@ func init() {
 	cmdFleet.PersistentFlags().StringVar(&globalFlags.ClientDriver, "driver", clientDriverAPI, fmt.Sprintf("Adapter used to execute fleetctl commands. Options include %q and %q.", clientDriverAPI, clientDriverEtcd))
 	cmdFleet.PersistentFlags().StringVar(&globalFlags.Endpoint, "endpoint", defaultEndpoint, fmt.Sprintf("Location of the fleet API if --driver=%s. Alternatively, if --driver=%s, location of the etcd API.", clientDriverAPI, clientDriverEtcd))
 	cmdFleet.PersistentFlags().StringVar(&globalFlags.EtcdKeyPrefix, "etcd-key-prefix", registry.DefaultKeyPrefix, "Keyspace for fleet data in etcd (development use only!)")
+	cmdFleet.PersistentFlags().StringVar(&globalFlags.EtcdAPIVersion, "etcd-api-version", registry.DefaultEtcdAPIVersion, fmt.Sprintf("Version of the etcd API used if --driver=%s, v2 or v3.", clientDriverEtcd))
 
 	cmdFleet.PersistentFlags().StringVar(&globalFlags.KeyFile, "key-file", "", "Location of TLS key file used to secure communication with the fleet API or etcd")
 	cmdFleet.PersistentFlags().StringVar(&globalFlags.CertFile, "cert-file", "", "Location of TLS cert file used to secure communication with the fleet API or etcd")
@@ -476,20 +480,20 @@ func getRegistryClient(cCmd *cobra.Command) (client.API, error) {
 		HeaderTimeoutPerRequest: getRequestTimeoutFlag(cCmd),
 	}
 
-	eClient, err := etcd.New(eCfg)
+	etcdAPIVersion, _ := cmdFleet.PersistentFlags().GetString("etcd-api-version")
+	kAPI, err := registry.NewKeysAPI(etcdAPIVersion, eCfg)
 	if err != nil {
 		return nil, err
 	}
 
 	etcdKeyPrefix, _ := cmdFleet.PersistentFlags().GetString("etcd-key-prefix")
-	kAPI := etcd.NewKeysAPI(eClient)
 	reg := registry.NewEtcdRegistry(kAPI, etcdKeyPrefix)
 
 	if msg, ok := checkVersion(reg); !ok {
 		stderr(msg)
 	}
 
-	return &client.RegistryClient{Registry: reg}, nil
+	return &client.RegistryClient{Registry: reg, Explainer: engine.Explainer{}, RolloutReporter: engine.RolloutReporter{}}, nil
 }
 
 // getChecker creates and returns a HostKeyChecker, or nil if any error is encountered
@@ -655,13 +659,71 @@ func findUnits(args []string) (sus []schema.Unit, err error) {
 	return filtered, nil
 }
 
-func createUnit(name string, uf *unit.UnitFile) (*schema.Unit, error) {
+func createUnit(name string, uf *unit.UnitFile, cause string) (*schema.Unit, error) {
+	u, err := newSchemaUnit(name, uf, cause)
+	if err != nil {
+		return nil, err
+	}
+	err = cAPI.CreateUnit(u)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating unit %s: %v", name, err)
+	}
+
+	log.Debugf("Created Unit(%s) in Registry", name)
+	return u, nil
+}
+
+// createUnitIfMatch creates or replaces the unit only if the unit in the
+// Registry still has the given ETag, or if etag is empty, only if the unit
+// does not exist yet. This keeps concurrent replacements from silently
+// overwriting each other.
+func createUnitIfMatch(name string, uf *unit.UnitFile, cause, etag string) (*schema.Unit, error) {
+	u, err := newSchemaUnit(name, uf, cause)
+	if err != nil {
+		return nil, err
+	}
+	if err := createSchemaUnitIfMatch(u, etag); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// createSchemaUnitIfMatch creates or replaces the unit like createUnitIfMatch
+// does, for a unit whose options are known already.
+func createSchemaUnitIfMatch(u *schema.Unit, etag string) error {
+	err := cAPI.CreateUnitIfMatch(u, etag)
+	if client.IsErrorPreconditionFailed(err) {
+		return fmt.Errorf("unit %s was changed concurrently, please try again", u.Name)
+	} else if err != nil {
+		return fmt.Errorf("failed creating unit %s: %v", u.Name, err)
+	}
+
+	log.Debugf("Created Unit(%s) in Registry", u.Name)
+	return nil
+}
+
+// unchangedUnitETag returns the ETag of the unit in the Registry, as long as
+// it still has the unit file of the given unit, which was retrieved earlier.
+func unchangedUnitETag(u *schema.Unit) (string, error) {
+	cur, etag, err := cAPI.UnitWithETag(u.Name)
+	if err != nil {
+		return "", fmt.Errorf("failed retrieving unit %s: %v", u.Name, err)
+	}
+	if cur == nil || !unit.MatchUnitFiles(schema.MapSchemaUnitOptionsToUnitFile(cur.Options), schema.MapSchemaUnitOptionsToUnitFile(u.Options)) {
+		return "", fmt.Errorf("unit %s was changed concurrently, please try again", u.Name)
+	}
+	return etag, nil
+}
+
+// newSchemaUnit validates the unit file and returns the unit to create.
+func newSchemaUnit(name string, uf *unit.UnitFile, cause string) (*schema.Unit, error) {
 	if uf == nil {
 		return nil, fmt.Errorf("nil unit provided")
 	}
 	u := schema.Unit{
-		Name:    name,
-		Options: schema.MapUnitFileToSchemaUnitOptions(uf),
+		Name:        name,
+		Options:     schema.MapUnitFileToSchemaUnitOptions(uf),
+		ChangeCause: cause,
 	}
 	// TODO(jonboulle): this dependency on the API package is awkward, and
 	// redundant with the check in api.unitsResource.set, but it is a
@@ -673,19 +735,31 @@ func createUnit(name string, uf *unit.UnitFile) (*schema.Unit, error) {
 	if err := api.ValidateOptions(u.Options); err != nil {
 		return nil, err
 	}
-	j := &job.Job{Unit: *uf}
+	j := &job.Job{Name: name, Unit: *uf}
 	if err := j.ValidateRequirements(); err != nil {
 		log.Warningf("Unit %s: %v", name, err)
 	}
-	err := cAPI.CreateUnit(&u)
-	if err != nil {
-		return nil, fmt.Errorf("failed creating unit %s: %v", name, err)
+	if len(j.StartAfter()) > 0 {
+		units, err := cAPI.Units()
+		if err != nil {
+			return nil, fmt.Errorf("failed retrieving units: %v", err)
+		}
+		if err := api.ValidateStartAfter(name, u.Options, units); err != nil {
+			return nil, err
+		}
 	}
-
-	log.Debugf("Created Unit(%s) in Registry", name)
 	return &u, nil
 }
 
+// changeCause returns the cause recorded with the unit file revisions
+// created by the named command, which defaults to the command itself.
+func changeCause(command string) string {
+	if sharedFlags.ChangeCause != "" {
+		return sharedFlags.ChangeCause
+	}
+	return fmt.Sprintf("%s %s", cliName, command)
+}
+
 // checkReplaceUnitState checks if the unit should be replaced.
 // It takes a Unit object as a parameter.
 // It returns 0 on success and if the unit should be replaced, 1 if the
@@ -729,14 +803,15 @@ func checkReplaceUnitState(unit *schema.Unit) (int, error) {
 // checkUnitCreation checks if the unit should be created.
 // It takes a unit file path as a parameter.
 // It returns 0 on success and if the unit should be created, 1 if the
-// unit should not be created; and any error encountered.
-func checkUnitCreation(cCmd *cobra.Command, arg string) (int, error) {
+// unit should not be created; the ETag of the unit to replace, which is
+// empty if the unit does not exist; and any error encountered.
+func checkUnitCreation(cCmd *cobra.Command, arg string) (int, string, error) {
 	name := unitNameMangle(arg)
 
 	// First, check if there already exists a Unit by the given name in the Registry
-	unit, err := cAPI.Unit(name)
+	unit, etag, err := cAPI.UnitWithETag(name)
 	if err != nil {
-		return 1, fmt.Errorf("error retrieving Unit(%s) from Registry: %v", name, err)
+		return 1, "", fmt.Errorf("error retrieving Unit(%s) from Registry: %v", name, err)
 	}
 
 	replace, _ := cCmd.Flags().GetBool("replace")
@@ -747,7 +822,7 @@ func checkUnitCreation(cCmd *cobra.Command, arg string) (int, error) {
 			log.Debugf("Unit(%s) was not found in Registry", name)
 		}
 		// Create a new unit
-		return 0, nil
+		return 0, "", nil
 	}
 
 	// if replace is not set then we warn in case the units differ
@@ -756,9 +831,10 @@ func checkUnitCreation(cCmd *cobra.Command, arg string) (int, error) {
 	// if replace is set then we fail for errors
 	if replace {
 		if err != nil {
-			return 1, err
+			return 1, "", err
 		} else if different {
-			return checkReplaceUnitState(unit)
+			ret, err := checkReplaceUnitState(unit)
+			return ret, etag, err
 		} else {
 			stdout("Found same Unit(%s) in Registry, nothing to do", unit.Name)
 		}
@@ -766,7 +842,7 @@ func checkUnitCreation(cCmd *cobra.Command, arg string) (int, error) {
 		log.Debugf("Found same Unit(%s) in Registry, no need to recreate it", name)
 	}
 
-	return 1, nil
+	return 1, "", nil
 }
 
 // lazyCreateUnits iterates over a set of unit names and, for each, attempts to
@@ -787,7 +863,7 @@ func lazyCreateUnits(cCmd *cobra.Command, args []string) error {
 		arg = maybeAppendDefaultUnitType(arg)
 		name := unitNameMangle(arg)
 
-		ret, err := checkUnitCreation(cCmd, arg)
+		ret, etag, err := checkUnitCreation(cCmd, arg)
 		if err != nil {
 			return err
 		} else if ret != 0 {
@@ -802,7 +878,7 @@ func lazyCreateUnits(cCmd *cobra.Command, args []string) error {
 			return err
 		}
 
-		_, err = createUnit(name, uf)
+		_, err = createUnitIfMatch(name, uf, changeCause(cCmd.Name()), etag)
 		if err != nil {
 			return err
 		}
@@ -1211,6 +1287,32 @@ func machineState(machID string) (*machine.MachineState, error) {
 	return nil, nil
 }
 
+// findMachine returns the MachineState of the machine identified by the
+// given full or short ID. An error is returned if no machine or more than
+// one machine matches.
+func findMachine(id string) (*machine.MachineState, error) {
+	machines, err := cAPI.Machines()
+	if err != nil {
+		return nil, fmt.Errorf("Error retrieving list of active machines: %v", err)
+	}
+
+	var found *machine.MachineState
+	for i, ms := range machines {
+		if !ms.MatchID(id) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("Machine ID %s is ambiguous", id)
+		}
+		found = &machines[i]
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("Machine %s not found", id)
+	}
+	return found, nil
+}
+
 // cachedMachineState makes a best-effort to retrieve the MachineState of the given machine ID.
 // It memoizes MachineState information for the life of a fleetctl invocation.
 // Any error encountered retrieving the list of machines is ignored.
@@ -16,9 +16,14 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/nickswift/fleet/client"
+	"github.com/nickswift/fleet/engine"
 	"github.com/nickswift/fleet/job"
 	"github.com/nickswift/fleet/machine"
 	"github.com/nickswift/fleet/registry"
@@ -27,6 +32,7 @@ import (
 	"github.com/nickswift/fleet/version"
 
 	"github.com/coreos/go-semver/semver"
+	"github.com/spf13/cobra"
 )
 
 type commandTestResults struct {
@@ -88,7 +94,102 @@ func newFakeRegistryForCommands(unitPrefix string, unitCount int, template bool)
 	reg.SetUnitStates(states)
 	reg.SetJobs(jobs)
 
-	return &client.RegistryClient{Registry: reg}
+	return &client.RegistryClient{Registry: reg, Explainer: engine.Explainer{}, RolloutReporter: engine.RolloutReporter{}}
+}
+
+// newFakeRegistryWithHistoryForCommands returns the registry of
+// newFakeRegistryForCommands, in which j1.service has been scheduled to
+// the second machine after the first one went away, foo.service requires
+// the metadata of the second machine, and hello.service has been created
+// and replaced.
+func newFakeRegistryWithHistoryForCommands(t *testing.T) client.API {
+	api := newFakeRegistryForCommands("j", 2, false)
+	reg := api.(*client.RegistryClient).Registry
+	events := []job.UnitEvent{
+		{
+			Time:      time.Date(2015, 3, 1, 3, 0, 0, 0, time.UTC),
+			MachineID: "c31e44e1-f858-436e-933e-59c642517860",
+			Type:      "UnscheduleUnit",
+			Reason:    "target Machine(c31e44e1-f858-436e-933e-59c642517860) went away",
+		},
+		{
+			Time:      time.Date(2015, 3, 1, 3, 0, 5, 0, time.UTC),
+			MachineID: "595989bb-cbb7-49ce-8726-722d6e157b4e",
+			Type:      "AttemptScheduleUnit",
+			Reason:    "target state launched and unit not scheduled",
+		},
+	}
+	for _, ev := range events {
+		if err := reg.RecordUnitEvent("j1.service", ev); err != nil {
+			t.Fatalf("unexpected error recording event: %v", err)
+		}
+	}
+
+	cAPI = api
+	err := cAPI.CreateUnit(&schema.Unit{
+		Name:         "foo.service",
+		Options:      []*schema.UnitOption{{Section: "X-Fleet", Name: "MachineMetadata", Value: "foo=bar"}},
+		DesiredState: "launched",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating unit foo.service: %v", err)
+	}
+	replaceUnitForTests(t, "hello.service", "/bin/a")
+	replaceUnitForTests(t, "hello.service", "/bin/b")
+	return api
+}
+
+// replaceUnitForTests creates or replaces the named unit with a unit file
+// running the given command, recording a new revision.
+func replaceUnitForTests(t *testing.T, name, exec string) {
+	err := cAPI.CreateUnit(&schema.Unit{
+		Name:         name,
+		Options:      []*schema.UnitOption{{Section: "Service", Name: "ExecStart", Value: exec}},
+		DesiredState: "launched",
+		ChangeCause:  "run " + exec,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating unit %s: %v", name, err)
+	}
+}
+
+// runCommandForTests runs the command with the given arguments and returns
+// its exit code along with what it wrote to stdout.
+func runCommandForTests(t *testing.T, run func(*cobra.Command, []string) int, cmd *cobra.Command, args []string) (int, string) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("unexpected error creating pipe: %v", err)
+	}
+	defer r.Close()
+
+	stdout, tabOut := os.Stdout, out
+	os.Stdout, out = w, getTabOutWithWriter(w)
+	defer func() {
+		os.Stdout, out = stdout, tabOut
+	}()
+
+	output := make(chan string)
+	go func() {
+		b, _ := ioutil.ReadAll(r)
+		output <- string(b)
+	}()
+
+	exit := run(cmd, args)
+	out.Flush()
+	w.Close()
+	return exit, <-output
+}
+
+// outputLinesForTests splits the output of a command into lines, with the
+// whitespace padding the columns of tables collapsed to single spaces.
+func outputLinesForTests(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line != "" {
+			lines = append(lines, strings.Join(strings.Fields(line), " "))
+		}
+	}
+	return lines
 }
 
 func appendJobsForTests(jobs *[]job.Job, machine machine.MachineState, prefix string, unitCount int, template bool) {
@@ -343,10 +444,68 @@ Replaces=abcd`),
 	for i, tt = range testCases {
 		un = tt.name
 		uf = tt.uf
-		if _, err := createUnit(un, uf); err == nil {
+		if _, err := createUnit(un, uf, ""); err == nil {
 			t.Errorf("case %d did not return error as expected!", i)
 			t.Logf("unit name: %v", un)
 			t.Logf("unit file: %#v", uf)
 		}
 	}
 }
+
+func TestCreateUnitIfMatch(t *testing.T) {
+	cAPI = newFakeRegistryForCommands("j", 1, false)
+	a := newUnitFile(t, "[Service]\nExecStart=/bin/a")
+	b := newUnitFile(t, "[Service]\nExecStart=/bin/b")
+
+	if _, err := createUnitIfMatch("foo.service", a, "", ""); err != nil {
+		t.Fatalf("unexpected error creating unit: %v", err)
+	}
+	_, etag, err := cAPI.UnitWithETag("foo.service")
+	if err != nil || etag == "" {
+		t.Fatalf("expected unit with ETag, got %q: %v", etag, err)
+	}
+
+	// another operator replaces the unit in the meantime
+	if _, err := createUnit("foo.service", b, ""); err != nil {
+		t.Fatalf("unexpected error replacing unit: %v", err)
+	}
+	if _, err := createUnitIfMatch("foo.service", a, "", etag); err == nil {
+		t.Errorf("expected error replacing unit changed concurrently")
+	}
+	if _, err := createUnitIfMatch("foo.service", a, "", ""); err == nil {
+		t.Errorf("expected error creating unit which exists")
+	}
+
+	_, etag, _ = cAPI.UnitWithETag("foo.service")
+	if _, err := createUnitIfMatch("foo.service", a, "", etag); err != nil {
+		t.Errorf("unexpected error replacing unit: %v", err)
+	}
+}
+
+func TestUnchangedUnitETag(t *testing.T) {
+	cAPI = newFakeRegistryForCommands("j", 1, false)
+	a := newUnitFile(t, "[Service]\nExecStart=/bin/a")
+	b := newUnitFile(t, "[Service]\nExecStart=/bin/b")
+
+	u, err := createUnit("foo.service", a, "")
+	if err != nil {
+		t.Fatalf("unexpected error creating unit: %v", err)
+	}
+	if etag, err := unchangedUnitETag(u); err != nil || etag == "" {
+		t.Errorf("expected ETag of unchanged unit, got %q: %v", etag, err)
+	}
+
+	// another operator replaces the unit in the meantime
+	if _, err := createUnit("foo.service", b, ""); err != nil {
+		t.Fatalf("unexpected error replacing unit: %v", err)
+	}
+	if _, err := unchangedUnitETag(u); err == nil {
+		t.Errorf("expected error for unit changed concurrently")
+	}
+	if err := cAPI.DestroyUnit("foo.service"); err != nil {
+		t.Fatalf("unexpected error destroying unit: %v", err)
+	}
+	if _, err := unchangedUnitETag(u); err == nil {
+		t.Errorf("expected error for unit destroyed concurrently")
+	}
+}
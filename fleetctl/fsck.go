@@ -0,0 +1,100 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/nickswift/fleet/client"
+	"github.com/nickswift/fleet/registry"
+)
+
+var (
+	cmdFsck = &cobra.Command{
+		Use:   "fsck [--repair]",
+		Short: "Check the registry for leftover data",
+		Long: `Walk the keys of fleet in etcd and report data left behind: units whose unit
+file is missing, keys of units which no longer exist, units scheduled to
+machines which no longer exist, and unit states published by machines or for
+units which no longer exist. The exit status is 1 if any is found.
+
+With --repair, the offending keys are removed. A key which changed since it
+was checked is left alone, as is a directory in which any key changed.
+
+As it reads etcd directly, fsck requires --driver=etcd.
+
+Check the registry and remove the leftovers:
+	fleetctl --driver=etcd fsck --repair`,
+		Run: runWrapper(runFsck),
+	}
+
+	fsckFlags = struct {
+		Repair bool
+	}{}
+)
+
+// registryChecker is implemented by the registries whose keys fsck is able
+// to check.
+type registryChecker interface {
+	CheckConsistency() ([]registry.Inconsistency, error)
+	RepairInconsistency(registry.Inconsistency) error
+}
+
+func init() {
+	cmdFleet.AddCommand(cmdFsck)
+
+	cmdFsck.Flags().BoolVar(&fsckFlags.Repair, "repair", false, "Remove the keys of the inconsistencies found.")
+}
+
+func runFsck(cCmd *cobra.Command, args []string) (exit int) {
+	if len(args) != 0 {
+		stderr("fsck takes no arguments")
+		return 1
+	}
+
+	var checker registryChecker
+	if rc, ok := cAPI.(*client.RegistryClient); ok {
+		checker, _ = rc.Registry.(registryChecker)
+	}
+	if checker == nil {
+		stderr("fsck requires --driver=%s", clientDriverEtcd)
+		return 1
+	}
+
+	found, err := checker.CheckConsistency()
+	if err != nil {
+		stderr("Error checking registry: %v", err)
+		return 1
+	}
+
+	for _, inc := range found {
+		stdout("%v", inc)
+		if !fsckFlags.Repair {
+			exit = 1
+			continue
+		}
+		if err := checker.RepairInconsistency(inc); err != nil {
+			stderr("Error removing %s: %v", inc.Key, err)
+			exit = 1
+			continue
+		}
+		stdout("Removed %s", inc.Key)
+	}
+
+	if len(found) == 0 {
+		stdout("No inconsistencies found")
+	}
+	return
+}
@@ -0,0 +1,75 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/nickswift/fleet/client"
+	"github.com/nickswift/fleet/pkg/localkv"
+	"github.com/nickswift/fleet/registry"
+)
+
+func TestRunFsck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fleetctl-fsck-")
+	if err != nil {
+		t.Fatalf("unexpected error creating directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	kAPI, err := localkv.NewKeysAPI(filepath.Join(dir, "registry.json"))
+	if err != nil {
+		t.Fatalf("unexpected error opening registry: %v", err)
+	}
+
+	// fsck reads the keys of etcd, which the API does not expose
+	cAPI = newFakeRegistryForCommands("j", 2, false)
+	if exit := runFsck(cmdFsck, nil); exit != 1 {
+		t.Errorf("expected exit code 1 without etcd registry, got %d", exit)
+	}
+
+	cAPI = &client.RegistryClient{Registry: registry.NewEtcdRegistry(kAPI, registry.DefaultKeyPrefix)}
+	fsckFlags.Repair = false
+	if exit := runFsck(cmdFsck, nil); exit != 0 {
+		t.Errorf("expected exit code 0 for empty registry, got %d", exit)
+	}
+	if exit := runFsck(cmdFsck, []string{"extra"}); exit != 1 {
+		t.Errorf("expected exit code 1 with arguments, got %d", exit)
+	}
+
+	key := registry.DefaultKeyPrefix + "states/gone.service/XXX"
+	if _, err = kAPI.Set(context.Background(), key, "{}", nil); err != nil {
+		t.Fatalf("unexpected error setting %s: %v", key, err)
+	}
+	if exit := runFsck(cmdFsck, nil); exit != 1 {
+		t.Errorf("expected exit code 1 for leftover state, got %d", exit)
+	}
+	if _, err = kAPI.Get(context.Background(), key, nil); err != nil {
+		t.Errorf("expected leftover state to be kept without --repair, got %v", err)
+	}
+
+	fsckFlags.Repair = true
+	defer func() { fsckFlags.Repair = false }()
+	if exit := runFsck(cmdFsck, nil); exit != 0 {
+		t.Errorf("expected exit code 0 when repairing, got %d", exit)
+	}
+	if _, err = kAPI.Get(context.Background(), key, nil); err == nil {
+		t.Errorf("expected leftover state to be removed with --repair")
+	}
+}
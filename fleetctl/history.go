@@ -0,0 +1,85 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickswift/fleet/machine"
+)
+
+var cmdHistory = &cobra.Command{
+	Use:   "history [-l|--full] [--no-legend] UNIT",
+	Short: "Show the scheduling history of a unit",
+	Long: `Show the most recent scheduling decisions the fleet engine made about the
+given unit, oldest first: when it was scheduled to or unscheduled from which
+machine, and why.
+
+Find out why hello.service moved to another machine:
+	fleetctl history hello.service`,
+	Run: runWrapper(runUnitHistory),
+}
+
+func init() {
+	cmdFleet.AddCommand(cmdHistory)
+
+	cmdHistory.Flags().BoolVar(&sharedFlags.Full, "full", false, "Do not ellipsize fields on output")
+	cmdHistory.Flags().BoolVar(&sharedFlags.Full, "l", false, "Shorthand for --full")
+	cmdHistory.Flags().BoolVar(&sharedFlags.NoLegend, "no-legend", false, "Do not print a legend (column headers)")
+}
+
+func runUnitHistory(cCmd *cobra.Command, args []string) (exit int) {
+	if len(args) != 1 {
+		stderr("One unit file must be provided")
+		return 1
+	}
+
+	name := unitNameMangle(args[0])
+	h, err := cAPI.UnitHistory(name)
+	if err != nil {
+		stderr("Error retrieving history of unit %s: %v", name, err)
+		return 1
+	}
+	if h == nil {
+		stderr("Unit %s does not exist", name)
+		return 1
+	}
+
+	if len(h.Events) == 0 {
+		stdout("No events recorded for unit %s", name)
+		return 0
+	}
+
+	noLegend, _ := cCmd.Flags().GetBool("no-legend")
+	if !noLegend {
+		fmt.Fprintln(out, "TIME\tMACHINE\tEVENT\tREASON")
+	}
+
+	full, _ := cCmd.Flags().GetBool("full")
+	for _, ev := range h.Events {
+		fmt.Fprintln(out, strings.Join([]string{
+			ev.Time,
+			machineIDLegend(machine.MachineState{ID: ev.MachineID}, full),
+			ev.Type,
+			ev.Reason,
+		}, "\t"))
+	}
+
+	out.Flush()
+	return 0
+}
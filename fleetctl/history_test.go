@@ -0,0 +1,51 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRunUnitHistory(t *testing.T) {
+	j1 := []string{
+		"TIME MACHINE EVENT REASON",
+		"2015-03-01T03:00:00Z c31e44e1... UnscheduleUnit target Machine(c31e44e1-f858-436e-933e-59c642517860) went away",
+		"2015-03-01T03:00:05Z 595989bb... AttemptScheduleUnit target state launched and unit not scheduled",
+	}
+	tests := []struct {
+		args   []string
+		exit   int
+		output []string
+	}{
+		{[]string{"j1.service"}, 0, j1},
+		{[]string{"j1"}, 0, j1},
+		{[]string{"j2.service"}, 0, []string{"No events recorded for unit j2.service"}},
+		{[]string{"y1.service"}, 1, nil},
+		{[]string{}, 1, nil},
+		{[]string{"j1.service", "j2.service"}, 1, nil},
+	}
+
+	for i, tt := range tests {
+		cAPI = newFakeRegistryWithHistoryForCommands(t)
+		exit, output := runCommandForTests(t, runUnitHistory, cmdHistory, tt.args)
+		if exit != tt.exit {
+			t.Errorf("case %d: expected exit code %d, got %d", i, tt.exit, exit)
+		}
+		if lines := outputLinesForTests(output); !reflect.DeepEqual(lines, tt.output) {
+			t.Errorf("case %d: expected output %q, got %q", i, tt.output, lines)
+		}
+	}
+}
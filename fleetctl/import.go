@@ -0,0 +1,303 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickswift/fleet/schema"
+	"github.com/nickswift/fleet/unit"
+)
+
+var (
+	cmdImport = &cobra.Command{
+		Use:   "import [--dry-run] [--prune] [--metadata] FILE",
+		Short: "Restore a snapshot of a cluster",
+		Long: `Restore a snapshot written by fleetctl export. Units missing from the cluster
+are created, units whose unit file differs are replaced, and the desired
+states of units and the replicas of replica sets are set as in the snapshot.
+The engine schedules the units again, so they may end up on other machines
+than those recorded in the snapshot.
+
+With --prune, units and replica sets which are not part of the snapshot are
+destroyed. With --metadata, the metadata recorded for a machine is set on the
+machine with the same ID, if it exists. With --dry-run, the changes are only
+printed, followed by the units which are scheduled to other machines than
+recorded in the snapshot.
+
+FILE may be "-" to read the snapshot from stdin.
+
+Clone a cluster, checking the changes first:
+	fleetctl --endpoint=http://prod:49153 export > prod.json
+	fleetctl --endpoint=http://staging:49153 import --dry-run --prune prod.json
+	fleetctl --endpoint=http://staging:49153 import --prune prod.json`,
+		Run: runWrapper(runImport),
+	}
+
+	importFlags = struct {
+		DryRun   bool
+		Prune    bool
+		Metadata bool
+	}{}
+)
+
+func init() {
+	cmdFleet.AddCommand(cmdImport)
+
+	cmdImport.Flags().BoolVar(&importFlags.DryRun, "dry-run", false, "Only print the changes the import would make.")
+	cmdImport.Flags().BoolVar(&importFlags.Prune, "prune", false, "Destroy units and replica sets which are not part of the snapshot.")
+	cmdImport.Flags().BoolVar(&importFlags.Metadata, "metadata", false, "Set the metadata recorded for each machine.")
+}
+
+// importChange is a single change made to the cluster by an import
+type importChange struct {
+	desc  string
+	apply func() error
+}
+
+func runImport(cCmd *cobra.Command, args []string) (exit int) {
+	if len(args) != 1 {
+		stderr("One snapshot file must be provided")
+		return 1
+	}
+
+	var r io.Reader = os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			stderr("Error opening snapshot: %v", err)
+			return 1
+		}
+		defer f.Close()
+		r = f
+	}
+
+	snap, err := readSnapshot(r)
+	if err != nil {
+		stderr("Error reading snapshot: %v", err)
+		return 1
+	}
+
+	changes, err := planImport(snap, importFlags.Prune, importFlags.Metadata)
+	if err != nil {
+		stderr("Error comparing snapshot with cluster: %v", err)
+		return 1
+	}
+
+	if importFlags.DryRun {
+		diffs, err := diffSchedule(snap)
+		if err != nil {
+			stderr("Error comparing snapshot with cluster: %v", err)
+			return 1
+		}
+		if len(changes) == 0 {
+			stdout("Cluster already matches snapshot")
+		}
+		for _, c := range changes {
+			stdout(c.desc)
+		}
+		for _, d := range diffs {
+			stdout(d)
+		}
+		return 0
+	}
+
+	if len(changes) == 0 {
+		stdout("Cluster already matches snapshot")
+		return 0
+	}
+	for _, c := range changes {
+		if err := c.apply(); err != nil {
+			stderr("Error applying change %q: %v", c.desc, err)
+			return 1
+		}
+		stdout(c.desc)
+	}
+	return 0
+}
+
+// diffSchedule describes the units of the snapshot which are scheduled to
+// another machine in the cluster. These are not changed by an import, as the
+// engine schedules the units itself.
+func diffSchedule(snap *snapshot) ([]string, error) {
+	units, err := cAPI.Units()
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving units: %v", err)
+	}
+	current := make(map[string]string, len(units))
+	for _, u := range units {
+		current[u.Name] = u.MachineID
+	}
+
+	var diffs []string
+	for _, su := range snap.Units {
+		if su.MachineID == current[su.Name] {
+			continue
+		}
+		diffs = append(diffs, fmt.Sprintf("unit %s is scheduled to %s, snapshot has %s", su.Name, machineOrNone(current[su.Name]), machineOrNone(su.MachineID)))
+	}
+	return diffs, nil
+}
+
+func machineOrNone(machID string) string {
+	if machID == "" {
+		return "no machine"
+	}
+	return "machine " + machID
+}
+
+// planImport compares the snapshot with the cluster, returning the changes
+// which restore the snapshot in the order they must be applied.
+func planImport(snap *snapshot, prune, withMetadata bool) ([]importChange, error) {
+	units, err := cAPI.Units()
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving units: %v", err)
+	}
+	rsets, err := cAPI.ReplicaSets()
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving replica sets: %v", err)
+	}
+
+	var changes []importChange
+	existing := make(map[string]*schema.Unit, len(units))
+	for _, u := range units {
+		existing[u.Name] = u
+	}
+	snapUnits := make(map[string]bool, len(snap.Units))
+	for _, su := range snap.Units {
+		su := &schema.Unit{Name: su.Name, Options: su.Options, DesiredState: su.DesiredState, ChangeCause: changeCause("import")}
+		snapUnits[su.Name] = true
+
+		eu, ok := existing[su.Name]
+		switch {
+		case !ok:
+			changes = append(changes, importChange{
+				desc:  fmt.Sprintf("create unit %s (%s)", su.Name, su.DesiredState),
+				apply: func() error { return createSchemaUnitIfMatch(su, "") },
+			})
+		case !unit.MatchUnitFiles(schema.MapSchemaUnitOptionsToUnitFile(su.Options), schema.MapSchemaUnitOptionsToUnitFile(eu.Options)):
+			changes = append(changes, importChange{
+				desc: fmt.Sprintf("replace unit file of %s (%s)", su.Name, su.DesiredState),
+				apply: func() error {
+					etag, err := unchangedUnitETag(eu)
+					if err != nil {
+						return err
+					}
+					return createSchemaUnitIfMatch(su, etag)
+				},
+			})
+		case eu.DesiredState != su.DesiredState:
+			changes = append(changes, importChange{
+				desc:  fmt.Sprintf("set desired state of %s from %s to %s", su.Name, eu.DesiredState, su.DesiredState),
+				apply: func() error { return cAPI.SetUnitTargetState(su.Name, su.DesiredState) },
+			})
+		}
+	}
+
+	existingRS := make(map[string]int64, len(rsets))
+	for _, rs := range rsets {
+		existingRS[rs.Template] = rs.Replicas
+	}
+	snapRS := make(map[string]bool, len(snap.ReplicaSets))
+	for _, rs := range snap.ReplicaSets {
+		rs := rs
+		snapRS[rs.Template] = true
+		if replicas, ok := existingRS[rs.Template]; ok && replicas == rs.Replicas {
+			continue
+		}
+		changes = append(changes, importChange{
+			desc:  fmt.Sprintf("set replicas of %s to %d", rs.Template, rs.Replicas),
+			apply: func() error { return cAPI.SetReplicaSet(rs) },
+		})
+	}
+
+	if prune {
+		// replica sets go first, so their instances are not created again
+		for _, rs := range rsets {
+			template := rs.Template
+			if snapRS[template] {
+				continue
+			}
+			changes = append(changes, importChange{
+				desc:  fmt.Sprintf("destroy replica set of %s", template),
+				apply: func() error { return cAPI.DestroyReplicaSet(template) },
+			})
+		}
+		for _, u := range units {
+			name := u.Name
+			if snapUnits[name] {
+				continue
+			}
+			changes = append(changes, importChange{
+				desc:  fmt.Sprintf("destroy unit %s", name),
+				apply: func() error { return cAPI.DestroyUnit(name) },
+			})
+		}
+	}
+
+	if withMetadata && len(snap.Machines) > 0 {
+		mcs, err := planMetadataImport(snap.Machines)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, mcs...)
+	}
+
+	return changes, nil
+}
+
+// planMetadataImport returns the changes setting the recorded metadata on
+// the machines with the same IDs.
+func planMetadataImport(machines []snapshotMachine) ([]importChange, error) {
+	current, err := cAPI.Machines()
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving machines: %v", err)
+	}
+	metadata := make(map[string]map[string]string, len(current))
+	for _, ms := range current {
+		metadata[ms.ID] = ms.Metadata
+	}
+
+	var changes []importChange
+	for _, sm := range machines {
+		md, ok := metadata[sm.ID]
+		if !ok {
+			stderr("Skipping metadata of machine %s, which is not part of the cluster", sm.ID)
+			continue
+		}
+
+		var keys sort.StringSlice
+		for key := range sm.Metadata {
+			keys = append(keys, key)
+		}
+		keys.Sort()
+		for _, key := range keys {
+			machID, key, value := sm.ID, key, sm.Metadata[key]
+			if cur, ok := md[key]; ok && cur == value {
+				continue
+			}
+			changes = append(changes, importChange{
+				desc:  fmt.Sprintf("set metadata %s=%s of machine %s", key, value, machID),
+				apply: func() error { return cAPI.SetMachineMetadata(machID, key, value) },
+			})
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,179 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/nickswift/fleet/schema"
+	"github.com/nickswift/fleet/unit"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	cAPI = newFakeRegistryForCommands("j", 2, false)
+	cAPI.CreateUnit(&schema.Unit{
+		Name:         "hello.service",
+		DesiredState: "launched",
+		Options:      []*schema.UnitOption{{Section: "Service", Name: "ExecStart", Value: "/bin/true"}},
+	})
+	snap, err := buildSnapshot(false)
+	if err != nil {
+		t.Fatalf("unexpected error building snapshot: %v", err)
+	}
+	if len(snap.Units) != 3 || len(snap.Machines) != 0 {
+		t.Fatalf("expected 3 units and no machines, got %d and %d", len(snap.Units), len(snap.Machines))
+	}
+
+	var buf bytes.Buffer
+	if err := writeSnapshot(&buf, snap); err != nil {
+		t.Fatalf("unexpected error writing snapshot: %v", err)
+	}
+	got, err := readSnapshot(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error reading snapshot: %v", err)
+	}
+	for j, u := range snap.Units {
+		g := got.Units[j]
+		uf, gf := schema.MapSchemaUnitOptionsToUnitFile(u.Options), schema.MapSchemaUnitOptionsToUnitFile(g.Options)
+		if u.Name != g.Name || u.DesiredState != g.DesiredState || u.MachineID != g.MachineID || !unit.MatchUnitFiles(uf, gf) {
+			t.Errorf("unit changed in round trip:\nexpected %#v\ngot %#v", u, g)
+		}
+	}
+
+	for _, data := range []string{`{"version": 2}`, `{"units": []}`} {
+		if _, err := readSnapshot(bytes.NewBufferString(data)); err == nil {
+			t.Errorf("expected error reading snapshot %s of unknown version", data)
+		}
+	}
+}
+
+func TestPlanImport(t *testing.T) {
+	machID := "c31e44e1-f858-436e-933e-59c642517860"
+
+	tests := []struct {
+		prune    bool
+		metadata bool
+		changes  []string
+	}{
+		{
+			changes: []string{
+				"create unit j1.service (launched)",
+				"set desired state of k1.service from launched to inactive",
+				"set replicas of web@.service to 3",
+			},
+		},
+		{
+			prune: true,
+			changes: []string{
+				"create unit j1.service (launched)",
+				"set desired state of k1.service from launched to inactive",
+				"set replicas of web@.service to 3",
+				"destroy unit k2.service",
+			},
+		},
+		{
+			metadata: true,
+			changes: []string{
+				"create unit j1.service (launched)",
+				"set desired state of k1.service from launched to inactive",
+				"set replicas of web@.service to 3",
+				"set metadata rack=r12 of machine " + machID,
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		cAPI = newFakeRegistryForCommands("j", 1, false)
+		snap, err := buildSnapshot(true)
+		if err != nil {
+			t.Fatalf("case %d: unexpected error building snapshot: %v", i, err)
+		}
+		cAPI.SetUnitTargetState("j1.service", "inactive")
+		snap.Units[0].DesiredState = "launched"
+		snap.ReplicaSets = []*schema.ReplicaSet{{Template: "web@.service", Replicas: 3}}
+		snap.Machines = []snapshotMachine{
+			{ID: machID, Metadata: map[string]string{"ping": "pong", "rack": "r12"}},
+			{ID: "deadbeef", Metadata: map[string]string{"rack": "r1"}},
+		}
+
+		// restore the snapshot in a cluster running other units
+		cAPI = newFakeRegistryForCommands("k", 2, false)
+		cAPI.SetUnitTargetState("k1.service", "launched")
+		k1 := *snap.Units[0]
+		k1.Name, k1.DesiredState = "k1.service", "inactive"
+		snap.Units = append(snap.Units, &k1)
+		k1opts, err := cAPI.Unit("k1.service")
+		if err != nil || k1opts == nil {
+			t.Fatalf("case %d: unexpected error fetching k1.service: %v", i, err)
+		}
+		k1.Options = k1opts.Options
+
+		changes, err := planImport(snap, tt.prune, tt.metadata)
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		var descs []string
+		for _, c := range changes {
+			descs = append(descs, c.desc)
+			if err := c.apply(); err != nil {
+				t.Fatalf("case %d: unexpected error applying %q: %v", i, c.desc, err)
+			}
+		}
+		if !reflect.DeepEqual(tt.changes, descs) {
+			t.Errorf("case %d: expected changes %v, got %v", i, tt.changes, descs)
+		}
+
+		// once applied, the cluster matches the snapshot
+		changes, err = planImport(snap, tt.prune, tt.metadata)
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		if len(changes) != 0 {
+			t.Errorf("case %d: expected no changes after import, got %d", i, len(changes))
+		}
+	}
+}
+
+func TestDiffSchedule(t *testing.T) {
+	cAPI = newFakeRegistryForCommands("j", 1, false)
+	snap, err := buildSnapshot(false)
+	if err != nil {
+		t.Fatalf("unexpected error building snapshot: %v", err)
+	}
+	if len(snap.Units) != 1 || snap.Units[0].MachineID == "" {
+		t.Fatalf("expected snapshot with a scheduled unit, got %#v", snap.Units)
+	}
+	diffs, err := diffSchedule(snap)
+	if err != nil || len(diffs) != 0 {
+		t.Fatalf("expected no differences, got %v: %v", diffs, err)
+	}
+
+	machID := snap.Units[0].MachineID
+	snap.Units[0].MachineID = "deadbeef"
+	snap.Units = append(snap.Units, &schema.Unit{Name: "hello.service", MachineID: machID})
+	diffs, err = diffSchedule(snap)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"unit j1.service is scheduled to machine " + machID + ", snapshot has machine deadbeef",
+		"unit hello.service is scheduled to no machine, snapshot has machine " + machID,
+	}
+	if !reflect.DeepEqual(want, diffs) {
+		t.Errorf("expected differences %v, got %v", want, diffs)
+	}
+}
@@ -17,6 +17,7 @@ package main
 import (
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -46,6 +47,15 @@ var (
 			}
 			return formatMetadata(ms.Metadata)
 		},
+		"cordoned": func(ms *machine.MachineState, full bool) string {
+			return strconv.FormatBool(ms.Cordoned)
+		},
+		"taints": func(ms *machine.MachineState, full bool) string {
+			if len(ms.Taints) == 0 {
+				return "-"
+			}
+			return formatTaints(ms.Taints)
+		},
 	}
 )
 
@@ -116,6 +126,19 @@ func runListMachines(cCmd *cobra.Command, args []string) (exit int) {
 	return 0
 }
 
+func formatTaints(taints map[string]string) string {
+	var sorted sort.StringSlice
+	for k := range taints {
+		sorted = append(sorted, k)
+	}
+	sorted.Sort()
+	formatted := make([]string, len(sorted))
+	for i, key := range sorted {
+		formatted[i] = machine.FormatTaint(key, taints[key])
+	}
+	return strings.Join(formatted, ",")
+}
+
 func formatMetadata(metadata map[string]string) string {
 	pairs := make([]string, len(metadata))
 	idx := 0
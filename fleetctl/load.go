@@ -45,6 +45,7 @@ func init() {
 	cmdLoad.Flags().IntVar(&sharedFlags.BlockAttempts, "block-attempts", 0, "Wait until the jobs are loaded, performing up to N attempts before giving up. A value of 0 indicates no limit. Does not apply to global units.")
 	cmdLoad.Flags().BoolVar(&sharedFlags.NoBlock, "no-block", false, "Do not wait until the jobs have been loaded before exiting. Always the case for global units.")
 	cmdLoad.Flags().BoolVar(&sharedFlags.Replace, "replace", false, "Replace the old scheduled units in the cluster with new versions.")
+	cmdLoad.Flags().StringVar(&sharedFlags.ChangeCause, "change-cause", "", "Cause recorded with the new unit file revisions. Defaults to the command.")
 }
 
 func runLoadUnit(cCmd *cobra.Command, args []string) (exit int) {
@@ -0,0 +1,87 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var cmdRevisions = &cobra.Command{
+	Use:   "revisions [-l|--full] [--no-legend] UNIT",
+	Short: "Show the unit file revisions of a unit",
+	Long: `Show the most recent unit files the given unit was created or replaced with,
+oldest first: when and why it changed, and which revision is current. A unit
+may be rolled back to any of these revisions with fleetctl rollback.
+
+Find out when hello.service was last replaced:
+	fleetctl revisions hello.service`,
+	Run: runWrapper(runUnitRevisions),
+}
+
+func init() {
+	cmdFleet.AddCommand(cmdRevisions)
+
+	cmdRevisions.Flags().BoolVar(&sharedFlags.Full, "full", false, "Do not ellipsize fields on output")
+	cmdRevisions.Flags().BoolVar(&sharedFlags.Full, "l", false, "Shorthand for --full")
+	cmdRevisions.Flags().BoolVar(&sharedFlags.NoLegend, "no-legend", false, "Do not print a legend (column headers)")
+}
+
+func runUnitRevisions(cCmd *cobra.Command, args []string) (exit int) {
+	if len(args) != 1 {
+		stderr("One unit file must be provided")
+		return 1
+	}
+
+	name := unitNameMangle(args[0])
+	revs, err := cAPI.UnitRevisions(name)
+	if err != nil {
+		stderr("Error retrieving revisions of unit %s: %v", name, err)
+		return 1
+	}
+	if revs == nil {
+		stderr("Unit %s does not exist", name)
+		return 1
+	}
+
+	if len(revs.Revisions) == 0 {
+		stdout("No revisions recorded for unit %s", name)
+		return 0
+	}
+
+	noLegend, _ := cCmd.Flags().GetBool("no-legend")
+	if !noLegend {
+		fmt.Fprintln(out, "REVISION\tTIME\tHASH\tCAUSE")
+	}
+
+	full, _ := cCmd.Flags().GetBool("full")
+	for _, rev := range revs.Revisions {
+		r := strconv.FormatInt(rev.Revision, 10)
+		if rev.Current {
+			r += " (current)"
+		}
+		hash := rev.UnitHash
+		if !full && len(hash) > 7 {
+			hash = hash[:7]
+		}
+		fmt.Fprintln(out, strings.Join([]string{r, rev.Time, hash, rev.Cause}, "\t"))
+	}
+
+	out.Flush()
+	return 0
+}
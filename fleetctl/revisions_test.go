@@ -0,0 +1,56 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestRunUnitRevisions(t *testing.T) {
+	hello := []string{
+		"REVISION TIME HASH CAUSE",
+		"1 TIME dc244fe run /bin/a",
+		"2 (current) TIME 7961c58 run /bin/b",
+	}
+	tests := []struct {
+		args   []string
+		exit   int
+		output []string
+	}{
+		{[]string{"hello.service"}, 0, hello},
+		{[]string{"hello"}, 0, hello},
+		// units without revisions
+		{[]string{"j1.service"}, 0, []string{"No revisions recorded for unit j1.service"}},
+		{[]string{"y1.service"}, 1, nil},
+		{[]string{}, 1, nil},
+		{[]string{"hello.service", "j1.service"}, 1, nil},
+	}
+
+	// the revisions are recorded at the time the fixture is built
+	timestamp := regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\S+`)
+	for i, tt := range tests {
+		cAPI = newFakeRegistryWithHistoryForCommands(t)
+		exit, output := runCommandForTests(t, runUnitRevisions, cmdRevisions, tt.args)
+		if exit != tt.exit {
+			t.Errorf("case %d: expected exit code %d, got %d", i, tt.exit, exit)
+		}
+		output = timestamp.ReplaceAllString(output, "TIME")
+		if lines := outputLinesForTests(output); !reflect.DeepEqual(lines, tt.output) {
+			t.Errorf("case %d: expected output %q, got %q", i, tt.output, lines)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickswift/fleet/schema"
+)
+
+var (
+	cmdRollback = &cobra.Command{
+		Use:   "rollback [--to=REV] UNIT",
+		Short: "Replace a unit with the unit file of an earlier revision",
+		Long: `Replace the given unit with the unit file of one of its revisions, as listed by
+fleetctl revisions, keeping its desired state. Without --to, the unit is rolled
+back to the revision before the current one. The rollback is recorded as a new
+revision itself, so it may be undone with another rollback.
+
+Undo the last replacement of hello.service:
+	fleetctl rollback hello.service
+
+Go back to revision 3 of hello.service:
+	fleetctl rollback --to=3 hello.service`,
+		Run: runWrapper(runRollbackUnit),
+	}
+
+	rollbackFlags = struct {
+		To int
+	}{}
+)
+
+func init() {
+	cmdFleet.AddCommand(cmdRollback)
+
+	cmdRollback.Flags().IntVar(&rollbackFlags.To, "to", 0, "Revision to roll back to. Defaults to the revision before the current one.")
+}
+
+func runRollbackUnit(cCmd *cobra.Command, args []string) (exit int) {
+	if len(args) != 1 {
+		stderr("One unit file must be provided")
+		return 1
+	}
+
+	name := unitNameMangle(args[0])
+	u, etag, err := cAPI.UnitWithETag(name)
+	if err != nil {
+		stderr("Error retrieving unit %s: %v", name, err)
+		return 1
+	}
+	revs, err := cAPI.UnitRevisions(name)
+	if err != nil {
+		stderr("Error retrieving revisions of unit %s: %v", name, err)
+		return 1
+	}
+	if u == nil || revs == nil {
+		stderr("Unit %s does not exist", name)
+		return 1
+	}
+
+	rev, err := findRollbackRevision(revs.Revisions, rollbackFlags.To)
+	if err != nil {
+		stderr("Unable to roll back unit %s: %v", name, err)
+		return 1
+	}
+	if rev.Current {
+		stdout("Unit %s is already at revision %d", name, rev.Revision)
+		return 0
+	}
+
+	err = createSchemaUnitIfMatch(&schema.Unit{
+		Name:         name,
+		Options:      rev.Options,
+		DesiredState: u.DesiredState,
+		ChangeCause:  fmt.Sprintf("%s rollback to revision %d", cliName, rev.Revision),
+	}, etag)
+	if err != nil {
+		stderr("Error rolling back unit %s: %v", name, err)
+		return 1
+	}
+
+	stdout("Rolled back unit %s to revision %d", name, rev.Revision)
+	return 0
+}
+
+// findRollbackRevision returns the revision with the given number, or the
+// revision before the current one if the number is 0.
+func findRollbackRevision(revs []*schema.UnitRevision, to int) (*schema.UnitRevision, error) {
+	var rev *schema.UnitRevision
+	if to == 0 {
+		for i, r := range revs {
+			if r.Current && i > 0 {
+				rev = revs[i-1]
+			}
+		}
+		if rev == nil {
+			return nil, fmt.Errorf("no revision before the current one")
+		}
+	} else {
+		for _, r := range revs {
+			if r.Revision == int64(to) {
+				rev = r
+			}
+		}
+		if rev == nil {
+			return nil, fmt.Errorf("revision %d does not exist", to)
+		}
+	}
+
+	if len(rev.Options) == 0 {
+		return nil, fmt.Errorf("unit file of revision %d is no longer stored", rev.Revision)
+	}
+	return rev, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestRunRollbackUnit(t *testing.T) {
+	tests := []struct {
+		args []string
+		to   int
+		exit int
+		// command of the unit after the rollback
+		exec string
+		// number of revisions after the rollback
+		revs int
+	}{
+		// back to the revision before the current one
+		{[]string{"hello.service"}, 0, 0, "/bin/b", 4},
+		{[]string{"hello.service"}, 1, 0, "/bin/a", 4},
+		// the current revision is left alone
+		{[]string{"hello.service"}, 3, 0, "/bin/c", 3},
+		{[]string{"hello.service"}, 7, 1, "/bin/c", 3},
+		// units without revisions
+		{[]string{"j1.service"}, 0, 1, "", 0},
+		{[]string{"y1.service"}, 0, 1, "", 0},
+		{[]string{}, 0, 1, "", 0},
+	}
+
+	for i, tt := range tests {
+		cAPI = newFakeRegistryForCommands("j", 2, false)
+		for _, exec := range []string{"/bin/a", "/bin/b", "/bin/c"} {
+			replaceUnitForTests(t, "hello.service", exec)
+		}
+
+		rollbackFlags.To = tt.to
+		if exit := runRollbackUnit(cmdRollback, tt.args); exit != tt.exit {
+			t.Errorf("case %d: expected exit code %d, got %d", i, tt.exit, exit)
+		}
+		if tt.exec == "" {
+			continue
+		}
+
+		u, err := cAPI.Unit("hello.service")
+		if err != nil || u == nil {
+			t.Fatalf("case %d: unexpected error fetching unit: %v", i, err)
+		}
+		if len(u.Options) != 1 || u.Options[0].Value != tt.exec {
+			t.Errorf("case %d: expected unit to run %s, got %v", i, tt.exec, u.Options)
+		}
+		if u.DesiredState != "launched" {
+			t.Errorf("case %d: expected unit to stay launched, got %s", i, u.DesiredState)
+		}
+		revs, err := cAPI.UnitRevisions("hello.service")
+		if err != nil {
+			t.Fatalf("case %d: unexpected error fetching revisions: %v", i, err)
+		}
+		if len(revs.Revisions) != tt.revs || !revs.Revisions[tt.revs-1].Current {
+			t.Errorf("case %d: expected %d revisions with the last one current, got %v", i, tt.revs, revs.Revisions)
+		}
+	}
+	rollbackFlags.To = 0
+}
@@ -0,0 +1,259 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/schema"
+	"github.com/nickswift/fleet/unit"
+)
+
+var (
+	cmdRollingUpdate = &cobra.Command{
+		Use:   "rolling-update [--file=FILE] [--max-unavailable=N] [--wait-active] [--block-attempts=N] TEMPLATE",
+		Short: "Replace the instances of a template unit in batches",
+		Long: `Replace the instances of a template unit with a new version of the unit
+file, a few at a time, so that a bad unit file does not take down all
+instances together.
+
+The template unit itself is replaced first if it exists in the cluster. Then
+the instances which differ from the new unit file are replaced in batches of
+at most --max-unavailable units. Each instance is stopped, replaced and
+returned to its previous desired state, and fleetctl waits for the whole batch
+to reach that state before moving on. With --wait-active, launched instances
+must also be reported active by systemd. If a batch fails, the rolling update
+halts and the remaining instances are left untouched.
+
+The new unit file is read from the local file named like the template, or
+from the file given with --file.
+
+Update the instances of app@.service two at a time:
+	fleetctl rolling-update --max-unavailable=2 --wait-active app@.service
+
+Update from a unit file in another directory:
+	fleetctl rolling-update --file=build/app@.service app@.service`,
+		Run: runWrapper(runRollingUpdate),
+	}
+
+	rollingUpdateFlags = struct {
+		File           string
+		MaxUnavailable int
+		WaitActive     bool
+	}{}
+)
+
+func init() {
+	cmdFleet.AddCommand(cmdRollingUpdate)
+
+	cmdRollingUpdate.Flags().StringVar(&rollingUpdateFlags.File, "file", "", "Local unit file to replace the instances with. Defaults to the file named like the template.")
+	cmdRollingUpdate.Flags().IntVar(&rollingUpdateFlags.MaxUnavailable, "max-unavailable", 1, "Maximum number of instances replaced at the same time.")
+	cmdRollingUpdate.Flags().BoolVar(&rollingUpdateFlags.WaitActive, "wait-active", false, "Wait for systemd to report the launched instances of each batch active before moving on.")
+	cmdRollingUpdate.Flags().IntVar(&sharedFlags.BlockAttempts, "block-attempts", 0, "Wait for each batch, performing up to N attempts before giving up. A value of 0 indicates no limit.")
+	cmdRollingUpdate.Flags().StringVar(&sharedFlags.ChangeCause, "change-cause", "", "Cause recorded with the new unit file revisions. Defaults to the command.")
+}
+
+func runRollingUpdate(cCmd *cobra.Command, args []string) (exit int) {
+	if len(args) != 1 {
+		stderr("One template unit must be provided")
+		return 1
+	}
+	if rollingUpdateFlags.MaxUnavailable < 1 {
+		stderr("--max-unavailable must be at least 1")
+		return 1
+	}
+
+	file := maybeAppendDefaultUnitType(args[0])
+	name := unitNameMangle(file)
+	if uni := unit.NewUnitNameInfo(name); uni == nil || !uni.IsTemplate() {
+		stderr("Unit %s is not a template unit", name)
+		return 1
+	}
+	if rollingUpdateFlags.File != "" {
+		file = rollingUpdateFlags.File
+	}
+
+	uf, err := getUnitFromFile(file)
+	if err != nil {
+		stderr("Error reading unit file %s: %v", file, err)
+		return 1
+	}
+
+	units, err := cAPI.Units()
+	if err != nil {
+		stderr("Error retrieving list of units from repository: %v", err)
+		return 1
+	}
+
+	for _, u := range units {
+		if u.Name != name || matchUnit(u, uf) {
+			continue
+		}
+		if err := replaceUnit(u, uf); err != nil {
+			stderr("Error replacing template unit %s: %v", name, err)
+			return 1
+		}
+		stdout("Replaced template unit %s", name)
+	}
+
+	batches := rollingUpdateBatches(units, name, uf, rollingUpdateFlags.MaxUnavailable)
+	if len(batches) == 0 {
+		stdout("All instances of %s are up to date", name)
+		return 0
+	}
+
+	attempts := getBlockAttempts(cCmd)
+	for i, batch := range batches {
+		if err := rollingUpdateBatch(batch, uf, attempts); err != nil {
+			stderr("Rolling update of %s halted in batch %d of %d: %v", name, i+1, len(batches), err)
+			var remaining []string
+			for _, b := range batches[i+1:] {
+				for _, u := range b {
+					remaining = append(remaining, u.Name)
+				}
+			}
+			if len(remaining) > 0 {
+				stderr("Instances not updated: %s", strings.Join(remaining, ", "))
+			}
+			return 1
+		}
+	}
+
+	stdout("Rolling update of %s complete", name)
+	return 0
+}
+
+// rollingUpdateBatches returns the instances of the given template which
+// differ from the unit file, ordered by instance name and split into batches
+// of at most size units.
+func rollingUpdateBatches(units []*schema.Unit, template string, uf *unit.UnitFile, size int) [][]*schema.Unit {
+	var outdated sortableUnitsByInstance
+	for _, u := range units {
+		uni := unit.NewUnitNameInfo(u.Name)
+		if uni == nil || !uni.IsInstance() || uni.Template != template || matchUnit(u, uf) {
+			continue
+		}
+		outdated = append(outdated, u)
+	}
+	sort.Sort(outdated)
+
+	var batches [][]*schema.Unit
+	for len(outdated) > 0 {
+		n := size
+		if n > len(outdated) {
+			n = len(outdated)
+		}
+		batches = append(batches, outdated[:n])
+		outdated = outdated[n:]
+	}
+	return batches
+}
+
+// rollingUpdateBatch replaces the given units with the unit file and waits
+// for them to return to their previous desired state.
+func rollingUpdateBatch(batch []*schema.Unit, uf *unit.UnitFile, attempts int) error {
+	var names []string
+	for _, u := range batch {
+		names = append(names, u.Name)
+		if err := replaceUnit(u, uf); err != nil {
+			return fmt.Errorf("failed replacing unit %s: %v", u.Name, err)
+		}
+	}
+
+	// replaced units are inactive until their desired state is restored
+	if err := tryWaitForUnitStates(names, "stop", job.JobStateInactive, attempts, os.Stdout); err != nil {
+		return err
+	}
+
+	states := make(map[job.JobState][]string)
+	var active []string
+	for _, u := range batch {
+		ds := job.JobState(u.DesiredState)
+		if ds == job.JobStateInactive {
+			continue
+		}
+		if err := cAPI.SetUnitTargetState(u.Name, string(ds)); err != nil {
+			return fmt.Errorf("failed setting target state of unit %s: %v", u.Name, err)
+		}
+		states[ds] = append(states[ds], u.Name)
+		if ds == job.JobStateLaunched && !suToGlobal(*u) {
+			active = append(active, u.Name)
+		}
+	}
+
+	for _, ds := range []job.JobState{job.JobStateLoaded, job.JobStateLaunched} {
+		if err := tryWaitForUnitStates(states[ds], "update", ds, attempts, os.Stdout); err != nil {
+			return err
+		}
+	}
+
+	if rollingUpdateFlags.WaitActive && len(active) > 0 {
+		if err := tryWaitForSystemdActiveState(active, attempts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replaceUnit replaces the Unit in the registry with the given unit file,
+// leaving it inactive. The Unit is only replaced if it was not changed since
+// it was retrieved.
+func replaceUnit(u *schema.Unit, uf *unit.UnitFile) error {
+	etag, err := unchangedUnitETag(u)
+	if err != nil {
+		return err
+	}
+	_, err = createUnitIfMatch(u.Name, uf, changeCause("rolling-update"), etag)
+	return err
+}
+
+// matchUnit returns whether the Unit was created from the given unit file.
+func matchUnit(u *schema.Unit, uf *unit.UnitFile) bool {
+	return unit.MatchUnitFiles(schema.MapSchemaUnitOptionsToUnitFile(u.Options), uf)
+}
+
+// sortableUnitsByInstance orders instances of a template by their numeric
+// instance, falling back to the unit name.
+type sortableUnitsByInstance []*schema.Unit
+
+func (su sortableUnitsByInstance) Len() int      { return len(su) }
+func (su sortableUnitsByInstance) Swap(i, j int) { su[i], su[j] = su[j], su[i] }
+
+func (su sortableUnitsByInstance) Less(i, j int) bool {
+	ii, iok := instanceIndex(su[i].Name)
+	ji, jok := instanceIndex(su[j].Name)
+	if iok && jok && ii != ji {
+		return ii < ji
+	}
+	if iok != jok {
+		return iok
+	}
+	return su[i].Name < su[j].Name
+}
+
+func instanceIndex(name string) (int, bool) {
+	uni := unit.NewUnitNameInfo(name)
+	if uni == nil {
+		return 0, false
+	}
+	return job.ReplicaIndex(uni.Template, name)
+}
@@ -0,0 +1,152 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/nickswift/fleet/client"
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/registry"
+	"github.com/nickswift/fleet/schema"
+	"github.com/nickswift/fleet/unit"
+)
+
+const (
+	rollingOldUnit = `[Service]
+ExecStart=/usr/bin/app --version=1
+
+[X-Fleet]
+Global=true
+`
+	rollingNewUnit = `[Service]
+ExecStart=/usr/bin/app --version=2
+
+[X-Fleet]
+Global=true
+`
+)
+
+func newRollingUnitFile(t *testing.T, contents string) *unit.UnitFile {
+	uf, err := unit.NewUnitFile(contents)
+	if err != nil {
+		t.Fatalf("unexpected error parsing unit file: %v", err)
+	}
+	return uf
+}
+
+func TestRollingUpdateBatches(t *testing.T) {
+	oldUF := newRollingUnitFile(t, rollingOldUnit)
+	newUF := newRollingUnitFile(t, rollingNewUnit)
+
+	var units []*schema.Unit
+	for _, name := range []string{"app@10.service", "app@.service", "app@2.service", "app@web.service", "other@1.service", "app@1.service", "app@3.service"} {
+		units = append(units, &schema.Unit{Name: name, Options: schema.MapUnitFileToSchemaUnitOptions(oldUF)})
+	}
+	// already up to date
+	units = append(units, &schema.Unit{Name: "app@4.service", Options: schema.MapUnitFileToSchemaUnitOptions(newUF)})
+
+	tests := []struct {
+		size int
+		want [][]string
+	}{
+		{1, [][]string{{"app@1.service"}, {"app@2.service"}, {"app@3.service"}, {"app@10.service"}, {"app@web.service"}}},
+		{2, [][]string{{"app@1.service", "app@2.service"}, {"app@3.service", "app@10.service"}, {"app@web.service"}}},
+		{10, [][]string{{"app@1.service", "app@2.service", "app@3.service", "app@10.service", "app@web.service"}}},
+	}
+
+	for i, tt := range tests {
+		var got [][]string
+		for _, batch := range rollingUpdateBatches(units, "app@.service", newUF, tt.size) {
+			var names []string
+			for _, u := range batch {
+				names = append(names, u.Name)
+			}
+			got = append(got, names)
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("case %d: expected batches %v, got %v", i, tt.want, got)
+		}
+	}
+}
+
+func TestRunRollingUpdate(t *testing.T) {
+	oldFlags := rollingUpdateFlags
+	defer func() {
+		rollingUpdateFlags = oldFlags
+	}()
+
+	dir, err := ioutil.TempDir("", "fleetctl-rolling-update")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "app@.service")
+	if err := ioutil.WriteFile(file, []byte(rollingNewUnit), 0644); err != nil {
+		t.Fatalf("unexpected error writing unit file: %v", err)
+	}
+
+	oldUF := newRollingUnitFile(t, rollingOldUnit)
+	jobs := []job.Job{{Name: "app@.service", Unit: *oldUF, TargetState: job.JobStateInactive}}
+	for _, name := range []string{"app@1.service", "app@2.service", "app@3.service"} {
+		jobs = append(jobs, job.Job{Name: name, Unit: *oldUF, TargetState: job.JobStateLaunched})
+	}
+	// the units are created like through the API, so that they have ETags
+	reg := registry.NewFakeRegistry()
+	reg.SetJobs(nil)
+	for _, j := range jobs {
+		if err := reg.CreateUnit(&job.Unit{Name: j.Name, Unit: j.Unit, TargetState: j.TargetState}); err != nil {
+			t.Fatalf("unexpected error creating unit %s: %v", j.Name, err)
+		}
+	}
+	cAPI = &client.RegistryClient{Registry: reg}
+
+	rollingUpdateFlags.MaxUnavailable = 0
+	if exit := runRollingUpdate(cmdRollingUpdate, []string{file}); exit != 1 {
+		t.Errorf("expected exit code 1 for invalid --max-unavailable, got %d", exit)
+	}
+
+	rollingUpdateFlags.MaxUnavailable = 2
+	if exit := runRollingUpdate(cmdRollingUpdate, []string{filepath.Join(dir, "app.service")}); exit != 1 {
+		t.Errorf("expected exit code 1 for non-template unit, got %d", exit)
+	}
+
+	if exit := runRollingUpdate(cmdRollingUpdate, []string{file}); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+
+	newUF := newRollingUnitFile(t, rollingNewUnit)
+	for _, j := range jobs {
+		u, err := cAPI.Unit(j.Name)
+		if err != nil || u == nil {
+			t.Fatalf("unable to find unit %s: %v", j.Name, err)
+		}
+		if !matchUnit(u, newUF) {
+			t.Errorf("unit %s was not replaced", j.Name)
+		}
+		if job.JobState(u.DesiredState) != j.TargetState {
+			t.Errorf("unit %s has desired state %s, want %s", j.Name, u.DesiredState, j.TargetState)
+		}
+	}
+
+	// nothing left to do
+	if exit := runRollingUpdate(cmdRollingUpdate, []string{file}); exit != 0 {
+		t.Errorf("expected exit code 0, got %d", exit)
+	}
+}
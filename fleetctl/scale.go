@@ -0,0 +1,106 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickswift/fleet/schema"
+	"github.com/nickswift/fleet/unit"
+)
+
+var cmdScale = &cobra.Command{
+	Use:   "scale TEMPLATE=COUNT...",
+	Short: "Set the desired number of instances of one or more template units",
+	Long: `Declare how many instances of a template unit should exist in the cluster.
+
+The template unit must have been submitted beforehand. fleet creates and
+launches the instances TEMPLATE@1, TEMPLATE@2, etc. until COUNT instances
+exist, and destroys the instances with the highest indices when scaling down.
+
+Scale app@.service to six instances:
+	fleetctl scale app@.service=6
+
+Remove all instances of app@.service:
+	fleetctl scale app@.service=0`,
+	Run: runWrapper(runScaleUnits),
+}
+
+func init() {
+	cmdFleet.AddCommand(cmdScale)
+}
+
+func runScaleUnits(cCmd *cobra.Command, args []string) (exit int) {
+	if len(args) == 0 {
+		stderr("No templates given")
+		return 1
+	}
+
+	rsets := make([]*schema.ReplicaSet, 0, len(args))
+	for _, arg := range args {
+		rs, err := parseScaleArg(arg)
+		if err != nil {
+			stderr("%v", err)
+			return 1
+		}
+		rsets = append(rsets, rs)
+	}
+
+	for _, rs := range rsets {
+		u, err := cAPI.Unit(rs.Template)
+		if err != nil {
+			stderr("Error retrieving unit %s from registry: %v", rs.Template, err)
+			return 1
+		}
+		if u == nil {
+			stderr("Unable to find template unit %s in registry", rs.Template)
+			return 1
+		}
+
+		if err := cAPI.SetReplicaSet(rs); err != nil {
+			stderr("Error scaling %s: %v", rs.Template, err)
+			return 1
+		}
+
+		stdout("Scaled %s to %d replicas", rs.Template, rs.Replicas)
+	}
+
+	return 0
+}
+
+// parseScaleArg parses an argument of the form TEMPLATE=COUNT into a
+// ReplicaSet, appending the default unit type to the template if necessary.
+func parseScaleArg(arg string) (*schema.ReplicaSet, error) {
+	idx := strings.LastIndex(arg, "=")
+	if idx == -1 {
+		return nil, fmt.Errorf("invalid argument %q, expected TEMPLATE=COUNT", arg)
+	}
+
+	name := unitNameMangle(arg[:idx])
+	if !unit.NewUnitNameInfo(name).IsTemplate() {
+		return nil, fmt.Errorf("unit %s is not a template unit", name)
+	}
+
+	count, err := strconv.Atoi(arg[idx+1:])
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid replica count %q for %s", arg[idx+1:], name)
+	}
+
+	return &schema.ReplicaSet{Template: name, Replicas: int64(count)}, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestParseScaleArg(t *testing.T) {
+	tests := []struct {
+		arg      string
+		template string
+		replicas int64
+		err      bool
+	}{
+		{"app@.service=6", "app@.service", 6, false},
+		{"app@=3", "app@.service", 3, false},
+		{"app@.service=0", "app@.service", 0, false},
+		{"app@.service", "", 0, true},
+		{"app@.service=-1", "", 0, true},
+		{"app@.service=many", "", 0, true},
+		{"app.service=2", "", 0, true},
+		{"app@1.service=2", "", 0, true},
+	}
+
+	for i, tt := range tests {
+		rs, err := parseScaleArg(tt.arg)
+		if tt.err {
+			if err == nil {
+				t.Errorf("case %d: expected error parsing %q", i, tt.arg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %d: unexpected error parsing %q: %v", i, tt.arg, err)
+			continue
+		}
+		if rs.Template != tt.template || rs.Replicas != tt.replicas {
+			t.Errorf("case %d: parseScaleArg(%q) = %s=%d, want %s=%d", i, tt.arg, rs.Template, rs.Replicas, tt.template, tt.replicas)
+		}
+	}
+}
+
+func TestRunScaleUnits(t *testing.T) {
+	tests := []struct {
+		args []string
+		exit int
+	}{
+		{[]string{"j@.service=3"}, 0},
+		{[]string{"j@=0"}, 0},
+		{[]string{"y@.service=3"}, 1},
+		{[]string{"j@.service"}, 1},
+		{[]string{}, 1},
+	}
+
+	for i, tt := range tests {
+		cAPI = newFakeRegistryForCommands("j", 1, true)
+		if exit := runScaleUnits(cmdScale, tt.args); exit != tt.exit {
+			t.Errorf("case %d: expected exit code %d, got %d", i, tt.exit, exit)
+		}
+	}
+
+	cAPI = newFakeRegistryForCommands("j", 1, true)
+	if exit := runScaleUnits(cmdScale, []string{"j@.service=4"}); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+	rs, err := cAPI.ReplicaSet("j@.service")
+	if err != nil {
+		t.Fatalf("unexpected error fetching ReplicaSet: %v", err)
+	}
+	if rs == nil || rs.Replicas != 4 {
+		t.Errorf("expected ReplicaSet with 4 replicas, got %v", rs)
+	}
+}
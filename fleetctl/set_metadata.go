@@ -0,0 +1,87 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var cmdSetMetadata = &cobra.Command{
+	Use:   "set-metadata MACHINE KEY=VALUE|KEY-...",
+	Short: "Change the metadata of a machine at runtime",
+	Long: `Set or remove metadata values of a machine through the API. Values set
+this way override those the machine is configured with in fleetd and take
+effect immediately: units whose MachineMetadata= requirements are no longer
+met are moved to other machines, and units waiting for matching metadata may
+be scheduled. A KEY followed by a dash removes the value set through the API,
+restoring the configured one, if any.
+
+The machine may be identified by its full or short ID.
+
+Move a machine to another rack:
+	fleetctl set-metadata 113f16a7 rack=r12
+
+Remove the override again:
+	fleetctl set-metadata 113f16a7 rack-`,
+	Run: runWrapper(runSetMachineMetadata),
+}
+
+func init() {
+	cmdFleet.AddCommand(cmdSetMetadata)
+}
+
+func runSetMachineMetadata(cCmd *cobra.Command, args []string) (exit int) {
+	if len(args) < 2 {
+		stderr("One machine and at least one metadata change must be provided")
+		return 1
+	}
+
+	set := make(map[string]string)
+	var del []string
+	for _, arg := range args[1:] {
+		if idx := strings.Index(arg, "="); idx > 0 {
+			set[arg[:idx]] = arg[idx+1:]
+		} else if strings.HasSuffix(arg, "-") && len(arg) > 1 && !strings.Contains(arg, "=") {
+			del = append(del, strings.TrimSuffix(arg, "-"))
+		} else {
+			stderr("Invalid metadata change %q, expected KEY=VALUE or KEY-", arg)
+			return 1
+		}
+	}
+
+	ms, err := findMachine(args[0])
+	if err != nil {
+		stderr("%v", err)
+		return 1
+	}
+
+	for key, value := range set {
+		if err := cAPI.SetMachineMetadata(ms.ID, key, value); err != nil {
+			stderr("Error setting metadata %s of machine %s: %v", key, ms.ID, err)
+			return 1
+		}
+	}
+	for _, key := range del {
+		if err := cAPI.DeleteMachineMetadata(ms.ID, key); err != nil {
+			stderr("Error removing metadata %s of machine %s: %v", key, ms.ID, err)
+			return 1
+		}
+	}
+
+	stdout("Updated metadata of machine %s", ms.ID)
+	return 0
+}
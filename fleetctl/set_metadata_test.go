@@ -0,0 +1,55 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRunSetMachineMetadata(t *testing.T) {
+	tests := []struct {
+		args     []string
+		exit     int
+		metadata map[string]string
+	}{
+		{[]string{"c31e44e1", "rack=r12", "ping=pang"}, 0, map[string]string{"ping": "pang", "rack": "r12", "zone": "a"}},
+		{[]string{"c31e44e1", "zone-"}, 0, map[string]string{"ping": "pong"}},
+		{[]string{"c31e44e1", "ping-"}, 0, map[string]string{"ping": "pong", "zone": "a"}},
+		{[]string{"c31e44e1", "rack"}, 1, nil},
+		{[]string{"c31e44e1", "=r12"}, 1, nil},
+		{[]string{"deadbeef", "rack=r12"}, 1, nil},
+		{[]string{"c31e44e1"}, 1, nil},
+	}
+
+	for i, tt := range tests {
+		cAPI = newFakeRegistryForCommands("j", 1, false)
+		cAPI.SetMachineMetadata("c31e44e1-f858-436e-933e-59c642517860", "zone", "a")
+		if exit := runSetMachineMetadata(cmdSetMetadata, tt.args); exit != tt.exit {
+			t.Errorf("case %d: expected exit code %d, got %d", i, tt.exit, exit)
+		}
+		if tt.exit != 0 {
+			continue
+		}
+
+		ms, err := findMachine("c31e44e1")
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(tt.metadata, ms.Metadata) {
+			t.Errorf("case %d: expected metadata %v, got %v", i, tt.metadata, ms.Metadata)
+		}
+	}
+}
@@ -53,6 +53,7 @@ func init() {
 	cmdStart.Flags().IntVar(&sharedFlags.BlockAttempts, "block-attempts", 0, "Wait until the units are launched, performing up to N attempts before giving up. A value of 0 indicates no limit. Does not apply to global units.")
 	cmdStart.Flags().BoolVar(&sharedFlags.NoBlock, "no-block", false, "Do not wait until the units have launched before exiting. Always the case for global units.")
 	cmdStart.Flags().BoolVar(&sharedFlags.Replace, "replace", false, "Replace the already started units in the cluster with new versions.")
+	cmdStart.Flags().StringVar(&sharedFlags.ChangeCause, "change-cause", "", "Cause recorded with the new unit file revisions. Defaults to the command.")
 }
 
 func runStartUnit(cCmd *cobra.Command, args []string) (exit int) {
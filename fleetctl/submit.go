@@ -39,6 +39,7 @@ func init() {
 
 	cmdSubmit.Flags().BoolVar(&sharedFlags.Sign, "sign", false, "DEPRECATED - this option cannot be used")
 	cmdSubmit.Flags().BoolVar(&sharedFlags.Replace, "replace", false, "Replace the old submitted units in the cluster with new versions.")
+	cmdSubmit.Flags().StringVar(&sharedFlags.ChangeCause, "change-cause", "", "Cause recorded with the new unit file revisions. Defaults to the command.")
 }
 
 func runSubmitUnit(cCmd *cobra.Command, args []string) (exit int) {
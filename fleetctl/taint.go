@@ -0,0 +1,74 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickswift/fleet/machine"
+)
+
+var cmdTaint = &cobra.Command{
+	Use:   "taint MACHINE [KEY[=VALUE]...]",
+	Short: "Repel units which do not tolerate the given taints from a machine",
+	Long: `Replace the taints of a machine set through the API. Only units which
+tolerate every taint of a machine, using Tolerates= in their [X-Fleet]
+section, are scheduled to it. Like with cordon, units already scheduled there
+stay until they are drained; global units which do not tolerate a new taint
+are stopped on it. The taints a machine is configured
+with in fleetd are kept. Without any taints, those set through the API are
+removed.
+
+The machine may be identified by its full or short ID.
+
+Dedicate a machine to database units:
+	fleetctl taint 113f16a7 dedicated=db
+
+Remove the taints again:
+	fleetctl taint 113f16a7`,
+	Run: runWrapper(runTaintMachine),
+}
+
+func init() {
+	cmdFleet.AddCommand(cmdTaint)
+}
+
+func runTaintMachine(cCmd *cobra.Command, args []string) (exit int) {
+	if len(args) < 1 {
+		stderr("One machine must be provided")
+		return 1
+	}
+
+	ms, err := findMachine(args[0])
+	if err != nil {
+		stderr("%v", err)
+		return 1
+	}
+
+	taints := machine.ParseTaints(strings.Join(args[1:], ","))
+	if err := cAPI.SetMachineTaints(ms.ID, taints); err != nil {
+		stderr("Error setting taints of machine %s: %v", ms.ID, err)
+		return 1
+	}
+
+	if len(taints) == 0 {
+		stdout("Removed taints of machine %s", ms.ID)
+	} else {
+		stdout("Tainted machine %s", ms.ID)
+	}
+	return 0
+}
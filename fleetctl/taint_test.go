@@ -0,0 +1,52 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRunTaintMachine(t *testing.T) {
+	tests := []struct {
+		args   []string
+		exit   int
+		taints map[string]string
+	}{
+		{[]string{"c31e44e1", "dedicated=db", "gpu"}, 0, map[string]string{"dedicated": "db", "gpu": ""}},
+		{[]string{"c31e44e1"}, 0, nil},
+		{[]string{"deadbeef", "gpu"}, 1, nil},
+		{[]string{}, 1, nil},
+	}
+
+	for i, tt := range tests {
+		cAPI = newFakeRegistryForCommands("j", 1, false)
+		cAPI.SetMachineTaints("c31e44e1-f858-436e-933e-59c642517860", map[string]string{"old": ""})
+		if exit := runTaintMachine(cmdTaint, tt.args); exit != tt.exit {
+			t.Errorf("case %d: expected exit code %d, got %d", i, tt.exit, exit)
+		}
+		if tt.exit != 0 {
+			continue
+		}
+
+		ms, err := findMachine("c31e44e1")
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(tt.taints, ms.Taints) {
+			t.Errorf("case %d: expected taints %v, got %v", i, tt.taints, ms.Taints)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nickswift/fleet/machine"
+)
+
+var cmdWhy = &cobra.Command{
+	Use:   "why [-l|--full] [--no-legend] UNIT",
+	Short: "Explain where a unit can and cannot be scheduled",
+	Long: `Explain for each machine in the cluster whether the given unit could be
+scheduled to it, and if not, why. This evaluates the same requirements as the
+fleet engine, e.g. MachineMetadata, Conflicts, MachineOf and resource
+requirements.
+
+Find out why hello.service is not running:
+	fleetctl why hello.service`,
+	Run: runWrapper(runWhyUnit),
+}
+
+func init() {
+	cmdFleet.AddCommand(cmdWhy)
+
+	cmdWhy.Flags().BoolVar(&sharedFlags.Full, "full", false, "Do not ellipsize fields on output")
+	cmdWhy.Flags().BoolVar(&sharedFlags.Full, "l", false, "Shorthand for --full")
+	cmdWhy.Flags().BoolVar(&sharedFlags.NoLegend, "no-legend", false, "Do not print a legend (column headers)")
+}
+
+func runWhyUnit(cCmd *cobra.Command, args []string) (exit int) {
+	if len(args) != 1 {
+		stderr("One unit file must be provided")
+		return 1
+	}
+
+	name := unitNameMangle(args[0])
+	exp, err := cAPI.ExplainUnit(name)
+	if err != nil {
+		stderr("Error explaining unit %s: %v", name, err)
+		return 1
+	}
+	if exp == nil {
+		stderr("Unit %s does not exist", name)
+		return 1
+	}
+
+	full, _ := cCmd.Flags().GetBool("full")
+	if exp.MachineID != "" {
+		stdout("Unit %s is scheduled to %s (desired state %s)", exp.Name, machineIDLegend(machine.MachineState{ID: exp.MachineID}, full), exp.DesiredState)
+	} else {
+		stdout("Unit %s is not scheduled (desired state %s)", exp.Name, exp.DesiredState)
+	}
+
+	if len(exp.Machines) == 0 {
+		stdout("No machines found in the cluster")
+		return 0
+	}
+
+	noLegend, _ := cCmd.Flags().GetBool("no-legend")
+	if !noLegend {
+		fmt.Fprintln(out, "MACHINE\tSCHEDULABLE\tREASON")
+	}
+
+	for _, mexp := range exp.Machines {
+		schedulable := "no"
+		reason := mexp.Reason
+		if mexp.Schedulable {
+			schedulable = "yes"
+			reason = "-"
+		}
+		fmt.Fprintln(out, strings.Join([]string{
+			machineIDLegend(machine.MachineState{ID: mexp.MachineID}, full),
+			schedulable,
+			reason,
+		}, "\t"))
+	}
+
+	out.Flush()
+	return 0
+}
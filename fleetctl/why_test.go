@@ -0,0 +1,61 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRunWhyUnit(t *testing.T) {
+	foo := []string{
+		"Unit foo.service is not scheduled (desired state launched)",
+		"MACHINE SCHEDULABLE REASON",
+		"595989bb... yes -",
+		"c31e44e1... no local Machine metadata insufficient: requirement foo=bar not satisfied",
+	}
+	tests := []struct {
+		args   []string
+		exit   int
+		output []string
+	}{
+		{[]string{"foo.service"}, 0, foo},
+		{[]string{"foo"}, 0, foo},
+		{
+			[]string{"j1.service"},
+			0,
+			[]string{
+				"Unit j1.service is scheduled to 595989bb... (desired state )",
+				"MACHINE SCHEDULABLE REASON",
+				"595989bb... yes -",
+				"c31e44e1... yes -",
+			},
+		},
+		{[]string{"y1.service"}, 1, nil},
+		{[]string{}, 1, nil},
+		{[]string{"j1.service", "j2.service"}, 1, nil},
+	}
+
+	for i, tt := range tests {
+		cAPI = newFakeRegistryWithHistoryForCommands(t)
+		exit, output := runCommandForTests(t, runWhyUnit, cmdWhy, tt.args)
+		if exit != tt.exit {
+			t.Errorf("case %d: expected exit code %d, got %d", i, tt.exit, exit)
+		}
+		if lines := outputLinesForTests(output); !reflect.DeepEqual(lines, tt.output) {
+			t.Errorf("case %d: expected output %q, got %q", i, tt.output, lines)
+		}
+	}
+}
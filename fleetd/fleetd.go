@@ -27,6 +27,7 @@ import (
 
 	"github.com/nickswift/fleet/agent"
 	"github.com/nickswift/fleet/config"
+	"github.com/nickswift/fleet/engine"
 	"github.com/nickswift/fleet/log"
 	"github.com/nickswift/fleet/pkg"
 	"github.com/nickswift/fleet/registry"
@@ -84,9 +85,17 @@ func Main() {
 	cfgset.String("etcd_cafile", "", "SSL Certificate Authority file used to secure etcd communication")
 	cfgset.String("etcd_key_prefix", registry.DefaultKeyPrefix, "Keyspace for fleet data in etcd")
 	cfgset.Float64("etcd_request_timeout", 1.0, "Amount of time in seconds to allow a single etcd request before considering it failed.")
+	cfgset.String("etcd_api_version", registry.DefaultEtcdAPIVersion, "Version of the etcd API used to store fleet data, v2 or v3")
+	cfgset.String("registry_backend", registry.DefaultBackend, "Backend storing fleet data, etcd or local")
+	cfgset.String("local_registry_file", registry.DefaultLocalRegistryFile, "File storing fleet data if registry_backend is local")
 	cfgset.Float64("engine_reconcile_interval", 2.0, "Interval at which the engine should reconcile the cluster schedule in etcd.")
+	cfgset.String("scheduler_strategy", engine.DefaultSchedulerStrategy, "Strategy used by the engine to place units on machines")
+	cfgset.Int("rebalance_threshold", 0, "Difference in the number of units between machines above which the engine moves units. 0 disables rebalancing by unit count")
+	cfgset.Int("rebalance_resource_threshold", 0, "Difference in resource utilization, in percent, between machines above which the engine moves units. 0 disables rebalancing by resource use")
+	cfgset.Int("rebalance_max_moves", engine.DefaultRebalanceMaxMoves, "Maximum number of units moved by the engine per reconcile round when rebalancing")
 	cfgset.String("public_ip", "", "IP address that fleet machine should publish")
 	cfgset.String("metadata", "", "List of key-value metadata to assign to the fleet machine")
+	cfgset.String("taints", "", "List of taints repelling units which do not tolerate them from the fleet machine")
 	cfgset.String("agent_ttl", agent.DefaultTTL, "TTL in seconds of fleet machine state in etcd")
 	cfgset.String("units_directory", "/run/fleet/units/", "Path to the fleet units directory")
 	cfgset.Bool("systemd_user", false, "When true use systemd --user)")
@@ -216,27 +225,35 @@ func getConfig(flagset *flag.FlagSet, userCfgFile string) (*config.Config, error
 	gconf.ParseSet("", flagset)
 
 	cfg := config.Config{
-		Verbosity:               (*flagset.Lookup("verbosity")).Value.(flag.Getter).Get().(int),
-		EtcdServers:             (*flagset.Lookup("etcd_servers")).Value.(flag.Getter).Get().(pkg.StringSlice),
-		EtcdUsername:            (*flagset.Lookup("etcd_username")).Value.(flag.Getter).Get().(string),
-		EtcdPassword:            (*flagset.Lookup("etcd_password")).Value.(flag.Getter).Get().(string),
-		EtcdKeyPrefix:           (*flagset.Lookup("etcd_key_prefix")).Value.(flag.Getter).Get().(string),
-		EtcdKeyFile:             (*flagset.Lookup("etcd_keyfile")).Value.(flag.Getter).Get().(string),
-		EtcdCertFile:            (*flagset.Lookup("etcd_certfile")).Value.(flag.Getter).Get().(string),
-		EtcdCAFile:              (*flagset.Lookup("etcd_cafile")).Value.(flag.Getter).Get().(string),
-		EtcdRequestTimeout:      (*flagset.Lookup("etcd_request_timeout")).Value.(flag.Getter).Get().(float64),
-		EngineReconcileInterval: (*flagset.Lookup("engine_reconcile_interval")).Value.(flag.Getter).Get().(float64),
-		PublicIP:                (*flagset.Lookup("public_ip")).Value.(flag.Getter).Get().(string),
-		RawMetadata:             (*flagset.Lookup("metadata")).Value.(flag.Getter).Get().(string),
-		AgentTTL:                (*flagset.Lookup("agent_ttl")).Value.(flag.Getter).Get().(string),
-		DisableEngine:           (*flagset.Lookup("disable_engine")).Value.(flag.Getter).Get().(bool),
-		DisableWatches:          (*flagset.Lookup("disable_watches")).Value.(flag.Getter).Get().(bool),
-		EnableGRPC:              (*flagset.Lookup("enable_grpc")).Value.(flag.Getter).Get().(bool),
-		VerifyUnits:             (*flagset.Lookup("verify_units")).Value.(flag.Getter).Get().(bool),
-		UnitsDirectory:          (*flagset.Lookup("units_directory")).Value.(flag.Getter).Get().(string),
-		SystemdUser:             (*flagset.Lookup("systemd_user")).Value.(flag.Getter).Get().(bool),
-		TokenLimit:              (*flagset.Lookup("token_limit")).Value.(flag.Getter).Get().(int),
-		AuthorizedKeysFile:      (*flagset.Lookup("authorized_keys_file")).Value.(flag.Getter).Get().(string),
+		Verbosity:                  (*flagset.Lookup("verbosity")).Value.(flag.Getter).Get().(int),
+		EtcdServers:                (*flagset.Lookup("etcd_servers")).Value.(flag.Getter).Get().(pkg.StringSlice),
+		EtcdUsername:               (*flagset.Lookup("etcd_username")).Value.(flag.Getter).Get().(string),
+		EtcdPassword:               (*flagset.Lookup("etcd_password")).Value.(flag.Getter).Get().(string),
+		EtcdKeyPrefix:              (*flagset.Lookup("etcd_key_prefix")).Value.(flag.Getter).Get().(string),
+		EtcdKeyFile:                (*flagset.Lookup("etcd_keyfile")).Value.(flag.Getter).Get().(string),
+		EtcdCertFile:               (*flagset.Lookup("etcd_certfile")).Value.(flag.Getter).Get().(string),
+		EtcdCAFile:                 (*flagset.Lookup("etcd_cafile")).Value.(flag.Getter).Get().(string),
+		EtcdRequestTimeout:         (*flagset.Lookup("etcd_request_timeout")).Value.(flag.Getter).Get().(float64),
+		EtcdAPIVersion:             (*flagset.Lookup("etcd_api_version")).Value.(flag.Getter).Get().(string),
+		RegistryBackend:            (*flagset.Lookup("registry_backend")).Value.(flag.Getter).Get().(string),
+		LocalRegistryFile:          (*flagset.Lookup("local_registry_file")).Value.(flag.Getter).Get().(string),
+		EngineReconcileInterval:    (*flagset.Lookup("engine_reconcile_interval")).Value.(flag.Getter).Get().(float64),
+		SchedulerStrategy:          (*flagset.Lookup("scheduler_strategy")).Value.(flag.Getter).Get().(string),
+		RebalanceThreshold:         (*flagset.Lookup("rebalance_threshold")).Value.(flag.Getter).Get().(int),
+		RebalanceResourceThreshold: (*flagset.Lookup("rebalance_resource_threshold")).Value.(flag.Getter).Get().(int),
+		RebalanceMaxMoves:          (*flagset.Lookup("rebalance_max_moves")).Value.(flag.Getter).Get().(int),
+		PublicIP:                   (*flagset.Lookup("public_ip")).Value.(flag.Getter).Get().(string),
+		RawMetadata:                (*flagset.Lookup("metadata")).Value.(flag.Getter).Get().(string),
+		RawTaints:                  (*flagset.Lookup("taints")).Value.(flag.Getter).Get().(string),
+		AgentTTL:                   (*flagset.Lookup("agent_ttl")).Value.(flag.Getter).Get().(string),
+		DisableEngine:              (*flagset.Lookup("disable_engine")).Value.(flag.Getter).Get().(bool),
+		DisableWatches:             (*flagset.Lookup("disable_watches")).Value.(flag.Getter).Get().(bool),
+		EnableGRPC:                 (*flagset.Lookup("enable_grpc")).Value.(flag.Getter).Get().(bool),
+		VerifyUnits:                (*flagset.Lookup("verify_units")).Value.(flag.Getter).Get().(bool),
+		UnitsDirectory:             (*flagset.Lookup("units_directory")).Value.(flag.Getter).Get().(string),
+		SystemdUser:                (*flagset.Lookup("systemd_user")).Value.(flag.Getter).Get().(bool),
+		TokenLimit:                 (*flagset.Lookup("token_limit")).Value.(flag.Getter).Get().(int),
+		AuthorizedKeysFile:         (*flagset.Lookup("authorized_keys_file")).Value.(flag.Getter).Get().(string),
 	}
 
 	if cfg.VerifyUnits {
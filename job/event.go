@@ -0,0 +1,29 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"time"
+)
+
+// UnitEvent records a scheduling decision the engine made about a Unit.
+type UnitEvent struct {
+	Time time.Time
+	// ID of the machine the Unit was scheduled to or unscheduled from
+	MachineID string
+	// type of the engine task, e.g. AttemptScheduleUnit
+	Type   string
+	Reason string
+}
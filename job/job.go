@@ -16,9 +16,15 @@ package job
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/nickswift/fleet/log"
+	"github.com/nickswift/fleet/machine"
 	"github.com/nickswift/fleet/pkg"
+	"github.com/nickswift/fleet/resource"
 	"github.com/nickswift/fleet/unit"
 )
 
@@ -50,6 +56,38 @@ const (
 	fleetMachineMetadata = "MachineMetadata"
 	// Require that the unit be scheduled on every machine in the cluster
 	fleetGlobal = "Global"
+	// Number of CPU cores reserved by the unit on its machine
+	fleetCores = "Cores"
+	// Amount of memory reserved by the unit on its machine
+	fleetMemory = "Memory"
+	// Amount of disk space reserved by the unit on its machine
+	fleetDisk = "Disk"
+	// Machine metadata key across whose values template instances are spread
+	fleetSpreadBy = "SpreadBy"
+	// Maximum difference in the number of instances between spread domains
+	fleetMaxSkew = "MaxSkew"
+	// Prefer machines with the given metadata, weighted
+	fleetPreferMachineMetadata = "PreferMachineMetadata"
+	// Prefer machines not running units matching the given glob, weighted
+	fleetPreferNotCollocatedWith = "PreferNotCollocatedWith"
+	// Prevent the engine from moving the unit to rebalance the cluster
+	fleetPinned = "Pinned"
+	// Scheduling priority, units with higher priority may preempt others
+	fleetPriority = "Priority"
+	// Start only after the given units are active, wherever they run
+	fleetStartAfter = "StartAfter"
+	// Move the unit to another machine when it fails
+	fleetRescheduleOnFailure = "RescheduleOnFailure"
+	// Maximum number of times a failing unit is moved
+	fleetRescheduleAttempts = "RescheduleAttempts"
+	// Delay before moving a failed unit, doubled on every attempt
+	fleetRescheduleBackoff = "RescheduleBackoff"
+	// Maximum number of machines a global unit is rolled out to at once
+	fleetGlobalMaxParallel = "GlobalMaxParallel"
+	// Roll a global unit out to further machines only once it is healthy
+	fleetGlobalHealthGate = "GlobalHealthGate"
+	// Machine taints the unit may run despite, by key or key=value
+	fleetTolerates = "Tolerates"
 
 	deprecatedXPrefix          = "X-"
 	deprecatedXConditionPrefix = "X-Condition"
@@ -68,8 +106,37 @@ var validRequirements = pkg.NewUnsafeSet(
 	fleetMachineMetadata,
 	fleetGlobal,
 	fleetReplaces,
+	fleetCores,
+	fleetMemory,
+	fleetDisk,
+	fleetSpreadBy,
+	fleetMaxSkew,
+	fleetPreferMachineMetadata,
+	fleetPreferNotCollocatedWith,
+	fleetPinned,
+	fleetPriority,
+	fleetStartAfter,
+	fleetRescheduleOnFailure,
+	fleetRescheduleAttempts,
+	fleetRescheduleBackoff,
+	fleetGlobalMaxParallel,
+	fleetGlobalHealthGate,
+	fleetTolerates,
 )
 
+const (
+	// DefaultRescheduleAttempts is the number of times a Job with
+	// RescheduleOnFailure is moved unless RescheduleAttempts is given.
+	DefaultRescheduleAttempts = 3
+	// DefaultRescheduleBackoff is the delay before a failed Job with
+	// RescheduleOnFailure is first moved unless RescheduleBackoff is given.
+	DefaultRescheduleBackoff = 10 * time.Second
+)
+
+// defaultPreferenceWeight is the weight of a preference that does not
+// specify one
+const defaultPreferenceWeight = 1
+
 func ParseJobState(s string) (JobState, error) {
 	js := JobState(s)
 
@@ -82,6 +149,21 @@ func ParseJobState(s string) (JobState, error) {
 	return js, err
 }
 
+// MetadataPreference is a soft requirement on machine metadata. Machines
+// satisfying the Requirement are preferred by the given Weight.
+type MetadataPreference struct {
+	Requirement machine.MetadataRequirement
+	Weight      int
+}
+
+// CollocationPreference is a soft requirement on the units running on a
+// machine. Machines not running any units matching the glob Pattern are
+// preferred by the given Weight.
+type CollocationPreference struct {
+	Pattern string
+	Weight  int
+}
+
 // Job is a legacy construct encapsulating a scheduled unit in fleet
 type Job struct {
 	Name            string
@@ -89,6 +171,8 @@ type Job struct {
 	TargetState     JobState
 	TargetMachineID string
 	Unit            unit.UnitFile
+	// IDs of the machines the Job failed on and was moved away from
+	FailedMachines []string
 }
 
 // ScheduledUnit represents a Unit known by fleet and encapsulates its current scheduling state. This does not include Global units.
@@ -104,6 +188,21 @@ type Unit struct {
 	Name        string
 	Unit        unit.UnitFile
 	TargetState JobState
+	// Held is set by the engine while a launched Unit waits for its
+	// StartAfter dependencies to become active
+	Held bool
+	// IDs of the machines the Unit failed on and was moved away from
+	FailedMachines []string
+	// progress of rolling out a global Unit with GlobalMaxParallel
+	Rollout *Rollout
+	// unit files the Unit was created or replaced with, oldest first
+	Revisions []UnitRevision
+	// ChangeCause is recorded with the new revision when the Unit is
+	// created or replaced
+	ChangeCause string
+	// registry index at which the Unit was last created or replaced, or 0
+	// if the registry does not track it
+	Index uint64
 }
 
 // IsGlobal returns whether a Unit is considered a global unit
@@ -143,6 +242,48 @@ func (u *Unit) Conflicts() []string {
 	return j.Conflicts()
 }
 
+func (u *Unit) Tolerations() []string {
+	j := &Job{
+		Name: u.Name,
+		Unit: u.Unit,
+	}
+	return j.Tolerations()
+}
+
+func (u *Unit) GlobalMaxParallel() int {
+	j := &Job{
+		Name: u.Name,
+		Unit: u.Unit,
+	}
+	return j.GlobalMaxParallel()
+}
+
+func (u *Unit) GlobalHealthGate() bool {
+	j := &Job{
+		Name: u.Name,
+		Unit: u.Unit,
+	}
+	return j.GlobalHealthGate()
+}
+
+// RolloutAdmits returns whether the global Unit may run on the given
+// machine. Units without GlobalMaxParallel may run on any machine, others
+// only on the machines the current version has been rolled out to.
+func (u *Unit) RolloutAdmits(machID string) bool {
+	if u.GlobalMaxParallel() <= 0 {
+		return true
+	}
+	return u.Rollout != nil && u.Rollout.Admits(u.Unit.Hash().String(), machID)
+}
+
+func (u *Unit) StartAfter() []string {
+	j := &Job{
+		Name: u.Name,
+		Unit: u.Unit,
+	}
+	return j.StartAfter()
+}
+
 func (u *Unit) Replaces() []string {
 	j := &Job{
 		Name: u.Name,
@@ -175,6 +316,22 @@ func (u *Unit) RequiredTargetMetadata() map[string]pkg.Set {
 	return j.RequiredTargetMetadata()
 }
 
+func (u *Unit) MetadataRequirements() []machine.MetadataRequirement {
+	j := &Job{
+		Name: u.Name,
+		Unit: u.Unit,
+	}
+	return j.MetadataRequirements()
+}
+
+func (u *Unit) Resources() resource.ResourceTuple {
+	j := &Job{
+		Name: u.Name,
+		Unit: u.Unit,
+	}
+	return j.Resources()
+}
+
 // requirements returns all relevant options from the [X-Fleet] section of a unit file.
 // Relevant options are identified with a `X-` prefix in the unit.
 // This prefix is stripped from relevant options before being returned.
@@ -222,6 +379,28 @@ func (j *Job) Conflicts() []string {
 	return conflicts
 }
 
+// StartAfter returns the names of the units which must be active, on any
+// machine, before this Job may be started. Each value may list several
+// space-separated unit names.
+func (j *Job) StartAfter() []string {
+	deps := make([]string, 0)
+	for _, v := range j.requirements()[fleetStartAfter] {
+		deps = append(deps, strings.Fields(v)...)
+	}
+	return deps
+}
+
+// Tolerations returns the machine taints the Job may run despite, each of
+// the form key or key=value. Each value may list several space-separated
+// tolerations, e.g. `Tolerates=dedicated=db gpu`.
+func (j *Job) Tolerations() []string {
+	tolerations := make([]string, 0)
+	for _, v := range j.requirements()[fleetTolerates] {
+		tolerations = append(tolerations, strings.Fields(v)...)
+	}
+	return tolerations
+}
+
 // Replaces returns a list of Job names that should be scheduled to the another
 // machine as this Job.
 func (j *Job) Replaces() []string {
@@ -275,33 +454,387 @@ func (j *Job) RequiredTarget() (string, bool) {
 
 // RequiredTargetMetadata return all machine-related metadata from a Job's
 // requirements. Valid metadata fields are strings of the form `key=value`,
-// where both key and value are not the empty string.
+// where both key and value are not the empty string. Other metadata
+// expressions are not included; see MetadataRequirements.
 func (j *Job) RequiredTargetMetadata() map[string]pkg.Set {
 	metadata := make(map[string]pkg.Set)
 
+	for _, req := range j.parsedMetadataRequirements() {
+		if req.Operator != machine.MetadataOpEqual {
+			continue
+		}
+
+		if _, ok := metadata[req.Key]; !ok {
+			metadata[req.Key] = pkg.NewUnsafeSet()
+		}
+		metadata[req.Key].Add(req.Values[0])
+	}
+
+	return metadata
+}
+
+// MetadataRequirements returns all machine metadata expressions from a Job's
+// requirements, all of which a machine must satisfy to run the Job. As with
+// RequiredTargetMetadata, multiple `key=value` expressions for the same key
+// are combined into a single requirement matching any of the values.
+// Invalid expressions are ignored.
+func (j *Job) MetadataRequirements() []machine.MetadataRequirement {
+	var reqs []machine.MetadataRequirement
+	equal := make(map[string]int)
+
+	for _, req := range j.parsedMetadataRequirements() {
+		if req.Operator != machine.MetadataOpEqual {
+			reqs = append(reqs, req)
+			continue
+		}
+
+		if idx, ok := equal[req.Key]; ok {
+			reqs[idx].Operator = machine.MetadataOpIn
+			reqs[idx].Values = append(reqs[idx].Values, req.Values...)
+			continue
+		}
+		equal[req.Key] = len(reqs)
+		reqs = append(reqs, req)
+	}
+
+	return reqs
+}
+
+func (j *Job) parsedMetadataRequirements() []machine.MetadataRequirement {
+	var reqs []machine.MetadataRequirement
+
 	for _, key := range []string{
 		deprecatedXConditionPrefix + fleetMachineMetadata,
 		fleetMachineMetadata,
 	} {
-		for _, valuePair := range j.requirements()[key] {
-			s := strings.Split(valuePair, "=")
-
-			if len(s) != 2 {
+		for _, expr := range j.requirements()[key] {
+			req, err := machine.ParseMetadataRequirement(expr)
+			if err != nil {
+				// such Units are refused when submitted, but may
+				// predate the syntax
+				log.Errorf("Ignoring MachineMetadata requirement of Job(%s): %v", j.Name, err)
 				continue
 			}
+			reqs = append(reqs, *req)
+		}
+	}
 
-			if len(s[0]) == 0 || len(s[1]) == 0 {
-				continue
+	return reqs
+}
+
+// ValidateMetadataRequirements returns an error describing the first
+// MachineMetadata expression of the Job which cannot be parsed, if any.
+func (j *Job) ValidateMetadataRequirements() error {
+	for _, key := range []string{
+		deprecatedXConditionPrefix + fleetMachineMetadata,
+		fleetMachineMetadata,
+	} {
+		for _, expr := range j.requirements()[key] {
+			if _, err := machine.ParseMetadataRequirement(expr); err != nil {
+				return fmt.Errorf("invalid MachineMetadata requirement: %v", err)
 			}
+		}
+	}
+	return nil
+}
+
+// Resources returns the machine resources reserved by a Job. Cores are given
+// as a (possibly fractional) number of cores, e.g. `Cores=0.5`. Memory and
+// Disk are given in megabytes, optionally with a `K`, `M`, `G` or `T` suffix,
+// e.g. `Memory=512` or `Memory=2G`. If an option appears more than once the
+// last value wins. Invalid values, which ValidateResources reports, are
+// ignored.
+func (j *Job) Resources() (res resource.ResourceTuple) {
+	requirements := j.requirements()
 
-			if _, ok := metadata[s[0]]; !ok {
-				metadata[s[0]] = pkg.NewUnsafeSet()
+	if values := requirements[fleetCores]; len(values) > 0 {
+		if cores, err := parseCores(values[len(values)-1]); err == nil {
+			res.Cores = cores
+		}
+	}
+
+	if values := requirements[fleetMemory]; len(values) > 0 {
+		if mem, err := parseMegabytes(values[len(values)-1]); err == nil {
+			res.Memory = mem
+		}
+	}
+
+	if values := requirements[fleetDisk]; len(values) > 0 {
+		if disk, err := parseMegabytes(values[len(values)-1]); err == nil {
+			res.Disk = disk
+		}
+	}
+
+	return
+}
+
+// ValidateResources returns an error describing the first Cores, Memory or
+// Disk value of the Job which cannot be parsed, if any.
+func (j *Job) ValidateResources() error {
+	requirements := j.requirements()
+	for _, v := range requirements[fleetCores] {
+		if _, err := parseCores(v); err != nil {
+			return fmt.Errorf("invalid %s value %q", fleetCores, v)
+		}
+	}
+	for _, key := range []string{fleetMemory, fleetDisk} {
+		for _, v := range requirements[key] {
+			if _, err := parseMegabytes(v); err != nil {
+				return fmt.Errorf("invalid %s value %q", key, v)
 			}
-			metadata[s[0]].Add(s[1])
 		}
 	}
+	return nil
+}
 
-	return metadata
+// parseCores converts a number of cores into hundredths of a core, the unit
+// used by resource.ResourceTuple.
+func parseCores(s string) (int, error) {
+	cores, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, err
+	}
+	if cores < 0 || math.IsInf(cores, 0) || math.IsNaN(cores) {
+		return 0, fmt.Errorf("invalid number of cores %q", s)
+	}
+	return int(math.Ceil(cores * 100)), nil
+}
+
+// parseMegabytes converts a size with an optional K, M, G or T suffix into
+// megabytes, rounding up to the nearest whole megabyte.
+func parseMegabytes(s string) (int, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if n := len(s); n > 1 && s[n-1] == 'B' && strings.ContainsRune("KMGT", rune(s[n-2])) {
+		s = s[:n-1]
+	}
+
+	mult := 1.0
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K':
+			mult = 1.0 / 1024
+			s = s[:n-1]
+		case 'M':
+			s = s[:n-1]
+		case 'G':
+			mult = 1024
+			s = s[:n-1]
+		case 'T':
+			mult = 1024 * 1024
+			s = s[:n-1]
+		}
+	}
+
+	size, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if size < 0 || math.IsInf(size, 0) || math.IsNaN(size) {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int(math.Ceil(size * mult)), nil
+}
+
+// MetadataPreferences returns the Job's soft machine metadata requirements,
+// given as `PreferMachineMetadata=<expression>:<weight>`, e.g.
+// `PreferMachineMetadata=ssd=true:50`. The expression supports the same
+// syntax as MachineMetadata. If the weight is omitted it defaults to 1.
+// Invalid preferences are ignored.
+func (j *Job) MetadataPreferences() []MetadataPreference {
+	var prefs []MetadataPreference
+	for _, value := range j.requirements()[fleetPreferMachineMetadata] {
+		expr, weight := parsePreferenceWeight(value)
+		req, err := machine.ParseMetadataRequirement(expr)
+		if err != nil {
+			continue
+		}
+		prefs = append(prefs, MetadataPreference{Requirement: *req, Weight: weight})
+	}
+	return prefs
+}
+
+// CollocationPreferences returns the Job's soft anti-affinities, given as
+// `PreferNotCollocatedWith=<glob>:<weight>`, e.g.
+// `PreferNotCollocatedWith=cache@*:20`. If the weight is omitted it
+// defaults to 1.
+func (j *Job) CollocationPreferences() []CollocationPreference {
+	var prefs []CollocationPreference
+	for _, value := range j.requirements()[fleetPreferNotCollocatedWith] {
+		pattern, weight := parsePreferenceWeight(value)
+		if len(pattern) == 0 {
+			continue
+		}
+		prefs = append(prefs, CollocationPreference{Pattern: pattern, Weight: weight})
+	}
+	return prefs
+}
+
+// HasPreferences returns whether the Job has any soft scheduling
+// requirements.
+func (j *Job) HasPreferences() bool {
+	reqs := j.requirements()
+	return len(reqs[fleetPreferMachineMetadata]) > 0 || len(reqs[fleetPreferNotCollocatedWith]) > 0
+}
+
+// parsePreferenceWeight splits a preference of the form `<value>:<weight>`.
+// If the value has no positive integer weight suffix, the whole value is
+// returned along with the default weight.
+func parsePreferenceWeight(s string) (string, int) {
+	s = strings.TrimSpace(s)
+	idx := strings.LastIndex(s, ":")
+	if idx == -1 {
+		return s, defaultPreferenceWeight
+	}
+	weight, err := strconv.Atoi(strings.TrimSpace(s[idx+1:]))
+	if err != nil || weight < 1 {
+		return s, defaultPreferenceWeight
+	}
+	return strings.TrimSpace(s[:idx]), weight
+}
+
+// SpreadBy returns the machine metadata key across whose values the Jobs in
+// this Job's spread group should be balanced, e.g. `SpreadBy=zone`. If no
+// such requirement exists, an empty string and false are returned. If the
+// option appears more than once the last value wins.
+func (j *Job) SpreadBy() (string, bool) {
+	values := j.requirements()[fleetSpreadBy]
+	if len(values) == 0 {
+		return "", false
+	}
+	key := strings.TrimSpace(values[len(values)-1])
+	return key, len(key) > 0
+}
+
+// MaxSkew returns the maximum allowed difference between the number of Jobs
+// of this Job's spread group in the most and least populated spread domains.
+// It is only meaningful together with SpreadBy. Values that are not positive
+// integers are ignored.
+func (j *Job) MaxSkew() (int, bool) {
+	values := j.requirements()[fleetMaxSkew]
+	if len(values) == 0 {
+		return 0, false
+	}
+	skew, err := strconv.Atoi(strings.TrimSpace(values[len(values)-1]))
+	if err != nil || skew < 1 {
+		return 0, false
+	}
+	return skew, true
+}
+
+// SpreadGroup returns the name identifying the Jobs that are spread together:
+// the name of the template for template instances (e.g. `app@.service`) or
+// the Job's own name otherwise.
+func (j *Job) SpreadGroup() string {
+	if uni := unit.NewUnitNameInfo(j.Name); uni != nil && uni.IsInstance() {
+		return uni.Template
+	}
+	return j.Name
+}
+
+// Pinned returns whether the Job opted out of being moved between machines
+// when the engine rebalances the cluster, e.g. `Pinned=true`. If the option
+// appears more than once the last value wins.
+func (j *Job) Pinned() bool {
+	values := j.requirements()[fleetPinned]
+	if len(values) == 0 {
+		return false
+	}
+	return isTruthyValue(values[len(values)-1])
+}
+
+// Priority returns the scheduling priority of the Job, e.g. `Priority=100`.
+// Jobs without a valid priority have a priority of 0. If the option appears
+// more than once the last value wins.
+func (j *Job) Priority() int {
+	values := j.requirements()[fleetPriority]
+	if len(values) == 0 {
+		return 0
+	}
+	prio, err := strconv.Atoi(strings.TrimSpace(values[len(values)-1]))
+	if err != nil {
+		return 0
+	}
+	return prio
+}
+
+// RescheduleOnFailure returns whether the engine should move the Job to
+// another machine when it fails, e.g. `RescheduleOnFailure=true`.
+func (j *Job) RescheduleOnFailure() bool {
+	values := j.requirements()[fleetRescheduleOnFailure]
+	if len(values) == 0 {
+		return false
+	}
+	return isTruthyValue(values[len(values)-1])
+}
+
+// RescheduleAttempts returns how many times a failing Job may be moved to
+// another machine, e.g. `RescheduleAttempts=5`. Missing or invalid values
+// result in DefaultRescheduleAttempts.
+func (j *Job) RescheduleAttempts() int {
+	values := j.requirements()[fleetRescheduleAttempts]
+	if len(values) == 0 {
+		return DefaultRescheduleAttempts
+	}
+	attempts, err := strconv.Atoi(strings.TrimSpace(values[len(values)-1]))
+	if err != nil || attempts < 0 {
+		return DefaultRescheduleAttempts
+	}
+	return attempts
+}
+
+// RescheduleBackoff returns how long a Job must have been failing before it
+// is moved, after having been moved the given number of times already. The
+// delay given by `RescheduleBackoff`, DefaultRescheduleBackoff if missing or
+// invalid, doubles with every attempt.
+func (j *Job) RescheduleBackoff(attempt int) time.Duration {
+	backoff := DefaultRescheduleBackoff
+	if values := j.requirements()[fleetRescheduleBackoff]; len(values) > 0 {
+		d, err := time.ParseDuration(strings.TrimSpace(values[len(values)-1]))
+		if err == nil && d >= 0 {
+			backoff = d
+		}
+	}
+	for i := 0; i < attempt && backoff < time.Hour; i++ {
+		backoff *= 2
+	}
+	return backoff
+}
+
+// GlobalMaxParallel returns the maximum number of machines a global Job is
+// rolled out to at once, e.g. `GlobalMaxParallel=2`. Zero, returned for
+// missing or invalid values, means the Job runs on all machines at once.
+func (j *Job) GlobalMaxParallel() int {
+	values := j.requirements()[fleetGlobalMaxParallel]
+	if len(values) == 0 {
+		return 0
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(values[len(values)-1]))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// GlobalHealthGate returns whether a global Job with GlobalMaxParallel is
+// rolled out to further machines only once it reached its target state on
+// the previous ones, e.g. `GlobalHealthGate=true`.
+func (j *Job) GlobalHealthGate() bool {
+	values := j.requirements()[fleetGlobalHealthGate]
+	if len(values) == 0 {
+		return false
+	}
+	return isTruthyValue(values[len(values)-1])
+}
+
+// FailedOn returns whether the Job failed on the given machine before and was
+// moved away from it.
+func (j *Job) FailedOn(machID string) bool {
+	for _, id := range j.FailedMachines {
+		if id == machID {
+			return true
+		}
+	}
+	return false
 }
 
 func (j *Job) Scheduled() bool {
@@ -18,8 +18,11 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/nickswift/fleet/machine"
 	"github.com/nickswift/fleet/pkg"
+	"github.com/nickswift/fleet/resource"
 	"github.com/nickswift/fleet/unit"
 )
 
@@ -425,6 +428,13 @@ MachineMetadata==asdf`,
 MachineMetadata=foo=asdf=WHAT`,
 			map[string]pkg.Set{},
 		},
+		// other expressions are not equality requirements
+		{
+			`[X-Fleet]
+MachineMetadata=foo!=bar
+MachineMetadata=size>=10`,
+			map[string]pkg.Set{},
+		},
 		// mix everything up
 		{
 			`[X-Fleet]
@@ -452,6 +462,327 @@ X-ConditionMachineMetadata="one=abc" "two=def"`,
 	}
 }
 
+func TestJobMetadataRequirements(t *testing.T) {
+	testCases := []struct {
+		unit string
+		out  []machine.MetadataRequirement
+	}{
+		{
+			`[X-Fleet]`,
+			nil,
+		},
+		// equality on the same key is merged, other expressions are kept
+		{
+			`[X-Fleet]
+MachineMetadata=region=us-east
+MachineMetadata=role!=db
+MachineMetadata=region=us-west
+MachineMetadata=disk_gb>=500
+MachineMetadata=zone in (a, b)
+MachineMetadata=ssd exists`,
+			[]machine.MetadataRequirement{
+				{Key: "region", Operator: machine.MetadataOpIn, Values: []string{"us-east", "us-west"}},
+				{Key: "role", Operator: machine.MetadataOpNotEqual, Values: []string{"db"}},
+				{Key: "disk_gb", Operator: machine.MetadataOpGreaterEqual, Values: []string{"500"}},
+				{Key: "zone", Operator: machine.MetadataOpIn, Values: []string{"a", "b"}},
+				{Key: "ssd", Operator: machine.MetadataOpExists},
+			},
+		},
+		// bad expressions get ignored
+		{
+			`[X-Fleet]
+MachineMetadata=disk_gb>=lots
+MachineMetadata=foo=`,
+			nil,
+		},
+	}
+	for i, tt := range testCases {
+		j := NewJob("echo.service", *newUnit(t, tt.unit))
+		reqs := j.MetadataRequirements()
+		if !reflect.DeepEqual(reqs, tt.out) {
+			t.Errorf("case %d: metadata requirements differ", i)
+			t.Logf("got: %#v", reqs)
+			t.Logf("want: %#v", tt.out)
+		}
+	}
+}
+
+func TestJobPreferences(t *testing.T) {
+	j := NewJob("app@1.service", *newUnit(t, `[X-Fleet]
+PreferMachineMetadata=ssd=true:50
+PreferMachineMetadata=disk_gb>=500
+PreferMachineMetadata=role!=:10
+PreferNotCollocatedWith=cache@*:20
+PreferNotCollocatedWith=%p@*:bad`))
+
+	if !j.HasPreferences() {
+		t.Errorf("expected job to have preferences")
+	}
+
+	wantMeta := []MetadataPreference{
+		{machine.MetadataRequirement{Key: "ssd", Operator: machine.MetadataOpEqual, Values: []string{"true"}}, 50},
+		{machine.MetadataRequirement{Key: "disk_gb", Operator: machine.MetadataOpGreaterEqual, Values: []string{"500"}}, 1},
+	}
+	if got := j.MetadataPreferences(); !reflect.DeepEqual(wantMeta, got) {
+		t.Errorf("unexpected metadata preferences")
+		t.Logf("got: %#v", got)
+		t.Logf("want: %#v", wantMeta)
+	}
+
+	wantColloc := []CollocationPreference{
+		{"cache@*", 20},
+		{"app@*:bad", 1},
+	}
+	if got := j.CollocationPreferences(); !reflect.DeepEqual(wantColloc, got) {
+		t.Errorf("unexpected collocation preferences")
+		t.Logf("got: %#v", got)
+		t.Logf("want: %#v", wantColloc)
+	}
+
+	if NewJob("foo.service", *newUnit(t, `[X-Fleet]`)).HasPreferences() {
+		t.Errorf("expected job without preferences")
+	}
+}
+
+func TestJobSpreadBy(t *testing.T) {
+	testCases := []struct {
+		name  string
+		unit  string
+		key   string
+		skew  int
+		group string
+	}{
+		{"foo.service", `[X-Fleet]`, "", 0, "foo.service"},
+		{"app@1.service", `[X-Fleet]
+SpreadBy=zone`, "zone", 0, "app@.service"},
+		{"app@2.service", `[X-Fleet]
+SpreadBy=zone
+SpreadBy=rack
+MaxSkew=2`, "rack", 2, "app@.service"},
+		// bad skew values are ignored
+		{"app@3.service", `[X-Fleet]
+SpreadBy=zone
+MaxSkew=0`, "zone", 0, "app@.service"},
+		{"app@4.service", `[X-Fleet]
+SpreadBy=
+MaxSkew=many`, "", 0, "app@.service"},
+	}
+	for i, tt := range testCases {
+		j := NewJob(tt.name, *newUnit(t, tt.unit))
+		key, ok := j.SpreadBy()
+		if key != tt.key || ok != (tt.key != "") {
+			t.Errorf("case %d: SpreadBy returned (%q, %t), want %q", i, key, ok, tt.key)
+		}
+		skew, ok := j.MaxSkew()
+		if skew != tt.skew || ok != (tt.skew != 0) {
+			t.Errorf("case %d: MaxSkew returned (%d, %t), want %d", i, skew, ok, tt.skew)
+		}
+		if group := j.SpreadGroup(); group != tt.group {
+			t.Errorf("case %d: SpreadGroup returned %q, want %q", i, group, tt.group)
+		}
+	}
+}
+
+func TestJobPinned(t *testing.T) {
+	testCases := []struct {
+		unit   string
+		pinned bool
+	}{
+		{`[X-Fleet]`, false},
+		{`[X-Fleet]
+Pinned=true`, true},
+		{`[X-Fleet]
+Pinned=yes`, true},
+		{`[X-Fleet]
+Pinned=false`, false},
+		{`[X-Fleet]
+Pinned=true
+Pinned=false`, false},
+	}
+	for i, tt := range testCases {
+		j := NewJob("foo.service", *newUnit(t, tt.unit))
+		if pinned := j.Pinned(); pinned != tt.pinned {
+			t.Errorf("case %d: Pinned returned %t, want %t", i, pinned, tt.pinned)
+		}
+	}
+}
+
+func TestJobPriority(t *testing.T) {
+	testCases := []struct {
+		unit string
+		prio int
+	}{
+		{`[X-Fleet]`, 0},
+		{`[X-Fleet]
+Priority=100`, 100},
+		{`[X-Fleet]
+Priority=-5`, -5},
+		{`[X-Fleet]
+Priority=high`, 0},
+		{`[X-Fleet]
+Priority=1
+Priority= 7 `, 7},
+	}
+	for i, tt := range testCases {
+		j := NewJob("foo.service", *newUnit(t, tt.unit))
+		if prio := j.Priority(); prio != tt.prio {
+			t.Errorf("case %d: Priority returned %d, want %d", i, prio, tt.prio)
+		}
+	}
+}
+
+func TestJobReschedulePolicy(t *testing.T) {
+	testCases := []struct {
+		unit     string
+		enabled  bool
+		attempts int
+		backoffs []time.Duration
+	}{
+		{`[X-Fleet]`, false, DefaultRescheduleAttempts, []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second}},
+		{`[X-Fleet]
+RescheduleOnFailure=true
+RescheduleAttempts=5
+RescheduleBackoff=1m`, true, 5, []time.Duration{time.Minute, 2 * time.Minute}},
+		{`[X-Fleet]
+RescheduleOnFailure=yes
+RescheduleAttempts=-1
+RescheduleBackoff=soon`, true, DefaultRescheduleAttempts, []time.Duration{DefaultRescheduleBackoff}},
+		{`[X-Fleet]
+RescheduleBackoff=45m`, false, DefaultRescheduleAttempts, []time.Duration{45 * time.Minute, 90 * time.Minute, 90 * time.Minute}},
+	}
+	for i, tt := range testCases {
+		j := NewJob("foo.service", *newUnit(t, tt.unit))
+		if enabled := j.RescheduleOnFailure(); enabled != tt.enabled {
+			t.Errorf("case %d: RescheduleOnFailure returned %t, want %t", i, enabled, tt.enabled)
+		}
+		if attempts := j.RescheduleAttempts(); attempts != tt.attempts {
+			t.Errorf("case %d: RescheduleAttempts returned %d, want %d", i, attempts, tt.attempts)
+		}
+		for attempt, want := range tt.backoffs {
+			if backoff := j.RescheduleBackoff(attempt); backoff != want {
+				t.Errorf("case %d: RescheduleBackoff(%d) returned %v, want %v", i, attempt, backoff, want)
+			}
+		}
+	}
+}
+
+func TestJobGlobalRolloutPolicy(t *testing.T) {
+	testCases := []struct {
+		unit        string
+		maxParallel int
+		healthGate  bool
+	}{
+		{`[X-Fleet]`, 0, false},
+		{`[X-Fleet]
+GlobalMaxParallel=3
+GlobalHealthGate=true`, 3, true},
+		{`[X-Fleet]
+GlobalMaxParallel=-2
+GlobalHealthGate=no`, 0, false},
+		{`[X-Fleet]
+GlobalMaxParallel=many`, 0, false},
+	}
+	for i, tt := range testCases {
+		j := NewJob("foo.service", *newUnit(t, tt.unit))
+		if n := j.GlobalMaxParallel(); n != tt.maxParallel {
+			t.Errorf("case %d: GlobalMaxParallel returned %d, want %d", i, n, tt.maxParallel)
+		}
+		if gate := j.GlobalHealthGate(); gate != tt.healthGate {
+			t.Errorf("case %d: GlobalHealthGate returned %t, want %t", i, gate, tt.healthGate)
+		}
+	}
+}
+
+func TestUnitRolloutAdmits(t *testing.T) {
+	uf := *newUnit(t, "[X-Fleet]\nGlobal=true\nGlobalMaxParallel=1")
+	hash := uf.Hash().String()
+	testCases := []struct {
+		unit    unit.UnitFile
+		rollout *Rollout
+		want    bool
+	}{
+		{*newUnit(t, "[X-Fleet]\nGlobal=true"), nil, true},
+		{uf, nil, false},
+		{uf, &Rollout{UnitHash: hash, Machines: []string{"other"}}, false},
+		{uf, &Rollout{UnitHash: "old", Machines: []string{"XXX"}}, false},
+		{uf, &Rollout{UnitHash: hash, Machines: []string{"other", "XXX"}}, true},
+	}
+	for i, tt := range testCases {
+		u := Unit{Name: "foo.service", Unit: tt.unit, Rollout: tt.rollout}
+		if got := u.RolloutAdmits("XXX"); got != tt.want {
+			t.Errorf("case %d: RolloutAdmits returned %t, want %t", i, got, tt.want)
+		}
+	}
+}
+
+func TestJobResources(t *testing.T) {
+	testCases := []struct {
+		unit string
+		out  resource.ResourceTuple
+	}{
+		// no resources
+		{
+			`[X-Fleet]`,
+			resource.ResourceTuple{},
+		},
+		// whole and fractional cores
+		{
+			`[X-Fleet]
+Cores=2`,
+			resource.ResourceTuple{Cores: 200},
+		},
+		{
+			`[X-Fleet]
+Cores=0.5`,
+			resource.ResourceTuple{Cores: 50},
+		},
+		// memory and disk in MB by default
+		{
+			`[X-Fleet]
+Memory=512
+Disk=1024`,
+			resource.ResourceTuple{Memory: 512, Disk: 1024},
+		},
+		// size suffixes
+		{
+			`[X-Fleet]
+Memory=2G
+Disk=1TB`,
+			resource.ResourceTuple{Memory: 2048, Disk: 1024 * 1024},
+		},
+		{
+			`[X-Fleet]
+Memory=512M
+Disk=1536k`,
+			resource.ResourceTuple{Memory: 512, Disk: 2},
+		},
+		// last value wins
+		{
+			`[X-Fleet]
+Memory=128
+Memory=256`,
+			resource.ResourceTuple{Memory: 256},
+		},
+		// bad values just get ignored
+		{
+			`[X-Fleet]
+Cores=lots
+Memory=-1
+Disk=10X`,
+			resource.ResourceTuple{},
+		},
+	}
+	for i, tt := range testCases {
+		j := NewJob("echo.service", *newUnit(t, tt.unit))
+		res := j.Resources()
+		if !reflect.DeepEqual(res, tt.out) {
+			t.Errorf("case %d: resources differ", i)
+			t.Logf("got: %#v", res)
+			t.Logf("want: %#v", tt.out)
+		}
+	}
+}
+
 func TestInstanceUnitPrintf(t *testing.T) {
 	u := unit.NewUnitNameInfo("foo@bar.waldo")
 	if u == nil {
@@ -591,6 +922,13 @@ func TestValidateRequirements(t *testing.T) {
 		"MachineMetadata=true=false",
 		"Global=true",
 		"Replaces=foo",
+		"Cores=2",
+		"Memory=512",
+		"Disk=10G",
+		"SpreadBy=zone",
+		"MaxSkew=1",
+		"PreferMachineMetadata=ssd=true:50",
+		"PreferNotCollocatedWith=cache@*:20",
 	}
 	for i, req := range tests {
 		contents := fmt.Sprintf("[X-Fleet]\n%s", req)
@@ -0,0 +1,54 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/nickswift/fleet/unit"
+)
+
+// ReplicaSet declares the desired number of instances of a template Unit.
+// The instances are named after the template with a numeric instance name,
+// e.g. `app@1.service`, `app@2.service`, etc. for `app@.service`.
+type ReplicaSet struct {
+	Template string
+	Replicas int
+}
+
+// ReplicaName returns the name of the replica with the given index of the
+// given template, e.g. `app@3.service` for `app@.service` and 3.
+func ReplicaName(template string, index int) string {
+	uni := unit.NewUnitNameInfo(template)
+	if uni == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s%d%s", uni.Name, index, template[len(uni.Name):])
+}
+
+// ReplicaIndex determines whether the named unit is a replica of the given
+// template. If so, the index of the replica is returned along with true.
+func ReplicaIndex(template, name string) (int, bool) {
+	uni := unit.NewUnitNameInfo(name)
+	if uni == nil || !uni.IsInstance() || uni.Template != template {
+		return 0, false
+	}
+	index, err := strconv.Atoi(uni.Instance)
+	if err != nil || index < 1 || strconv.Itoa(index) != uni.Instance {
+		return 0, false
+	}
+	return index, true
+}
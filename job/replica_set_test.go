@@ -0,0 +1,68 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"testing"
+)
+
+func TestReplicaName(t *testing.T) {
+	tests := []struct {
+		template string
+		index    int
+		want     string
+	}{
+		{"app@.service", 1, "app@1.service"},
+		{"app@.service", 12, "app@12.service"},
+		{"web@.socket", 3, "web@3.socket"},
+		{"invalid", 1, ""},
+	}
+
+	for i, tt := range tests {
+		got := ReplicaName(tt.template, tt.index)
+		if got != tt.want {
+			t.Errorf("case %d: ReplicaName(%q, %d) = %q, want %q", i, tt.template, tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestReplicaIndex(t *testing.T) {
+	tests := []struct {
+		template string
+		name     string
+		index    int
+		ok       bool
+	}{
+		{"app@.service", "app@1.service", 1, true},
+		{"app@.service", "app@42.service", 42, true},
+		// only canonical, positive indices are replicas
+		{"app@.service", "app@0.service", 0, false},
+		{"app@.service", "app@01.service", 0, false},
+		{"app@.service", "app@-1.service", 0, false},
+		{"app@.service", "app@foo.service", 0, false},
+		// not an instance of the template
+		{"app@.service", "app@.service", 0, false},
+		{"app@.service", "app@1.socket", 0, false},
+		{"app@.service", "other@1.service", 0, false},
+		{"app@.service", "app.service", 0, false},
+	}
+
+	for i, tt := range tests {
+		index, ok := ReplicaIndex(tt.template, tt.name)
+		if index != tt.index || ok != tt.ok {
+			t.Errorf("case %d: ReplicaIndex(%q, %q) = (%d, %t), want (%d, %t)", i, tt.template, tt.name, index, ok, tt.index, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"time"
+
+	"github.com/nickswift/fleet/unit"
+)
+
+// UnitRevision records a unit file a Unit was created or replaced with.
+type UnitRevision struct {
+	// Revision numbers increase by one with every new unit file
+	Revision int
+	UnitHash unit.Hash
+	Time     time.Time
+	// why the unit file was changed, e.g. the fleetctl command used
+	Cause string
+}
+
+// AppendUnitRevision adds a revision for the given unit file to the
+// revisions of a Unit, keeping at most max revisions. If the unit file is
+// already the latest revision, the revisions are returned unchanged.
+func AppendUnitRevision(revs []UnitRevision, hash unit.Hash, t time.Time, cause string, max int) []UnitRevision {
+	rev := 1
+	if len(revs) > 0 {
+		last := revs[len(revs)-1]
+		if last.UnitHash == hash {
+			return revs
+		}
+		rev = last.Revision + 1
+	}
+
+	revs = append(revs, UnitRevision{Revision: rev, UnitHash: hash, Time: t, Cause: cause})
+	if len(revs) > max {
+		revs = revs[len(revs)-max:]
+	}
+	return revs
+}
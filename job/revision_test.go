@@ -0,0 +1,69 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nickswift/fleet/unit"
+)
+
+func TestAppendUnitRevision(t *testing.T) {
+	hash := func(contents string) unit.Hash {
+		uf, err := unit.NewUnitFile(contents)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return uf.Hash()
+	}
+	a, b, c := hash("[Service]\nExecStart=/bin/a"), hash("[Service]\nExecStart=/bin/b"), hash("[Service]\nExecStart=/bin/c")
+	t0 := time.Unix(1000, 0)
+
+	tests := []struct {
+		revs []UnitRevision
+		hash unit.Hash
+		want []UnitRevision
+	}{
+		// the first revision of a Unit
+		{
+			nil, a,
+			[]UnitRevision{{1, a, t0, "cause"}},
+		},
+		// the latest unit file is not recorded again
+		{
+			[]UnitRevision{{1, a, t0, "old"}}, a,
+			[]UnitRevision{{1, a, t0, "old"}},
+		},
+		// an older unit file becomes a new revision
+		{
+			[]UnitRevision{{1, a, t0, "old"}, {2, b, t0, "old"}}, a,
+			[]UnitRevision{{1, a, t0, "old"}, {2, b, t0, "old"}, {3, a, t0, "cause"}},
+		},
+		// the oldest revisions are dropped
+		{
+			[]UnitRevision{{4, a, t0, "old"}, {5, b, t0, "old"}, {6, a, t0, "old"}}, c,
+			[]UnitRevision{{5, b, t0, "old"}, {6, a, t0, "old"}, {7, c, t0, "cause"}},
+		},
+	}
+
+	for i, tt := range tests {
+		got := AppendUnitRevision(tt.revs, tt.hash, t0, "cause", 3)
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("case %d: expected revisions %v, got %v", i, tt.want, got)
+		}
+	}
+}
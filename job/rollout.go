@@ -0,0 +1,38 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+// Rollout records the machines a version of a global Unit with
+// GlobalMaxParallel has been rolled out to so far.
+type Rollout struct {
+	// hash of the unit file being rolled out
+	UnitHash string
+	// IDs of the machines allowed to run the unit file
+	Machines []string
+}
+
+// Admits returns whether the given machine may run the unit file with the
+// given hash. Rollouts of another version of the unit file admit nothing.
+func (r *Rollout) Admits(hash, machID string) bool {
+	if r.UnitHash != hash {
+		return false
+	}
+	for _, id := range r.Machines {
+		if id == machID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+// StartAfterCycle determines whether adding the given Unit to the other
+// Units would create a cycle of StartAfter dependencies, which would hold
+// the Units involved forever. If so, the names of the Units forming the
+// cycle are returned, starting and ending with the given Unit. A Unit in
+// the list with the same name as the given Unit is ignored.
+func StartAfterCycle(u Unit, units []Unit) []string {
+	deps := map[string][]string{u.Name: u.StartAfter()}
+	for _, other := range units {
+		if other.Name != u.Name {
+			deps[other.Name] = other.StartAfter()
+		}
+	}
+
+	visited := make(map[string]bool)
+	var visit func(name string, path []string) []string
+	visit = func(name string, path []string) []string {
+		path = append(path, name)
+		for _, dep := range deps[name] {
+			if dep == u.Name {
+				return append(path, dep)
+			}
+			if visited[dep] {
+				continue
+			}
+			visited[dep] = true
+			if cycle := visit(dep, path); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+
+	return visit(u.Name, nil)
+}
@@ -0,0 +1,86 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJobStartAfter(t *testing.T) {
+	tests := []struct {
+		name string
+		unit string
+		deps []string
+	}{
+		{"app.service", `[X-Fleet]`, []string{}},
+		{"app.service", `[X-Fleet]
+StartAfter=db.service`, []string{"db.service"}},
+		{"app.service", `[X-Fleet]
+StartAfter=db.service cache.service
+StartAfter=queue.service`, []string{"db.service", "cache.service", "queue.service"}},
+		{"app@1.service", `[X-Fleet]
+StartAfter=db@%i.service`, []string{"db@1.service"}},
+	}
+
+	for i, tt := range tests {
+		j := NewJob(tt.name, *newUnit(t, tt.unit))
+		if deps := j.StartAfter(); !reflect.DeepEqual(tt.deps, deps) {
+			t.Errorf("case %d: StartAfter returned %v, want %v", i, deps, tt.deps)
+		}
+	}
+}
+
+func TestStartAfterCycle(t *testing.T) {
+	newStartAfterUnit := func(name, deps string) Unit {
+		contents := `[X-Fleet]`
+		if deps != "" {
+			contents += "\nStartAfter=" + deps
+		}
+		return Unit{Name: name, Unit: *newUnit(t, contents)}
+	}
+
+	units := []Unit{
+		newStartAfterUnit("db.service", ""),
+		newStartAfterUnit("app.service", "db.service cache.service"),
+		newStartAfterUnit("cache.service", "proxy.service"),
+		newStartAfterUnit("web.service", "app.service"),
+	}
+
+	tests := []struct {
+		unit  Unit
+		cycle []string
+	}{
+		// no dependencies
+		{newStartAfterUnit("proxy.service", ""), nil},
+		// dependency on a unit which does not exist yet
+		{newStartAfterUnit("proxy.service", "dns.service"), nil},
+		// direct cycle
+		{newStartAfterUnit("proxy.service", "cache.service"), []string{"proxy.service", "cache.service", "proxy.service"}},
+		// indirect cycle
+		{newStartAfterUnit("proxy.service", "web.service"), []string{"proxy.service", "web.service", "app.service", "cache.service", "proxy.service"}},
+		// depending on itself
+		{newStartAfterUnit("proxy.service", "proxy.service"), []string{"proxy.service", "proxy.service"}},
+		// replacing an existing unit removes its old dependencies
+		{newStartAfterUnit("app.service", "db.service"), nil},
+		{newStartAfterUnit("db.service", "web.service"), []string{"db.service", "web.service", "app.service", "db.service"}},
+	}
+
+	for i, tt := range tests {
+		if cycle := StartAfterCycle(tt.unit, units); !reflect.DeepEqual(tt.cycle, cycle) {
+			t.Errorf("case %d: StartAfterCycle returned %v, want %v", i, cycle, tt.cycle)
+		}
+	}
+}
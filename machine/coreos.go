@@ -15,22 +15,29 @@
 package machine
 
 import (
+	"bufio"
 	"errors"
 	"io/ioutil"
 	"net"
+	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/vishvananda/netlink"
 
 	"github.com/nickswift/fleet/log"
+	"github.com/nickswift/fleet/resource"
 	"github.com/nickswift/fleet/unit"
 )
 
 const (
 	machineIDPath = "/etc/machine-id"
+	meminfoPath   = "/proc/meminfo"
 )
 
 func NewCoreOSMachine(static MachineState, um unit.UnitManager) *CoreOSMachine {
@@ -48,6 +55,8 @@ type CoreOSMachine struct {
 	um           unit.UnitManager
 	staticState  MachineState
 	dynamicState *MachineState
+	// metadata set at runtime, overlaid on that of the static state
+	metadataOverlay map[string]string
 }
 
 func (m *CoreOSMachine) String() string {
@@ -56,6 +65,7 @@ func (m *CoreOSMachine) String() string {
 
 // State returns a MachineState object representing the CoreOSMachine's
 // static state overlaid on its dynamic state at the time of execution.
+// The metadata set at runtime is overlaid on the result.
 func (m *CoreOSMachine) State() (state MachineState) {
 	m.RLock()
 	defer m.RUnlock()
@@ -65,10 +75,19 @@ func (m *CoreOSMachine) State() (state MachineState) {
 	} else {
 		state = stackState(m.staticState, *m.dynamicState)
 	}
+	state.Metadata = OverlayMetadata(state.Metadata, m.metadataOverlay)
 
 	return
 }
 
+// SetMetadataOverlay replaces the metadata set at runtime.
+func (m *CoreOSMachine) SetMetadataOverlay(metadata map[string]string) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.metadataOverlay = metadata
+}
+
 // Refresh updates the current state of the CoreOSMachine.
 func (m *CoreOSMachine) Refresh() {
 	m.RLock()
@@ -108,9 +127,10 @@ func (m *CoreOSMachine) currentState() *MachineState {
 	}
 	publicIP := getLocalIP()
 	return &MachineState{
-		ID:       id,
-		PublicIP: publicIP,
-		Metadata: make(map[string]string, 0),
+		ID:             id,
+		PublicIP:       publicIP,
+		Metadata:       make(map[string]string, 0),
+		TotalResources: readLocalResources("/"),
 	}
 }
 
@@ -133,6 +153,58 @@ func readLocalMachineID(root string) (string, error) {
 	return mID, nil
 }
 
+// readLocalResources determines the total CPU, memory and disk capacity of
+// the local host. Any component that cannot be determined is left as zero.
+func readLocalResources(root string) *resource.ResourceTuple {
+	res := &resource.ResourceTuple{
+		Cores: runtime.NumCPU() * 100,
+	}
+
+	mem, err := readTotalMemory(filepath.Join(root, meminfoPath))
+	if err != nil {
+		log.Debugf("Unable to determine total memory: %v", err)
+	} else {
+		res.Memory = mem
+	}
+
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(root, &fs); err != nil {
+		log.Debugf("Unable to determine total disk space: %v", err)
+	} else {
+		res.Disk = int(uint64(fs.Blocks) * uint64(fs.Bsize) / (1024 * 1024))
+	}
+
+	return res
+}
+
+// readTotalMemory parses the MemTotal field of the given meminfo file,
+// returning the total memory in MB.
+func readTotalMemory(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+		kb, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return 0, err
+		}
+		return kb / 1024, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	return 0, errors.New("MemTotal not found")
+}
+
 func getLocalIP() (got string) {
 	iface := getDefaultGatewayIface()
 	if iface == nil {
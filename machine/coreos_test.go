@@ -65,6 +65,32 @@ func TestReadLocalMachineIDFound(t *testing.T) {
 	}
 }
 
+func TestReadTotalMemory(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "fleet-")
+	if err != nil {
+		t.Fatalf("Failed creating tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	meminfo := filepath.Join(dir, "meminfo")
+	contents := "MemTotal:        8048888 kB\nMemFree:         1386944 kB\n"
+	if err := ioutil.WriteFile(meminfo, []byte(contents), os.FileMode(0644)); err != nil {
+		t.Fatalf("Failed writing fake meminfo file: %v", err)
+	}
+
+	mem, err := readTotalMemory(meminfo)
+	if err != nil {
+		t.Fatalf("Unexpected error reading meminfo: %v", err)
+	}
+	if mem != 7860 {
+		t.Fatalf("Received incorrect total memory %d, expected 7860", mem)
+	}
+
+	if _, err := readTotalMemory(filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("Expected error for missing meminfo, but got nil")
+	}
+}
+
 func TestUsableAddress(t *testing.T) {
 	tests := []struct {
 		ip net.IP
@@ -23,6 +23,14 @@ type Machine interface {
 	State() MachineState
 }
 
+// MetadataOverlayer is implemented by Machines whose metadata can be
+// extended at runtime, e.g. through the API.
+type MetadataOverlayer interface {
+	// SetMetadataOverlay replaces the metadata overlaid on the Machine's
+	// configured metadata.
+	SetMetadataOverlay(metadata map[string]string)
+}
+
 // HasMetadata determine if the Metadata of a given MachineState
 // matches the indicated values.
 func HasMetadata(state *MachineState, metadata map[string]pkg.Set) bool {
@@ -0,0 +1,205 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machine
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nickswift/fleet/log"
+)
+
+type MetadataOperator string
+
+const (
+	MetadataOpEqual        = MetadataOperator("=")
+	MetadataOpNotEqual     = MetadataOperator("!=")
+	MetadataOpGreater      = MetadataOperator(">")
+	MetadataOpGreaterEqual = MetadataOperator(">=")
+	MetadataOpLess         = MetadataOperator("<")
+	MetadataOpLessEqual    = MetadataOperator("<=")
+	MetadataOpIn           = MetadataOperator("in")
+	MetadataOpExists       = MetadataOperator("exists")
+)
+
+var (
+	metadataInRegexp     = regexp.MustCompile(`^([^\s=!<>()]+)\s+in\s*\((.*)\)$`)
+	metadataExistsRegexp = regexp.MustCompile(`^([^\s=!<>()]+)\s+exists$`)
+
+	// comparison operators, longest first so that e.g. ">=" is not
+	// mistaken for ">"
+	metadataComparisonOps = []MetadataOperator{
+		MetadataOpNotEqual,
+		MetadataOpGreaterEqual,
+		MetadataOpLessEqual,
+		MetadataOpGreater,
+		MetadataOpLess,
+		MetadataOpEqual,
+	}
+)
+
+// MetadataRequirement is a single expression a machine's metadata must
+// satisfy, e.g. `role!=db`, `disk_gb>=500`, `zone in (a,b)` or `ssd exists`.
+type MetadataRequirement struct {
+	Key      string
+	Operator MetadataOperator
+	Values   []string
+}
+
+// ParseMetadataRequirement parses a single metadata expression. The
+// following forms are supported:
+//
+//	key=value, key!=value
+//	key>value, key>=value, key<value, key<=value (numeric values only)
+//	key in (value1,value2,...)
+//	key exists
+func ParseMetadataRequirement(expr string) (*MetadataRequirement, error) {
+	expr = strings.TrimSpace(expr)
+
+	if m := metadataExistsRegexp.FindStringSubmatch(expr); m != nil {
+		return &MetadataRequirement{Key: m[1], Operator: MetadataOpExists}, nil
+	}
+
+	if m := metadataInRegexp.FindStringSubmatch(expr); m != nil {
+		var values []string
+		for _, v := range strings.Split(m[2], ",") {
+			v = strings.TrimSpace(v)
+			if len(v) == 0 {
+				return nil, fmt.Errorf("empty value in metadata expression %q", expr)
+			}
+			values = append(values, v)
+		}
+		return &MetadataRequirement{Key: m[1], Operator: MetadataOpIn, Values: values}, nil
+	}
+
+	idx := strings.IndexAny(expr, "!<>=")
+	if idx == -1 {
+		return nil, fmt.Errorf("invalid metadata expression %q", expr)
+	}
+	key := strings.TrimSpace(expr[:idx])
+	rest := expr[idx:]
+
+	for _, op := range metadataComparisonOps {
+		if !strings.HasPrefix(rest, string(op)) {
+			continue
+		}
+
+		value := strings.TrimSpace(rest[len(op):])
+		if len(key) == 0 || len(value) == 0 || strings.ContainsAny(value, "=") {
+			return nil, fmt.Errorf("invalid metadata expression %q", expr)
+		}
+		if isNumericOperator(op) {
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("non-numeric value in metadata expression %q", expr)
+			}
+		}
+		return &MetadataRequirement{Key: key, Operator: op, Values: []string{value}}, nil
+	}
+
+	return nil, fmt.Errorf("invalid metadata expression %q", expr)
+}
+
+func isNumericOperator(op MetadataOperator) bool {
+	return op == MetadataOpGreater || op == MetadataOpGreaterEqual || op == MetadataOpLess || op == MetadataOpLessEqual
+}
+
+func (mr MetadataRequirement) String() string {
+	switch mr.Operator {
+	case MetadataOpExists:
+		return fmt.Sprintf("%s exists", mr.Key)
+	case MetadataOpIn:
+		return fmt.Sprintf("%s in (%s)", mr.Key, strings.Join(mr.Values, ","))
+	default:
+		return fmt.Sprintf("%s%s%s", mr.Key, mr.Operator, strings.Join(mr.Values, ","))
+	}
+}
+
+// Matches determines whether the given metadata satisfies the requirement.
+// A missing key only satisfies a `!=` requirement. Numeric comparisons are
+// never satisfied by non-numeric metadata values.
+func (mr MetadataRequirement) Matches(metadata map[string]string) bool {
+	local, ok := metadata[mr.Key]
+
+	switch mr.Operator {
+	case MetadataOpExists:
+		return ok
+	case MetadataOpNotEqual:
+		return !ok || local != mr.Values[0]
+	case MetadataOpEqual, MetadataOpIn:
+		if !ok {
+			return false
+		}
+		for _, v := range mr.Values {
+			if local == v {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !ok || len(mr.Values) == 0 {
+		return false
+	}
+	lf, err := strconv.ParseFloat(local, 64)
+	if err != nil {
+		return false
+	}
+	rf, err := strconv.ParseFloat(mr.Values[0], 64)
+	if err != nil {
+		return false
+	}
+
+	switch mr.Operator {
+	case MetadataOpGreater:
+		return lf > rf
+	case MetadataOpGreaterEqual:
+		return lf >= rf
+	case MetadataOpLess:
+		return lf < rf
+	case MetadataOpLessEqual:
+		return lf <= rf
+	}
+	return false
+}
+
+// MatchesMetadata determines if the Metadata of a given MachineState
+// satisfies all of the given requirements.
+func MatchesMetadata(state *MachineState, reqs []MetadataRequirement) bool {
+	for _, req := range reqs {
+		if !req.Matches(state.Metadata) {
+			log.Debugf("Local Metadata does not match requirement %s", req)
+			return false
+		}
+	}
+	return true
+}
+
+// OverlayMetadata returns the given metadata extended by the overlay, whose
+// values take precedence. Neither of the given maps is modified.
+func OverlayMetadata(metadata, overlay map[string]string) map[string]string {
+	if len(overlay) == 0 {
+		return metadata
+	}
+	merged := make(map[string]string, len(metadata)+len(overlay))
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,133 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMetadataRequirement(t *testing.T) {
+	testCases := []struct {
+		expr string
+		want *MetadataRequirement
+	}{
+		{"region=us-east-1", &MetadataRequirement{"region", MetadataOpEqual, []string{"us-east-1"}}},
+		{"role!=db", &MetadataRequirement{"role", MetadataOpNotEqual, []string{"db"}}},
+		{"disk_gb>=500", &MetadataRequirement{"disk_gb", MetadataOpGreaterEqual, []string{"500"}}},
+		{"disk_gb > 500", &MetadataRequirement{"disk_gb", MetadataOpGreater, []string{"500"}}},
+		{"cores<=4", &MetadataRequirement{"cores", MetadataOpLessEqual, []string{"4"}}},
+		{"cores<4.5", &MetadataRequirement{"cores", MetadataOpLess, []string{"4.5"}}},
+		{"zone in (a,b, c)", &MetadataRequirement{"zone", MetadataOpIn, []string{"a", "b", "c"}}},
+		{"ssd exists", &MetadataRequirement{"ssd", MetadataOpExists, nil}},
+
+		// invalid expressions
+		{"region", nil},
+		{"region=", nil},
+		{"=us-east-1", nil},
+		{"foo=asdf=WHAT", nil},
+		{"disk_gb>=lots", nil},
+		{"zone in (a,,b)", nil},
+		{"zone in a,b", nil},
+	}
+
+	for i, tt := range testCases {
+		got, err := ParseMetadataRequirement(tt.expr)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("case %d: expected error parsing %q, got %#v", i, tt.expr, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %d: unexpected error parsing %q: %v", i, tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("case %d: parsing %q returned %#v, want %#v", i, tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesMetadata(t *testing.T) {
+	metadata := map[string]string{
+		"region":  "us-east-1",
+		"role":    "web",
+		"disk_gb": "500",
+		"ssd":     "",
+	}
+
+	testCases := []struct {
+		exprs []string
+		want  bool
+	}{
+		{[]string{}, true},
+		{[]string{"region=us-east-1"}, true},
+		{[]string{"region=us-west-1"}, false},
+		{[]string{"role!=db"}, true},
+		{[]string{"role!=web"}, false},
+		{[]string{"rack!=r1"}, true},
+		{[]string{"disk_gb>=500"}, true},
+		{[]string{"disk_gb>500"}, false},
+		{[]string{"disk_gb<1000"}, true},
+		{[]string{"disk_gb<=499.5"}, false},
+		{[]string{"region>=1"}, false},
+		{[]string{"memory_gb>=1"}, false},
+		{[]string{"region in (us-east-1,us-west-1)"}, true},
+		{[]string{"region in (eu-west-1)"}, false},
+		{[]string{"ssd exists"}, true},
+		{[]string{"gpu exists"}, false},
+		{[]string{"ssd exists", "role!=db", "disk_gb>=250"}, true},
+		{[]string{"ssd exists", "role!=web"}, false},
+	}
+
+	for i, tt := range testCases {
+		var reqs []MetadataRequirement
+		for _, expr := range tt.exprs {
+			req, err := ParseMetadataRequirement(expr)
+			if err != nil {
+				t.Fatalf("case %d: unexpected error parsing %q: %v", i, expr, err)
+			}
+			reqs = append(reqs, *req)
+		}
+
+		ms := &MachineState{Metadata: metadata}
+		if got := MatchesMetadata(ms, reqs); got != tt.want {
+			t.Errorf("case %d: MatchesMetadata(%v) returned %t, expected %t", i, tt.exprs, got, tt.want)
+		}
+	}
+}
+
+func TestOverlayMetadata(t *testing.T) {
+	metadata := map[string]string{"region": "us-east", "ssd": "true"}
+	tests := []struct {
+		overlay map[string]string
+		want    map[string]string
+	}{
+		{nil, map[string]string{"region": "us-east", "ssd": "true"}},
+		{map[string]string{"rack": "r12"}, map[string]string{"region": "us-east", "ssd": "true", "rack": "r12"}},
+		{map[string]string{"region": "us-west"}, map[string]string{"region": "us-west", "ssd": "true"}},
+	}
+
+	for i, tt := range tests {
+		got := OverlayMetadata(metadata, tt.overlay)
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("case %d: expected %v, got %v", i, tt.want, got)
+		}
+		if len(metadata) != 2 || metadata["region"] != "us-east" {
+			t.Fatalf("case %d: original metadata modified: %v", i, metadata)
+		}
+	}
+}
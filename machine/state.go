@@ -14,6 +14,10 @@
 
 package machine
 
+import (
+	"github.com/nickswift/fleet/resource"
+)
+
 const (
 	shortIDLen = 8
 )
@@ -26,6 +30,22 @@ type MachineState struct {
 	Metadata     map[string]string
 	Capabilities Capabilities
 	Version      string
+
+	// TotalResources is the total capacity of the machine, including
+	// the resources reserved for the host itself. It is nil if the
+	// machine does not advertise its capacity.
+	TotalResources *resource.ResourceTuple `json:",omitempty"`
+
+	// Cordoned indicates that no new units may be scheduled to the
+	// machine. It is not published by the machine itself but stored
+	// separately in the Registry.
+	Cordoned bool `json:",omitempty"`
+
+	// Taints repel all units which do not tolerate them, by key or by
+	// key and value. They are configured on the machine itself and may
+	// be extended through the API, which stores them separately in the
+	// Registry.
+	Taints map[string]string `json:",omitempty"`
 }
 
 func (ms MachineState) ShortID() string {
@@ -59,6 +79,10 @@ func stackState(top, bottom MachineState) MachineState {
 		state.Metadata = top.Metadata
 	}
 
+	if len(top.Taints) > 0 {
+		state.Taints = top.Taints
+	}
+
 	if len(top.Capabilities) > 0 {
 		state.Capabilities = top.Capabilities
 	}
@@ -67,5 +91,9 @@ func stackState(top, bottom MachineState) MachineState {
 		state.Version = top.Version
 	}
 
+	if top.TotalResources != nil && !top.TotalResources.Empty() {
+		state.TotalResources = top.TotalResources
+	}
+
 	return state
 }
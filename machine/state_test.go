@@ -14,7 +14,11 @@
 
 package machine
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/nickswift/fleet/resource"
+)
 
 func TestStackState(t *testing.T) {
 	top := MachineState{
@@ -24,10 +28,11 @@ func TestStackState(t *testing.T) {
 		Version:  "1",
 	}
 	bottom := MachineState{
-		ID:       "595989bb-cbb7-49ce-8726-722d6e157b4e",
-		PublicIP: "5.6.7.8",
-		Metadata: map[string]string{"foo": "bar"},
-		Version:  "",
+		ID:             "595989bb-cbb7-49ce-8726-722d6e157b4e",
+		PublicIP:       "5.6.7.8",
+		Metadata:       map[string]string{"foo": "bar"},
+		Version:        "",
+		TotalResources: &resource.ResourceTuple{Cores: 400, Memory: 8192, Disk: 20480},
 	}
 	stacked := stackState(top, bottom)
 
@@ -46,6 +51,10 @@ func TestStackState(t *testing.T) {
 	if stacked.Version != "1" {
 		t.Errorf("Unexpected Version value %s", stacked.Version)
 	}
+
+	if stacked.TotalResources == nil || *stacked.TotalResources != *bottom.TotalResources {
+		t.Errorf("Unexpected TotalResources value %v", stacked.TotalResources)
+	}
 }
 
 func TestStackStateEmptyTop(t *testing.T) {
@@ -91,6 +100,9 @@ var shortIDTests = []struct {
 			map[string]string{"foo": "bar"},
 			Capabilities{},
 			"",
+			nil,
+			false,
+			nil,
 		},
 		s: "595989bb",
 		l: "595989bb-cbb7-49ce-8726-722d6e157b4e",
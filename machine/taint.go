@@ -0,0 +1,86 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machine
+
+import (
+	"sort"
+	"strings"
+)
+
+// ParseTaints parses a comma-separated list of taints, each of the form
+// key=value or key, into a map of taint keys to values.
+func ParseTaints(raw string) map[string]string {
+	taints := make(map[string]string)
+	for _, taint := range strings.Split(raw, ",") {
+		parts := strings.SplitN(taint, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if key == "" {
+			continue
+		}
+		val := ""
+		if len(parts) == 2 {
+			val = strings.TrimSpace(parts[1])
+		}
+		taints[key] = val
+	}
+	return taints
+}
+
+// OverlayTaints returns the taints a Machine is configured with extended by
+// those set through the API, which take precedence. Neither of the given
+// maps is modified.
+func OverlayTaints(configured, set map[string]string) map[string]string {
+	return OverlayMetadata(configured, set)
+}
+
+// FormatTaint formats a single taint as key=value, or just key if the taint
+// has no value.
+func FormatTaint(key, val string) string {
+	if val == "" {
+		return key
+	}
+	return key + "=" + val
+}
+
+// UntoleratedTaint returns the first taint of the given MachineState, in
+// order of their keys, that is not accepted by any of the given
+// tolerations. A toleration of the form key accepts the taint with that
+// key regardless of its value, one of the form key=value only accepts the
+// taint with exactly that value. If all taints are tolerated, false is
+// returned.
+func UntoleratedTaint(state *MachineState, tolerations []string) (taint string, found bool) {
+	var keys sort.StringSlice
+	for key := range state.Taints {
+		keys = append(keys, key)
+	}
+	keys.Sort()
+
+	for _, key := range keys {
+		val := state.Taints[key]
+		if !tolerates(tolerations, key, val) {
+			return FormatTaint(key, val), true
+		}
+	}
+	return "", false
+}
+
+func tolerates(tolerations []string, key, val string) bool {
+	for _, t := range tolerations {
+		if t == key || t == key+"="+val {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTaints(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want map[string]string
+	}{
+		{"", map[string]string{}},
+		{"dedicated=db", map[string]string{"dedicated": "db"}},
+		{" dedicated = db , gpu,, ", map[string]string{"dedicated": "db", "gpu": ""}},
+		{"=db,a=b=c", map[string]string{"a": "b=c"}},
+	}
+
+	for i, tt := range tests {
+		if got := ParseTaints(tt.raw); !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("case %d: expected %v, got %v", i, tt.want, got)
+		}
+	}
+}
+
+func TestUntoleratedTaint(t *testing.T) {
+	taints := map[string]string{"dedicated": "db", "gpu": ""}
+	tests := []struct {
+		tolerations []string
+		taint       string
+		found       bool
+	}{
+		{nil, "dedicated=db", true},
+		{[]string{"dedicated=db"}, "gpu", true},
+		{[]string{"dedicated=web", "gpu"}, "dedicated=db", true},
+		{[]string{"dedicated=db", "gpu"}, "", false},
+		{[]string{"dedicated", "gpu="}, "", false},
+	}
+
+	for i, tt := range tests {
+		taint, found := UntoleratedTaint(&MachineState{Taints: taints}, tt.tolerations)
+		if taint != tt.taint || found != tt.found {
+			t.Errorf("case %d: expected (%q, %t), got (%q, %t)", i, tt.taint, tt.found, taint, found)
+		}
+	}
+
+	if _, found := UntoleratedTaint(&MachineState{}, nil); found {
+		t.Errorf("expected a machine without taints to tolerate everything")
+	}
+}
@@ -0,0 +1,315 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package etcdv3 implements the etcd v2 KeysAPI on top of the v3 API of
+// etcd, so that the etcd-backed Registry, lease Manager and EventStream can
+// be used with etcd clusters which no longer serve the v2 API.
+//
+// The v3 API is accessed through the JSON gateway of etcd. Directories are
+// emulated with key prefixes, TTLs with leases and the indexes of the v2 API
+// with the revisions of the v3 API.
+package etcdv3
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+const (
+	// apiPrefix is the path of the JSON gateway of the v3 API
+	apiPrefix = "/v3/"
+
+	// codeUnauthenticated is the gRPC status code of requests with a
+	// missing or expired auth token
+	codeUnauthenticated = 16
+	// codeNotFound is the gRPC status code of requests referring to a
+	// lease which expired or was revoked
+	codeNotFound = 5
+)
+
+// errLeaseNotFound is returned for requests referring to a lease which
+// expired or was revoked.
+var errLeaseNotFound = errors.New("etcdv3: requested lease not found")
+
+// jsonInt64 is an int64 which the JSON gateway encodes as a string
+type jsonInt64 int64
+
+func (i jsonInt64) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(strconv.FormatInt(int64(i), 10))), nil
+}
+
+func (i *jsonInt64) UnmarshalJSON(b []byte) error {
+	n, err := strconv.ParseInt(strings.Trim(string(b), `"`), 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = jsonInt64(n)
+	return nil
+}
+
+// gatewayError is the body of an unsuccessful response of the JSON gateway
+type gatewayError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+type client struct {
+	endpoints []string
+	hc        *http.Client
+	timeout   time.Duration
+	username  string
+	password  string
+
+	mu     sync.Mutex
+	pinned int
+	token  string
+}
+
+func newClient(cfg etcd.Config) (*client, error) {
+	if len(cfg.Endpoints) == 0 {
+		return nil, etcd.ErrNoEndpoints
+	}
+	for _, ep := range cfg.Endpoints {
+		if _, err := url.Parse(ep); err != nil {
+			return nil, err
+		}
+	}
+
+	var rt http.RoundTripper = etcd.DefaultTransport
+	if cfg.Transport != nil {
+		rt = cfg.Transport
+	}
+
+	c := &client{
+		endpoints: cfg.Endpoints,
+		hc:        &http.Client{Transport: rt},
+		timeout:   cfg.HeaderTimeoutPerRequest,
+		username:  cfg.Username,
+		password:  cfg.Password,
+	}
+	return c, nil
+}
+
+// call sends the request to the given method of the JSON gateway and
+// decodes the response into resp.
+func (c *client) call(ctx context.Context, method string, req, resp interface{}) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	body, err := c.stream(ctx, method, req)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return json.NewDecoder(body).Decode(resp)
+}
+
+// stream sends the request to the given method of the JSON gateway and
+// returns the body of the response, which the caller must close.
+func (c *client) stream(ctx context.Context, method string, req interface{}) (io.ReadCloser, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	reauth := c.username != ""
+	for {
+		token, err := c.authToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := c.post(ctx, method, b, token)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode == http.StatusOK {
+			return res.Body, nil
+		}
+
+		gerr := decodeError(res)
+		if gerr.Code == codeUnauthenticated && reauth {
+			// the token expired, authenticate once more
+			reauth = false
+			c.setToken("")
+			continue
+		}
+		if gerr.Code == codeNotFound {
+			return nil, errLeaseNotFound
+		}
+		return nil, fmt.Errorf("etcdv3: %s", gerr.Message)
+	}
+}
+
+// post sends the body to the given method of the JSON gateway, trying each
+// endpoint in turn until one of them responds.
+func (c *client) post(ctx context.Context, method string, body []byte, token string) (*http.Response, error) {
+	c.mu.Lock()
+	pinned := c.pinned
+	c.mu.Unlock()
+
+	var lastErr error
+	for i := range c.endpoints {
+		idx := (pinned + i) % len(c.endpoints)
+		u := strings.TrimSuffix(c.endpoints[idx], "/") + apiPrefix + method
+
+		req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+
+		res, err := c.hc.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+
+		c.mu.Lock()
+		c.pinned = idx
+		c.mu.Unlock()
+		return res, nil
+	}
+
+	return nil, lastErr
+}
+
+// authToken returns the token authenticating requests, authenticating
+// with the configured credentials first if necessary.
+func (c *client) authToken(ctx context.Context) (string, error) {
+	if c.username == "" {
+		return "", nil
+	}
+
+	c.mu.Lock()
+	token := c.token
+	c.mu.Unlock()
+	if token != "" {
+		return token, nil
+	}
+
+	b, err := json.Marshal(&authenticateRequest{Name: c.username, Password: c.password})
+	if err != nil {
+		return "", err
+	}
+	res, err := c.post(ctx, "auth/authenticate", b, "")
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("etcdv3: failed authenticating as %s: %s", c.username, decodeError(res).Message)
+	}
+
+	var resp authenticateResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return "", err
+	}
+	if resp.Token == "" {
+		return "", errors.New("etcdv3: authentication returned no token")
+	}
+
+	c.setToken(resp.Token)
+	return resp.Token, nil
+}
+
+func (c *client) setToken(token string) {
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+}
+
+func decodeError(res *http.Response) gatewayError {
+	defer res.Body.Close()
+
+	var gerr gatewayError
+	if err := json.NewDecoder(res.Body).Decode(&gerr); err != nil || gerr.Message == "" {
+		gerr.Message = fmt.Sprintf("unexpected response status %s", res.Status)
+	}
+	return gerr
+}
+
+// grantLease creates a lease expiring after the given TTL, returning its
+// ID and the TTL in seconds granted by etcd.
+func (c *client) grantLease(ctx context.Context, ttl time.Duration) (int64, int64, error) {
+	var resp leaseGrantResponse
+	if err := c.call(ctx, "lease/grant", &leaseGrantRequest{TTL: jsonInt64(leaseSeconds(ttl))}, &resp); err != nil {
+		return 0, 0, err
+	}
+	return int64(resp.ID), int64(resp.TTL), nil
+}
+
+// keepAliveLease refreshes a lease, returning the TTL in seconds it was
+// refreshed to, or errLeaseNotFound if it already expired.
+func (c *client) keepAliveLease(ctx context.Context, id int64) (int64, error) {
+	// the gateway streams the responses of the keepalive, of which only
+	// the first one is read
+	var msg leaseKeepAliveStreamMessage
+	if err := c.call(ctx, "lease/keepalive", &leaseKeepAliveRequest{ID: jsonInt64(id)}, &msg); err != nil {
+		return 0, err
+	}
+	if msg.Error != nil {
+		if msg.Error.Code == codeNotFound {
+			return 0, errLeaseNotFound
+		}
+		return 0, fmt.Errorf("etcdv3: %s", msg.Error.Message)
+	}
+	if msg.Result == nil || msg.Result.TTL <= 0 {
+		return 0, errLeaseNotFound
+	}
+	return int64(msg.Result.TTL), nil
+}
+
+// revokeLease removes a lease along with the keys attached to it.
+func (c *client) revokeLease(ctx context.Context, id int64) error {
+	var resp leaseRevokeResponse
+	return c.call(ctx, "lease/revoke", &leaseRevokeRequest{ID: jsonInt64(id)}, &resp)
+}
+
+// leaseTTL returns the remaining TTL of a lease in seconds.
+func (c *client) leaseTTL(ctx context.Context, id int64) (int64, error) {
+	var resp leaseTimeToLiveResponse
+	if err := c.call(ctx, "lease/timetolive", &leaseTimeToLiveRequest{ID: jsonInt64(id)}, &resp); err != nil {
+		return 0, err
+	}
+	return int64(resp.TTL), nil
+}
+
+// leaseSeconds rounds a TTL up to the whole seconds of the TTL of a lease.
+func leaseSeconds(ttl time.Duration) int64 {
+	return int64((ttl + time.Second - 1) / time.Second)
+}
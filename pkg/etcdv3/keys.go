@@ -0,0 +1,377 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdv3
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+
+	"github.com/nickswift/fleet/pkg/keytree"
+)
+
+// NewKeysAPI returns a KeysAPI storing keys through the v3 API of the etcd
+// cluster described by cfg.
+func NewKeysAPI(cfg etcd.Config) (etcd.KeysAPI, error) {
+	c, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &keysAPI{c: c, leases: make(map[string]keyLease)}, nil
+}
+
+type keysAPI struct {
+	c *client
+
+	// leases are the leases of the keys set with a TTL, which are
+	// refreshed when the keys are set again rather than replaced
+	mu     sync.Mutex
+	leases map[string]keyLease
+}
+
+// keyLease is the lease a key is attached to.
+type keyLease struct {
+	id int64
+	// secs is the TTL in seconds the lease was requested with, and ttl
+	// the one etcd granted
+	secs int64
+	ttl  int64
+}
+
+func (k *keysAPI) Get(ctx context.Context, key string, opts *etcd.GetOptions) (*etcd.Response, error) {
+	key = keytree.Clean(key)
+	dir := keytree.DirPrefix(key)
+	req := &rangeRequest{
+		Key:      []byte(key),
+		RangeEnd: prefixEnd([]byte(dir)),
+	}
+	var resp rangeResponse
+	if err := k.c.call(ctx, "kv/range", req, &resp); err != nil {
+		return nil, err
+	}
+
+	rev := uint64(resp.Header.Revision)
+	node, lease := buildNode(key, resp.Kvs, opts != nil && opts.Recursive)
+	if node == nil {
+		return nil, keytree.KeyNotFound(key, rev)
+	}
+
+	if lease != 0 {
+		ttl, err := k.c.leaseTTL(ctx, lease)
+		if err != nil {
+			return nil, err
+		}
+		setTTL(node, ttl)
+	}
+
+	return &etcd.Response{Action: "get", Node: node, Index: rev}, nil
+}
+
+func (k *keysAPI) Set(ctx context.Context, key, value string, opts *etcd.SetOptions) (*etcd.Response, error) {
+	if opts == nil {
+		opts = &etcd.SetOptions{}
+	}
+	if opts.Dir || opts.Refresh {
+		return nil, errors.New("etcdv3: directories and refreshing TTLs are not supported")
+	}
+	key = keytree.Clean(key)
+
+	var lease keyLease
+	var granted bool
+	if opts.TTL > 0 {
+		var err error
+		if lease, granted, err = k.keyLease(ctx, key, opts.TTL); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := k.put(ctx, key, value, lease.id, opts)
+	if err == errLeaseNotFound && !granted {
+		// the lease of the key expired, or another client replaced it
+		k.forgetLeases(key)
+		if lease, granted, err = k.keyLease(ctx, key, opts.TTL); err != nil {
+			return nil, err
+		}
+		resp, err = k.put(ctx, key, value, lease.id, opts)
+	}
+	if err != nil {
+		if granted {
+			// the lease would expire on its own, but there is no need
+			// to wait for that
+			k.c.revokeLease(ctx, lease.id)
+		}
+		return nil, err
+	}
+
+	var ttl int64
+	if granted {
+		ttl = lease.ttl
+		k.mu.Lock()
+		k.leases[key] = lease
+		k.mu.Unlock()
+	} else if lease.id != 0 {
+		// putting the key does not refresh the lease it is attached to
+		if ttl, err = k.c.keepAliveLease(ctx, lease.id); err != nil {
+			k.forgetLeases(key)
+			return nil, err
+		}
+	}
+
+	rev := uint64(resp.Header.Revision)
+	node := &etcd.Node{
+		Key:           key,
+		Value:         value,
+		CreatedIndex:  rev,
+		ModifiedIndex: rev,
+	}
+	var prev *etcd.Node
+	if len(resp.Responses) > 0 && resp.Responses[0].ResponsePut != nil && resp.Responses[0].ResponsePut.PrevKv != nil {
+		prevKv := resp.Responses[0].ResponsePut.PrevKv
+		prev = leafNode(prevKv)
+		node.CreatedIndex = prev.CreatedIndex
+
+		// each lease is attached to a single key, so a lease the key
+		// is no longer attached to is not needed anymore
+		if prevKv.Lease != 0 && int64(prevKv.Lease) != lease.id {
+			k.c.revokeLease(ctx, int64(prevKv.Lease))
+		}
+	}
+	if lease.id == 0 {
+		k.forgetLeases(key)
+	} else {
+		setTTL(node, ttl)
+	}
+
+	action := "set"
+	switch {
+	case opts.PrevExist == etcd.PrevNoExist:
+		action = "create"
+	case opts.PrevIndex != 0 || opts.PrevValue != "":
+		action = "compareAndSwap"
+	case opts.PrevExist == etcd.PrevExist:
+		action = "update"
+	}
+
+	return &etcd.Response{Action: action, Node: node, PrevNode: prev, Index: rev}, nil
+}
+
+// keyLease returns the lease to attach the key to for the given TTL: the
+// lease it was last set with if it was granted for the same TTL, or a new
+// one, in which case granted is set.
+func (k *keysAPI) keyLease(ctx context.Context, key string, ttl time.Duration) (lease keyLease, granted bool, err error) {
+	secs := leaseSeconds(ttl)
+	k.mu.Lock()
+	lease, ok := k.leases[key]
+	k.mu.Unlock()
+	if ok && lease.secs == secs {
+		return lease, false, nil
+	}
+
+	id, grantedTTL, err := k.c.grantLease(ctx, ttl)
+	if err != nil {
+		return keyLease{}, false, err
+	}
+	return keyLease{id: id, secs: secs, ttl: grantedTTL}, true, nil
+}
+
+// forgetLeases drops the leases of the keys within the tree of the given key.
+func (k *keysAPI) forgetLeases(key string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for kk := range k.leases {
+		if keytree.InTree(key, kk) {
+			delete(k.leases, kk)
+		}
+	}
+}
+
+// put sets the key to the value, attached to the given lease unless it is
+// zero, if the conditions of opts hold.
+func (k *keysAPI) put(ctx context.Context, key, value string, lease int64, opts *etcd.SetOptions) (*txnResponse, error) {
+	txn := &txnRequest{
+		Compare: compares(key, opts.PrevExist, opts.PrevIndex, opts.PrevValue),
+		Success: []requestOp{{RequestPut: &putRequest{
+			Key:    []byte(key),
+			Value:  []byte(value),
+			Lease:  jsonInt64(lease),
+			PrevKv: true,
+		}}},
+		Failure: []requestOp{{RequestRange: &rangeRequest{Key: []byte(key)}}},
+	}
+	var resp txnResponse
+	if err := k.c.call(ctx, "kv/txn", txn, &resp); err != nil {
+		return nil, err
+	}
+	if !resp.Succeeded {
+		return nil, compareFailed(key, opts.PrevExist, &resp)
+	}
+	return &resp, nil
+}
+
+func (k *keysAPI) Delete(ctx context.Context, key string, opts *etcd.DeleteOptions) (*etcd.Response, error) {
+	if opts == nil {
+		opts = &etcd.DeleteOptions{}
+	}
+	key = keytree.Clean(key)
+
+	success := []requestOp{{RequestDeleteRange: &deleteRangeRequest{Key: []byte(key), PrevKv: true}}}
+	if opts.Recursive {
+		dir := []byte(keytree.DirPrefix(key))
+		success = append(success, requestOp{RequestDeleteRange: &deleteRangeRequest{Key: dir, RangeEnd: prefixEnd(dir)}})
+	}
+	txn := &txnRequest{
+		Compare: compares(key, etcd.PrevIgnore, opts.PrevIndex, opts.PrevValue),
+		Success: success,
+		Failure: []requestOp{{RequestRange: &rangeRequest{Key: []byte(key)}}},
+	}
+	var resp txnResponse
+	if err := k.c.call(ctx, "kv/txn", txn, &resp); err != nil {
+		return nil, err
+	}
+	if !resp.Succeeded {
+		return nil, compareFailed(key, etcd.PrevIgnore, &resp)
+	}
+	k.forgetLeases(key)
+
+	rev := uint64(resp.Header.Revision)
+	var deleted int64
+	var prev *etcd.Node
+	for _, op := range resp.Responses {
+		if op.ResponseDeleteRange == nil {
+			continue
+		}
+		deleted += int64(op.ResponseDeleteRange.Deleted)
+		if prev == nil && len(op.ResponseDeleteRange.PrevKvs) > 0 {
+			prev = leafNode(op.ResponseDeleteRange.PrevKvs[0])
+		}
+	}
+	if deleted == 0 {
+		return nil, keytree.KeyNotFound(key, rev)
+	}
+
+	action := "delete"
+	if opts.PrevIndex != 0 || opts.PrevValue != "" {
+		action = "compareAndDelete"
+	}
+	node := &etcd.Node{Key: key, Dir: prev == nil, ModifiedIndex: rev}
+	return &etcd.Response{Action: action, Node: node, PrevNode: prev, Index: rev}, nil
+}
+
+func (k *keysAPI) Create(ctx context.Context, key, value string) (*etcd.Response, error) {
+	return k.Set(ctx, key, value, &etcd.SetOptions{PrevExist: etcd.PrevNoExist})
+}
+
+func (k *keysAPI) CreateInOrder(ctx context.Context, dir, value string, opts *etcd.CreateInOrderOptions) (*etcd.Response, error) {
+	return nil, errors.New("etcdv3: creating keys in order is not supported")
+}
+
+func (k *keysAPI) Update(ctx context.Context, key, value string) (*etcd.Response, error) {
+	return k.Set(ctx, key, value, &etcd.SetOptions{PrevExist: etcd.PrevExist})
+}
+
+func (k *keysAPI) Watcher(key string, opts *etcd.WatcherOptions) etcd.Watcher {
+	w := &watcher{c: k.c, key: keytree.Clean(key)}
+	if opts != nil {
+		w.recursive = opts.Recursive
+		if opts.AfterIndex > 0 {
+			w.next = int64(opts.AfterIndex) + 1
+		}
+	}
+	return w
+}
+
+// prefixEnd returns the end of the range of all keys with the given prefix.
+func prefixEnd(prefix []byte) []byte {
+	end := make([]byte, len(prefix))
+	copy(end, prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// the prefix consists of 0xff bytes only, so range over all keys
+	return []byte{0}
+}
+
+func leafNode(kv *keyValue) *etcd.Node {
+	return &etcd.Node{
+		Key:           string(kv.Key),
+		Value:         string(kv.Value),
+		CreatedIndex:  uint64(kv.CreateRevision),
+		ModifiedIndex: uint64(kv.ModRevision),
+	}
+}
+
+func setTTL(node *etcd.Node, ttl int64) {
+	node.TTL = ttl
+	exp := time.Now().Add(time.Duration(ttl) * time.Second)
+	node.Expiration = &exp
+}
+
+// buildNode returns the Node of the given key from the key-values of its
+// tree, or nil if the tree is empty. If the key refers to a single
+// key-value, the ID of its lease is returned as well. Unless recursive is
+// set, the returned directory only includes its immediate children.
+func buildNode(key string, kvs []*keyValue, recursive bool) (*etcd.Node, int64) {
+	leaves := make([]*etcd.Node, 0, len(kvs))
+	for _, kv := range kvs {
+		if string(kv.Key) == key {
+			return leafNode(kv), int64(kv.Lease)
+		}
+		leaves = append(leaves, leafNode(kv))
+	}
+	return keytree.Build(key, leaves, recursive), 0
+}
+
+// compares returns the conditions of a transaction modifying the key,
+// mirroring the preconditions of the v2 API.
+func compares(key string, prevExist etcd.PrevExistType, prevIndex uint64, prevValue string) []compare {
+	var cmps []compare
+	zero := jsonInt64(0)
+	switch prevExist {
+	case etcd.PrevNoExist:
+		cmps = append(cmps, compare{Key: []byte(key), Target: "CREATE", Result: "EQUAL", CreateRevision: &zero})
+	case etcd.PrevExist:
+		cmps = append(cmps, compare{Key: []byte(key), Target: "CREATE", Result: "GREATER", CreateRevision: &zero})
+	}
+	if prevIndex != 0 {
+		idx := jsonInt64(prevIndex)
+		cmps = append(cmps, compare{Key: []byte(key), Target: "MOD", Result: "EQUAL", ModRevision: &idx})
+	}
+	if prevValue != "" {
+		cmps = append(cmps, compare{Key: []byte(key), Target: "VALUE", Result: "EQUAL", Value: []byte(prevValue)})
+	}
+	return cmps
+}
+
+// compareFailed returns the error of the v2 API corresponding to the failed
+// transaction, whose failure branch fetched the key.
+func compareFailed(key string, prevExist etcd.PrevExistType, resp *txnResponse) error {
+	exists := len(resp.Responses) > 0 && resp.Responses[0].ResponseRange != nil && len(resp.Responses[0].ResponseRange.Kvs) > 0
+	rev := uint64(resp.Header.Revision)
+
+	switch {
+	case exists && prevExist == etcd.PrevNoExist:
+		return keytree.NodeExist(key, rev)
+	case !exists:
+		return keytree.KeyNotFound(key, rev)
+	default:
+		return keytree.TestFailed(key, rev)
+	}
+}
@@ -0,0 +1,605 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdv3
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// fakeGateway serves the subset of the JSON gateway of the v3 API used by
+// this package from memory.
+type fakeGateway struct {
+	sync.Mutex
+	rev       int64
+	kvs       map[string]*keyValue
+	leases    map[int64]int64
+	granted   int64
+	revoked   []int64
+	keptAlive []int64
+
+	// token is required in the Authorization header if not empty
+	token string
+	// watches records the watch requests, which are answered with the
+	// messages of the next of watchStreams; the stream is then kept open
+	// until the request is canceled, unless its messages end with an
+	// empty one
+	watches      []watchCreateRequest
+	watchStreams [][]string
+}
+
+func newFakeGateway() *fakeGateway {
+	return &fakeGateway{
+		rev:    1,
+		kvs:    make(map[string]*keyValue),
+		leases: make(map[int64]int64),
+	}
+}
+
+func (g *fakeGateway) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/v3/watch" {
+		g.serveWatch(rw, req)
+		return
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	if req.URL.Path == "/v3/auth/authenticate" {
+		var ar authenticateRequest
+		json.NewDecoder(req.Body).Decode(&ar)
+		if ar.Name != "fleet" || ar.Password != "secret" {
+			rw.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(rw, `{"error":"authentication failed","code":3,"message":"authentication failed"}`)
+			return
+		}
+		json.NewEncoder(rw).Encode(&authenticateResponse{Token: g.token})
+		return
+	}
+	if g.token != "" && req.Header.Get("Authorization") != g.token {
+		rw.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(rw, `{"error":"invalid auth token","code":16,"message":"invalid auth token"}`)
+		return
+	}
+
+	var resp interface{}
+	switch req.URL.Path {
+	case "/v3/kv/range":
+		var rr rangeRequest
+		json.NewDecoder(req.Body).Decode(&rr)
+		resp = g.rangeKeys(&rr)
+	case "/v3/kv/txn":
+		var tr txnRequest
+		json.NewDecoder(req.Body).Decode(&tr)
+		for _, op := range tr.Success {
+			if op.RequestPut != nil && op.RequestPut.Lease != 0 && g.leases[int64(op.RequestPut.Lease)] == 0 {
+				rw.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(rw, `{"error":"etcdserver: requested lease not found","code":5,"message":"etcdserver: requested lease not found"}`)
+				return
+			}
+		}
+		resp = g.txn(&tr)
+	case "/v3/lease/grant":
+		var lr leaseGrantRequest
+		json.NewDecoder(req.Body).Decode(&lr)
+		g.granted++
+		id := g.granted + 100
+		g.leases[id] = int64(lr.TTL)
+		resp = &leaseGrantResponse{ID: jsonInt64(id), TTL: lr.TTL}
+	case "/v3/lease/revoke":
+		var lr leaseRevokeRequest
+		json.NewDecoder(req.Body).Decode(&lr)
+		g.revoked = append(g.revoked, int64(lr.ID))
+		g.expire(int64(lr.ID))
+		resp = &leaseRevokeResponse{}
+	case "/v3/lease/keepalive":
+		var lr leaseKeepAliveRequest
+		json.NewDecoder(req.Body).Decode(&lr)
+		g.keptAlive = append(g.keptAlive, int64(lr.ID))
+		resp = &leaseKeepAliveStreamMessage{Result: &leaseKeepAliveResponse{ID: lr.ID, TTL: jsonInt64(g.leases[int64(lr.ID)])}}
+	case "/v3/lease/timetolive":
+		var lr leaseTimeToLiveRequest
+		json.NewDecoder(req.Body).Decode(&lr)
+		resp = &leaseTimeToLiveResponse{ID: lr.ID, TTL: jsonInt64(g.leases[int64(lr.ID)])}
+	default:
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(rw).Encode(resp)
+}
+
+func (g *fakeGateway) serveWatch(rw http.ResponseWriter, req *http.Request) {
+	var wr watchRequest
+	json.NewDecoder(req.Body).Decode(&wr)
+	g.Lock()
+	g.watches = append(g.watches, *wr.CreateRequest)
+	var msgs []string
+	if len(g.watchStreams) > 0 {
+		msgs = g.watchStreams[0]
+		g.watchStreams = g.watchStreams[1:]
+	}
+	g.Unlock()
+
+	for _, msg := range msgs {
+		if msg == "" {
+			return
+		}
+		fmt.Fprintln(rw, msg)
+	}
+	rw.(http.Flusher).Flush()
+
+	<-req.Context().Done()
+}
+
+// expire removes a lease along with the keys attached to it.
+func (g *fakeGateway) expire(id int64) {
+	delete(g.leases, id)
+	for k, kv := range g.kvs {
+		if int64(kv.Lease) == id {
+			delete(g.kvs, k)
+		}
+	}
+}
+
+func (g *fakeGateway) header() responseHeader {
+	return responseHeader{Revision: jsonInt64(g.rev)}
+}
+
+func (g *fakeGateway) inRange(k string, key, end []byte) bool {
+	switch {
+	case len(end) == 0:
+		return k == string(key)
+	case bytes.Equal(end, []byte{0}):
+		return k >= string(key)
+	default:
+		return k >= string(key) && k < string(end)
+	}
+}
+
+func (g *fakeGateway) rangeKeys(rr *rangeRequest) *rangeResponse {
+	var keys []string
+	for k := range g.kvs {
+		if g.inRange(k, rr.Key, rr.RangeEnd) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	resp := &rangeResponse{Header: g.header()}
+	for _, k := range keys {
+		resp.Kvs = append(resp.Kvs, g.kvs[k])
+	}
+	return resp
+}
+
+func (g *fakeGateway) txn(tr *txnRequest) *txnResponse {
+	succeeded := true
+	for _, cmp := range tr.Compare {
+		kv := g.kvs[string(cmp.Key)]
+		if kv == nil {
+			kv = &keyValue{}
+		}
+		var ok bool
+		switch {
+		case cmp.Target == "CREATE" && cmp.Result == "EQUAL":
+			ok = kv.CreateRevision == *cmp.CreateRevision
+		case cmp.Target == "CREATE" && cmp.Result == "GREATER":
+			ok = kv.CreateRevision > *cmp.CreateRevision
+		case cmp.Target == "MOD" && cmp.Result == "EQUAL":
+			ok = kv.ModRevision == *cmp.ModRevision
+		case cmp.Target == "VALUE" && cmp.Result == "EQUAL":
+			ok = bytes.Equal(kv.Value, cmp.Value)
+		}
+		succeeded = succeeded && ok
+	}
+
+	ops := tr.Success
+	if !succeeded {
+		ops = tr.Failure
+	}
+
+	resp := &txnResponse{Succeeded: succeeded}
+	rev := g.rev + 1
+	for _, op := range ops {
+		switch {
+		case op.RequestRange != nil:
+			resp.Responses = append(resp.Responses, responseOp{ResponseRange: g.rangeKeys(op.RequestRange)})
+		case op.RequestPut != nil:
+			put := op.RequestPut
+			kv := &keyValue{Key: put.Key, Value: put.Value, CreateRevision: jsonInt64(rev), ModRevision: jsonInt64(rev), Lease: put.Lease}
+			prev := g.kvs[string(put.Key)]
+			if prev != nil {
+				kv.CreateRevision = prev.CreateRevision
+			}
+			g.kvs[string(put.Key)] = kv
+			g.rev = rev
+			resp.Responses = append(resp.Responses, responseOp{ResponsePut: &putResponse{PrevKv: prev}})
+		case op.RequestDeleteRange != nil:
+			dr := &deleteRangeResponse{}
+			for k, kv := range g.kvs {
+				if g.inRange(k, op.RequestDeleteRange.Key, op.RequestDeleteRange.RangeEnd) {
+					delete(g.kvs, k)
+					dr.Deleted++
+					dr.PrevKvs = append(dr.PrevKvs, kv)
+					g.rev = rev
+				}
+			}
+			resp.Responses = append(resp.Responses, responseOp{ResponseDeleteRange: dr})
+		}
+	}
+	resp.Header = g.header()
+	return resp
+}
+
+func newTestKeysAPI(t *testing.T, g *fakeGateway, cfg etcd.Config) (etcd.KeysAPI, func()) {
+	srv := httptest.NewServer(g)
+	cfg.Endpoints = append(cfg.Endpoints, srv.URL)
+	kAPI, err := NewKeysAPI(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error creating KeysAPI: %v", err)
+	}
+	return kAPI, func() {
+		// end the streams of watches still open
+		srv.CloseClientConnections()
+		srv.Close()
+	}
+}
+
+func errorCode(err error) int {
+	if eerr, ok := err.(etcd.Error); ok {
+		return eerr.Code
+	}
+	return 0
+}
+
+func TestPrefixEnd(t *testing.T) {
+	tests := []struct {
+		prefix []byte
+		want   []byte
+	}{
+		{[]byte("/fleet/"), []byte("/fleet0")},
+		{[]byte("a"), []byte("b")},
+		{[]byte{'a', 0xff}, []byte("b")},
+		{[]byte{0xff, 0xff}, []byte{0}},
+	}
+
+	for i, tt := range tests {
+		if got := prefixEnd(tt.prefix); !bytes.Equal(tt.want, got) {
+			t.Errorf("case %d: expected %q, got %q", i, tt.want, got)
+		}
+	}
+}
+
+func TestKeysAPIGet(t *testing.T) {
+	g := newFakeGateway()
+	kAPI, done := newTestKeysAPI(t, g, etcd.Config{})
+	defer done()
+
+	ctx := context.Background()
+	for _, key := range []string{"/fleet/job/b/object", "/fleet/job/a/target", "/fleet/job/a/object", "/fleet/jobs", "/fleet/job-x"} {
+		if _, err := kAPI.Set(ctx, key, key, nil); err != nil {
+			t.Fatalf("unexpected error setting %s: %v", key, err)
+		}
+	}
+
+	res, err := kAPI.Get(ctx, "/fleet/job/a/object", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Node.Dir || res.Node.Value != "/fleet/job/a/object" {
+		t.Errorf("expected leaf node, got %#v", res.Node)
+	}
+
+	res, err = kAPI.Get(ctx, "/fleet/job/", &etcd.GetOptions{Recursive: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var keys []string
+	for _, dir := range res.Node.Nodes {
+		if !dir.Dir {
+			t.Errorf("expected %s to be a directory", dir.Key)
+		}
+		for _, node := range dir.Nodes {
+			keys = append(keys, node.Key)
+		}
+	}
+	want := []string{"/fleet/job/a/object", "/fleet/job/a/target", "/fleet/job/b/object"}
+	if !reflect.DeepEqual(want, keys) {
+		t.Errorf("expected keys %v, got %v", want, keys)
+	}
+
+	res, err = kAPI.Get(ctx, "/fleet", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keys = nil
+	for _, node := range res.Node.Nodes {
+		keys = append(keys, node.Key)
+		if len(node.Nodes) != 0 {
+			t.Errorf("expected no children of %s without recursion", node.Key)
+		}
+	}
+	want = []string{"/fleet/job", "/fleet/job-x", "/fleet/jobs"}
+	if !reflect.DeepEqual(want, keys) {
+		t.Errorf("expected keys %v, got %v", want, keys)
+	}
+
+	if _, err = kAPI.Get(ctx, "/fleet/job/c", nil); errorCode(err) != etcd.ErrorCodeKeyNotFound {
+		t.Errorf("expected key not found error, got %v", err)
+	}
+}
+
+func TestKeysAPISetConditions(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		opts  *etcd.SetOptions
+		exist bool
+		code  int
+	}{
+		{&etcd.SetOptions{PrevExist: etcd.PrevNoExist}, false, 0},
+		{&etcd.SetOptions{PrevExist: etcd.PrevNoExist}, true, etcd.ErrorCodeNodeExist},
+		{&etcd.SetOptions{PrevExist: etcd.PrevExist}, false, etcd.ErrorCodeKeyNotFound},
+		{&etcd.SetOptions{PrevExist: etcd.PrevExist}, true, 0},
+		{&etcd.SetOptions{PrevIndex: 2}, true, 0},
+		{&etcd.SetOptions{PrevIndex: 3}, true, etcd.ErrorCodeTestFailed},
+		{&etcd.SetOptions{PrevValue: "old"}, true, 0},
+		{&etcd.SetOptions{PrevValue: "other"}, true, etcd.ErrorCodeTestFailed},
+		{&etcd.SetOptions{PrevValue: "old"}, false, etcd.ErrorCodeKeyNotFound},
+	}
+
+	for i, tt := range tests {
+		g := newFakeGateway()
+		kAPI, done := newTestKeysAPI(t, g, etcd.Config{})
+		if tt.exist {
+			kAPI.Set(ctx, "/foo", "old", nil)
+		}
+
+		res, err := kAPI.Set(ctx, "/foo", "new", tt.opts)
+		done()
+		if code := errorCode(err); code != tt.code {
+			t.Errorf("case %d: expected error code %d, got %v", i, tt.code, err)
+			continue
+		}
+		if tt.code != 0 {
+			continue
+		}
+		if res.Node.Value != "new" || res.Node.ModifiedIndex != uint64(g.rev) {
+			t.Errorf("case %d: unexpected node %#v", i, res.Node)
+		}
+		if tt.exist && (res.PrevNode == nil || res.PrevNode.Value != "old") {
+			t.Errorf("case %d: unexpected previous node %#v", i, res.PrevNode)
+		}
+	}
+}
+
+func TestKeysAPISetTTL(t *testing.T) {
+	g := newFakeGateway()
+	kAPI, done := newTestKeysAPI(t, g, etcd.Config{})
+	defer done()
+
+	ctx := context.Background()
+	res, err := kAPI.Set(ctx, "/lease/engine", "XXX", &etcd.SetOptions{PrevExist: etcd.PrevNoExist, TTL: 1500 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Node.TTLDuration() != 2*time.Second {
+		t.Errorf("expected TTL of 2s, got %v", res.Node.TTLDuration())
+	}
+	if lease := g.kvs["/lease/engine"].Lease; lease == 0 || g.leases[int64(lease)] != 2 {
+		t.Errorf("expected key attached to lease of 2s, got lease %d", lease)
+	}
+
+	res, err = kAPI.Get(ctx, "/lease/engine", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Node.TTL != 2 {
+		t.Errorf("expected TTL of 2, got %d", res.Node.TTL)
+	}
+
+	// the lease granted for a failed Set is revoked right away
+	if _, err = kAPI.Set(ctx, "/lease/engine", "YYY", &etcd.SetOptions{PrevExist: etcd.PrevNoExist, TTL: time.Second}); errorCode(err) != etcd.ErrorCodeNodeExist {
+		t.Fatalf("expected node exists error, got %v", err)
+	}
+	if len(g.revoked) != 1 {
+		t.Errorf("expected one lease to be revoked, got %v", g.revoked)
+	}
+
+	// setting the key again refreshes its lease rather than replacing it
+	lease := g.kvs["/lease/engine"].Lease
+	res, err = kAPI.Set(ctx, "/lease/engine", "XXX", &etcd.SetOptions{PrevIndex: res.Node.ModifiedIndex, TTL: 1500 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if g.kvs["/lease/engine"].Lease != lease || len(g.revoked) != 1 {
+		t.Errorf("expected key to keep lease %d, got lease %d and revoked %v", lease, g.kvs["/lease/engine"].Lease, g.revoked)
+	}
+	if len(g.keptAlive) != 1 || g.keptAlive[0] != int64(lease) {
+		t.Errorf("expected lease %d to be kept alive, got %v", lease, g.keptAlive)
+	}
+	if res.Node.TTL != 2 {
+		t.Errorf("expected TTL of 2, got %d", res.Node.TTL)
+	}
+
+	// a new lease replaces the one of another TTL
+	if _, err = kAPI.Set(ctx, "/lease/engine", "XXX", &etcd.SetOptions{TTL: 5 * time.Second}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(g.revoked) != 2 || g.revoked[1] != int64(lease) {
+		t.Errorf("expected lease %d to be revoked, got %v", lease, g.revoked)
+	}
+	lease = g.kvs["/lease/engine"].Lease
+	if g.leases[int64(lease)] != 5 {
+		t.Errorf("expected key attached to lease of 5s, got lease %d", lease)
+	}
+
+	// and one which expired
+	g.expire(int64(lease))
+	if _, err = kAPI.Set(ctx, "/lease/engine", "XXX", &etcd.SetOptions{TTL: 5 * time.Second}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if kv := g.kvs["/lease/engine"]; kv == nil || kv.Lease == lease || g.leases[int64(kv.Lease)] != 5 {
+		t.Errorf("expected key attached to a new lease of 5s, got %#v", kv)
+	}
+}
+
+func TestKeysAPIDelete(t *testing.T) {
+	g := newFakeGateway()
+	kAPI, done := newTestKeysAPI(t, g, etcd.Config{})
+	defer done()
+
+	ctx := context.Background()
+	for _, key := range []string{"/job/a/object", "/job/a/target", "/job/ab"} {
+		kAPI.Set(ctx, key, "XXX", nil)
+	}
+
+	if _, err := kAPI.Delete(ctx, "/job/a/target", &etcd.DeleteOptions{PrevValue: "YYY"}); errorCode(err) != etcd.ErrorCodeTestFailed {
+		t.Errorf("expected compare failed error, got %v", err)
+	}
+	res, err := kAPI.Delete(ctx, "/job/a/target", &etcd.DeleteOptions{PrevValue: "XXX"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.PrevNode == nil || res.PrevNode.Key != "/job/a/target" {
+		t.Errorf("unexpected previous node %#v", res.PrevNode)
+	}
+	if _, err = kAPI.Delete(ctx, "/job/a/target", nil); errorCode(err) != etcd.ErrorCodeKeyNotFound {
+		t.Errorf("expected key not found error, got %v", err)
+	}
+
+	if _, err = kAPI.Delete(ctx, "/job/a", &etcd.DeleteOptions{Recursive: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(g.kvs) != 1 || g.kvs["/job/ab"] == nil {
+		t.Errorf("expected only /job/ab to remain, got %v", g.kvs)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	g := newFakeGateway()
+	g.watchStreams = [][]string{
+		{
+			`{"result":{"header":{"revision":"7"},"created":true}}`,
+			`{"result":{"header":{"revision":"9"},"events":[{"kv":{"key":"L2pvYnM=","mod_revision":"8"}},{"kv":{"key":"L2pvYi9hL3RhcmdldA==","value":"WFhY","mod_revision":"9"}}]}}`,
+			`{"result":{"header":{"revision":"9"},"events":[{"type":"DELETE","kv":{"key":"L2pvYi9iL3RhcmdldA==","mod_revision":"9"}}]}}`,
+			"",
+		},
+		{`{"result":{"header":{"revision":"12"},"compact_revision":"11"}}`},
+	}
+	kAPI, done := newTestKeysAPI(t, g, etcd.Config{})
+	defer done()
+
+	w := kAPI.Watcher("/job", &etcd.WatcherOptions{AfterIndex: 4, Recursive: true})
+	ctx := context.Background()
+	res, err := w.Next(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Action != "set" || res.Node.Key != "/job/a/target" || res.Node.Value != "XXX" {
+		t.Errorf("unexpected first response %#v", res)
+	}
+	res, err = w.Next(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Action != "delete" || res.Node.Key != "/job/b/target" {
+		t.Errorf("unexpected second response %#v", res)
+	}
+
+	// the stream ends, and is opened again after the last Event
+	if _, err = w.Next(ctx); err == nil {
+		t.Errorf("expected error of closed stream")
+	}
+	if _, err = w.Next(ctx); errorCode(err) != etcd.ErrorCodeEventIndexCleared {
+		t.Errorf("expected event index cleared error, got %v", err)
+	}
+
+	if len(g.watches) != 2 {
+		t.Fatalf("expected 2 watches, got %d", len(g.watches))
+	}
+	if g.watches[0].StartRevision != 5 || g.watches[1].StartRevision != 10 {
+		t.Errorf("expected watches to start at revisions 5 and 10, got %d and %d", g.watches[0].StartRevision, g.watches[1].StartRevision)
+	}
+	if string(g.watches[0].Key) != "/job" || string(g.watches[0].RangeEnd) != "/job0" {
+		t.Errorf("unexpected range of watch [%s, %s)", g.watches[0].Key, g.watches[0].RangeEnd)
+	}
+}
+
+func TestWatcherKeepsStream(t *testing.T) {
+	g := newFakeGateway()
+	g.watchStreams = [][]string{
+		{`{"result":{"header":{"revision":"7"},"created":true}}`},
+	}
+	kAPI, done := newTestKeysAPI(t, g, etcd.Config{})
+	defer done()
+
+	w := kAPI.Watcher("/job", nil)
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		_, err := w.Next(ctx)
+		cancel()
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected deadline exceeded error, got %v", err)
+		}
+	}
+
+	g.Lock()
+	defer g.Unlock()
+	if len(g.watches) != 1 {
+		t.Errorf("expected the stream to be kept open across calls, got %d watches", len(g.watches))
+	}
+	// the stream would be opened again after the revision it started at
+	if next := w.(*watcher).next; next != 8 {
+		t.Errorf("expected watch to resume at revision 8, got %d", next)
+	}
+}
+
+func TestClientFailoverAndAuth(t *testing.T) {
+	g := newFakeGateway()
+	g.token = "sometoken"
+	down := httptest.NewServer(g)
+	down.Close()
+
+	kAPI, done := newTestKeysAPI(t, g, etcd.Config{Endpoints: []string{down.URL}, Username: "fleet", Password: "secret"})
+	defer done()
+
+	ctx := context.Background()
+	if _, err := kAPI.Set(ctx, "/foo", "bar", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// an expired token is replaced
+	g.token = "othertoken"
+	if _, err := kAPI.Get(ctx, "/foo", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	kAPI, done = newTestKeysAPI(t, g, etcd.Config{Username: "fleet", Password: "wrong"})
+	defer done()
+	if _, err := kAPI.Get(ctx, "/foo", nil); err == nil {
+		t.Errorf("expected authentication error")
+	}
+}
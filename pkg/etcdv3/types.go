@@ -0,0 +1,185 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdv3
+
+// The messages of the v3 API used by this package, as encoded by the JSON
+// gateway of etcd. Only the fields which are needed are declared.
+
+type responseHeader struct {
+	Revision jsonInt64 `json:"revision"`
+}
+
+type keyValue struct {
+	Key            []byte    `json:"key"`
+	Value          []byte    `json:"value"`
+	CreateRevision jsonInt64 `json:"create_revision"`
+	ModRevision    jsonInt64 `json:"mod_revision"`
+	Lease          jsonInt64 `json:"lease"`
+}
+
+type rangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+}
+
+type rangeResponse struct {
+	Header responseHeader `json:"header"`
+	Kvs    []*keyValue    `json:"kvs"`
+}
+
+type putRequest struct {
+	Key    []byte    `json:"key"`
+	Value  []byte    `json:"value"`
+	Lease  jsonInt64 `json:"lease,omitempty"`
+	PrevKv bool      `json:"prev_kv,omitempty"`
+}
+
+type putResponse struct {
+	Header responseHeader `json:"header"`
+	PrevKv *keyValue      `json:"prev_kv"`
+}
+
+type deleteRangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+	PrevKv   bool   `json:"prev_kv,omitempty"`
+}
+
+type deleteRangeResponse struct {
+	Header  responseHeader `json:"header"`
+	Deleted jsonInt64      `json:"deleted"`
+	PrevKvs []*keyValue    `json:"prev_kvs"`
+}
+
+// compare is a condition of a transaction; exactly one of CreateRevision,
+// ModRevision and Value must be set, according to Target.
+type compare struct {
+	Key            []byte     `json:"key"`
+	Target         string     `json:"target"`
+	Result         string     `json:"result"`
+	CreateRevision *jsonInt64 `json:"create_revision,omitempty"`
+	ModRevision    *jsonInt64 `json:"mod_revision,omitempty"`
+	Value          []byte     `json:"value,omitempty"`
+}
+
+type requestOp struct {
+	RequestRange       *rangeRequest       `json:"request_range,omitempty"`
+	RequestPut         *putRequest         `json:"request_put,omitempty"`
+	RequestDeleteRange *deleteRangeRequest `json:"request_delete_range,omitempty"`
+}
+
+type responseOp struct {
+	ResponseRange       *rangeResponse       `json:"response_range"`
+	ResponsePut         *putResponse         `json:"response_put"`
+	ResponseDeleteRange *deleteRangeResponse `json:"response_delete_range"`
+}
+
+type txnRequest struct {
+	Compare []compare   `json:"compare,omitempty"`
+	Success []requestOp `json:"success,omitempty"`
+	Failure []requestOp `json:"failure,omitempty"`
+}
+
+type txnResponse struct {
+	Header    responseHeader `json:"header"`
+	Succeeded bool           `json:"succeeded"`
+	Responses []responseOp   `json:"responses"`
+}
+
+type leaseGrantRequest struct {
+	TTL jsonInt64 `json:"TTL"`
+}
+
+type leaseGrantResponse struct {
+	ID  jsonInt64 `json:"ID"`
+	TTL jsonInt64 `json:"TTL"`
+}
+
+type leaseRevokeRequest struct {
+	ID jsonInt64 `json:"ID"`
+}
+
+type leaseRevokeResponse struct {
+	Header responseHeader `json:"header"`
+}
+
+type leaseKeepAliveRequest struct {
+	ID jsonInt64 `json:"ID"`
+}
+
+type leaseKeepAliveResponse struct {
+	Header responseHeader `json:"header"`
+	ID     jsonInt64      `json:"ID"`
+	TTL    jsonInt64      `json:"TTL"`
+}
+
+// leaseKeepAliveStreamMessage is a single message of the stream of
+// LeaseKeepAliveResponses returned by the JSON gateway.
+type leaseKeepAliveStreamMessage struct {
+	Result *leaseKeepAliveResponse `json:"result"`
+	Error  *gatewayError           `json:"error"`
+}
+
+type leaseTimeToLiveRequest struct {
+	ID jsonInt64 `json:"ID"`
+}
+
+type leaseTimeToLiveResponse struct {
+	ID  jsonInt64 `json:"ID"`
+	TTL jsonInt64 `json:"TTL"`
+}
+
+type authenticateRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type authenticateResponse struct {
+	Token string `json:"token"`
+}
+
+type watchCreateRequest struct {
+	Key           []byte    `json:"key"`
+	RangeEnd      []byte    `json:"range_end,omitempty"`
+	StartRevision jsonInt64 `json:"start_revision,omitempty"`
+	PrevKv        bool      `json:"prev_kv,omitempty"`
+}
+
+type watchRequest struct {
+	CreateRequest *watchCreateRequest `json:"create_request"`
+}
+
+type watchEvent struct {
+	// Type is empty for PUT events, the default of the enum
+	Type   string    `json:"type"`
+	Kv     *keyValue `json:"kv"`
+	PrevKv *keyValue `json:"prev_kv"`
+}
+
+type watchResponse struct {
+	Header          responseHeader `json:"header"`
+	Created         bool           `json:"created"`
+	Canceled        bool           `json:"canceled"`
+	CancelReason    string         `json:"cancel_reason"`
+	CompactRevision jsonInt64      `json:"compact_revision"`
+	Events          []watchEvent   `json:"events"`
+}
+
+// watchStreamMessage is a single message of the stream of WatchResponses
+// returned by the JSON gateway.
+type watchStreamMessage struct {
+	Result *watchResponse `json:"result"`
+	Error  *gatewayError  `json:"error"`
+}
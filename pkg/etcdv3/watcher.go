@@ -0,0 +1,222 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdv3
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+
+	"github.com/nickswift/fleet/pkg/keytree"
+)
+
+// streamIdleTimeout is how long the stream of a watcher is kept open while
+// Next is not called, so that abandoned watchers do not hold on to it.
+const streamIdleTimeout = time.Minute
+
+// watcher implements the etcd.Watcher interface with watches of the v3 API.
+// The stream of the watch stays open between calls to Next; once it is
+// closed, it is opened again at the revision following the last Event
+// received.
+type watcher struct {
+	c         *client
+	key       string
+	recursive bool
+
+	// next is the revision to start watching at, or zero to only watch
+	// for future changes
+	next int64
+	// pending are the Events received but not yet returned by Next
+	pending []*etcd.Response
+
+	// results are the messages of the open stream, if any, which is
+	// closed by cancel
+	results chan watchResult
+	cancel  context.CancelFunc
+	// idle closes the stream unless Next is called in time
+	idle *time.Timer
+}
+
+// watchResult is a message received from the stream of a watch.
+type watchResult struct {
+	wr  *watchResponse
+	err error
+}
+
+func (w *watcher) Next(ctx context.Context) (*etcd.Response, error) {
+	if w.idle != nil {
+		w.idle.Stop()
+	}
+	defer func() {
+		if w.idle != nil {
+			w.idle.Reset(streamIdleTimeout)
+		}
+	}()
+
+	for len(w.pending) == 0 {
+		if w.results == nil {
+			w.open()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case r, ok := <-w.results:
+			if !ok {
+				// the stream was closed while idle
+				w.close()
+				continue
+			}
+			if err := w.receive(r); err != nil {
+				w.close()
+				return nil, err
+			}
+		}
+	}
+
+	res := w.pending[0]
+	w.pending = w.pending[1:]
+	return res, nil
+}
+
+// open opens the stream of the watch of the key or tree, starting at the
+// next revision.
+func (w *watcher) open() {
+	req := &watchCreateRequest{
+		Key:           []byte(w.key),
+		StartRevision: jsonInt64(w.next),
+		PrevKv:        true,
+	}
+	if w.recursive {
+		req.RangeEnd = prefixEnd([]byte(keytree.DirPrefix(w.key)))
+	}
+
+	// the stream outlives the context of the call to Next opening it
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan watchResult)
+	go stream(ctx, w.c, req, results)
+
+	w.results = results
+	w.cancel = cancel
+	w.idle = time.AfterFunc(streamIdleTimeout, cancel)
+	w.idle.Stop()
+}
+
+// close closes the stream of the watch, if any.
+func (w *watcher) close() {
+	if w.cancel != nil {
+		w.cancel()
+		w.idle.Stop()
+	}
+	w.results = nil
+	w.cancel = nil
+	w.idle = nil
+}
+
+// receive adds the Events of a message of the stream to the pending ones.
+func (w *watcher) receive(r watchResult) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	wr := r.wr
+	if wr.Created && w.next == 0 {
+		// resume at the revision the watch started at if the stream
+		// is closed before any Event is received
+		w.next = int64(wr.Header.Revision) + 1
+	}
+	if wr.CompactRevision != 0 {
+		return etcd.Error{
+			Code:    etcd.ErrorCodeEventIndexCleared,
+			Message: "The event in requested index is outdated and cleared",
+			Cause:   fmt.Sprintf("the requested history has been cleared [%d/%d]", wr.CompactRevision, w.next),
+			Index:   uint64(wr.Header.Revision),
+		}
+	}
+	if wr.Canceled {
+		return fmt.Errorf("etcdv3: watch of %s canceled: %s", w.key, wr.CancelReason)
+	}
+
+	for _, ev := range wr.Events {
+		if ev.Kv == nil {
+			continue
+		}
+		w.next = int64(ev.Kv.ModRevision) + 1
+		if !keytree.InTree(w.key, string(ev.Kv.Key)) {
+			continue
+		}
+		w.pending = append(w.pending, eventResponse(ev, uint64(wr.Header.Revision)))
+	}
+	return nil
+}
+
+// stream sends the messages of the stream of the watch to results until it
+// fails or ctx is canceled, and closes results.
+func stream(ctx context.Context, c *client, req *watchCreateRequest, results chan<- watchResult) {
+	defer close(results)
+	send := func(r watchResult) bool {
+		select {
+		case results <- r:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	body, err := c.stream(ctx, "watch", &watchRequest{CreateRequest: req})
+	if err != nil {
+		if ctx.Err() == nil {
+			send(watchResult{err: err})
+		}
+		return
+	}
+	defer body.Close()
+
+	dec := json.NewDecoder(body)
+	for {
+		var msg watchStreamMessage
+		if err := dec.Decode(&msg); err != nil {
+			if ctx.Err() == nil {
+				send(watchResult{err: err})
+			}
+			return
+		}
+		if msg.Error != nil {
+			send(watchResult{err: fmt.Errorf("etcdv3: %s", msg.Error.Message)})
+			return
+		}
+		if msg.Result != nil && !send(watchResult{wr: msg.Result}) {
+			return
+		}
+	}
+}
+
+func eventResponse(ev watchEvent, rev uint64) *etcd.Response {
+	res := &etcd.Response{
+		Action: "set",
+		Node:   leafNode(ev.Kv),
+		Index:  rev,
+	}
+	if ev.PrevKv != nil {
+		res.PrevNode = leafNode(ev.PrevKv)
+	}
+	if ev.Type == "DELETE" {
+		res.Action = "delete"
+	}
+	return res
+}
@@ -0,0 +1,132 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package keytree lays out flat keys as the directory tree of the v2 API of
+// etcd. It is shared by the KeysAPI implementations which do not store
+// directories themselves.
+package keytree
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	etcd "github.com/coreos/etcd/client"
+)
+
+// Clean returns the key in the canonical form of the v2 API, rooted at "/"
+// without a trailing slash.
+func Clean(key string) string {
+	return path.Join("/", key)
+}
+
+// DirPrefix returns the prefix shared by all keys within the directory of
+// the given key.
+func DirPrefix(key string) string {
+	if key == "/" {
+		return key
+	}
+	return key + "/"
+}
+
+// InTree determines whether k is the given key or within its directory.
+func InTree(key, k string) bool {
+	return k == key || strings.HasPrefix(k, DirPrefix(key))
+}
+
+// Build returns the Node of the directory of the given key holding the
+// given leaves, or nil if none of them lies within it. Unless recursive is
+// set, the directory only includes its immediate children. The
+// ModifiedIndex of each directory is the highest one of the keys within it.
+func Build(key string, leaves []*etcd.Node, recursive bool) *etcd.Node {
+	prefix := DirPrefix(key)
+	var within etcd.Nodes
+	for _, leaf := range leaves {
+		if strings.HasPrefix(leaf.Key, prefix) {
+			within = append(within, leaf)
+		}
+	}
+	if len(within) == 0 {
+		return nil
+	}
+	sort.Sort(within)
+
+	root := &etcd.Node{Key: key, Dir: true}
+	for _, leaf := range within {
+		parts := strings.Split(strings.TrimPrefix(leaf.Key, prefix), "/")
+		insertNode(root, parts, leaf, recursive)
+	}
+	sortNodes(root)
+	return root
+}
+
+// insertNode adds the leaf to the directory at the given path relative to
+// it, creating the directories in between.
+func insertNode(dir *etcd.Node, parts []string, leaf *etcd.Node, recursive bool) {
+	if leaf.ModifiedIndex > dir.ModifiedIndex {
+		dir.ModifiedIndex = leaf.ModifiedIndex
+	}
+	if len(parts) == 1 {
+		dir.Nodes = append(dir.Nodes, leaf)
+		return
+	}
+
+	// leaves of the same directory are adjacent, as they are sorted
+	key := path.Join(dir.Key, parts[0])
+	var child *etcd.Node
+	if n := len(dir.Nodes); n > 0 && dir.Nodes[n-1].Dir && dir.Nodes[n-1].Key == key {
+		child = dir.Nodes[n-1]
+	} else {
+		child = &etcd.Node{Key: key, Dir: true}
+		dir.Nodes = append(dir.Nodes, child)
+	}
+
+	if recursive {
+		insertNode(child, parts[1:], leaf, recursive)
+	} else if leaf.ModifiedIndex > child.ModifiedIndex {
+		child.ModifiedIndex = leaf.ModifiedIndex
+	}
+}
+
+// sortNodes sorts the nodes of each directory by key, as the v2 API does.
+func sortNodes(dir *etcd.Node) {
+	sort.Sort(dir.Nodes)
+	for _, n := range dir.Nodes {
+		if n.Dir {
+			sortNodes(n)
+		}
+	}
+}
+
+// KeyNotFound returns the error of the v2 API for a missing key.
+func KeyNotFound(key string, index uint64) error {
+	return etcd.Error{Code: etcd.ErrorCodeKeyNotFound, Message: "Key not found", Cause: key, Index: index}
+}
+
+// NodeExist returns the error of the v2 API for a key which must not exist.
+func NodeExist(key string, index uint64) error {
+	return etcd.Error{Code: etcd.ErrorCodeNodeExist, Message: "Key already exists", Cause: key, Index: index}
+}
+
+// TestFailed returns the error of the v2 API for a failed comparison of the
+// previous index or value of a key.
+func TestFailed(key string, index uint64) error {
+	return etcd.Error{Code: etcd.ErrorCodeTestFailed, Message: "Compare failed", Cause: key, Index: index}
+}
+
+// NotFile returns the error of the v2 API for a directory which was
+// expected to be a key.
+func NotFile(key string, index uint64) error {
+	return etcd.Error{Code: etcd.ErrorCodeNotFile, Message: "Not a file", Cause: key, Index: index}
+}
@@ -0,0 +1,70 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keytree
+
+import (
+	"reflect"
+	"testing"
+
+	etcd "github.com/coreos/etcd/client"
+)
+
+func TestBuild(t *testing.T) {
+	leaves := []*etcd.Node{
+		{Key: "/fleet/job/b/object", ModifiedIndex: 4},
+		{Key: "/fleet/job/a/target", ModifiedIndex: 2},
+		{Key: "/fleet/job/a/object", ModifiedIndex: 1},
+		{Key: "/fleet/job-x", ModifiedIndex: 5},
+	}
+
+	if node := Build("/fleet/machines", leaves, true); node != nil {
+		t.Errorf("expected no node for empty directory, got %v", node)
+	}
+
+	node := Build("/fleet/job", leaves, false)
+	want := &etcd.Node{Key: "/fleet/job", Dir: true, ModifiedIndex: 4, Nodes: etcd.Nodes{
+		{Key: "/fleet/job/a", Dir: true, ModifiedIndex: 2},
+		{Key: "/fleet/job/b", Dir: true, ModifiedIndex: 4},
+	}}
+	if !reflect.DeepEqual(want, node) {
+		t.Errorf("expected %v, got %v", want, node)
+	}
+
+	node = Build("/fleet/job", leaves, true)
+	want = &etcd.Node{Key: "/fleet/job", Dir: true, ModifiedIndex: 4, Nodes: etcd.Nodes{
+		{Key: "/fleet/job/a", Dir: true, ModifiedIndex: 2, Nodes: etcd.Nodes{leaves[2], leaves[1]}},
+		{Key: "/fleet/job/b", Dir: true, ModifiedIndex: 4, Nodes: etcd.Nodes{leaves[0]}},
+	}}
+	if !reflect.DeepEqual(want, node) {
+		t.Errorf("expected %v, got %v", want, node)
+	}
+}
+
+func TestInTree(t *testing.T) {
+	tests := []struct {
+		key, k string
+		want   bool
+	}{
+		{"/fleet/job", "/fleet/job", true},
+		{"/fleet/job", "/fleet/job/a/object", true},
+		{"/fleet/job", "/fleet/job-x", false},
+		{"/", "/fleet", true},
+	}
+	for _, tt := range tests {
+		if got := InTree(tt.key, tt.k); got != tt.want {
+			t.Errorf("InTree(%q, %q): expected %t, got %t", tt.key, tt.k, tt.want, got)
+		}
+	}
+}
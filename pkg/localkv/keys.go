@@ -0,0 +1,451 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package localkv implements the KeysAPI of the etcd v2 client in memory,
+// persisting the keys to a local file. It allows a single fleetd to run
+// without an etcd cluster.
+package localkv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+
+	"github.com/nickswift/fleet/pkg/keytree"
+)
+
+const (
+	// maxEvents bounds the history of Events kept for watchers
+	maxEvents = 1000
+)
+
+// NewKeysAPI returns a KeysAPI storing keys in the file at the given path,
+// which is created if it does not exist. The keys are laid out as with the
+// v2 API of etcd. Every change rewrites the whole file, so it suits small
+// registries only. The file must not be used by several processes at once.
+func NewKeysAPI(file string) (etcd.KeysAPI, error) {
+	return openStore(file)
+}
+
+// entry is a key holding a value; directories are implied by the keys
+// within them.
+type entry struct {
+	Value         string     `json:"value"`
+	CreatedIndex  uint64     `json:"createdIndex"`
+	ModifiedIndex uint64     `json:"modifiedIndex"`
+	Expiration    *time.Time `json:"expiration,omitempty"`
+}
+
+// database is the content of the file of a store.
+type database struct {
+	Index uint64            `json:"index"`
+	Keys  map[string]*entry `json:"keys"`
+}
+
+type store struct {
+	mu    sync.Mutex
+	file  string
+	index uint64
+	keys  map[string]*entry
+
+	// events are the most recent Events, oldest first; the Events up to
+	// the index cleared are no longer known
+	events  []*etcd.Response
+	cleared uint64
+	// changed is closed and replaced whenever an Event occurs
+	changed chan struct{}
+
+	now func() time.Time
+}
+
+func openStore(file string) (*store, error) {
+	s := &store{
+		file:    file,
+		keys:    make(map[string]*entry),
+		changed: make(chan struct{}),
+		now:     time.Now,
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			return nil, err
+		}
+		return s, s.save(database{Keys: s.keys})
+	} else if err != nil {
+		return nil, err
+	}
+
+	var db database
+	if err := json.Unmarshal(b, &db); err != nil {
+		return nil, fmt.Errorf("invalid registry file %s: %v", file, err)
+	}
+	s.index = db.Index
+	if db.Keys != nil {
+		s.keys = db.Keys
+	}
+	// the Events preceding the load are unknown to watchers
+	s.cleared = s.index
+	return s, nil
+}
+
+// save writes the database to the file of the store, replacing it
+// atomically and durably.
+func (s *store) save(db database) error {
+	b, err := json.Marshal(db)
+	if err != nil {
+		return err
+	}
+	tmp := s.file + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.file); err != nil {
+		return err
+	}
+
+	// the rename only survives a crash once the directory is synced
+	dir, err := os.Open(filepath.Dir(s.file))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// commit saves the keys resulting from the change of the given Event, and
+// only then makes them current, records the Event and wakes up the waiting
+// watchers. The store is left unchanged if saving fails.
+func (s *store) commit(keys map[string]*entry, res *etcd.Response) error {
+	if err := s.save(database{Index: res.Index, Keys: keys}); err != nil {
+		return err
+	}
+	s.keys = keys
+	s.index = res.Index
+
+	s.events = append(s.events, res)
+	if n := len(s.events) - maxEvents; n > 0 {
+		s.cleared = s.events[n-1].Index
+		s.events = s.events[n:]
+	}
+	close(s.changed)
+	s.changed = make(chan struct{})
+	return nil
+}
+
+// cloneKeys returns a copy of the keys to apply a change to. Entries are
+// never modified, so they are shared with the copy.
+func (s *store) cloneKeys() map[string]*entry {
+	keys := make(map[string]*entry, len(s.keys))
+	for k, e := range s.keys {
+		keys[k] = e
+	}
+	return keys
+}
+
+// expire removes the keys whose TTL has elapsed.
+func (s *store) expire() error {
+	now := s.now()
+	var expired []string
+	for k, e := range s.keys {
+		if e.Expiration != nil && !e.Expiration.After(now) {
+			expired = append(expired, k)
+		}
+	}
+	sort.Strings(expired)
+
+	for _, k := range expired {
+		prev := s.keys[k]
+		keys := s.cloneKeys()
+		delete(keys, k)
+		index := s.index + 1
+		res := &etcd.Response{
+			Action:   "expire",
+			Node:     &etcd.Node{Key: k, CreatedIndex: prev.CreatedIndex, ModifiedIndex: index},
+			PrevNode: s.node(k, prev),
+			Index:    index,
+		}
+		if err := s.commit(keys, res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextExpiration returns the time until the next key expires, or a negative
+// duration if no key has a TTL.
+func (s *store) nextExpiration() time.Duration {
+	var next *time.Time
+	for _, e := range s.keys {
+		if e.Expiration != nil && (next == nil || e.Expiration.Before(*next)) {
+			next = e.Expiration
+		}
+	}
+	if next == nil {
+		return -1
+	}
+	if d := next.Sub(s.now()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+func (s *store) Get(ctx context.Context, key string, opts *etcd.GetOptions) (*etcd.Response, error) {
+	key = keytree.Clean(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.expire(); err != nil {
+		return nil, err
+	}
+
+	if e, ok := s.keys[key]; ok {
+		return &etcd.Response{Action: "get", Node: s.node(key, e), Index: s.index}, nil
+	}
+	node := s.dirNode(key, opts != nil && opts.Recursive)
+	if node == nil {
+		return nil, keytree.KeyNotFound(key, s.index)
+	}
+	return &etcd.Response{Action: "get", Node: node, Index: s.index}, nil
+}
+
+func (s *store) Set(ctx context.Context, key, value string, opts *etcd.SetOptions) (*etcd.Response, error) {
+	if opts == nil {
+		opts = &etcd.SetOptions{}
+	}
+	if opts.Dir || opts.Refresh {
+		return nil, errors.New("localkv: directories and refreshing TTLs are not supported")
+	}
+	key = keytree.Clean(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.expire(); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkParents(key); err != nil {
+		return nil, err
+	}
+	if s.isDir(key) {
+		if opts.PrevExist == etcd.PrevNoExist {
+			return nil, keytree.NodeExist(key, s.index)
+		}
+		return nil, keytree.NotFile(key, s.index)
+	}
+	prev := s.keys[key]
+	if err := s.compare(key, prev, opts.PrevExist, opts.PrevIndex, opts.PrevValue); err != nil {
+		return nil, err
+	}
+
+	index := s.index + 1
+	e := &entry{Value: value, CreatedIndex: index, ModifiedIndex: index}
+	var prevNode *etcd.Node
+	if prev != nil {
+		e.CreatedIndex = prev.CreatedIndex
+		prevNode = s.node(key, prev)
+	}
+	if opts.TTL > 0 {
+		exp := s.now().Add(opts.TTL)
+		e.Expiration = &exp
+	}
+	keys := s.cloneKeys()
+	keys[key] = e
+
+	action := "set"
+	switch {
+	case opts.PrevExist == etcd.PrevNoExist:
+		action = "create"
+	case opts.PrevIndex != 0 || opts.PrevValue != "":
+		action = "compareAndSwap"
+	case opts.PrevExist == etcd.PrevExist:
+		action = "update"
+	}
+
+	res := &etcd.Response{Action: action, Node: s.node(key, e), PrevNode: prevNode, Index: index}
+	if err := s.commit(keys, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *store) Delete(ctx context.Context, key string, opts *etcd.DeleteOptions) (*etcd.Response, error) {
+	if opts == nil {
+		opts = &etcd.DeleteOptions{}
+	}
+	key = keytree.Clean(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.expire(); err != nil {
+		return nil, err
+	}
+
+	action := "delete"
+	if opts.PrevIndex != 0 || opts.PrevValue != "" {
+		action = "compareAndDelete"
+	}
+
+	index := s.index + 1
+	keys := s.cloneKeys()
+	var res *etcd.Response
+	prev, ok := s.keys[key]
+	if !ok {
+		if !s.isDir(key) {
+			return nil, keytree.KeyNotFound(key, s.index)
+		}
+		if !opts.Recursive || action != "delete" {
+			return nil, keytree.NotFile(key, s.index)
+		}
+
+		for k := range keys {
+			if keytree.InTree(key, k) {
+				delete(keys, k)
+			}
+		}
+		res = &etcd.Response{
+			Action:   action,
+			Node:     &etcd.Node{Key: key, Dir: true, ModifiedIndex: index},
+			PrevNode: &etcd.Node{Key: key, Dir: true},
+			Index:    index,
+		}
+	} else {
+		if err := s.compare(key, prev, etcd.PrevIgnore, opts.PrevIndex, opts.PrevValue); err != nil {
+			return nil, err
+		}
+		delete(keys, key)
+		res = &etcd.Response{
+			Action:   action,
+			Node:     &etcd.Node{Key: key, CreatedIndex: prev.CreatedIndex, ModifiedIndex: index},
+			PrevNode: s.node(key, prev),
+			Index:    index,
+		}
+	}
+
+	if err := s.commit(keys, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *store) Create(ctx context.Context, key, value string) (*etcd.Response, error) {
+	return s.Set(ctx, key, value, &etcd.SetOptions{PrevExist: etcd.PrevNoExist})
+}
+
+func (s *store) CreateInOrder(ctx context.Context, dir, value string, opts *etcd.CreateInOrderOptions) (*etcd.Response, error) {
+	return nil, errors.New("localkv: creating keys in order is not supported")
+}
+
+func (s *store) Update(ctx context.Context, key, value string) (*etcd.Response, error) {
+	return s.Set(ctx, key, value, &etcd.SetOptions{PrevExist: etcd.PrevExist})
+}
+
+func (s *store) Watcher(key string, opts *etcd.WatcherOptions) etcd.Watcher {
+	w := &watcher{s: s, key: keytree.Clean(key)}
+	if opts != nil {
+		w.recursive = opts.Recursive
+		if opts.AfterIndex > 0 {
+			w.next = opts.AfterIndex + 1
+		}
+	}
+	return w
+}
+
+// compare checks the preconditions of the v2 API on the key, whose current
+// entry is prev.
+func (s *store) compare(key string, prev *entry, prevExist etcd.PrevExistType, prevIndex uint64, prevValue string) error {
+	switch {
+	case prev != nil && prevExist == etcd.PrevNoExist:
+		return keytree.NodeExist(key, s.index)
+	case prev == nil && (prevExist == etcd.PrevExist || prevIndex != 0 || prevValue != ""):
+		return keytree.KeyNotFound(key, s.index)
+	case prev != nil && prevIndex != 0 && prev.ModifiedIndex != prevIndex,
+		prev != nil && prevValue != "" && prev.Value != prevValue:
+		return keytree.TestFailed(key, s.index)
+	}
+	return nil
+}
+
+// checkParents ensures that none of the parents of the key holds a value.
+func (s *store) checkParents(key string) error {
+	if key == "/" {
+		return etcd.Error{Code: etcd.ErrorCodeRootROnly, Message: "Root is read only", Cause: key, Index: s.index}
+	}
+	for p := path.Dir(key); p != "/"; p = path.Dir(p) {
+		if _, ok := s.keys[p]; ok {
+			return etcd.Error{Code: etcd.ErrorCodeNotDir, Message: "Not a directory", Cause: p, Index: s.index}
+		}
+	}
+	return nil
+}
+
+// isDir determines whether any key lies within the directory of the key.
+func (s *store) isDir(key string) bool {
+	prefix := keytree.DirPrefix(key)
+	for k := range s.keys {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *store) node(key string, e *entry) *etcd.Node {
+	n := &etcd.Node{
+		Key:           key,
+		Value:         e.Value,
+		CreatedIndex:  e.CreatedIndex,
+		ModifiedIndex: e.ModifiedIndex,
+	}
+	if e.Expiration != nil {
+		exp := *e.Expiration
+		n.Expiration = &exp
+		n.TTL = int64(math.Ceil(exp.Sub(s.now()).Seconds()))
+	}
+	return n
+}
+
+// dirNode returns the Node of the directory of the given key, or nil if it
+// is empty. Unless recursive is set, the directory only includes its
+// immediate children.
+func (s *store) dirNode(key string, recursive bool) *etcd.Node {
+	var leaves []*etcd.Node
+	for k, e := range s.keys {
+		if keytree.InTree(key, k) {
+			leaves = append(leaves, s.node(k, e))
+		}
+	}
+	return keytree.Build(key, leaves, recursive)
+}
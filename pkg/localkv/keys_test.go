@@ -0,0 +1,350 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localkv
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+func newTestStore(t *testing.T) (*store, func()) {
+	dir, err := ioutil.TempDir("", "fleet-localkv-")
+	if err != nil {
+		t.Fatalf("unexpected error creating directory: %v", err)
+	}
+	s, err := openStore(filepath.Join(dir, "registry.json"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("unexpected error opening store: %v", err)
+	}
+	return s, func() { os.RemoveAll(dir) }
+}
+
+func errorCode(err error) int {
+	if eerr, ok := err.(etcd.Error); ok {
+		return eerr.Code
+	}
+	return 0
+}
+
+func TestStoreGet(t *testing.T) {
+	s, done := newTestStore(t)
+	defer done()
+
+	ctx := context.Background()
+	for _, key := range []string{"/fleet/job/b/object", "/fleet/job/a/target", "/fleet/job/a/object", "/fleet/jobs", "/fleet/job-x"} {
+		if _, err := s.Set(ctx, key, key, nil); err != nil {
+			t.Fatalf("unexpected error setting %s: %v", key, err)
+		}
+	}
+
+	res, err := s.Get(ctx, "/fleet/job/a/object", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Node.Dir || res.Node.Value != "/fleet/job/a/object" {
+		t.Errorf("expected leaf node, got %#v", res.Node)
+	}
+
+	res, err = s.Get(ctx, "/fleet/job/", &etcd.GetOptions{Recursive: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var keys []string
+	for _, dir := range res.Node.Nodes {
+		for _, n := range dir.Nodes {
+			keys = append(keys, n.Key)
+		}
+	}
+	want := []string{"/fleet/job/a/object", "/fleet/job/a/target", "/fleet/job/b/object"}
+	if !reflect.DeepEqual(want, keys) {
+		t.Errorf("expected keys %v, got %v", want, keys)
+	}
+
+	res, err = s.Get(ctx, "/fleet", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keys = nil
+	for _, n := range res.Node.Nodes {
+		keys = append(keys, n.Key)
+		if n.Dir && len(n.Nodes) != 0 {
+			t.Errorf("expected %s to be listed without its children", n.Key)
+		}
+	}
+	want = []string{"/fleet/job", "/fleet/job-x", "/fleet/jobs"}
+	if !reflect.DeepEqual(want, keys) {
+		t.Errorf("expected keys %v, got %v", want, keys)
+	}
+
+	if _, err = s.Get(ctx, "/fleet/job/c", nil); errorCode(err) != etcd.ErrorCodeKeyNotFound {
+		t.Errorf("expected KeyNotFound, got %v", err)
+	}
+}
+
+func TestStoreSetConditions(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		opts  *etcd.SetOptions
+		exist bool
+		code  int
+	}{
+		{&etcd.SetOptions{PrevExist: etcd.PrevNoExist}, false, 0},
+		{&etcd.SetOptions{PrevExist: etcd.PrevNoExist}, true, etcd.ErrorCodeNodeExist},
+		{&etcd.SetOptions{PrevExist: etcd.PrevExist}, false, etcd.ErrorCodeKeyNotFound},
+		{&etcd.SetOptions{PrevExist: etcd.PrevExist}, true, 0},
+		{&etcd.SetOptions{PrevIndex: 1}, true, 0},
+		{&etcd.SetOptions{PrevIndex: 2}, true, etcd.ErrorCodeTestFailed},
+		{&etcd.SetOptions{PrevValue: "old"}, true, 0},
+		{&etcd.SetOptions{PrevValue: "other"}, true, etcd.ErrorCodeTestFailed},
+		{&etcd.SetOptions{PrevValue: "old"}, false, etcd.ErrorCodeKeyNotFound},
+	}
+
+	for i, tt := range tests {
+		s, done := newTestStore(t)
+		if tt.exist {
+			s.Set(ctx, "/foo", "old", nil)
+		}
+
+		res, err := s.Set(ctx, "/foo", "new", tt.opts)
+		if code := errorCode(err); code != tt.code {
+			t.Errorf("case %d: expected error code %d, got %v", i, tt.code, err)
+		} else if err == nil && res.Node.Value != "new" {
+			t.Errorf("case %d: expected new value, got %#v", i, res.Node)
+		}
+		done()
+	}
+}
+
+func TestStoreSetPaths(t *testing.T) {
+	s, done := newTestStore(t)
+	defer done()
+
+	ctx := context.Background()
+	s.Set(ctx, "/fleet/job/a/object", "x", nil)
+	if _, err := s.Set(ctx, "/fleet/job/a/object/y", "x", nil); errorCode(err) != etcd.ErrorCodeNotDir {
+		t.Errorf("expected NotDir setting key within a key, got %v", err)
+	}
+	if _, err := s.Set(ctx, "/fleet/job/a", "x", nil); errorCode(err) != etcd.ErrorCodeNotFile {
+		t.Errorf("expected NotFile setting a directory, got %v", err)
+	}
+	if _, err := s.Create(ctx, "/fleet/job/a", "x"); errorCode(err) != etcd.ErrorCodeNodeExist {
+		t.Errorf("expected NodeExist creating a directory, got %v", err)
+	}
+}
+
+func TestStoreDelete(t *testing.T) {
+	s, done := newTestStore(t)
+	defer done()
+
+	ctx := context.Background()
+	s.Set(ctx, "/fleet/job/a/object", "x", nil)
+	s.Set(ctx, "/fleet/job/a/target", "y", nil)
+
+	if _, err := s.Delete(ctx, "/fleet/job/a", nil); errorCode(err) != etcd.ErrorCodeNotFile {
+		t.Errorf("expected NotFile deleting a directory, got %v", err)
+	}
+	if _, err := s.Delete(ctx, "/fleet/job/a/target", &etcd.DeleteOptions{PrevIndex: 1}); errorCode(err) != etcd.ErrorCodeTestFailed {
+		t.Errorf("expected TestFailed, got %v", err)
+	}
+	res, err := s.Delete(ctx, "/fleet/job/a/target", &etcd.DeleteOptions{PrevIndex: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Action != "compareAndDelete" || res.PrevNode.Value != "y" {
+		t.Errorf("unexpected response %#v", res)
+	}
+
+	if _, err = s.Delete(ctx, "/fleet/job", &etcd.DeleteOptions{Recursive: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = s.Get(ctx, "/fleet/job/a/object", nil); errorCode(err) != etcd.ErrorCodeKeyNotFound {
+		t.Errorf("expected tree to be deleted, got %v", err)
+	}
+	if _, err = s.Delete(ctx, "/fleet/job", &etcd.DeleteOptions{Recursive: true}); errorCode(err) != etcd.ErrorCodeKeyNotFound {
+		t.Errorf("expected KeyNotFound, got %v", err)
+	}
+}
+
+func TestStoreTTL(t *testing.T) {
+	s, done := newTestStore(t)
+	defer done()
+
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	res, err := s.Set(ctx, "/lease/engine", "XXX", &etcd.SetOptions{PrevExist: etcd.PrevNoExist, TTL: 1500 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Node.TTL != 2 || res.Node.Expiration == nil {
+		t.Errorf("expected TTL to be rounded up to 2s, got %#v", res.Node)
+	}
+
+	now = now.Add(2 * time.Second)
+	if _, err = s.Get(ctx, "/lease/engine", nil); errorCode(err) != etcd.ErrorCodeKeyNotFound {
+		t.Errorf("expected key to expire, got %v", err)
+	}
+
+	w := s.Watcher("/lease", &etcd.WatcherOptions{AfterIndex: res.Index, Recursive: true})
+	ev, err := w.Next(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ev.Action != "expire" || ev.Node.Key != "/lease/engine" {
+		t.Errorf("expected expire event, got %#v", ev)
+	}
+}
+
+func TestStorePersistence(t *testing.T) {
+	s, done := newTestStore(t)
+	defer done()
+
+	ctx := context.Background()
+	s.Set(ctx, "/fleet/job/a/object", "x", nil)
+	s.Set(ctx, "/fleet/job/b/object", "y", nil)
+	s.Delete(ctx, "/fleet/job/b/object", nil)
+
+	reopened, err := openStore(s.file)
+	if err != nil {
+		t.Fatalf("unexpected error reopening store: %v", err)
+	}
+	res, err := reopened.Get(ctx, "/fleet/job/a/object", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Node.Value != "x" || res.Index != 3 {
+		t.Errorf("expected value and index to be kept, got %#v at %d", res.Node, res.Index)
+	}
+	if _, err = reopened.Get(ctx, "/fleet/job/b/object", nil); errorCode(err) != etcd.ErrorCodeKeyNotFound {
+		t.Errorf("expected deleted key to stay deleted, got %v", err)
+	}
+
+	// the Events preceding the reopening are not known
+	w := reopened.Watcher("/fleet", &etcd.WatcherOptions{AfterIndex: 1, Recursive: true})
+	if _, err = w.Next(ctx); errorCode(err) != etcd.ErrorCodeEventIndexCleared {
+		t.Errorf("expected EventIndexCleared, got %v", err)
+	}
+}
+
+func TestStoreSaveFailure(t *testing.T) {
+	s, done := newTestStore(t)
+	defer done()
+
+	ctx := context.Background()
+	s.Set(ctx, "/fleet/job/a/object", "x", nil)
+
+	// a directory in place of the temporary file makes saving fail
+	if err := os.Mkdir(s.file+".tmp", 0700); err != nil {
+		t.Fatalf("unexpected error creating directory: %v", err)
+	}
+	if _, err := s.Set(ctx, "/fleet/job/a/object", "y", nil); err == nil {
+		t.Fatalf("expected error setting key which cannot be saved")
+	}
+	if _, err := s.Delete(ctx, "/fleet/job", &etcd.DeleteOptions{Recursive: true}); err == nil {
+		t.Fatalf("expected error deleting keys which cannot be saved")
+	}
+	res, err := s.Get(ctx, "/fleet/job/a/object", nil)
+	if err != nil || res.Node.Value != "x" || res.Index != 1 || len(s.events) != 1 {
+		t.Fatalf("expected store to be left unchanged, got %v at %v, %v", res, s.events, err)
+	}
+
+	os.Remove(s.file + ".tmp")
+	if res, err = s.Set(ctx, "/fleet/job/a/object", "y", nil); err != nil || res.Index != 2 {
+		t.Fatalf("expected key to be set at index 2, got %v, %v", res, err)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	s, done := newTestStore(t)
+	defer done()
+
+	ctx := context.Background()
+	s.Set(ctx, "/fleet/job/a/object", "x", nil)
+
+	w := s.Watcher("/fleet/job", &etcd.WatcherOptions{Recursive: true})
+	other := s.Watcher("/fleet/machines", &etcd.WatcherOptions{Recursive: true})
+
+	results := make(chan *etcd.Response, 1)
+	go func() {
+		res, err := w.Next(ctx)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		results <- res
+	}()
+
+	// give the watcher the chance to start waiting, so that it only
+	// sees later Events
+	time.Sleep(50 * time.Millisecond)
+	s.Set(ctx, "/fleet/job/a/target", "y", nil)
+	select {
+	case res := <-results:
+		if res == nil || res.Action != "set" || res.Node.Key != "/fleet/job/a/target" {
+			t.Errorf("unexpected event %#v", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("watcher was not woken up")
+	}
+
+	leaf := s.Watcher("/fleet/job/a/object", &etcd.WatcherOptions{AfterIndex: s.index})
+	s.Delete(ctx, "/fleet/job", &etcd.DeleteOptions{Recursive: true})
+	res, err := leaf.Next(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Action != "delete" || res.Node.Key != "/fleet/job" {
+		t.Errorf("expected deletion of directory to concern its keys, got %#v", res)
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err = other.Next(cctx); err != context.DeadlineExceeded {
+		t.Errorf("expected unrelated watcher to time out, got %v", err)
+	}
+}
+
+func TestWatcherHistoryCleared(t *testing.T) {
+	s, done := newTestStore(t)
+	defer done()
+
+	ctx := context.Background()
+	for i := 0; i < maxEvents+2; i++ {
+		s.Set(ctx, "/foo", "x", nil)
+	}
+
+	// the Events at indexes 1 and 2 were dropped
+	w := s.Watcher("/foo", &etcd.WatcherOptions{AfterIndex: 1})
+	if _, err := w.Next(ctx); errorCode(err) != etcd.ErrorCodeEventIndexCleared {
+		t.Errorf("expected EventIndexCleared, got %v", err)
+	}
+
+	w = s.Watcher("/foo", &etcd.WatcherOptions{AfterIndex: 2})
+	res, err := w.Next(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Index != 3 {
+		t.Errorf("expected event at index 3, got %d", res.Index)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localkv
+
+import (
+	"fmt"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+
+	"github.com/nickswift/fleet/pkg/keytree"
+)
+
+// watcher implements the etcd.Watcher interface over the history of Events
+// of a store.
+type watcher struct {
+	s         *store
+	key       string
+	recursive bool
+
+	// next is the index of the first Event to return, or zero to only
+	// return Events occurring after the first call to Next
+	next uint64
+}
+
+func (w *watcher) Next(ctx context.Context) (*etcd.Response, error) {
+	s := w.s
+	for {
+		s.mu.Lock()
+		if err := s.expire(); err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+		if w.next == 0 {
+			w.next = s.index + 1
+		}
+		if w.next <= s.cleared {
+			err := etcd.Error{
+				Code:    etcd.ErrorCodeEventIndexCleared,
+				Message: "The event in requested index is outdated and cleared",
+				Cause:   fmt.Sprintf("the requested history has been cleared [%d/%d]", s.cleared+1, w.next),
+				Index:   s.index,
+			}
+			s.mu.Unlock()
+			return nil, err
+		}
+
+		for _, ev := range s.events {
+			if ev.Index >= w.next && w.matches(ev) {
+				w.next = ev.Index + 1
+				s.mu.Unlock()
+				res := *ev
+				return &res, nil
+			}
+		}
+		// none of the known Events matches, so only the later ones
+		// need to be looked at
+		w.next = s.index + 1
+
+		changed := s.changed
+		timeout := s.nextExpiration()
+		s.mu.Unlock()
+
+		// keys expire while the store is idle as well
+		var expiry *time.Timer
+		var expired <-chan time.Time
+		if timeout >= 0 {
+			expiry = time.NewTimer(timeout)
+			expired = expiry.C
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-changed:
+		case <-expired:
+		}
+		if expiry != nil {
+			expiry.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// matches determines whether the Event concerns the watched key. As with the
+// v2 API, removing a directory concerns the keys within it.
+func (w *watcher) matches(ev *etcd.Response) bool {
+	k := ev.Node.Key
+	if k == w.key || (w.recursive && keytree.InTree(w.key, k)) {
+		return true
+	}
+	return (ev.Action == "delete" || ev.Action == "expire") && ev.Node.Dir && keytree.InTree(k, w.key)
+}
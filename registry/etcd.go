@@ -15,12 +15,48 @@
 package registry
 
 import (
+	"fmt"
 	"path"
 
 	etcd "github.com/coreos/etcd/client"
+
+	"github.com/nickswift/fleet/pkg/etcdv3"
 )
 
-const DefaultKeyPrefix = "/_coreos.com/fleet/"
+const (
+	DefaultKeyPrefix = "/_coreos.com/fleet/"
+
+	EtcdAPIv2 = "v2"
+	EtcdAPIv3 = "v3"
+
+	DefaultEtcdAPIVersion = EtcdAPIv2
+
+	// BackendEtcd stores fleet data in etcd, while BackendLocal stores it
+	// in a file local to a single fleetd
+	BackendEtcd  = "etcd"
+	BackendLocal = "local"
+
+	DefaultBackend           = BackendEtcd
+	DefaultLocalRegistryFile = "/var/lib/fleet/registry.json"
+)
+
+// NewKeysAPI returns a KeysAPI using the given version of the etcd API.
+// With the v3 API, the keys of fleet are laid out as with the v2 API, with
+// leases providing their TTLs and revisions their indexes.
+func NewKeysAPI(version string, cfg etcd.Config) (etcd.KeysAPI, error) {
+	switch version {
+	case EtcdAPIv2:
+		eClient, err := etcd.New(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return etcd.NewKeysAPI(eClient), nil
+	case EtcdAPIv3:
+		return etcdv3.NewKeysAPI(cfg)
+	default:
+		return nil, fmt.Errorf("unknown etcd API version %q, expected %q or %q", version, EtcdAPIv2, EtcdAPIv3)
+	}
+}
 
 func NewEtcdRegistry(kAPI etcd.KeysAPI, keyPrefix string) *EtcdRegistry {
 	return &EtcdRegistry{
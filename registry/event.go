@@ -17,6 +17,7 @@ package registry
 import (
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	etcd "github.com/coreos/etcd/client"
@@ -31,6 +32,8 @@ const (
 	JobTargetChangeEvent = pkg.Event("JobTargetChangeEvent")
 	// Occurs when any Job's target state is touched
 	JobTargetStateChangeEvent = pkg.Event("JobTargetStateChangeEvent")
+	// Occurs when any Machine's metadata is set or deleted through the API
+	MachineMetadataChangeEvent = pkg.Event("MachineMetadataChangeEvent")
 )
 
 type etcdEventStream struct {
@@ -45,23 +48,51 @@ func NewEtcdEventStream(kAPI etcd.KeysAPI, rootPrefix string) pkg.EventStream {
 // Next returns a channel which will emit an Event as soon as one of interest occurs
 func (es *etcdEventStream) Next(stop chan struct{}) chan pkg.Event {
 	evchan := make(chan pkg.Event)
-	go func() {
-		for {
-			select {
-			case <-stop:
-				return
-			default:
-			}
 
-			res := watch(es.kAPI, path.Join(es.rootPrefix, jobPrefix), stop)
-			if ev, ok := parse(res, es.rootPrefix); ok {
-				evchan <- ev
-				return
-			}
+	// done ends the watches of all prefixes as soon as one of them found
+	// an Event, or the caller stopped waiting
+	done := make(chan struct{})
+	var once sync.Once
+	finish := func() { once.Do(func() { close(done) }) }
+	go func() {
+		select {
+		case <-stop:
+			finish()
+		case <-done:
 		}
-
 	}()
 
+	for _, prefix := range []string{jobPrefix, machinePrefix} {
+		go func(key string) {
+			// changes which are not of interest, like the heartbeats
+			// of the machines, are skipped by resuming the watch right
+			// after them, so that no change is missed in between
+			var index uint64
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				res := watch(es.kAPI, key, index, done)
+				if res != nil && res.Node != nil {
+					index = res.Node.ModifiedIndex
+				}
+				if ev, ok := parse(res, es.rootPrefix); ok {
+					once.Do(func() {
+						close(done)
+						select {
+						case evchan <- ev:
+						case <-stop:
+						}
+					})
+					return
+				}
+			}
+		}(path.Join(es.rootPrefix, prefix))
+	}
+
 	return evchan
 }
 
@@ -70,6 +101,16 @@ func parse(res *etcd.Response, prefix string) (ev pkg.Event, ok bool) {
 		return
 	}
 
+	if strings.HasPrefix(res.Node.Key, path.Join(prefix, machinePrefix)+"/") {
+		// machines/<id>/metadata/<key>
+		parts := strings.Split(strings.TrimPrefix(res.Node.Key, path.Join(prefix, machinePrefix)+"/"), "/")
+		if len(parts) == 3 && parts[1] == metadataKey {
+			ev = MachineMetadataChangeEvent
+			ok = true
+		}
+		return
+	}
+
 	if !strings.HasPrefix(res.Node.Key, path.Join(prefix, jobPrefix)) {
 		return
 	}
@@ -86,7 +127,19 @@ func parse(res *etcd.Response, prefix string) (ev pkg.Event, ok bool) {
 	return
 }
 
-func watch(kAPI etcd.KeysAPI, key string, stop chan struct{}) (res *etcd.Response) {
+// watch returns the first change of the key, or of the keys below it, made
+// after the given index. An index of 0 watches for changes from now on.
+func watch(kAPI etcd.KeysAPI, key string, afterIndex uint64, stop chan struct{}) (res *etcd.Response) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
 	for res == nil {
 		select {
 		case <-stop:
@@ -94,17 +147,25 @@ func watch(kAPI etcd.KeysAPI, key string, stop chan struct{}) (res *etcd.Respons
 			return
 		default:
 			opts := &etcd.WatcherOptions{
-				AfterIndex: 0,
+				AfterIndex: afterIndex,
 				Recursive:  true,
 			}
 			watcher := kAPI.Watcher(key, opts)
 			log.Debugf("Creating etcd watcher: %s", key)
 
 			var err error
-			res, err = watcher.Next(context.Background())
-			if err != nil {
+			res, err = watcher.Next(ctx)
+			if err == nil {
+				return
+			}
+			if ctx.Err() == nil {
 				log.Errorf("etcd watcher %v returned error: %v", key, err)
 			}
+			// the changes since the index are no longer kept by
+			// etcd, so only later ones can be watched
+			if isEtcdError(err, etcd.ErrorCodeEventIndexCleared) {
+				afterIndex = 0
+			}
 		}
 
 		// Let's not slam the etcd server in the event that we know
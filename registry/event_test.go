@@ -67,6 +67,15 @@ func TestFilterEtcdEvents(t *testing.T) {
 			ev: JobTargetChangeEvent,
 			ok: true,
 		},
+		{
+			in: "/fleet/machines/asdf/object",
+			ok: false,
+		},
+		{
+			in: "/fleet/machines/asdf/metadata/region",
+			ev: MachineMetadataChangeEvent,
+			ok: true,
+		},
 	}
 
 	for i, tt := range tests {
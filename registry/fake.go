@@ -20,11 +20,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/coreos/go-semver/semver"
 	"github.com/nickswift/fleet/job"
 	"github.com/nickswift/fleet/machine"
 	"github.com/nickswift/fleet/pkg/lease"
 	"github.com/nickswift/fleet/unit"
-	"github.com/coreos/go-semver/semver"
 )
 
 func NewFakeRegistry() *FakeRegistry {
@@ -32,6 +32,7 @@ func NewFakeRegistry() *FakeRegistry {
 		machines:      []machine.MachineState{},
 		jobStates:     map[string]map[string]*unit.UnitState{},
 		jobs:          map[string]job.Job{},
+		replicaSets:   map[string]job.ReplicaSet{},
 		daemonVersion: nil,
 	}
 }
@@ -43,10 +44,27 @@ type FakeRegistry struct {
 	Registry
 	sync.RWMutex
 
-	machines      []machine.MachineState
+	machines  []machine.MachineState
+	cordoned  map[string]bool
+	taints    map[string]map[string]string
+	metadata  map[string]map[string]string
+	held      map[string]bool
+	failed    map[string][]string
+	rollouts  map[string]*job.Rollout
+	events    map[string][]job.UnitEvent
+	revisions map[string][]job.UnitRevision
+	// indexes at which the Units were last created or replaced
+	unitIndexes   map[string]uint64
+	unitIdx       uint64
 	jobStates     map[string]map[string]*unit.UnitState
 	jobs          map[string]job.Job
+	replicaSets   map[string]job.ReplicaSet
 	daemonVersion *semver.Version
+
+	// unitFiles are the indexes of the stored unit files by hash
+	unitFiles        map[string]uint64
+	unitFileContents map[string]unit.UnitFile
+	unitFileIdx      uint64
 }
 
 func (f *FakeRegistry) SetMachines(machines []machine.MachineState) {
@@ -63,7 +81,53 @@ func (f *FakeRegistry) SetJobs(jobs []job.Job) {
 	f.jobs = make(map[string]job.Job, len(jobs))
 	for _, j := range jobs {
 		f.jobs[j.Name] = j
+		f.storeUnitFile(j.Unit)
+	}
+}
+
+// storeUnitFile records that a unit file was stored, like the EtcdRegistry
+// does when creating a Unit.
+func (f *FakeRegistry) storeUnitFile(u unit.UnitFile) {
+	if f.unitFiles == nil {
+		f.unitFiles = make(map[string]uint64)
+		f.unitFileContents = make(map[string]unit.UnitFile)
+	}
+	f.unitFileIdx++
+	f.unitFiles[u.Hash().String()] = f.unitFileIdx
+	f.unitFileContents[u.Hash().String()] = u
+}
+
+func (f *FakeRegistry) UnitFile(hash unit.Hash) (*unit.UnitFile, error) {
+	f.RLock()
+	defer f.RUnlock()
+
+	u, ok := f.unitFileContents[hash.String()]
+	if !ok {
+		return nil, nil
+	}
+	return &u, nil
+}
+
+func (f *FakeRegistry) UnitFiles() (map[string]uint64, error) {
+	f.RLock()
+	defer f.RUnlock()
+
+	files := make(map[string]uint64, len(f.unitFiles))
+	for hash, idx := range f.unitFiles {
+		files[hash] = idx
+	}
+	return files, nil
+}
+
+func (f *FakeRegistry) DestroyUnitFile(hash string, idx uint64) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.unitFiles[hash] == idx {
+		delete(f.unitFiles, hash)
+		delete(f.unitFileContents, hash)
 	}
+	return nil
 }
 
 func (f *FakeRegistry) SetUnitStates(states []unit.UnitState) {
@@ -93,7 +157,92 @@ func (f *FakeRegistry) Machines() ([]machine.MachineState, error) {
 	f.RLock()
 	defer f.RUnlock()
 
-	return f.machines, nil
+	if len(f.cordoned) == 0 && len(f.taints) == 0 && len(f.metadata) == 0 {
+		return f.machines, nil
+	}
+
+	machines := make([]machine.MachineState, len(f.machines))
+	for i, ms := range f.machines {
+		ms.Cordoned = f.cordoned[ms.ID]
+		ms.Taints = machine.OverlayTaints(ms.Taints, f.taints[ms.ID])
+		ms.Metadata = machine.OverlayMetadata(ms.Metadata, f.metadata[ms.ID])
+		machines[i] = ms
+	}
+	return machines, nil
+}
+
+func (f *FakeRegistry) MachineMetadata(machID string) (map[string]string, error) {
+	f.RLock()
+	defer f.RUnlock()
+
+	metadata := make(map[string]string, len(f.metadata[machID]))
+	for k, v := range f.metadata[machID] {
+		metadata[k] = v
+	}
+	return metadata, nil
+}
+
+func (f *FakeRegistry) SetMachineMetadata(machID, key, value string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.metadata == nil {
+		f.metadata = make(map[string]map[string]string)
+	}
+	if f.metadata[machID] == nil {
+		f.metadata[machID] = make(map[string]string)
+	}
+	f.metadata[machID][key] = value
+	return nil
+}
+
+func (f *FakeRegistry) DeleteMachineMetadata(machID, key string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	delete(f.metadata[machID], key)
+	return nil
+}
+
+func (f *FakeRegistry) MachineTaints(machID string) (map[string]string, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.taints[machID], nil
+}
+
+func (f *FakeRegistry) SetMachineTaints(machID string, taints map[string]string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if len(taints) == 0 {
+		delete(f.taints, machID)
+		return nil
+	}
+	if f.taints == nil {
+		f.taints = make(map[string]map[string]string)
+	}
+	f.taints[machID] = taints
+	return nil
+}
+
+func (f *FakeRegistry) CordonMachine(machID string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.cordoned == nil {
+		f.cordoned = make(map[string]bool)
+	}
+	f.cordoned[machID] = true
+	return nil
+}
+
+func (f *FakeRegistry) UncordonMachine(machID string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	delete(f.cordoned, machID)
+	return nil
 }
 
 func (f *FakeRegistry) Units() ([]job.Unit, error) {
@@ -110,9 +259,14 @@ func (f *FakeRegistry) Units() ([]job.Unit, error) {
 	for i, jName := range sorted {
 		j := f.jobs[jName]
 		u := job.Unit{
-			Name:        j.Name,
-			Unit:        j.Unit,
-			TargetState: j.TargetState,
+			Name:           j.Name,
+			Unit:           j.Unit,
+			TargetState:    j.TargetState,
+			Held:           f.held[j.Name],
+			FailedMachines: f.failed[j.Name],
+			Rollout:        f.rollouts[j.Name],
+			Revisions:      f.revisions[j.Name],
+			Index:          f.unitIndexes[j.Name],
 		}
 		units[i] = u
 	}
@@ -154,9 +308,14 @@ func (f *FakeRegistry) Unit(name string) (*job.Unit, error) {
 	}
 
 	u := job.Unit{
-		Name:        j.Name,
-		Unit:        j.Unit,
-		TargetState: j.TargetState,
+		Name:           j.Name,
+		Unit:           j.Unit,
+		TargetState:    j.TargetState,
+		Held:           f.held[j.Name],
+		FailedMachines: f.failed[j.Name],
+		Rollout:        f.rollouts[j.Name],
+		Revisions:      f.revisions[j.Name],
+		Index:          f.unitIndexes[j.Name],
 	}
 	return &u, nil
 }
@@ -182,17 +341,44 @@ func (f *FakeRegistry) CreateUnit(u *job.Unit) error {
 	f.Lock()
 	defer f.Unlock()
 
+	return f.unsafeCreateUnit(u)
+}
+
+func (f *FakeRegistry) ReplaceUnit(u *job.Unit, prevIndex uint64) error {
+	f.Lock()
+	defer f.Unlock()
+
 	_, ok := f.jobs[u.Name]
-	if ok {
-		return errors.New("unit already exists")
+	if ok != (prevIndex != 0) || f.unitIndexes[u.Name] != prevIndex {
+		return ErrUnitModified
 	}
+	return f.unsafeCreateUnit(u)
+}
 
+func (f *FakeRegistry) unsafeCreateUnit(u *job.Unit) error {
 	j := job.Job{
 		Name: u.Name,
 		Unit: u.Unit,
 	}
 
+	// like the EtcdRegistry, replace the Unit of an existing job
+	// without touching its scheduling
+	if ej, ok := f.jobs[u.Name]; ok {
+		j.TargetMachineID = ej.TargetMachineID
+		j.State = ej.State
+	}
+
 	f.jobs[u.Name] = j
+	f.storeUnitFile(u.Unit)
+	if f.revisions == nil {
+		f.revisions = make(map[string][]job.UnitRevision)
+	}
+	f.revisions[u.Name] = job.AppendUnitRevision(f.revisions[u.Name], u.Unit.Hash(), time.Now(), u.ChangeCause, maxUnitRevisions)
+	if f.unitIndexes == nil {
+		f.unitIndexes = make(map[string]uint64)
+	}
+	f.unitIdx++
+	f.unitIndexes[u.Name] = f.unitIdx
 	return f.unsafeSetUnitTargetState(u.Name, u.TargetState)
 }
 
@@ -200,7 +386,106 @@ func (f *FakeRegistry) DestroyUnit(name string) error {
 	f.Lock()
 	defer f.Unlock()
 
+	f.unsafeDestroyUnit(name)
+	return nil
+}
+
+func (f *FakeRegistry) DestroyUnitAt(name string, prevIndex uint64) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if _, ok := f.jobs[name]; !ok || f.unitIndexes[name] != prevIndex {
+		return ErrUnitModified
+	}
+	f.unsafeDestroyUnit(name)
+	return nil
+}
+
+func (f *FakeRegistry) unsafeDestroyUnit(name string) {
 	delete(f.jobs, name)
+	delete(f.held, name)
+	delete(f.failed, name)
+	delete(f.rollouts, name)
+	delete(f.events, name)
+	delete(f.revisions, name)
+	delete(f.unitIndexes, name)
+}
+
+func (f *FakeRegistry) RecordUnitEvent(name string, ev job.UnitEvent) error {
+	f.Lock()
+	defer f.Unlock()
+
+	// like the EtcdRegistry, drop the events of Units which do not exist
+	if _, ok := f.jobs[name]; !ok {
+		return nil
+	}
+	if f.events == nil {
+		f.events = make(map[string][]job.UnitEvent)
+	}
+	f.events[name] = appendUnitEvent(f.events[name], ev)
+	return nil
+}
+
+func (f *FakeRegistry) UnitEvents(name string) ([]job.UnitEvent, error) {
+	f.RLock()
+	defer f.RUnlock()
+
+	return append([]job.UnitEvent(nil), f.events[name]...), nil
+}
+
+func (f *FakeRegistry) AdmitGlobalUnit(name, hash, machID string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.rollouts == nil {
+		f.rollouts = make(map[string]*job.Rollout)
+	}
+	r, ok := f.rollouts[name]
+	if !ok || r.UnitHash != hash {
+		r = &job.Rollout{UnitHash: hash}
+	}
+	f.rollouts[name] = &job.Rollout{
+		UnitHash: hash,
+		Machines: append(append([]string(nil), r.Machines...), machID),
+	}
+	return nil
+}
+
+func (f *FakeRegistry) RecordUnitFailure(name, machID string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.failed == nil {
+		f.failed = make(map[string][]string)
+	}
+	f.failed[name] = append(f.failed[name], machID)
+	return nil
+}
+
+func (f *FakeRegistry) ClearUnitFailures(name string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	delete(f.failed, name)
+	return nil
+}
+
+func (f *FakeRegistry) HoldUnit(name string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.held == nil {
+		f.held = make(map[string]bool)
+	}
+	f.held[name] = true
+	return nil
+}
+
+func (f *FakeRegistry) ReleaseUnit(name string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	delete(f.held, name)
 	return nil
 }
 
@@ -240,6 +525,19 @@ func (f *FakeRegistry) ScheduleUnit(name string, machID string) error {
 	return nil
 }
 
+func (f *FakeRegistry) UnscheduleUnit(name, machID string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	// like the EtcdRegistry, only unschedule from the given machine
+	if j, ok := f.jobs[name]; ok && j.TargetMachineID == machID {
+		j.TargetMachineID = ""
+		f.jobs[name] = j
+	}
+
+	return nil
+}
+
 func (f *FakeRegistry) SaveUnitState(jobName string, unitState *unit.UnitState, ttl time.Duration) {
 	f.Lock()
 	defer f.Unlock()
@@ -281,6 +579,53 @@ func (f *FakeRegistry) UnitStates() ([]*unit.UnitState, error) {
 	return states, nil
 }
 
+func (f *FakeRegistry) ReplicaSets() ([]job.ReplicaSet, error) {
+	f.RLock()
+	defer f.RUnlock()
+
+	var sorted sort.StringSlice
+	for template := range f.replicaSets {
+		sorted = append(sorted, template)
+	}
+	sorted.Sort()
+
+	rsets := make([]job.ReplicaSet, 0, len(sorted))
+	for _, template := range sorted {
+		rsets = append(rsets, f.replicaSets[template])
+	}
+	return rsets, nil
+}
+
+func (f *FakeRegistry) ReplicaSet(template string) (*job.ReplicaSet, error) {
+	f.RLock()
+	defer f.RUnlock()
+
+	rs, ok := f.replicaSets[template]
+	if !ok {
+		return nil, nil
+	}
+	return &rs, nil
+}
+
+func (f *FakeRegistry) SetReplicaSet(rs job.ReplicaSet) error {
+	f.Lock()
+	defer f.Unlock()
+
+	f.replicaSets[rs.Template] = rs
+	return nil
+}
+
+func (f *FakeRegistry) DestroyReplicaSet(template string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if _, ok := f.replicaSets[template]; !ok {
+		return errors.New("replica set does not exist")
+	}
+	delete(f.replicaSets, template)
+	return nil
+}
+
 func (f *FakeRegistry) UnitHeartbeat(name, machID string, ttl time.Duration) error {
 	return nil
 }
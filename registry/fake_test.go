@@ -15,7 +15,9 @@
 package registry
 
 import (
+	"fmt"
 	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/nickswift/fleet/job"
@@ -33,6 +35,51 @@ func TestFakeRegistryUnitLifecycle(t *testing.T) {
 		t.Fatalf("Expected no units, got %v", units)
 	}
 
+	testRegistryUnitLifecycle(t, reg)
+
+	units, err = reg.Units()
+	if err != nil {
+		t.Fatalf("Received error while calling Units: %v", err)
+	}
+	if !reflect.DeepEqual([]job.Unit{}, units) {
+		t.Fatalf("Expected no units, got %v", units)
+	}
+}
+
+func TestFakeRegistryUnitEventsBounded(t *testing.T) {
+	testRegistryUnitEventsBounded(t, NewFakeRegistry())
+}
+
+func TestFakeRegistryReplaceUnit(t *testing.T) {
+	reg := NewFakeRegistry()
+	reg.SetJobs(nil)
+	testRegistryReplaceUnit(t, reg)
+}
+
+func TestFakeRegistryDestroyUnitAt(t *testing.T) {
+	reg := NewFakeRegistry()
+	reg.SetJobs(nil)
+	testRegistryDestroyUnitAt(t, reg)
+}
+
+func TestFakeRegistryConcurrentRevisions(t *testing.T) {
+	reg := NewFakeRegistry()
+	reg.SetJobs(nil)
+	testRegistryConcurrentRevisions(t, reg)
+}
+
+// The tests below describe the behavior expected of every implementation of
+// the Registry, and are run against each of them.
+
+func testRegistryUnitLifecycle(t *testing.T, reg Registry) {
+	units, err := reg.Units()
+	if err != nil {
+		t.Fatalf("Received error while calling Jobs: %v", err)
+	}
+	if len(units) != 0 {
+		t.Fatalf("Expected no units, got %v", units)
+	}
+
 	uf, _ := unit.NewUnitFile("")
 	u1 := job.Unit{Name: "u1.service", Unit: *uf, TargetState: job.JobStateLoaded}
 	err = reg.CreateUnit(&u1)
@@ -47,6 +94,13 @@ func TestFakeRegistryUnitLifecycle(t *testing.T) {
 	if len(units) != 1 {
 		t.Fatalf("Expected 1 Unit, got %v", units)
 	}
+	if revs := units[0].Revisions; len(revs) != 1 || revs[0].Revision != 1 || revs[0].UnitHash != uf.Hash() {
+		t.Fatalf("Expected unit file to be recorded as revision 1, got %v", revs)
+	}
+	if units[0].Index == 0 {
+		t.Fatalf("Expected unit to have an index")
+	}
+	units[0].Revisions, units[0].Index = nil, 0
 	if !reflect.DeepEqual(u1, units[0]) {
 		t.Fatalf("Expected unit %v, got %v", u1, units[0])
 	}
@@ -73,7 +127,135 @@ func TestFakeRegistryUnitLifecycle(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Received error while calling Units: %v", err)
 	}
-	if !reflect.DeepEqual([]job.Unit{}, units) {
+	if len(units) != 0 {
 		t.Fatalf("Expected no units, got %v", units)
 	}
 }
+
+func testRegistryUnitEventsBounded(t *testing.T, reg Registry) {
+	uf, _ := unit.NewUnitFile("[Service]\nExecStart=/bin/a")
+	if err := reg.CreateUnit(&job.Unit{Name: "u1.service", Unit: *uf}); err != nil {
+		t.Fatalf("Received error while calling CreateUnit: %v", err)
+	}
+	for i := 0; i < maxUnitEvents+5; i++ {
+		reg.RecordUnitEvent("u1.service", job.UnitEvent{MachineID: fmt.Sprintf("m%d", i)})
+	}
+
+	events, err := reg.UnitEvents("u1.service")
+	if err != nil {
+		t.Fatalf("Received error while calling UnitEvents: %v", err)
+	}
+	if len(events) != maxUnitEvents {
+		t.Fatalf("Expected %d events, got %d", maxUnitEvents, len(events))
+	}
+	if events[0].MachineID != "m5" || events[maxUnitEvents-1].MachineID != fmt.Sprintf("m%d", maxUnitEvents+4) {
+		t.Errorf("Expected the oldest events to be dropped, got %v", events)
+	}
+
+	reg.DestroyUnit("u1.service")
+	if events, _ = reg.UnitEvents("u1.service"); len(events) != 0 {
+		t.Errorf("Expected events to be removed with the unit, got %v", events)
+	}
+
+	// events of a unit which no longer exists are dropped
+	if err := reg.RecordUnitEvent("u1.service", job.UnitEvent{MachineID: "m1"}); err != nil {
+		t.Fatalf("Received error while calling RecordUnitEvent: %v", err)
+	}
+	if events, _ = reg.UnitEvents("u1.service"); len(events) != 0 {
+		t.Errorf("Expected no events of destroyed unit, got %v", events)
+	}
+}
+
+func testRegistryReplaceUnit(t *testing.T, reg Registry) {
+	uf, _ := unit.NewUnitFile("[Service]\nExecStart=/bin/a")
+	u := job.Unit{Name: "u1.service", Unit: *uf}
+	if err := reg.ReplaceUnit(&u, 0); err != nil {
+		t.Fatalf("Received error while creating unit: %v", err)
+	}
+	if err := reg.ReplaceUnit(&u, 0); err != ErrUnitModified {
+		t.Fatalf("Expected ErrUnitModified creating existing unit, got %v", err)
+	}
+
+	cur, _ := reg.Unit("u1.service")
+	if err := reg.ReplaceUnit(&u, cur.Index+1); err != ErrUnitModified {
+		t.Fatalf("Expected ErrUnitModified replacing unit at wrong index, got %v", err)
+	}
+	if err := reg.ReplaceUnit(&u, cur.Index); err != nil {
+		t.Fatalf("Received error while replacing unit: %v", err)
+	}
+	if err := reg.ReplaceUnit(&u, cur.Index); err != ErrUnitModified {
+		t.Fatalf("Expected ErrUnitModified replacing unit at outdated index, got %v", err)
+	}
+}
+
+func testRegistryDestroyUnitAt(t *testing.T, reg Registry) {
+	uf, _ := unit.NewUnitFile("[Service]\nExecStart=/bin/a")
+	u := job.Unit{Name: "u1.service", Unit: *uf}
+	if err := reg.CreateUnit(&u); err != nil {
+		t.Fatalf("Received error while creating unit: %v", err)
+	}
+	reg.ScheduleUnit("u1.service", "XXX")
+
+	old, _ := reg.Unit("u1.service")
+	if err := reg.DestroyUnitAt("u1.service", old.Index+1); err != ErrUnitModified {
+		t.Fatalf("Expected ErrUnitModified destroying unit at wrong index, got %v", err)
+	}
+	if err := reg.CreateUnit(&u); err != nil {
+		t.Fatalf("Received error while replacing unit: %v", err)
+	}
+	if err := reg.DestroyUnitAt("u1.service", old.Index); err != ErrUnitModified {
+		t.Fatalf("Expected ErrUnitModified destroying unit at outdated index, got %v", err)
+	}
+
+	cur, _ := reg.Unit("u1.service")
+	if err := reg.DestroyUnitAt("u1.service", cur.Index); err != nil {
+		t.Fatalf("Received error while destroying unit: %v", err)
+	}
+	if u, err := reg.Unit("u1.service"); err != nil || u != nil {
+		t.Fatalf("Expected unit to be destroyed, got %v, %v", u, err)
+	}
+	if su, err := reg.ScheduledUnit("u1.service"); err != nil || su != nil {
+		t.Fatalf("Expected schedule to be destroyed with the unit, got %v, %v", su, err)
+	}
+	if err := reg.DestroyUnitAt("u1.service", cur.Index); err != ErrUnitModified {
+		t.Fatalf("Expected ErrUnitModified destroying missing unit, got %v", err)
+	}
+}
+
+func testRegistryConcurrentRevisions(t *testing.T, reg Registry) {
+	const n = 5
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			uf, _ := unit.NewUnitFile(fmt.Sprintf("[Service]\nExecStart=/bin/%d", i))
+			if err := reg.CreateUnit(&job.Unit{Name: "u1.service", Unit: *uf}); err != nil {
+				t.Errorf("Received error while creating unit: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	u, err := reg.Unit("u1.service")
+	if err != nil || u == nil {
+		t.Fatalf("Expected unit, got %v, %v", u, err)
+	}
+	if len(u.Revisions) != n {
+		t.Fatalf("Expected %d revisions, got %v", n, u.Revisions)
+	}
+	for i, rev := range u.Revisions {
+		if rev.Revision != i+1 {
+			t.Errorf("Expected revision %d, got %v", i+1, u.Revisions)
+		}
+	}
+
+	// a failed replacement leaves no revision behind
+	uf, _ := unit.NewUnitFile("[Service]\nExecStart=/bin/other")
+	if err := reg.ReplaceUnit(&job.Unit{Name: "u1.service", Unit: *uf}, u.Index+1); err != ErrUnitModified {
+		t.Fatalf("Expected ErrUnitModified, got %v", err)
+	}
+	if cur, _ := reg.Unit("u1.service"); len(cur.Revisions) != n {
+		t.Errorf("Expected %d revisions after failed replacement, got %v", n, cur.Revisions)
+	}
+}
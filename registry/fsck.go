@@ -0,0 +1,254 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// Inconsistency is data left in the registry which no longer belongs to any
+// Unit or Machine, or refers to data which no longer exists.
+type Inconsistency struct {
+	// Key is the key or directory holding the data, which
+	// RepairInconsistency removes
+	Key string
+	// Dir is whether Key is a directory
+	Dir bool
+	// Index is the modification index of Key, or the highest one in its
+	// tree if it is a directory, so that it is only removed if it did not
+	// change since it was checked
+	Index  uint64
+	Reason string
+}
+
+func (i Inconsistency) String() string {
+	return fmt.Sprintf("%s: %s", i.Key, i.Reason)
+}
+
+// CheckConsistency walks the keys of the Units, their states and the
+// Machines, and returns the inconsistencies found, ordered by key.
+func (r *EtcdRegistry) CheckConsistency() ([]Inconsistency, error) {
+	jobs, err := r.getTree(jobPrefix)
+	if err != nil {
+		return nil, err
+	}
+	units, err := r.getTree(unitPrefix)
+	if err != nil {
+		return nil, err
+	}
+	machines, err := r.getTree(machinePrefix)
+	if err != nil {
+		return nil, err
+	}
+	states, err := r.getTree(statesPrefix)
+	if err != nil {
+		return nil, err
+	}
+	legacyStates, err := r.getTree(statePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[string]bool)
+	for _, node := range units {
+		hashes[path.Base(node.Key)] = true
+	}
+	// only the Machines which published their state are alive; cordons,
+	// taints and metadata outlive them on purpose
+	alive := make(map[string]bool)
+	for _, dir := range machines {
+		if getValueInDir(dir, "object") != "" {
+			alive[path.Base(dir.Key)] = true
+		}
+	}
+
+	var found []Inconsistency
+	names := make(map[string]bool)
+	for _, dir := range jobs {
+		name := path.Base(dir.Key)
+		inc, ok := checkJobDir(dir, hashes, alive)
+		found = append(found, inc...)
+		if ok {
+			names[name] = true
+		}
+	}
+
+	for _, dir := range states {
+		name := path.Base(dir.Key)
+		if !names[name] {
+			found = append(found, Inconsistency{Key: dir.Key, Dir: true, Index: treeIndex(dir), Reason: fmt.Sprintf("states of Unit(%s), which does not exist", name)})
+			continue
+		}
+		for _, node := range dir.Nodes {
+			if machID := path.Base(node.Key); !alive[machID] {
+				found = append(found, Inconsistency{Key: node.Key, Index: node.ModifiedIndex, Reason: fmt.Sprintf("state of Unit(%s) on Machine(%s), which does not exist", name, machID)})
+			}
+		}
+	}
+
+	for _, node := range legacyStates {
+		name := path.Base(node.Key)
+		if !names[name] {
+			found = append(found, Inconsistency{Key: node.Key, Index: node.ModifiedIndex, Reason: fmt.Sprintf("legacy state of Unit(%s), which does not exist", name)})
+			continue
+		}
+		var usm unitStateModel
+		if err := unmarshal(node.Value, &usm); err != nil {
+			found = append(found, Inconsistency{Key: node.Key, Index: node.ModifiedIndex, Reason: fmt.Sprintf("invalid legacy state of Unit(%s): %v", name, err)})
+		} else if usm.MachineState != nil && !alive[usm.MachineState.ID] {
+			found = append(found, Inconsistency{Key: node.Key, Index: node.ModifiedIndex, Reason: fmt.Sprintf("legacy state of Unit(%s) on Machine(%s), which does not exist", name, usm.MachineState.ID)})
+		}
+	}
+
+	sort.Sort(inconsistencies(found))
+	return found, nil
+}
+
+// checkJobDir returns the inconsistencies of the directory of a Unit, and
+// whether it holds a valid Unit.
+func checkJobDir(dir *etcd.Node, hashes, alive map[string]bool) ([]Inconsistency, bool) {
+	name := path.Base(dir.Key)
+	var obj, target *etcd.Node
+	// the heartbeats of the agents expire on their own, even if the Unit
+	// is gone
+	expiring := true
+	for _, node := range dir.Nodes {
+		switch path.Base(node.Key) {
+		case "object":
+			obj = node
+		case "target":
+			target = node
+		}
+		if node.Expiration == nil {
+			expiring = false
+		}
+	}
+
+	if obj == nil {
+		if expiring {
+			return nil, false
+		}
+		return []Inconsistency{{Key: dir.Key, Dir: true, Index: treeIndex(dir), Reason: fmt.Sprintf("keys of Unit(%s), which has no object", name)}}, false
+	}
+
+	var jm jobModel
+	if err := unmarshal(obj.Value, &jm); err != nil {
+		return []Inconsistency{{Key: dir.Key, Dir: true, Index: treeIndex(dir), Reason: fmt.Sprintf("invalid object of Unit(%s): %v", name, err)}}, false
+	}
+	if hash := jm.UnitHash.String(); !hashes[hash] {
+		return []Inconsistency{{Key: dir.Key, Dir: true, Index: treeIndex(dir), Reason: fmt.Sprintf("Unit(%s) refers to unit file %s, which does not exist", name, hash)}}, false
+	}
+
+	var found []Inconsistency
+	if target != nil && !alive[target.Value] {
+		found = append(found, Inconsistency{Key: target.Key, Index: target.ModifiedIndex, Reason: fmt.Sprintf("Unit(%s) is scheduled to Machine(%s), which does not exist", name, target.Value)})
+	}
+	return found, true
+}
+
+// getTree returns the children of the directory at the given path relative
+// to the key prefix, along with their descendants.
+func (r *EtcdRegistry) getTree(dir string) (etcd.Nodes, error) {
+	opts := &etcd.GetOptions{
+		Recursive: true,
+		Quorum:    true,
+	}
+	res, err := r.kAPI.Get(context.Background(), r.prefixed(dir), opts)
+	if err != nil {
+		if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			err = nil
+		}
+		return nil, err
+	}
+	return res.Node.Nodes, nil
+}
+
+// RepairInconsistency removes the key or directory of the inconsistency. A
+// key which changed since it was checked is left alone, as is a directory in
+// which any key changed.
+func (r *EtcdRegistry) RepairInconsistency(inc Inconsistency) error {
+	if !inc.Dir {
+		opts := &etcd.DeleteOptions{
+			PrevIndex: inc.Index,
+		}
+		_, err := r.kAPI.Delete(context.Background(), inc.Key, opts)
+		if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			err = nil
+		}
+		return err
+	}
+
+	opts := &etcd.GetOptions{
+		Recursive: true,
+		Quorum:    true,
+	}
+	res, err := r.kAPI.Get(context.Background(), inc.Key, opts)
+	if err != nil {
+		if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			err = nil
+		}
+		return err
+	}
+	if treeIndex(res.Node) != inc.Index {
+		return fmt.Errorf("%s changed since it was checked", inc.Key)
+	}
+	return r.deleteTree(res.Node)
+}
+
+// deleteTree removes every key of the tree under the index it was read at,
+// and then the emptied directories, so that keys written meanwhile are kept.
+func (r *EtcdRegistry) deleteTree(dir *etcd.Node) error {
+	for _, node := range dir.Nodes {
+		var err error
+		if node.Dir {
+			err = r.deleteTree(node)
+		} else {
+			opts := &etcd.DeleteOptions{
+				PrevIndex: node.ModifiedIndex,
+			}
+			_, err = r.kAPI.Delete(context.Background(), node.Key, opts)
+		}
+		if err != nil && !isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			return err
+		}
+	}
+	_, err := r.kAPI.Delete(context.Background(), dir.Key, &etcd.DeleteOptions{Dir: true})
+	if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+		err = nil
+	}
+	return err
+}
+
+// treeIndex returns the highest modification index in the tree of the node.
+func treeIndex(node *etcd.Node) uint64 {
+	index := node.ModifiedIndex
+	for _, child := range node.Nodes {
+		if i := treeIndex(child); i > index {
+			index = i
+		}
+	}
+	return index
+}
+
+type inconsistencies []Inconsistency
+
+func (is inconsistencies) Len() int           { return len(is) }
+func (is inconsistencies) Less(i, j int) bool { return is[i].Key < is[j].Key }
+func (is inconsistencies) Swap(i, j int)      { is[i], is[j] = is[j], is[i] }
@@ -0,0 +1,117 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/machine"
+	"github.com/nickswift/fleet/unit"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	kAPI, done := newLocalKeysAPI(t)
+	defer done()
+	reg := NewEtcdRegistry(kAPI, "/fleet/")
+	ctx := context.Background()
+
+	reg.CreateMachineState(machine.MachineState{ID: "m1"}, 0)
+	reg.CordonMachine("m3")
+
+	uf, _ := unit.NewUnitFile("[Service]\nExecStart=/bin/a")
+	for _, name := range []string{"a.service", "b.service", "c.service"} {
+		if err := reg.CreateUnit(&job.Unit{Name: name, Unit: *uf}); err != nil {
+			t.Fatalf("Received error while calling CreateUnit: %v", err)
+		}
+	}
+	reg.ScheduleUnit("a.service", "m1")
+	reg.ScheduleUnit("b.service", "m2")
+	for _, machID := range []string{"m2", "m1"} {
+		us := unit.NewUnitState("loaded", "active", "running", machID)
+		us.UnitHash = uf.Hash().String()
+		reg.SaveUnitState("a.service", us, 0)
+	}
+
+	// c.service refers to a unit file which was removed
+	missing, _ := unit.NewUnitFile("[Service]\nExecStart=/bin/c")
+	obj, _ := marshal(jobModel{Name: "c.service", UnitHash: missing.Hash()})
+	kAPI.Set(ctx, "/fleet/job/c.service/object", obj, nil)
+	// the target state of d.service outlived its object, while the
+	// heartbeat of e.service expires on its own
+	kAPI.Set(ctx, "/fleet/job/d.service/target-state", "launched", nil)
+	kAPI.Set(ctx, "/fleet/job/e.service/job-state", "m1", &etcd.SetOptions{TTL: time.Minute})
+	kAPI.Set(ctx, "/fleet/states/gone.service/m1", "{}", nil)
+	kAPI.Set(ctx, "/fleet/state/gone.service", "{}", nil)
+
+	found, err := reg.CheckConsistency()
+	if err != nil {
+		t.Fatalf("Received error while calling CheckConsistency: %v", err)
+	}
+	var keys []string
+	for _, inc := range found {
+		keys = append(keys, inc.Key)
+	}
+	want := []string{
+		"/fleet/job/b.service/target",
+		"/fleet/job/c.service",
+		"/fleet/job/d.service",
+		"/fleet/state/gone.service",
+		"/fleet/states/a.service/m2",
+		"/fleet/states/gone.service",
+	}
+	if !reflect.DeepEqual(want, keys) {
+		t.Fatalf("Expected inconsistencies of %v, got %v", want, found)
+	}
+
+	// b.service was rescheduled and d.service was stopped since they
+	// were checked
+	kAPI.Set(ctx, "/fleet/job/b.service/target", "m1", nil)
+	kAPI.Set(ctx, "/fleet/job/d.service/target-state", "inactive", nil)
+	for i, inc := range found {
+		err = reg.RepairInconsistency(inc)
+		if changed := i == 0 || i == 2; changed && err == nil {
+			t.Errorf("Expected changed %s not to be removed", inc.Key)
+		} else if !changed && err != nil {
+			t.Errorf("Received error while repairing %v: %v", inc, err)
+		}
+	}
+	if res, err := kAPI.Get(ctx, "/fleet/job/d.service/target-state", nil); err != nil || res.Node.Value != "inactive" {
+		t.Fatalf("Expected changed directory to be kept, got %v, %v", res, err)
+	}
+
+	if found, err = reg.CheckConsistency(); err != nil || len(found) != 1 || found[0].Key != "/fleet/job/d.service" {
+		t.Fatalf("Expected only the changed directory to be left, got %v, %v", found, err)
+	}
+	if err = reg.RepairInconsistency(found[0]); err != nil {
+		t.Errorf("Received error while repairing %v: %v", found[0], err)
+	}
+
+	if found, err = reg.CheckConsistency(); err != nil || len(found) != 0 {
+		t.Fatalf("Expected no inconsistencies after repair, got %v, %v", found, err)
+	}
+	units, err := reg.Units()
+	if err != nil || len(units) != 2 {
+		t.Fatalf("Expected valid units to be kept, got %v, %v", units, err)
+	}
+	if su, _ := reg.ScheduledUnit("a.service"); su == nil || su.TargetMachineID != "m1" {
+		t.Errorf("Expected schedule of a.service to be kept, got %v", su)
+	}
+}
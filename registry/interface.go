@@ -25,20 +25,43 @@ import (
 )
 
 type Registry interface {
+	AdmitGlobalUnit(name, hash, machID string) error
 	ClearUnitHeartbeat(name string)
+	CordonMachine(machID string) error
 	CreateMachineState(ms machine.MachineState, ttl time.Duration) (uint64, error)
 	CreateUnit(*job.Unit) error
+	ReplaceUnit(u *job.Unit, prevIndex uint64) error
 	DestroyUnit(string) error
+	DestroyUnitAt(name string, prevIndex uint64) error
+	DestroyUnitFile(hash string, idx uint64) error
+	UnitFile(hash unit.Hash) (*unit.UnitFile, error)
+	ClearUnitFailures(name string) error
+	HoldUnit(name string) error
+	RecordUnitEvent(name string, ev job.UnitEvent) error
+	RecordUnitFailure(name, machID string) error
+	UnitEvents(name string) ([]job.UnitEvent, error)
 	UnitHeartbeat(name, machID string, ttl time.Duration) error
 	Machines() ([]machine.MachineState, error)
+	MachineMetadata(machID string) (map[string]string, error)
+	SetMachineMetadata(machID, key, value string) error
+	DeleteMachineMetadata(machID, key string) error
 	RemoveMachineState(machID string) error
 	RemoveUnitState(jobName string) error
 	SaveUnitState(jobName string, unitState *unit.UnitState, ttl time.Duration)
 	ScheduleUnit(name, machID string) error
 	SetUnitTargetState(name string, state job.JobState) error
 	SetMachineState(ms machine.MachineState, ttl time.Duration) (uint64, error)
+	MachineTaints(machID string) (map[string]string, error)
+	SetMachineTaints(machID string, taints map[string]string) error
+	ReleaseUnit(name string) error
+	UncordonMachine(machID string) error
 	UnscheduleUnit(name, machID string) error
 
+	ReplicaSets() ([]job.ReplicaSet, error)
+	ReplicaSet(template string) (*job.ReplicaSet, error)
+	SetReplicaSet(rs job.ReplicaSet) error
+	DestroyReplicaSet(template string) error
+
 	IsRegistryReady() bool
 	UseEtcdRegistry() bool
 	UnitRegistry
@@ -50,6 +73,7 @@ type UnitRegistry interface {
 	Unit(name string) (*job.Unit, error)
 	Units() ([]job.Unit, error)
 	UnitStates() ([]*unit.UnitState, error)
+	UnitFiles() (map[string]uint64, error)
 }
 
 type ClusterRegistry interface {
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"path"
 	"sort"
+	"time"
 
 	etcd "github.com/coreos/etcd/client"
 	"golang.org/x/net/context"
@@ -29,9 +30,33 @@ import (
 )
 
 const (
-	jobPrefix = "job"
+	jobPrefix    = "job"
+	heldKey      = "held"
+	failedKey    = "failed-machines"
+	rolloutKey   = "rollout"
+	eventsKey    = "events"
+	revisionsKey = "revisions"
+
+	// maximum number of scheduling events kept for each Unit
+	maxUnitEvents = 50
+	// maximum number of unit file revisions kept for each Unit
+	maxUnitRevisions = 10
+	// maximum number of attempts to record a revision while the
+	// revisions are changed concurrently
+	maxRevisionAttempts = 10
+	// maximum number of attempts to record a scheduling event while the
+	// events are changed concurrently
+	maxEventAttempts = 10
 )
 
+// ErrUnitModified is returned by ReplaceUnit if the Unit was created,
+// replaced or destroyed since the given index.
+var ErrUnitModified = errors.New("unit was modified concurrently")
+
+// ErrPreconditionUnsupported is returned by conditional writes of Units to
+// registries which do not track the indexes of Units.
+var ErrPreconditionUnsupported = errors.New("registry is unable to enforce preconditions on units")
+
 // Schedule returns all ScheduledUnits known by fleet, ordered by name
 func (r *EtcdRegistry) Schedule() ([]job.ScheduledUnit, error) {
 	key := r.prefixed(jobPrefix)
@@ -182,10 +207,83 @@ func (r *EtcdRegistry) dirToUnit(dir *etcd.Node, unitHashLookupFunc func(unit.Ha
 		}
 		u.TargetState = ts
 	}
+	if err := setUnitRecords(u, dir); err != nil {
+		return nil, err
+	}
 
 	return u, nil
 }
 
+// setUnitRecords sets what is recorded about the Unit in its directory next
+// to its object: its hold, the Machines it failed on, its rollout and its
+// revisions.
+func setUnitRecords(u *job.Unit, dir *etcd.Node) error {
+	u.Held = getValueInDir(dir, heldKey) != ""
+	if failed := getValueInDir(dir, failedKey); failed != "" {
+		if err := unmarshal(failed, &u.FailedMachines); err != nil {
+			return fmt.Errorf("failed to parse Unit(%s) failed machines: %v", u.Name, err)
+		}
+	}
+	if rollout := getValueInDir(dir, rolloutKey); rollout != "" {
+		u.Rollout = &job.Rollout{}
+		if err := unmarshal(rollout, u.Rollout); err != nil {
+			return fmt.Errorf("failed to parse Unit(%s) rollout: %v", u.Name, err)
+		}
+	}
+	if revs := getValueInDir(dir, revisionsKey); revs != "" {
+		if err := unmarshal(revs, &u.Revisions); err != nil {
+			return fmt.Errorf("failed to parse Unit(%s) revisions: %v", u.Name, err)
+		}
+	}
+	return nil
+}
+
+// UnitRecords returns the Units with only what is recorded about them next
+// to their objects set, as by setUnitRecords, keyed by name. Unlike Units,
+// it does not read the unit files.
+func (r *EtcdRegistry) UnitRecords() (map[string]*job.Unit, error) {
+	key := r.prefixed(jobPrefix)
+	opts := &etcd.GetOptions{
+		Recursive: true,
+	}
+	res, err := r.kAPI.Get(context.Background(), key, opts)
+	if err != nil {
+		if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			err = nil
+		}
+		return nil, err
+	}
+
+	units := make(map[string]*job.Unit)
+	for _, dir := range res.Node.Nodes {
+		u := &job.Unit{Name: path.Base(dir.Key)}
+		if err := setUnitRecords(u, dir); err != nil {
+			return nil, err
+		}
+		units[u.Name] = u
+	}
+	return units, nil
+}
+
+// UnitRecord is UnitRecords for the Unit of the given name. Returns nil if
+// no such Unit exists.
+func (r *EtcdRegistry) UnitRecord(name string) (*job.Unit, error) {
+	key := r.prefixed(jobPrefix, name)
+	res, err := r.kAPI.Get(context.Background(), key, nil)
+	if err != nil {
+		if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			err = nil
+		}
+		return nil, err
+	}
+
+	u := &job.Unit{Name: name}
+	if err := setUnitRecords(u, res.Node); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
 // ScheduledUnit retrieves the ScheduledUnit by the given name from the Registry.
 // Returns nil if no such ScheduledUnit exists, and any error encountered.
 func (r *EtcdRegistry) ScheduledUnit(name string) (*job.ScheduledUnit, error) {
@@ -278,8 +376,9 @@ func (r *EtcdRegistry) getUnitFromObjectNode(node *etcd.Node, unitHashLookupFunc
 	}
 
 	ju := &job.Unit{
-		Name: jm.Name,
-		Unit: *unit,
+		Name:  jm.Name,
+		Unit:  *unit,
+		Index: node.ModifiedIndex,
 	}
 	return ju, nil
 
@@ -307,12 +406,92 @@ func (r *EtcdRegistry) DestroyUnit(name string) error {
 		return err
 	}
 
-	// TODO(jonboulle): add unit reference counting and actually destroying Units
+	// the unit file is left to the unit file collector of the engine, as
+	// other Units may refer to it
+	return nil
+}
+
+// DestroyUnitAt removes the Unit like DestroyUnit, but only if it was last
+// created or replaced at prevIndex. Otherwise ErrUnitModified is returned.
+// Removing the object of the Unit is the atomic step: the other keys of the
+// Unit are removed afterwards, unless they changed in the meantime.
+func (r *EtcdRegistry) DestroyUnitAt(name string, prevIndex uint64) error {
+	key := r.prefixed(jobPrefix, name)
+	opts := &etcd.GetOptions{
+		Recursive: true,
+		Quorum:    true,
+	}
+	res, err := r.kAPI.Get(context.Background(), key, opts)
+	if err != nil {
+		if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			err = ErrUnitModified
+		}
+		return err
+	}
+
+	objKey := path.Join(key, "object")
+	delOpts := &etcd.DeleteOptions{
+		PrevIndex: prevIndex,
+	}
+	_, err = r.kAPI.Delete(context.Background(), objKey, delOpts)
+	if err != nil {
+		if isEtcdError(err, etcd.ErrorCodeTestFailed) || isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			err = ErrUnitModified
+		}
+		return err
+	}
+
+	for _, node := range res.Node.Nodes {
+		if node.Key == objKey {
+			continue
+		}
+		opts := &etcd.DeleteOptions{
+			PrevIndex: node.ModifiedIndex,
+		}
+		if node.Dir {
+			opts = &etcd.DeleteOptions{Recursive: true}
+		}
+		if _, err := r.kAPI.Delete(context.Background(), node.Key, opts); err != nil && !isEtcdError(err, etcd.ErrorCodeKeyNotFound) && !isEtcdError(err, etcd.ErrorCodeTestFailed) {
+			log.Warningf("Failed removing %s of destroyed Unit(%s): %v", node.Key, name, err)
+		}
+	}
+	// etcd keeps empty directories
+	r.kAPI.Delete(context.Background(), key, &etcd.DeleteOptions{Dir: true})
+
 	return nil
 }
 
 // CreateUnit attempts to store a Unit and its associated unit file in the registry
 func (r *EtcdRegistry) CreateUnit(u *job.Unit) error {
+	opts := &etcd.SetOptions{
+		// Since we support replacing units, just ignore previous
+		// job keys if they exist, this allows us to update the
+		// job object key with a new unit.
+		PrevExist: etcd.PrevIgnore,
+	}
+	return r.storeUnit(u, opts)
+}
+
+// ReplaceUnit stores the Unit like CreateUnit, but only if the stored Unit
+// was last created or replaced at prevIndex. A prevIndex of 0 requires that
+// the Unit does not exist yet. Otherwise ErrUnitModified is returned.
+func (r *EtcdRegistry) ReplaceUnit(u *job.Unit, prevIndex uint64) error {
+	opts := &etcd.SetOptions{
+		PrevIndex: prevIndex,
+	}
+	if prevIndex == 0 {
+		opts.PrevExist = etcd.PrevNoExist
+	}
+	err := r.storeUnit(u, opts)
+	if isEtcdError(err, etcd.ErrorCodeTestFailed) || isEtcdError(err, etcd.ErrorCodeNodeExist) || isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+		err = ErrUnitModified
+	}
+	return err
+}
+
+// storeUnit stores the Unit and its unit file, writing the job object key
+// with the given options.
+func (r *EtcdRegistry) storeUnit(u *job.Unit, opts *etcd.SetOptions) error {
 	if err := r.storeOrGetUnitFile(u.Unit); err != nil {
 		return err
 	}
@@ -326,27 +505,261 @@ func (r *EtcdRegistry) CreateUnit(u *job.Unit) error {
 		return err
 	}
 
-	opts := &etcd.SetOptions{
-		// Since we support replacing units, just ignore previous
-		// job keys if they exist, this allows us to update the
-		// job object key with a new unit.
-		PrevExist: etcd.PrevIgnore,
+	// the revision is recorded first, so that a stored Unit always has
+	// its unit file among its revisions
+	undo, err := r.recordUnitRevision(u)
+	if err != nil {
+		return err
 	}
+
 	key := r.prefixed(jobPrefix, u.Name, "object")
 	_, err = r.kAPI.Set(context.Background(), key, val, opts)
 	if err != nil {
+		undo()
 		return err
 	}
 
 	return r.SetUnitTargetState(u.Name, u.TargetState)
 }
 
+// recordUnitRevision adds the unit file of the Unit to its revisions, unless
+// it is the latest revision already. The unit files of older revisions stay
+// stored, as the unit file collector keeps those which are referenced by a
+// revision. Concurrent changes of the revisions are retried, so that none is
+// lost. The returned function undoes the change, unless the revisions were
+// changed again since.
+func (r *EtcdRegistry) recordUnitRevision(u *job.Unit) (undo func(), err error) {
+	key := r.prefixed(jobPrefix, u.Name, revisionsKey)
+	hash := u.Unit.Hash()
+	for attempt := 0; attempt < maxRevisionAttempts; attempt++ {
+		var revs []job.UnitRevision
+		var prev *etcd.Node
+		res, err := r.kAPI.Get(context.Background(), key, &etcd.GetOptions{Quorum: true})
+		if err == nil {
+			prev = res.Node
+			if err = unmarshal(prev.Value, &revs); err != nil {
+				return nil, err
+			}
+		} else if !isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			return nil, err
+		}
+
+		if len(revs) > 0 && revs[len(revs)-1].UnitHash == hash {
+			return func() {}, nil
+		}
+		revs = job.AppendUnitRevision(revs, hash, time.Now(), u.ChangeCause, maxUnitRevisions)
+
+		val, err := marshal(revs)
+		if err != nil {
+			return nil, err
+		}
+		opts := &etcd.SetOptions{
+			PrevExist: etcd.PrevNoExist,
+		}
+		if prev != nil {
+			opts = &etcd.SetOptions{PrevIndex: prev.ModifiedIndex}
+		}
+		res, err = r.kAPI.Set(context.Background(), key, val, opts)
+		if isEtcdError(err, etcd.ErrorCodeTestFailed) || isEtcdError(err, etcd.ErrorCodeNodeExist) || isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		idx := res.Node.ModifiedIndex
+		return func() {
+			var err error
+			if prev == nil {
+				_, err = r.kAPI.Delete(context.Background(), key, &etcd.DeleteOptions{PrevIndex: idx})
+			} else {
+				_, err = r.kAPI.Set(context.Background(), key, prev.Value, &etcd.SetOptions{PrevIndex: idx})
+			}
+			if err != nil {
+				log.Warningf("Failed undoing revision of Unit(%s): %v", u.Name, err)
+			}
+		}, nil
+	}
+	return nil, fmt.Errorf("revisions of Unit(%s) changed concurrently %d times", u.Name, maxRevisionAttempts)
+}
+
 func (r *EtcdRegistry) SetUnitTargetState(name string, state job.JobState) error {
 	key := r.jobTargetStatePath(name)
 	_, err := r.kAPI.Set(context.Background(), key, string(state), nil)
 	return err
 }
 
+// HoldUnit prevents the given Unit from being started by the agent it is
+// scheduled to, which only loads it until the Unit is released.
+func (r *EtcdRegistry) HoldUnit(name string) error {
+	key := r.prefixed(jobPrefix, name, heldKey)
+	_, err := r.kAPI.Set(context.Background(), key, "true", nil)
+	return err
+}
+
+// ReleaseUnit allows a held Unit to be started again.
+func (r *EtcdRegistry) ReleaseUnit(name string) error {
+	key := r.prefixed(jobPrefix, name, heldKey)
+	_, err := r.kAPI.Delete(context.Background(), key, nil)
+	if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+		err = nil
+	}
+	return err
+}
+
+// RecordUnitFailure adds the given Machine to the list of Machines the Unit
+// failed on and was moved away from.
+func (r *EtcdRegistry) RecordUnitFailure(name, machID string) error {
+	key := r.prefixed(jobPrefix, name, failedKey)
+	var failed []string
+	res, err := r.kAPI.Get(context.Background(), key, nil)
+	if err == nil {
+		if err = unmarshal(res.Node.Value, &failed); err != nil {
+			return err
+		}
+	} else if !isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+		return err
+	}
+
+	val, err := marshal(append(failed, machID))
+	if err != nil {
+		return err
+	}
+	_, err = r.kAPI.Set(context.Background(), key, val, nil)
+	return err
+}
+
+// ClearUnitFailures forgets the Machines the Unit failed on.
+func (r *EtcdRegistry) ClearUnitFailures(name string) error {
+	key := r.prefixed(jobPrefix, name, failedKey)
+	_, err := r.kAPI.Delete(context.Background(), key, nil)
+	if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+		err = nil
+	}
+	return err
+}
+
+// AdmitGlobalUnit allows the given Machine to run the version of the global
+// Unit with the given hash. Machines admitted for a previous version of the
+// Unit are forgotten.
+func (r *EtcdRegistry) AdmitGlobalUnit(name, hash, machID string) error {
+	key := r.prefixed(jobPrefix, name, rolloutKey)
+	var rollout job.Rollout
+	res, err := r.kAPI.Get(context.Background(), key, nil)
+	if err == nil {
+		if err = unmarshal(res.Node.Value, &rollout); err != nil {
+			return err
+		}
+	} else if !isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+		return err
+	}
+
+	if rollout.UnitHash != hash {
+		rollout = job.Rollout{UnitHash: hash}
+	}
+	rollout.Machines = append(rollout.Machines, machID)
+	val, err := marshal(rollout)
+	if err != nil {
+		return err
+	}
+	_, err = r.kAPI.Set(context.Background(), key, val, nil)
+	return err
+}
+
+// RecordUnitEvent adds the given scheduling event to the history of the
+// Unit, dropping the oldest events beyond maxUnitEvents. The event is
+// dropped if the Unit does not exist, so that its events do not outlive it
+// when it is destroyed concurrently.
+func (r *EtcdRegistry) RecordUnitEvent(name string, ev job.UnitEvent) error {
+	dirKey := r.prefixed(jobPrefix, name)
+	objKey := path.Join(dirKey, "object")
+	key := path.Join(dirKey, eventsKey)
+	for attempt := 0; attempt < maxEventAttempts; attempt++ {
+		res, err := r.kAPI.Get(context.Background(), dirKey, &etcd.GetOptions{Quorum: true})
+		if err != nil {
+			if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+				err = nil
+			}
+			return err
+		}
+
+		var obj, prev *etcd.Node
+		for _, node := range res.Node.Nodes {
+			switch node.Key {
+			case objKey:
+				obj = node
+			case key:
+				prev = node
+			}
+		}
+		if obj == nil {
+			return nil
+		}
+
+		var events []job.UnitEvent
+		opts := &etcd.SetOptions{
+			PrevExist: etcd.PrevNoExist,
+		}
+		if prev != nil {
+			if err = unmarshal(prev.Value, &events); err != nil {
+				return err
+			}
+			opts = &etcd.SetOptions{PrevIndex: prev.ModifiedIndex}
+		}
+
+		val, err := marshal(appendUnitEvent(events, ev))
+		if err != nil {
+			return err
+		}
+		res, err = r.kAPI.Set(context.Background(), key, val, opts)
+		if isEtcdError(err, etcd.ErrorCodeTestFailed) || isEtcdError(err, etcd.ErrorCodeNodeExist) || isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// the event may have been recorded while the Unit was being
+		// destroyed, in which case it is removed again
+		if _, err = r.kAPI.Get(context.Background(), objKey, &etcd.GetOptions{Quorum: true}); isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			if _, err := r.kAPI.Delete(context.Background(), key, &etcd.DeleteOptions{PrevIndex: res.Node.ModifiedIndex}); err != nil && !isEtcdError(err, etcd.ErrorCodeKeyNotFound) && !isEtcdError(err, etcd.ErrorCodeTestFailed) {
+				return err
+			}
+			r.kAPI.Delete(context.Background(), dirKey, &etcd.DeleteOptions{Dir: true})
+			return nil
+		}
+		return err
+	}
+	return fmt.Errorf("events of Unit(%s) changed concurrently %d times", name, maxEventAttempts)
+}
+
+// UnitEvents returns the scheduling events recorded for the Unit, oldest
+// first.
+func (r *EtcdRegistry) UnitEvents(name string) ([]job.UnitEvent, error) {
+	key := r.prefixed(jobPrefix, name, eventsKey)
+	res, err := r.kAPI.Get(context.Background(), key, nil)
+	if err != nil {
+		if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			err = nil
+		}
+		return nil, err
+	}
+
+	var events []job.UnitEvent
+	if err = unmarshal(res.Node.Value, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func appendUnitEvent(events []job.UnitEvent, ev job.UnitEvent) []job.UnitEvent {
+	events = append(events, ev)
+	if len(events) > maxUnitEvents {
+		events = events[len(events)-maxUnitEvents:]
+	}
+	return events
+}
+
 func (r *EtcdRegistry) ScheduleUnit(name string, machID string) error {
 	key := r.jobTargetAgentPath(name)
 	opts := &etcd.SetOptions{
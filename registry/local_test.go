@@ -0,0 +1,223 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/machine"
+	"github.com/nickswift/fleet/pkg/lease"
+	"github.com/nickswift/fleet/pkg/localkv"
+	"github.com/nickswift/fleet/unit"
+)
+
+func newLocalKeysAPI(t *testing.T) (etcd.KeysAPI, func()) {
+	dir, err := ioutil.TempDir("", "fleet-registry-")
+	if err != nil {
+		t.Fatalf("Received error while creating directory: %v", err)
+	}
+	kAPI, err := localkv.NewKeysAPI(filepath.Join(dir, "registry.json"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Received error while opening local registry: %v", err)
+	}
+	return kAPI, func() { os.RemoveAll(dir) }
+}
+
+func TestLocalRegistryUnitLifecycle(t *testing.T) {
+	kAPI, done := newLocalKeysAPI(t)
+	defer done()
+	testRegistryUnitLifecycle(t, NewEtcdRegistry(kAPI, DefaultKeyPrefix))
+}
+
+func TestLocalRegistryUnitEventsBounded(t *testing.T) {
+	kAPI, done := newLocalKeysAPI(t)
+	defer done()
+	testRegistryUnitEventsBounded(t, NewEtcdRegistry(kAPI, DefaultKeyPrefix))
+}
+
+func TestLocalRegistryReplaceUnit(t *testing.T) {
+	kAPI, done := newLocalKeysAPI(t)
+	defer done()
+	testRegistryReplaceUnit(t, NewEtcdRegistry(kAPI, DefaultKeyPrefix))
+}
+
+func TestLocalRegistryDestroyUnitAt(t *testing.T) {
+	kAPI, done := newLocalKeysAPI(t)
+	defer done()
+	testRegistryDestroyUnitAt(t, NewEtcdRegistry(kAPI, DefaultKeyPrefix))
+}
+
+// delayedKeysAPI delays reads, so that concurrent read-modify-write cycles
+// overlap.
+type delayedKeysAPI struct {
+	etcd.KeysAPI
+}
+
+func (k delayedKeysAPI) Get(ctx context.Context, key string, opts *etcd.GetOptions) (*etcd.Response, error) {
+	time.Sleep(10 * time.Millisecond)
+	return k.KeysAPI.Get(ctx, key, opts)
+}
+
+func TestLocalRegistryConcurrentRevisions(t *testing.T) {
+	kAPI, done := newLocalKeysAPI(t)
+	defer done()
+	testRegistryConcurrentRevisions(t, NewEtcdRegistry(delayedKeysAPI{kAPI}, DefaultKeyPrefix))
+}
+
+// beforeEventsKeysAPI calls a function right before each write of the
+// scheduling events of a Unit.
+type beforeEventsKeysAPI struct {
+	etcd.KeysAPI
+	before *func()
+}
+
+func (k beforeEventsKeysAPI) Set(ctx context.Context, key, value string, opts *etcd.SetOptions) (*etcd.Response, error) {
+	if path.Base(key) == eventsKey && *k.before != nil {
+		(*k.before)()
+	}
+	return k.KeysAPI.Set(ctx, key, value, opts)
+}
+
+func TestLocalRegistryUnitEventsOfDestroyedUnit(t *testing.T) {
+	kAPI, done := newLocalKeysAPI(t)
+	defer done()
+
+	// the unit is destroyed concurrently, both before its first event
+	// and before a later one is recorded
+	for _, first := range []bool{true, false} {
+		var before func()
+		reg := NewEtcdRegistry(beforeEventsKeysAPI{kAPI, &before}, DefaultKeyPrefix)
+
+		uf, _ := unit.NewUnitFile("[Service]\nExecStart=/bin/a")
+		if err := reg.CreateUnit(&job.Unit{Name: "u1.service", Unit: *uf}); err != nil {
+			t.Fatalf("Received error while calling CreateUnit: %v", err)
+		}
+		if !first {
+			reg.RecordUnitEvent("u1.service", job.UnitEvent{MachineID: "m0"})
+		}
+
+		before = func() {
+			before = nil
+			reg.DestroyUnit("u1.service")
+		}
+		if err := reg.RecordUnitEvent("u1.service", job.UnitEvent{MachineID: "m1"}); err != nil {
+			t.Fatalf("Received error while calling RecordUnitEvent: %v", err)
+		}
+		found, err := reg.CheckConsistency()
+		if err != nil {
+			t.Fatalf("Received error while calling CheckConsistency: %v", err)
+		}
+		if len(found) != 0 {
+			t.Errorf("Expected no keys left of the destroyed unit, got %v", found)
+		}
+	}
+}
+
+func TestLocalRegistryEventStream(t *testing.T) {
+	kAPI, done := newLocalKeysAPI(t)
+	defer done()
+	reg := NewEtcdRegistry(kAPI, DefaultKeyPrefix)
+	es := NewEtcdEventStream(kAPI, DefaultKeyPrefix)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	evchan := es.Next(stop)
+
+	// give the watches the chance to start
+	time.Sleep(50 * time.Millisecond)
+	if err := reg.ScheduleUnit("u1.service", "XXX"); err != nil {
+		t.Fatalf("Received error while calling ScheduleUnit: %v", err)
+	}
+
+	select {
+	case ev := <-evchan:
+		if ev != JobTargetChangeEvent {
+			t.Errorf("Expected %v, got %v", JobTargetChangeEvent, ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected an event to be emitted")
+	}
+}
+
+func TestLocalRegistryEventStreamAfterHeartbeat(t *testing.T) {
+	kAPI, done := newLocalKeysAPI(t)
+	defer done()
+	reg := NewEtcdRegistry(kAPI, DefaultKeyPrefix)
+	es := NewEtcdEventStream(kAPI, DefaultKeyPrefix)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	evchan := es.Next(stop)
+
+	// the metadata change right after a heartbeat is not missed
+	time.Sleep(50 * time.Millisecond)
+	if _, err := reg.CreateMachineState(machine.MachineState{ID: "XXX"}, time.Minute); err != nil {
+		t.Fatalf("Received error while calling CreateMachineState: %v", err)
+	}
+	if err := reg.SetMachineMetadata("XXX", "region", "us-west"); err != nil {
+		t.Fatalf("Received error while calling SetMachineMetadata: %v", err)
+	}
+
+	select {
+	case ev := <-evchan:
+		if ev != MachineMetadataChangeEvent {
+			t.Errorf("Expected %v, got %v", MachineMetadataChangeEvent, ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected an event to be emitted")
+	}
+}
+
+func TestLocalRegistryLease(t *testing.T) {
+	kAPI, done := newLocalKeysAPI(t)
+	defer done()
+	mgr := lease.NewEtcdLeaseManager(kAPI, DefaultKeyPrefix)
+
+	l, err := mgr.AcquireLease("engine-leader", "XXX", 1, time.Minute)
+	if err != nil || l == nil {
+		t.Fatalf("Expected lease to be acquired, got %v, %v", l, err)
+	}
+	if other, err := mgr.AcquireLease("engine-leader", "YYY", 1, time.Minute); err != nil || other != nil {
+		t.Fatalf("Expected held lease not to be acquired, got %v, %v", other, err)
+	}
+	if stolen, err := mgr.StealLease("engine-leader", "YYY", 1, time.Minute, l.Index()+1); err == nil && stolen != nil {
+		t.Fatalf("Expected lease not to be stolen at wrong index")
+	}
+
+	if err = l.Renew(time.Minute); err != nil {
+		t.Fatalf("Received error while renewing lease: %v", err)
+	}
+	cur, err := mgr.GetLease("engine-leader")
+	if err != nil || cur == nil || cur.MachineID() != "XXX" || cur.Index() != l.Index() {
+		t.Fatalf("Expected renewed lease, got %v, %v", cur, err)
+	}
+
+	if err = l.Release(); err != nil {
+		t.Fatalf("Received error while releasing lease: %v", err)
+	}
+	if cur, err = mgr.GetLease("engine-leader"); err != nil || cur != nil {
+		t.Fatalf("Expected lease to be released, got %v, %v", cur, err)
+	}
+}
@@ -15,6 +15,7 @@
 package registry
 
 import (
+	"path"
 	"strings"
 	"time"
 
@@ -26,6 +27,9 @@ import (
 
 const (
 	machinePrefix = "machines"
+	cordonedKey   = "cordoned"
+	taintsKey     = "taints"
+	metadataKey   = "metadata"
 )
 
 func (r *EtcdRegistry) Machines() (machines []machine.MachineState, err error) {
@@ -44,18 +48,42 @@ func (r *EtcdRegistry) Machines() (machines []machine.MachineState, err error) {
 	}
 
 	for _, node := range resp.Node.Nodes {
+		var mach *machine.MachineState
+		cordoned := false
+		var taints map[string]string
+		var metadata map[string]string
 		for _, obj := range node.Nodes {
+			if strings.HasSuffix(obj.Key, "/"+cordonedKey) {
+				cordoned = true
+				continue
+			}
+			if strings.HasSuffix(obj.Key, "/"+taintsKey) {
+				err = unmarshal(obj.Value, &taints)
+				if err != nil {
+					return
+				}
+				continue
+			}
+			if strings.HasSuffix(obj.Key, "/"+metadataKey) {
+				metadata = metadataFromDir(obj)
+				continue
+			}
 			if !strings.HasSuffix(obj.Key, "/object") {
 				continue
 			}
 
-			var mach machine.MachineState
-			err = unmarshal(obj.Value, &mach)
+			mach = &machine.MachineState{}
+			err = unmarshal(obj.Value, mach)
 			if err != nil {
 				return
 			}
+		}
 
-			machines = append(machines, mach)
+		if mach != nil {
+			mach.Cordoned = cordoned
+			mach.Taints = machine.OverlayTaints(mach.Taints, taints)
+			mach.Metadata = machine.OverlayMetadata(mach.Metadata, metadata)
+			machines = append(machines, *mach)
 		}
 	}
 
@@ -117,3 +145,106 @@ func (r *EtcdRegistry) RemoveMachineState(machID string) error {
 	}
 	return err
 }
+
+// CordonMachine marks the given Machine as unschedulable, preventing new
+// units from being scheduled to it. The mark outlives the Machine's state,
+// so it persists across restarts of the Machine.
+func (r *EtcdRegistry) CordonMachine(machID string) error {
+	key := r.prefixed(machinePrefix, machID, cordonedKey)
+	_, err := r.kAPI.Set(context.Background(), key, "true", nil)
+	return err
+}
+
+// UncordonMachine makes the given Machine schedulable again.
+func (r *EtcdRegistry) UncordonMachine(machID string) error {
+	key := r.prefixed(machinePrefix, machID, cordonedKey)
+	_, err := r.kAPI.Delete(context.Background(), key, nil)
+	if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+		err = nil
+	}
+	return err
+}
+
+// SetMachineTaints replaces the taints of the given Machine set through the
+// API, which are added to those the Machine is configured with. Like the
+// cordon, they outlive the Machine's state. No taints remove them all.
+func (r *EtcdRegistry) SetMachineTaints(machID string, taints map[string]string) error {
+	key := r.prefixed(machinePrefix, machID, taintsKey)
+	if len(taints) == 0 {
+		_, err := r.kAPI.Delete(context.Background(), key, nil)
+		if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			err = nil
+		}
+		return err
+	}
+
+	val, err := marshal(taints)
+	if err != nil {
+		return err
+	}
+	_, err = r.kAPI.Set(context.Background(), key, val, nil)
+	return err
+}
+
+// MachineTaints returns the taints of the given Machine set through the API,
+// which are added to those the Machine is configured with.
+func (r *EtcdRegistry) MachineTaints(machID string) (map[string]string, error) {
+	key := r.prefixed(machinePrefix, machID, taintsKey)
+	res, err := r.kAPI.Get(context.Background(), key, nil)
+	if err != nil {
+		if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			err = nil
+		}
+		return nil, err
+	}
+
+	var taints map[string]string
+	if err := unmarshal(res.Node.Value, &taints); err != nil {
+		return nil, err
+	}
+	return taints, nil
+}
+
+// MachineMetadata returns the metadata of the given Machine set through the
+// API, which overlays the metadata the Machine is configured with.
+func (r *EtcdRegistry) MachineMetadata(machID string) (map[string]string, error) {
+	key := r.prefixed(machinePrefix, machID, metadataKey)
+	opts := &etcd.GetOptions{
+		Recursive: true,
+	}
+	res, err := r.kAPI.Get(context.Background(), key, opts)
+	if err != nil {
+		if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			err = nil
+		}
+		return nil, err
+	}
+	return metadataFromDir(res.Node), nil
+}
+
+// SetMachineMetadata sets a single metadata key of the given Machine. Like
+// the cordon, the metadata outlives the Machine's state.
+func (r *EtcdRegistry) SetMachineMetadata(machID, key, value string) error {
+	k := r.prefixed(machinePrefix, machID, metadataKey, key)
+	_, err := r.kAPI.Set(context.Background(), k, value, nil)
+	return err
+}
+
+// DeleteMachineMetadata removes a single metadata key of the given Machine
+// set through the API.
+func (r *EtcdRegistry) DeleteMachineMetadata(machID, key string) error {
+	k := r.prefixed(machinePrefix, machID, metadataKey, key)
+	_, err := r.kAPI.Delete(context.Background(), k, nil)
+	if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+		err = nil
+	}
+	return err
+}
+
+func metadataFromDir(dir *etcd.Node) map[string]string {
+	metadata := make(map[string]string, len(dir.Nodes))
+	for _, node := range dir.Nodes {
+		metadata[path.Base(node.Key)] = node.Value
+	}
+	return metadata
+}
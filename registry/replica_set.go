@@ -0,0 +1,102 @@
+// Copyright 2014 The fleet Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"errors"
+	"path"
+
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+
+	"github.com/nickswift/fleet/job"
+	"github.com/nickswift/fleet/log"
+)
+
+const (
+	replicaSetPrefix = "replica-set"
+)
+
+// ReplicaSets lists all ReplicaSets stored in the Registry, ordered by
+// template name.
+func (r *EtcdRegistry) ReplicaSets() ([]job.ReplicaSet, error) {
+	key := r.prefixed(replicaSetPrefix)
+	opts := &etcd.GetOptions{
+		Sort: true,
+	}
+	res, err := r.kAPI.Get(context.Background(), key, opts)
+	if err != nil {
+		if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			err = nil
+		}
+		return nil, err
+	}
+
+	rsets := make([]job.ReplicaSet, 0, len(res.Node.Nodes))
+	for _, node := range res.Node.Nodes {
+		var rs job.ReplicaSet
+		if err := unmarshal(node.Value, &rs); err != nil {
+			_, name := path.Split(node.Key)
+			log.Errorf("Failed to parse ReplicaSet(%s) from etcd: %v", name, err)
+			continue
+		}
+		rsets = append(rsets, rs)
+	}
+
+	return rsets, nil
+}
+
+// ReplicaSet retrieves the ReplicaSet of the given template from the
+// Registry. Returns nil if no such ReplicaSet exists, and any error
+// encountered.
+func (r *EtcdRegistry) ReplicaSet(template string) (*job.ReplicaSet, error) {
+	key := r.prefixed(replicaSetPrefix, template)
+	res, err := r.kAPI.Get(context.Background(), key, nil)
+	if err != nil {
+		if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			err = nil
+		}
+		return nil, err
+	}
+
+	var rs job.ReplicaSet
+	if err := unmarshal(res.Node.Value, &rs); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+// SetReplicaSet creates or replaces the ReplicaSet of a template.
+func (r *EtcdRegistry) SetReplicaSet(rs job.ReplicaSet) error {
+	val, err := marshal(rs)
+	if err != nil {
+		return err
+	}
+
+	key := r.prefixed(replicaSetPrefix, rs.Template)
+	_, err = r.kAPI.Set(context.Background(), key, val, nil)
+	return err
+}
+
+// DestroyReplicaSet removes the ReplicaSet of a template from the Registry.
+// The instances of the template are left untouched.
+func (r *EtcdRegistry) DestroyReplicaSet(template string) error {
+	key := r.prefixed(replicaSetPrefix, template)
+	_, err := r.kAPI.Delete(context.Background(), key, nil)
+	if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+		err = errors.New("replica set does not exist")
+	}
+	return err
+}
@@ -254,6 +254,10 @@ func (r *RegistryMux) CreateUnit(unit *job.Unit) error {
 	return r.getRegistry().CreateUnit(unit)
 }
 
+func (r *RegistryMux) ReplaceUnit(unit *job.Unit, prevIndex uint64) error {
+	return r.getRegistry().ReplaceUnit(unit, prevIndex)
+}
+
 func (r *RegistryMux) CreateMachineState(ms machine.MachineState, ttl time.Duration) (uint64, error) {
 	return r.getRegistry().CreateMachineState(ms, ttl)
 }
@@ -262,6 +266,10 @@ func (r *RegistryMux) DestroyUnit(unit string) error {
 	return r.getRegistry().DestroyUnit(unit)
 }
 
+func (r *RegistryMux) DestroyUnitAt(unit string, prevIndex uint64) error {
+	return r.getRegistry().DestroyUnitAt(unit, prevIndex)
+}
+
 func (r *RegistryMux) UnitHeartbeat(name string, machID string, ttl time.Duration) error {
 	return r.getRegistry().UnitHeartbeat(name, machID, ttl)
 }
@@ -274,6 +282,74 @@ func (r *RegistryMux) RemoveMachineState(machID string) error {
 	return r.etcdRegistry.RemoveMachineState(machID)
 }
 
+func (r *RegistryMux) MachineMetadata(machID string) (map[string]string, error) {
+	return r.etcdRegistry.MachineMetadata(machID)
+}
+
+func (r *RegistryMux) SetMachineMetadata(machID, key, value string) error {
+	return r.etcdRegistry.SetMachineMetadata(machID, key, value)
+}
+
+func (r *RegistryMux) DeleteMachineMetadata(machID, key string) error {
+	return r.etcdRegistry.DeleteMachineMetadata(machID, key)
+}
+
+func (r *RegistryMux) MachineTaints(machID string) (map[string]string, error) {
+	return r.etcdRegistry.MachineTaints(machID)
+}
+
+func (r *RegistryMux) SetMachineTaints(machID string, taints map[string]string) error {
+	return r.etcdRegistry.SetMachineTaints(machID, taints)
+}
+
+func (r *RegistryMux) CordonMachine(machID string) error {
+	return r.etcdRegistry.CordonMachine(machID)
+}
+
+func (r *RegistryMux) UncordonMachine(machID string) error {
+	return r.etcdRegistry.UncordonMachine(machID)
+}
+
+func (r *RegistryMux) AdmitGlobalUnit(name, hash, machID string) error {
+	return r.etcdRegistry.AdmitGlobalUnit(name, hash, machID)
+}
+
+func (r *RegistryMux) UnitFiles() (map[string]uint64, error) {
+	return r.etcdRegistry.UnitFiles()
+}
+
+func (r *RegistryMux) DestroyUnitFile(hash string, idx uint64) error {
+	return r.etcdRegistry.DestroyUnitFile(hash, idx)
+}
+
+func (r *RegistryMux) UnitFile(hash unit.Hash) (*unit.UnitFile, error) {
+	return r.etcdRegistry.UnitFile(hash)
+}
+
+func (r *RegistryMux) RecordUnitEvent(name string, ev job.UnitEvent) error {
+	return r.etcdRegistry.RecordUnitEvent(name, ev)
+}
+
+func (r *RegistryMux) UnitEvents(name string) ([]job.UnitEvent, error) {
+	return r.etcdRegistry.UnitEvents(name)
+}
+
+func (r *RegistryMux) RecordUnitFailure(name, machID string) error {
+	return r.etcdRegistry.RecordUnitFailure(name, machID)
+}
+
+func (r *RegistryMux) ClearUnitFailures(name string) error {
+	return r.etcdRegistry.ClearUnitFailures(name)
+}
+
+func (r *RegistryMux) HoldUnit(name string) error {
+	return r.etcdRegistry.HoldUnit(name)
+}
+
+func (r *RegistryMux) ReleaseUnit(name string) error {
+	return r.etcdRegistry.ReleaseUnit(name)
+}
+
 func (r *RegistryMux) RemoveUnitState(jobName string) error {
 	return r.getRegistry().RemoveUnitState(jobName)
 }
@@ -298,6 +374,22 @@ func (r *RegistryMux) UnscheduleUnit(name string, machID string) error {
 	return r.getRegistry().UnscheduleUnit(name, machID)
 }
 
+func (r *RegistryMux) ReplicaSets() ([]job.ReplicaSet, error) {
+	return r.etcdRegistry.ReplicaSets()
+}
+
+func (r *RegistryMux) ReplicaSet(template string) (*job.ReplicaSet, error) {
+	return r.etcdRegistry.ReplicaSet(template)
+}
+
+func (r *RegistryMux) SetReplicaSet(rs job.ReplicaSet) error {
+	return r.etcdRegistry.SetReplicaSet(rs)
+}
+
+func (r *RegistryMux) DestroyReplicaSet(template string) error {
+	return r.etcdRegistry.DestroyReplicaSet(template)
+}
+
 func (r *RegistryMux) Schedule() ([]job.ScheduledUnit, error) {
 	return r.getRegistry().Schedule()
 }
@@ -306,12 +398,53 @@ func (r *RegistryMux) ScheduledUnit(name string) (*job.ScheduledUnit, error) {
 	return r.getRegistry().ScheduledUnit(name)
 }
 
+// Unit returns the Unit from the current registry. The in-memory registry
+// of a gRPC engine only keeps unit files and target states, so what the
+// engine records about the Unit is read from etcd then.
 func (r *RegistryMux) Unit(name string) (*job.Unit, error) {
-	return r.getRegistry().Unit(name)
+	reg := r.getRegistry()
+	u, err := reg.Unit(name)
+	if err != nil || u == nil || reg == r.etcdRegistry {
+		return u, err
+	}
+
+	rec, err := r.etcdRegistry.UnitRecord(name)
+	if err != nil {
+		return nil, err
+	}
+	if rec != nil {
+		copyUnitRecords(u, rec)
+	}
+	return u, nil
 }
 
+// Units returns the Units from the current registry, with what the engine
+// records about them read from etcd, like Unit.
 func (r *RegistryMux) Units() ([]job.Unit, error) {
-	return r.getRegistry().Units()
+	reg := r.getRegistry()
+	units, err := reg.Units()
+	if err != nil || len(units) == 0 || reg == r.etcdRegistry {
+		return units, err
+	}
+
+	recs, err := r.etcdRegistry.UnitRecords()
+	if err != nil {
+		return nil, err
+	}
+	for i := range units {
+		if rec, ok := recs[units[i].Name]; ok {
+			copyUnitRecords(&units[i], rec)
+		}
+	}
+	return units, nil
+}
+
+// copyUnitRecords copies what is recorded about a Unit in etcd from rec.
+func copyUnitRecords(u, rec *job.Unit) {
+	u.Held = rec.Held
+	u.FailedMachines = rec.FailedMachines
+	u.Rollout = rec.Rollout
+	u.Revisions = rec.Revisions
 }
 
 func (r *RegistryMux) UnitStates() ([]*unit.UnitState, error) {
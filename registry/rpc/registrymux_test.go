@@ -15,9 +15,15 @@
 package rpc
 
 import (
+	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	etcd "github.com/coreos/etcd/client"
 	"golang.org/x/net/context"
@@ -25,6 +31,7 @@ import (
 	"github.com/nickswift/fleet/job"
 	"github.com/nickswift/fleet/machine"
 	"github.com/nickswift/fleet/pkg/lease"
+	"github.com/nickswift/fleet/pkg/localkv"
 	"github.com/nickswift/fleet/registry"
 	"github.com/nickswift/fleet/systemd"
 	"github.com/nickswift/fleet/unit"
@@ -145,3 +152,69 @@ Description = Foo
 		t.Fatalf("unexpected error removing machine state: %v", err)
 	}
 }
+
+func TestRegistryMuxUnitRecordsOverGRPC(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fleet-")
+	if err != nil {
+		t.Fatalf("failed creating tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	kAPI, err := localkv.NewKeysAPI(filepath.Join(dir, "registry.json"))
+	if err != nil {
+		t.Fatalf("unexpected error opening local registry: %v", err)
+	}
+	etcdReg := registry.NewEtcdRegistry(kAPI, "/fleet/")
+
+	srv, err := NewRPCServer(etcdReg, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("unexpected error creating rpc server: %v", err)
+	}
+	go srv.Start()
+	defer srv.Stop()
+
+	rpcReg := NewRPCRegistry(func(_ string, timeout time.Duration) (net.Conn, error) {
+		return net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", rpcServerPort), timeout)
+	})
+	rpcReg.Connect()
+	defer rpcReg.Close()
+	reg := &RegistryMux{
+		etcdRegistry:         etcdReg,
+		currentRegistry:      rpcReg,
+		rpcRegistry:          rpcReg,
+		handlingEngineChange: new(sync.RWMutex),
+	}
+
+	uf, err := unit.NewUnitFile("[Service]\nExecStart=/bin/true")
+	if err != nil {
+		t.Fatalf("unexpected error parsing unit: %v", err)
+	}
+	name := "hello.service"
+	if err := reg.CreateUnit(&job.Unit{Name: name, Unit: *uf, TargetState: job.JobStateLaunched}); err != nil {
+		t.Fatalf("unexpected error creating unit: %v", err)
+	}
+	if err := reg.HoldUnit(name); err != nil {
+		t.Fatalf("unexpected error holding unit: %v", err)
+	}
+	if err := reg.RecordUnitFailure(name, "m1"); err != nil {
+		t.Fatalf("unexpected error recording failure: %v", err)
+	}
+	if err := reg.AdmitGlobalUnit(name, uf.Hash().String(), "m2"); err != nil {
+		t.Fatalf("unexpected error admitting unit: %v", err)
+	}
+
+	// the engine keeps neither holds, failures, rollouts nor revisions in
+	// memory
+	if u, err := rpcReg.Unit(name); err != nil || u == nil || u.Held {
+		t.Fatalf("expected unit without hold from rpc registry, got %v, %v", u, err)
+	}
+
+	u, err := reg.Unit(name)
+	if err != nil || u == nil || !u.Held || !reflect.DeepEqual(u.FailedMachines, []string{"m1"}) || !u.RolloutAdmits("m2") || len(u.Revisions) != 1 {
+		t.Errorf("expected held unit with one revision which failed on m1 and is admitted to m2, got %#v, %v", u, err)
+	}
+	units, err := reg.Units()
+	if err != nil || len(units) != 1 || !units[0].Held || !reflect.DeepEqual(units[0].FailedMachines, []string{"m1"}) || !units[0].RolloutAdmits("m2") || len(units[0].Revisions) != 1 {
+		t.Errorf("expected held unit with one revision which failed on m1 and is admitted to m2, got %#v, %v", units, err)
+	}
+}
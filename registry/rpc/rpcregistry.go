@@ -29,6 +29,7 @@ import (
 	"github.com/nickswift/fleet/log"
 	"github.com/nickswift/fleet/machine"
 	pb "github.com/nickswift/fleet/protobuf"
+	"github.com/nickswift/fleet/registry"
 	"github.com/nickswift/fleet/unit"
 )
 
@@ -43,6 +44,11 @@ const (
 
 var DebugRPCRegistry bool = false
 
+// errEtcdOnly is returned for the data which the engine does not keep in
+// memory, such as machine metadata, unit events and replica sets.
+// RegistryMux reads and writes it through the etcd registry instead.
+var errEtcdOnly = errors.New("only available through the etcd registry")
+
 type RPCRegistry struct {
 	dialer         func(addr string, timeout time.Duration) (net.Conn, error)
 	mu             *sync.Mutex
@@ -161,6 +167,29 @@ func (r *RPCRegistry) CreateUnit(j *job.Unit) error {
 	return err
 }
 
+// ReplaceUnit stores the Unit like CreateUnit. The in-memory registry does
+// not track indexes, so only creating a Unit which does not exist yet, with a
+// prevIndex of 0, is supported.
+func (r *RPCRegistry) ReplaceUnit(j *job.Unit, prevIndex uint64) error {
+	if prevIndex != 0 {
+		return registry.ErrPreconditionUnsupported
+	}
+	u, err := r.Unit(j.Name)
+	if err != nil {
+		return err
+	}
+	if u != nil {
+		return registry.ErrUnitModified
+	}
+	return r.CreateUnit(j)
+}
+
+// DestroyUnitAt is unsupported, as the in-memory registry does not track
+// indexes.
+func (r *RPCRegistry) DestroyUnitAt(unitName string, prevIndex uint64) error {
+	return registry.ErrPreconditionUnsupported
+}
+
 func (r *RPCRegistry) DestroyUnit(unitName string) error {
 	if DebugRPCRegistry {
 		defer debug.Exit_(debug.Enter_(unitName))
@@ -187,6 +216,74 @@ func (r *RPCRegistry) RemoveMachineState(machID string) error {
 	return errors.New("Remove machine state function not implemented")
 }
 
+func (r *RPCRegistry) MachineMetadata(machID string) (map[string]string, error) {
+	return nil, errEtcdOnly
+}
+
+func (r *RPCRegistry) SetMachineMetadata(machID, key, value string) error {
+	return errEtcdOnly
+}
+
+func (r *RPCRegistry) DeleteMachineMetadata(machID, key string) error {
+	return errEtcdOnly
+}
+
+func (r *RPCRegistry) MachineTaints(machID string) (map[string]string, error) {
+	return nil, errEtcdOnly
+}
+
+func (r *RPCRegistry) SetMachineTaints(machID string, taints map[string]string) error {
+	return errEtcdOnly
+}
+
+func (r *RPCRegistry) CordonMachine(machID string) error {
+	return errEtcdOnly
+}
+
+func (r *RPCRegistry) UncordonMachine(machID string) error {
+	return errEtcdOnly
+}
+
+func (r *RPCRegistry) AdmitGlobalUnit(name, hash, machID string) error {
+	return errEtcdOnly
+}
+
+func (r *RPCRegistry) UnitFiles() (map[string]uint64, error) {
+	return nil, errEtcdOnly
+}
+
+func (r *RPCRegistry) DestroyUnitFile(hash string, idx uint64) error {
+	return errEtcdOnly
+}
+
+func (r *RPCRegistry) UnitFile(hash unit.Hash) (*unit.UnitFile, error) {
+	return nil, errEtcdOnly
+}
+
+func (r *RPCRegistry) RecordUnitEvent(name string, ev job.UnitEvent) error {
+	return errEtcdOnly
+}
+
+func (r *RPCRegistry) UnitEvents(name string) ([]job.UnitEvent, error) {
+	return nil, errEtcdOnly
+}
+
+func (r *RPCRegistry) RecordUnitFailure(name, machID string) error {
+	return errEtcdOnly
+}
+
+func (r *RPCRegistry) ClearUnitFailures(name string) error {
+	return errEtcdOnly
+}
+
+func (r *RPCRegistry) HoldUnit(name string) error {
+	return errEtcdOnly
+}
+
+func (r *RPCRegistry) ReleaseUnit(name string) error {
+	return errEtcdOnly
+}
+
 func (r *RPCRegistry) RemoveUnitState(unitName string) error {
 	_, err := r.getClient().RemoveUnitState(r.ctx(), &pb.UnitName{unitName})
 	return err
@@ -256,6 +353,22 @@ func (r *RPCRegistry) CreateMachineState(ms machine.MachineState, ttl time.Durat
 	panic("Set machine state function not implemented")
 }
 
+func (r *RPCRegistry) ReplicaSets() ([]job.ReplicaSet, error) {
+	return nil, errEtcdOnly
+}
+
+func (r *RPCRegistry) ReplicaSet(template string) (*job.ReplicaSet, error) {
+	return nil, errEtcdOnly
+}
+
+func (r *RPCRegistry) SetReplicaSet(rs job.ReplicaSet) error {
+	return errEtcdOnly
+}
+
+func (r *RPCRegistry) DestroyReplicaSet(template string) error {
+	return errEtcdOnly
+}
+
 func (r *RPCRegistry) Schedule() ([]job.ScheduledUnit, error) {
 	if DebugRPCRegistry {
 		defer debug.Exit_(debug.Enter_())
@@ -302,6 +415,8 @@ func (r *RPCRegistry) ScheduledUnit(unitName string) (*job.ScheduledUnit, error)
 
 }
 
+// Unit returns the Unit as known to the engine, which only keeps its unit
+// file and target state. RegistryMux adds what is recorded about it in etcd.
 func (r *RPCRegistry) Unit(unitName string) (*job.Unit, error) {
 	if DebugRPCRegistry {
 		defer debug.Exit_(debug.Enter_(unitName))
@@ -319,6 +434,8 @@ func (r *RPCRegistry) Unit(unitName string) (*job.Unit, error) {
 	return nil, nil
 }
 
+// Units returns the Units known to the engine, with only their unit files
+// and target states, like Unit.
 func (r *RPCRegistry) Units() ([]job.Unit, error) {
 	if DebugRPCRegistry {
 		defer debug.Exit_(debug.Enter_())
@@ -15,6 +15,7 @@
 package registry
 
 import (
+	"path"
 	"strings"
 	"time"
 
@@ -30,6 +31,9 @@ const (
 	unitPrefix = "/unit/"
 )
 
+// storeOrGetUnitFile stores the unit file by its hash. A unit file which is
+// already stored is written again, so that its index tells the unit file
+// collector that it may be about to be referenced.
 func (r *EtcdRegistry) storeOrGetUnitFile(u unit.UnitFile) (err error) {
 	um := unitModel{
 		Raw: u.String(),
@@ -41,16 +45,8 @@ func (r *EtcdRegistry) storeOrGetUnitFile(u unit.UnitFile) (err error) {
 	}
 
 	key := r.hashedUnitPath(u.Hash())
-	opts := &etcd.SetOptions{
-		PrevExist: etcd.PrevNoExist,
-	}
 	start := time.Now()
-	_, err = r.kAPI.Set(context.Background(), key, val, opts)
-	// unit is already stored
-	if isEtcdError(err, etcd.ErrorCodeNodeExist) {
-		// TODO(jonboulle): verify more here?
-		err = nil
-	}
+	_, err = r.kAPI.Set(context.Background(), key, val, nil)
 	if err != nil {
 		metrics.ReportRegistryOpFailure(metrics.Set)
 		return
@@ -59,6 +55,25 @@ func (r *EtcdRegistry) storeOrGetUnitFile(u unit.UnitFile) (err error) {
 	return
 }
 
+// UnitFile retrieves the unit file stored under the given hash, or nil if
+// no such unit file exists.
+func (r *EtcdRegistry) UnitFile(hash unit.Hash) (*unit.UnitFile, error) {
+	key := r.hashedUnitPath(hash)
+	res, err := r.kAPI.Get(context.Background(), key, nil)
+	if err != nil {
+		if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			err = nil
+		}
+		return nil, err
+	}
+
+	var um unitModel
+	if err := unmarshal(res.Node.Value, &um); err != nil {
+		return nil, err
+	}
+	return unit.NewUnitFile(um.Raw)
+}
+
 // getUnitByHash retrieves from the Registry the Unit associated with the given Hash
 func (r *EtcdRegistry) getUnitByHash(hash unit.Hash) *unit.UnitFile {
 	key := r.hashedUnitPath(hash)
@@ -116,6 +131,42 @@ func (r *EtcdRegistry) getAllUnitsHashMap() (map[string]*unit.UnitFile, error) {
 	return hashToUnit, nil
 }
 
+// UnitFiles returns the hashes of all unit files stored in the Registry,
+// each with the index at which it was last stored.
+func (r *EtcdRegistry) UnitFiles() (map[string]uint64, error) {
+	key := r.prefixed(unitPrefix)
+	opts := &etcd.GetOptions{
+		Recursive: true,
+		Quorum:    true,
+	}
+	resp, err := r.kAPI.Get(context.Background(), key, opts)
+	if err != nil {
+		if isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+			err = nil
+		}
+		return map[string]uint64{}, err
+	}
+
+	files := make(map[string]uint64, len(resp.Node.Nodes))
+	for _, node := range resp.Node.Nodes {
+		files[path.Base(node.Key)] = node.ModifiedIndex
+	}
+	return files, nil
+}
+
+// DestroyUnitFile removes the unit file with the given hash, unless it was
+// stored again after the given index, e.g. for a Unit being created.
+func (r *EtcdRegistry) DestroyUnitFile(hash string, idx uint64) error {
+	opts := &etcd.DeleteOptions{
+		PrevIndex: idx,
+	}
+	_, err := r.kAPI.Delete(context.Background(), r.prefixed(unitPrefix, hash), opts)
+	if isEtcdError(err, etcd.ErrorCodeTestFailed) || isEtcdError(err, etcd.ErrorCodeKeyNotFound) {
+		err = nil
+	}
+	return err
+}
+
 func (r *EtcdRegistry) unitFromEtcdNode(hash unit.Hash, etcdNode *etcd.Node) *unit.UnitFile {
 	var um unitModel
 	if err := unmarshal(etcdNode.Value, &um); err != nil {
@@ -14,6 +14,10 @@
 
 package resource
 
+import (
+	"fmt"
+)
+
 // ResourceTuple groups together CPU, memory and disk space. This could be
 // total, available or consumed. It could also be used by job resource requirements.
 type ResourceTuple struct {
@@ -30,6 +34,28 @@ func (rt ResourceTuple) Empty() bool {
 	return rt.Cores == 0 && rt.Memory == 0 && rt.Disk == 0
 }
 
+// String returns a human-readable representation of the ResourceTuple, with
+// Cores expressed in whole cores rather than hundredths.
+func (rt ResourceTuple) String() string {
+	return fmt.Sprintf("cores=%.2f memory=%dMB disk=%dMB", float64(rt.Cores)/100, rt.Memory, rt.Disk)
+}
+
+// Fits returns true if every component of the ResourceTuple is no larger
+// than the corresponding component of the available ResourceTuple. Components
+// that are not requested (i.e. zero) are always considered to fit.
+func (rt ResourceTuple) Fits(available ResourceTuple) bool {
+	if rt.Cores > 0 && rt.Cores > available.Cores {
+		return false
+	}
+	if rt.Memory > 0 && rt.Memory > available.Memory {
+		return false
+	}
+	if rt.Disk > 0 && rt.Disk > available.Disk {
+		return false
+	}
+	return true
+}
+
 const (
 	// TODO(jonboulle): make these configurable
 	HostCores  = 100
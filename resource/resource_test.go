@@ -70,3 +70,47 @@ func TestSub(t *testing.T) {
 		}
 	}
 }
+
+func TestFits(t *testing.T) {
+	for i, tt := range []struct {
+		want      ResourceTuple
+		available ResourceTuple
+		fits      bool
+	}{
+		{
+			ResourceTuple{0, 0, 0},
+			ResourceTuple{0, 0, 0},
+			true,
+		},
+		{
+			ResourceTuple{100, 512, 0},
+			ResourceTuple{200, 1024, 0},
+			true,
+		},
+		{
+			ResourceTuple{100, 512, 0},
+			ResourceTuple{100, 512, -10},
+			true,
+		},
+		{
+			ResourceTuple{0, 2048, 0},
+			ResourceTuple{400, 1024, 4096},
+			false,
+		},
+		{
+			ResourceTuple{300, 0, 0},
+			ResourceTuple{200, 1024, 4096},
+			false,
+		},
+		{
+			ResourceTuple{0, 0, 10},
+			ResourceTuple{200, 1024, 0},
+			false,
+		},
+	} {
+		got := tt.want.Fits(tt.available)
+		if got != tt.fits {
+			t.Errorf("case %d: got %t, want %t", i, got, tt.fits)
+		}
+	}
+}
@@ -15,6 +15,8 @@
 package schema
 
 import (
+	"time"
+
 	gsunit "github.com/coreos/go-systemd/unit"
 
 	"github.com/nickswift/fleet/job"
@@ -83,10 +85,69 @@ func MapSchemaUnitsToUnits(entities []*Unit) []job.Unit {
 	return units
 }
 
+func MapReplicaSetToSchemaReplicaSet(rs *job.ReplicaSet) *ReplicaSet {
+	return &ReplicaSet{
+		Template: rs.Template,
+		Replicas: int64(rs.Replicas),
+	}
+}
+
+func MapSchemaReplicaSetToReplicaSet(entity *ReplicaSet) *job.ReplicaSet {
+	return &job.ReplicaSet{
+		Template: entity.Template,
+		Replicas: int(entity.Replicas),
+	}
+}
+
+func MapUnitEventsToSchemaUnitHistory(name string, events []job.UnitEvent) *UnitHistory {
+	sh := UnitHistory{
+		Name:   name,
+		Events: make([]*UnitEvent, len(events)),
+	}
+	for i, ev := range events {
+		sh.Events[i] = &UnitEvent{
+			Time:      ev.Time.UTC().Format(time.RFC3339),
+			MachineID: ev.MachineID,
+			Type:      ev.Type,
+			Reason:    ev.Reason,
+		}
+	}
+	return &sh
+}
+
+// MapUnitRevisionsToSchemaUnitRevisions maps the revisions of the Unit,
+// along with the unit files found for them by hash.
+func MapUnitRevisionsToSchemaUnitRevisions(u *job.Unit, files map[unit.Hash]*unit.UnitFile) *UnitRevisions {
+	sr := UnitRevisions{
+		Name:      u.Name,
+		Revisions: make([]*UnitRevision, len(u.Revisions)),
+	}
+	current := -1
+	for i, rev := range u.Revisions {
+		sr.Revisions[i] = &UnitRevision{
+			Revision: int64(rev.Revision),
+			UnitHash: rev.UnitHash.String(),
+			Time:     rev.Time.UTC().Format(time.RFC3339),
+			Cause:    rev.Cause,
+		}
+		if uf := files[rev.UnitHash]; uf != nil {
+			sr.Revisions[i].Options = MapUnitFileToSchemaUnitOptions(uf)
+		}
+		if rev.UnitHash == u.Unit.Hash() {
+			current = i
+		}
+	}
+	if current >= 0 {
+		sr.Revisions[current].Current = true
+	}
+	return &sr
+}
+
 func MapMachineStateToSchema(ms *machine.MachineState) *Machine {
 	sm := Machine{
 		Id:        ms.ID,
 		PrimaryIP: ms.PublicIP,
+		Cordoned:  ms.Cordoned,
 	}
 
 	sm.Metadata = make(map[string]string, len(ms.Metadata))
@@ -94,6 +155,13 @@ func MapMachineStateToSchema(ms *machine.MachineState) *Machine {
 		sm.Metadata[k] = v
 	}
 
+	if len(ms.Taints) > 0 {
+		sm.Taints = make(map[string]string, len(ms.Taints))
+		for k, v := range ms.Taints {
+			sm.Taints[k] = v
+		}
+	}
+
 	return &sm
 }
 
@@ -105,6 +173,7 @@ func MapSchemaToMachineStates(entities []*Machine) []machine.MachineState {
 		ms := machine.MachineState{
 			ID:       me.Id,
 			PublicIP: me.PrimaryIP,
+			Cordoned: me.Cordoned,
 		}
 
 		ms.Metadata = make(map[string]string, len(me.Metadata))
@@ -112,6 +181,13 @@ func MapSchemaToMachineStates(entities []*Machine) []machine.MachineState {
 			ms.Metadata[k] = v
 		}
 
+		if len(me.Taints) > 0 {
+			ms.Taints = make(map[string]string, len(me.Taints))
+			for k, v := range me.Taints {
+				ms.Taints[k] = v
+			}
+		}
+
 		machines[i] = ms
 	}
 
@@ -60,6 +60,7 @@ func New(client *http.Client) (*Service, error) {
 	}
 	s := &Service{client: client, BasePath: basePath}
 	s.Machines = NewMachinesService(s)
+	s.ReplicaSets = NewReplicaSetsService(s)
 	s.UnitState = NewUnitStateService(s)
 	s.Units = NewUnitsService(s)
 	return s, nil
@@ -71,6 +72,8 @@ type Service struct {
 
 	Machines *MachinesService
 
+	ReplicaSets *ReplicaSetsService
+
 	UnitState *UnitStateService
 
 	Units *UnitsService
@@ -85,6 +88,15 @@ type MachinesService struct {
 	s *Service
 }
 
+func NewReplicaSetsService(s *Service) *ReplicaSetsService {
+	rs := &ReplicaSetsService{s: s}
+	return rs
+}
+
+type ReplicaSetsService struct {
+	s *Service
+}
+
 func NewUnitStateService(s *Service) *UnitStateService {
 	rs := &UnitStateService{s: s}
 	return rs
@@ -104,11 +116,27 @@ type UnitsService struct {
 }
 
 type Machine struct {
+	Cordoned bool `json:"cordoned,omitempty"`
+
 	Id string `json:"id,omitempty"`
 
 	Metadata map[string]string `json:"metadata,omitempty"`
 
 	PrimaryIP string `json:"primaryIP,omitempty"`
+
+	Taints map[string]string `json:"taints,omitempty"`
+}
+
+type MachineExplanation struct {
+	MachineID string `json:"machineID,omitempty"`
+
+	Reason string `json:"reason,omitempty"`
+
+	Schedulable bool `json:"schedulable,omitempty"`
+}
+
+type MachineMetadataValue struct {
+	Value string `json:"value,omitempty"`
 }
 
 type MachinePage struct {
@@ -117,7 +145,31 @@ type MachinePage struct {
 	NextPageToken string `json:"nextPageToken,omitempty"`
 }
 
+type MachineRollout struct {
+	MachineID string `json:"machineID,omitempty"`
+
+	Status string `json:"status,omitempty"`
+}
+
+type MachineTaints struct {
+	Taints map[string]string `json:"taints,omitempty"`
+}
+
+type ReplicaSet struct {
+	Replicas int64 `json:"replicas,omitempty"`
+
+	Template string `json:"template,omitempty"`
+}
+
+type ReplicaSetPage struct {
+	NextPageToken string `json:"nextPageToken,omitempty"`
+
+	ReplicaSets []*ReplicaSet `json:"replicaSets,omitempty"`
+}
+
 type Unit struct {
+	ChangeCause string `json:"changeCause,omitempty"`
+
 	CurrentState string `json:"currentState,omitempty"`
 
 	DesiredState string `json:"desiredState,omitempty"`
@@ -127,6 +179,36 @@ type Unit struct {
 	Name string `json:"name,omitempty"`
 
 	Options []*UnitOption `json:"options,omitempty"`
+
+	// ServerResponse contains the HTTP response code and headers from the
+	// server.
+	googleapi.ServerResponse `json:"-"`
+}
+
+type UnitEvent struct {
+	MachineID string `json:"machineID,omitempty"`
+
+	Reason string `json:"reason,omitempty"`
+
+	Time string `json:"time,omitempty"`
+
+	Type string `json:"type,omitempty"`
+}
+
+type UnitExplanation struct {
+	DesiredState string `json:"desiredState,omitempty"`
+
+	MachineID string `json:"machineID,omitempty"`
+
+	Machines []*MachineExplanation `json:"machines,omitempty"`
+
+	Name string `json:"name,omitempty"`
+}
+
+type UnitHistory struct {
+	Events []*UnitEvent `json:"events,omitempty"`
+
+	Name string `json:"name,omitempty"`
 }
 
 type UnitOption struct {
@@ -143,6 +225,38 @@ type UnitPage struct {
 	Units []*Unit `json:"units,omitempty"`
 }
 
+type UnitRevision struct {
+	Cause string `json:"cause,omitempty"`
+
+	Current bool `json:"current,omitempty"`
+
+	Options []*UnitOption `json:"options,omitempty"`
+
+	Revision int64 `json:"revision,omitempty"`
+
+	Time string `json:"time,omitempty"`
+
+	UnitHash string `json:"unitHash,omitempty"`
+}
+
+type UnitRevisions struct {
+	Name string `json:"name,omitempty"`
+
+	Revisions []*UnitRevision `json:"revisions,omitempty"`
+}
+
+type UnitRollout struct {
+	Hash string `json:"hash,omitempty"`
+
+	HealthGate bool `json:"healthGate,omitempty"`
+
+	Machines []*MachineRollout `json:"machines,omitempty"`
+
+	MaxParallel int64 `json:"maxParallel,omitempty"`
+
+	Name string `json:"name,omitempty"`
+}
+
 type UnitState struct {
 	Hash string `json:"hash,omitempty"`
 
@@ -163,6 +277,147 @@ type UnitStatePage struct {
 	States []*UnitState `json:"states,omitempty"`
 }
 
+// method id "fleet.Machine.Cordon":
+
+type MachinesCordonCall struct {
+	s         *Service
+	machineID string
+	opt_      map[string]interface{}
+}
+
+// Cordon: Prevent new Units from being scheduled to the referenced
+// Machine.
+func (r *MachinesService) Cordon(machineID string) *MachinesCordonCall {
+	c := &MachinesCordonCall{s: r.s, opt_: make(map[string]interface{})}
+	c.machineID = machineID
+	return c
+}
+
+// Fields allows partial responses to be retrieved.
+// See https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
+// for more information.
+func (c *MachinesCordonCall) Fields(s ...googleapi.Field) *MachinesCordonCall {
+	c.opt_["fields"] = googleapi.CombineFields(s)
+	return c
+}
+
+func (c *MachinesCordonCall) Do() error {
+	var body io.Reader = nil
+	params := make(url.Values)
+	params.Set("alt", "json")
+	if v, ok := c.opt_["fields"]; ok {
+		params.Set("fields", fmt.Sprintf("%v", v))
+	}
+	urls := googleapi.ResolveRelative(c.s.BasePath, "machines/{machineID}/cordon")
+	urls += "?" + params.Encode()
+	req, _ := http.NewRequest("PUT", urls, body)
+	googleapi.Expand(req.URL, map[string]string{
+		"machineID": c.machineID,
+	})
+	req.Header.Set("User-Agent", "google-api-go-client/0.5")
+	res, err := c.s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer googleapi.CloseBody(res)
+	if err := googleapi.CheckResponse(res); err != nil {
+		return err
+	}
+	return nil
+	// {
+	//   "description": "Prevent new Units from being scheduled to the referenced Machine.",
+	//   "httpMethod": "PUT",
+	//   "id": "fleet.Machine.Cordon",
+	//   "parameterOrder": [
+	//     "machineID"
+	//   ],
+	//   "parameters": {
+	//     "machineID": {
+	//       "location": "path",
+	//       "required": true,
+	//       "type": "string"
+	//     }
+	//   },
+	//   "path": "machines/{machineID}/cordon"
+	// }
+
+}
+
+// method id "fleet.Machine.DeleteMetadata":
+
+type MachinesDeleteMetadataCall struct {
+	s         *Service
+	machineID string
+	key       string
+	opt_      map[string]interface{}
+}
+
+// DeleteMetadata: Remove a metadata value of the referenced Machine set
+// through the API.
+func (r *MachinesService) DeleteMetadata(machineID string, key string) *MachinesDeleteMetadataCall {
+	c := &MachinesDeleteMetadataCall{s: r.s, opt_: make(map[string]interface{})}
+	c.machineID = machineID
+	c.key = key
+	return c
+}
+
+// Fields allows partial responses to be retrieved.
+// See https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
+// for more information.
+func (c *MachinesDeleteMetadataCall) Fields(s ...googleapi.Field) *MachinesDeleteMetadataCall {
+	c.opt_["fields"] = googleapi.CombineFields(s)
+	return c
+}
+
+func (c *MachinesDeleteMetadataCall) Do() error {
+	var body io.Reader = nil
+	params := make(url.Values)
+	params.Set("alt", "json")
+	if v, ok := c.opt_["fields"]; ok {
+		params.Set("fields", fmt.Sprintf("%v", v))
+	}
+	urls := googleapi.ResolveRelative(c.s.BasePath, "machines/{machineID}/metadata/{key}")
+	urls += "?" + params.Encode()
+	req, _ := http.NewRequest("DELETE", urls, body)
+	googleapi.Expand(req.URL, map[string]string{
+		"machineID": c.machineID,
+		"key":       c.key,
+	})
+	req.Header.Set("User-Agent", "google-api-go-client/0.5")
+	res, err := c.s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer googleapi.CloseBody(res)
+	if err := googleapi.CheckResponse(res); err != nil {
+		return err
+	}
+	return nil
+	// {
+	//   "description": "Remove a metadata value of the referenced Machine set through the API.",
+	//   "httpMethod": "DELETE",
+	//   "id": "fleet.Machine.DeleteMetadata",
+	//   "parameterOrder": [
+	//     "machineID",
+	//     "key"
+	//   ],
+	//   "parameters": {
+	//     "key": {
+	//       "location": "path",
+	//       "required": true,
+	//       "type": "string"
+	//     },
+	//     "machineID": {
+	//       "location": "path",
+	//       "required": true,
+	//       "type": "string"
+	//     }
+	//   },
+	//   "path": "machines/{machineID}/metadata/{key}"
+	// }
+
+}
+
 // method id "fleet.Machine.List":
 
 type MachinesListCall struct {
@@ -238,6 +493,523 @@ func (c *MachinesListCall) Do() (*MachinePage, error) {
 
 }
 
+// method id "fleet.Machine.SetMetadata":
+
+type MachinesSetMetadataCall struct {
+	s                    *Service
+	machineID            string
+	key                  string
+	machinemetadatavalue *MachineMetadataValue
+	opt_                 map[string]interface{}
+}
+
+// SetMetadata: Set a metadata value of the referenced Machine, overriding
+// any value from its configuration.
+func (r *MachinesService) SetMetadata(machineID string, key string, machinemetadatavalue *MachineMetadataValue) *MachinesSetMetadataCall {
+	c := &MachinesSetMetadataCall{s: r.s, opt_: make(map[string]interface{})}
+	c.machineID = machineID
+	c.key = key
+	c.machinemetadatavalue = machinemetadatavalue
+	return c
+}
+
+// Fields allows partial responses to be retrieved.
+// See https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
+// for more information.
+func (c *MachinesSetMetadataCall) Fields(s ...googleapi.Field) *MachinesSetMetadataCall {
+	c.opt_["fields"] = googleapi.CombineFields(s)
+	return c
+}
+
+func (c *MachinesSetMetadataCall) Do() error {
+	var body io.Reader = nil
+	body, err := googleapi.WithoutDataWrapper.JSONReader(c.machinemetadatavalue)
+	if err != nil {
+		return err
+	}
+	ctype := "application/json"
+	params := make(url.Values)
+	params.Set("alt", "json")
+	if v, ok := c.opt_["fields"]; ok {
+		params.Set("fields", fmt.Sprintf("%v", v))
+	}
+	urls := googleapi.ResolveRelative(c.s.BasePath, "machines/{machineID}/metadata/{key}")
+	urls += "?" + params.Encode()
+	req, _ := http.NewRequest("PUT", urls, body)
+	googleapi.Expand(req.URL, map[string]string{
+		"machineID": c.machineID,
+		"key":       c.key,
+	})
+	req.Header.Set("Content-Type", ctype)
+	req.Header.Set("User-Agent", "google-api-go-client/0.5")
+	res, err := c.s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer googleapi.CloseBody(res)
+	if err := googleapi.CheckResponse(res); err != nil {
+		return err
+	}
+	return nil
+	// {
+	//   "description": "Set a metadata value of the referenced Machine, overriding any value from its configuration.",
+	//   "httpMethod": "PUT",
+	//   "id": "fleet.Machine.SetMetadata",
+	//   "parameterOrder": [
+	//     "machineID",
+	//     "key"
+	//   ],
+	//   "parameters": {
+	//     "key": {
+	//       "location": "path",
+	//       "required": true,
+	//       "type": "string"
+	//     },
+	//     "machineID": {
+	//       "location": "path",
+	//       "required": true,
+	//       "type": "string"
+	//     }
+	//   },
+	//   "path": "machines/{machineID}/metadata/{key}",
+	//   "request": {
+	//     "$ref": "MachineMetadataValue"
+	//   }
+	// }
+
+}
+
+// method id "fleet.Machine.SetTaints":
+
+type MachinesSetTaintsCall struct {
+	s             *Service
+	machineID     string
+	machinetaints *MachineTaints
+	opt_          map[string]interface{}
+}
+
+// SetTaints: Replace the taints of the referenced Machine set through the
+// API.
+func (r *MachinesService) SetTaints(machineID string, machinetaints *MachineTaints) *MachinesSetTaintsCall {
+	c := &MachinesSetTaintsCall{s: r.s, opt_: make(map[string]interface{})}
+	c.machineID = machineID
+	c.machinetaints = machinetaints
+	return c
+}
+
+// Fields allows partial responses to be retrieved.
+// See https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
+// for more information.
+func (c *MachinesSetTaintsCall) Fields(s ...googleapi.Field) *MachinesSetTaintsCall {
+	c.opt_["fields"] = googleapi.CombineFields(s)
+	return c
+}
+
+func (c *MachinesSetTaintsCall) Do() error {
+	var body io.Reader = nil
+	body, err := googleapi.WithoutDataWrapper.JSONReader(c.machinetaints)
+	if err != nil {
+		return err
+	}
+	ctype := "application/json"
+	params := make(url.Values)
+	params.Set("alt", "json")
+	if v, ok := c.opt_["fields"]; ok {
+		params.Set("fields", fmt.Sprintf("%v", v))
+	}
+	urls := googleapi.ResolveRelative(c.s.BasePath, "machines/{machineID}/taints")
+	urls += "?" + params.Encode()
+	req, _ := http.NewRequest("PUT", urls, body)
+	googleapi.Expand(req.URL, map[string]string{
+		"machineID": c.machineID,
+	})
+	req.Header.Set("Content-Type", ctype)
+	req.Header.Set("User-Agent", "google-api-go-client/0.5")
+	res, err := c.s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer googleapi.CloseBody(res)
+	if err := googleapi.CheckResponse(res); err != nil {
+		return err
+	}
+	return nil
+	// {
+	//   "description": "Replace the taints of the referenced Machine set through the API.",
+	//   "httpMethod": "PUT",
+	//   "id": "fleet.Machine.SetTaints",
+	//   "parameterOrder": [
+	//     "machineID"
+	//   ],
+	//   "parameters": {
+	//     "machineID": {
+	//       "location": "path",
+	//       "required": true,
+	//       "type": "string"
+	//     }
+	//   },
+	//   "path": "machines/{machineID}/taints",
+	//   "request": {
+	//     "$ref": "MachineTaints"
+	//   }
+	// }
+
+}
+
+// method id "fleet.Machine.Uncordon":
+
+type MachinesUncordonCall struct {
+	s         *Service
+	machineID string
+	opt_      map[string]interface{}
+}
+
+// Uncordon: Allow new Units to be scheduled to the referenced Machine
+// again.
+func (r *MachinesService) Uncordon(machineID string) *MachinesUncordonCall {
+	c := &MachinesUncordonCall{s: r.s, opt_: make(map[string]interface{})}
+	c.machineID = machineID
+	return c
+}
+
+// Fields allows partial responses to be retrieved.
+// See https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
+// for more information.
+func (c *MachinesUncordonCall) Fields(s ...googleapi.Field) *MachinesUncordonCall {
+	c.opt_["fields"] = googleapi.CombineFields(s)
+	return c
+}
+
+func (c *MachinesUncordonCall) Do() error {
+	var body io.Reader = nil
+	params := make(url.Values)
+	params.Set("alt", "json")
+	if v, ok := c.opt_["fields"]; ok {
+		params.Set("fields", fmt.Sprintf("%v", v))
+	}
+	urls := googleapi.ResolveRelative(c.s.BasePath, "machines/{machineID}/cordon")
+	urls += "?" + params.Encode()
+	req, _ := http.NewRequest("DELETE", urls, body)
+	googleapi.Expand(req.URL, map[string]string{
+		"machineID": c.machineID,
+	})
+	req.Header.Set("User-Agent", "google-api-go-client/0.5")
+	res, err := c.s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer googleapi.CloseBody(res)
+	if err := googleapi.CheckResponse(res); err != nil {
+		return err
+	}
+	return nil
+	// {
+	//   "description": "Allow new Units to be scheduled to the referenced Machine again.",
+	//   "httpMethod": "DELETE",
+	//   "id": "fleet.Machine.Uncordon",
+	//   "parameterOrder": [
+	//     "machineID"
+	//   ],
+	//   "parameters": {
+	//     "machineID": {
+	//       "location": "path",
+	//       "required": true,
+	//       "type": "string"
+	//     }
+	//   },
+	//   "path": "machines/{machineID}/cordon"
+	// }
+
+}
+
+// method id "fleet.ReplicaSet.Delete":
+
+type ReplicaSetsDeleteCall struct {
+	s            *Service
+	templateName string
+	opt_         map[string]interface{}
+}
+
+// Delete: Delete the ReplicaSet of a template Unit.
+func (r *ReplicaSetsService) Delete(templateName string) *ReplicaSetsDeleteCall {
+	c := &ReplicaSetsDeleteCall{s: r.s, opt_: make(map[string]interface{})}
+	c.templateName = templateName
+	return c
+}
+
+// Fields allows partial responses to be retrieved.
+// See https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
+// for more information.
+func (c *ReplicaSetsDeleteCall) Fields(s ...googleapi.Field) *ReplicaSetsDeleteCall {
+	c.opt_["fields"] = googleapi.CombineFields(s)
+	return c
+}
+
+func (c *ReplicaSetsDeleteCall) Do() error {
+	var body io.Reader = nil
+	params := make(url.Values)
+	params.Set("alt", "json")
+	if v, ok := c.opt_["fields"]; ok {
+		params.Set("fields", fmt.Sprintf("%v", v))
+	}
+	urls := googleapi.ResolveRelative(c.s.BasePath, "replica-sets/{templateName}")
+	urls += "?" + params.Encode()
+	req, _ := http.NewRequest("DELETE", urls, body)
+	googleapi.Expand(req.URL, map[string]string{
+		"templateName": c.templateName,
+	})
+	req.Header.Set("User-Agent", "google-api-go-client/0.5")
+	res, err := c.s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer googleapi.CloseBody(res)
+	if err := googleapi.CheckResponse(res); err != nil {
+		return err
+	}
+	return nil
+	// {
+	//   "description": "Delete the ReplicaSet of a template Unit.",
+	//   "httpMethod": "DELETE",
+	//   "id": "fleet.ReplicaSet.Delete",
+	//   "parameterOrder": [
+	//     "templateName"
+	//   ],
+	//   "parameters": {
+	//     "templateName": {
+	//       "location": "path",
+	//       "required": true,
+	//       "type": "string"
+	//     }
+	//   },
+	//   "path": "replica-sets/{templateName}"
+	// }
+
+}
+
+// method id "fleet.ReplicaSet.Get":
+
+type ReplicaSetsGetCall struct {
+	s            *Service
+	templateName string
+	opt_         map[string]interface{}
+}
+
+// Get: Retrieve the ReplicaSet of a template Unit.
+func (r *ReplicaSetsService) Get(templateName string) *ReplicaSetsGetCall {
+	c := &ReplicaSetsGetCall{s: r.s, opt_: make(map[string]interface{})}
+	c.templateName = templateName
+	return c
+}
+
+// Fields allows partial responses to be retrieved.
+// See https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
+// for more information.
+func (c *ReplicaSetsGetCall) Fields(s ...googleapi.Field) *ReplicaSetsGetCall {
+	c.opt_["fields"] = googleapi.CombineFields(s)
+	return c
+}
+
+func (c *ReplicaSetsGetCall) Do() (*ReplicaSet, error) {
+	var body io.Reader = nil
+	params := make(url.Values)
+	params.Set("alt", "json")
+	if v, ok := c.opt_["fields"]; ok {
+		params.Set("fields", fmt.Sprintf("%v", v))
+	}
+	urls := googleapi.ResolveRelative(c.s.BasePath, "replica-sets/{templateName}")
+	urls += "?" + params.Encode()
+	req, _ := http.NewRequest("GET", urls, body)
+	googleapi.Expand(req.URL, map[string]string{
+		"templateName": c.templateName,
+	})
+	req.Header.Set("User-Agent", "google-api-go-client/0.5")
+	res, err := c.s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer googleapi.CloseBody(res)
+	if err := googleapi.CheckResponse(res); err != nil {
+		return nil, err
+	}
+	var ret *ReplicaSet
+	if err := json.NewDecoder(res.Body).Decode(&ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+	// {
+	//   "description": "Retrieve the ReplicaSet of a template Unit.",
+	//   "httpMethod": "GET",
+	//   "id": "fleet.ReplicaSet.Get",
+	//   "parameterOrder": [
+	//     "templateName"
+	//   ],
+	//   "parameters": {
+	//     "templateName": {
+	//       "location": "path",
+	//       "required": true,
+	//       "type": "string"
+	//     }
+	//   },
+	//   "path": "replica-sets/{templateName}",
+	//   "response": {
+	//     "$ref": "ReplicaSet"
+	//   }
+	// }
+
+}
+
+// method id "fleet.ReplicaSet.List":
+
+type ReplicaSetsListCall struct {
+	s    *Service
+	opt_ map[string]interface{}
+}
+
+// List: Retrieve a page of ReplicaSet objects.
+func (r *ReplicaSetsService) List() *ReplicaSetsListCall {
+	c := &ReplicaSetsListCall{s: r.s, opt_: make(map[string]interface{})}
+	return c
+}
+
+// NextPageToken sets the optional parameter "nextPageToken":
+func (c *ReplicaSetsListCall) NextPageToken(nextPageToken string) *ReplicaSetsListCall {
+	c.opt_["nextPageToken"] = nextPageToken
+	return c
+}
+
+// Fields allows partial responses to be retrieved.
+// See https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
+// for more information.
+func (c *ReplicaSetsListCall) Fields(s ...googleapi.Field) *ReplicaSetsListCall {
+	c.opt_["fields"] = googleapi.CombineFields(s)
+	return c
+}
+
+func (c *ReplicaSetsListCall) Do() (*ReplicaSetPage, error) {
+	var body io.Reader = nil
+	params := make(url.Values)
+	params.Set("alt", "json")
+	if v, ok := c.opt_["nextPageToken"]; ok {
+		params.Set("nextPageToken", fmt.Sprintf("%v", v))
+	}
+	if v, ok := c.opt_["fields"]; ok {
+		params.Set("fields", fmt.Sprintf("%v", v))
+	}
+	urls := googleapi.ResolveRelative(c.s.BasePath, "replica-sets")
+	urls += "?" + params.Encode()
+	req, _ := http.NewRequest("GET", urls, body)
+
+	// googleapi.SetOpaque(req.URL)
+
+	req.Header.Set("User-Agent", "google-api-go-client/0.5")
+	res, err := c.s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer googleapi.CloseBody(res)
+	if err := googleapi.CheckResponse(res); err != nil {
+		return nil, err
+	}
+	var ret *ReplicaSetPage
+	if err := json.NewDecoder(res.Body).Decode(&ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+	// {
+	//   "description": "Retrieve a page of ReplicaSet objects.",
+	//   "httpMethod": "GET",
+	//   "id": "fleet.ReplicaSet.List",
+	//   "parameters": {
+	//     "nextPageToken": {
+	//       "location": "query",
+	//       "type": "string"
+	//     }
+	//   },
+	//   "path": "replica-sets",
+	//   "response": {
+	//     "$ref": "ReplicaSetPage"
+	//   }
+	// }
+
+}
+
+// method id "fleet.ReplicaSet.Set":
+
+type ReplicaSetsSetCall struct {
+	s            *Service
+	templateName string
+	replicaset   *ReplicaSet
+	opt_         map[string]interface{}
+}
+
+// Set: Create or update the ReplicaSet of a template Unit.
+func (r *ReplicaSetsService) Set(templateName string, replicaset *ReplicaSet) *ReplicaSetsSetCall {
+	c := &ReplicaSetsSetCall{s: r.s, opt_: make(map[string]interface{})}
+	c.templateName = templateName
+	c.replicaset = replicaset
+	return c
+}
+
+// Fields allows partial responses to be retrieved.
+// See https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
+// for more information.
+func (c *ReplicaSetsSetCall) Fields(s ...googleapi.Field) *ReplicaSetsSetCall {
+	c.opt_["fields"] = googleapi.CombineFields(s)
+	return c
+}
+
+func (c *ReplicaSetsSetCall) Do() error {
+	var body io.Reader = nil
+	body, err := googleapi.WithoutDataWrapper.JSONReader(c.replicaset)
+	if err != nil {
+		return err
+	}
+	ctype := "application/json"
+	params := make(url.Values)
+	params.Set("alt", "json")
+	if v, ok := c.opt_["fields"]; ok {
+		params.Set("fields", fmt.Sprintf("%v", v))
+	}
+	urls := googleapi.ResolveRelative(c.s.BasePath, "replica-sets/{templateName}")
+	urls += "?" + params.Encode()
+	req, _ := http.NewRequest("PUT", urls, body)
+	googleapi.Expand(req.URL, map[string]string{
+		"templateName": c.templateName,
+	})
+	req.Header.Set("Content-Type", ctype)
+	req.Header.Set("User-Agent", "google-api-go-client/0.5")
+	res, err := c.s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer googleapi.CloseBody(res)
+	if err := googleapi.CheckResponse(res); err != nil {
+		return err
+	}
+	return nil
+	// {
+	//   "description": "Create or update the ReplicaSet of a template Unit.",
+	//   "httpMethod": "PUT",
+	//   "id": "fleet.ReplicaSet.Set",
+	//   "parameterOrder": [
+	//     "templateName"
+	//   ],
+	//   "parameters": {
+	//     "templateName": {
+	//       "location": "path",
+	//       "required": true,
+	//       "type": "string"
+	//     }
+	//   },
+	//   "path": "replica-sets/{templateName}",
+	//   "request": {
+	//     "$ref": "ReplicaSet"
+	//   }
+	// }
+
+}
+
 // method id "fleet.UnitState.List":
 
 type UnitStateListCall struct {
@@ -362,6 +1134,21 @@ func (c *UnitsDeleteCall) Fields(s ...googleapi.Field) *UnitsDeleteCall {
 	return c
 }
 
+// IfMatch sets the optional parameter which makes the operation fail if
+// the ETag of the Unit does not match the given value.
+func (c *UnitsDeleteCall) IfMatch(entityTag string) *UnitsDeleteCall {
+	c.opt_["ifMatch"] = entityTag
+	return c
+}
+
+// IfNoneMatch sets the optional parameter which makes the operation fail if
+// the ETag of the Unit matches the given value, or with "*", if the Unit
+// exists.
+func (c *UnitsDeleteCall) IfNoneMatch(entityTag string) *UnitsDeleteCall {
+	c.opt_["ifNoneMatch"] = entityTag
+	return c
+}
+
 func (c *UnitsDeleteCall) Do() error {
 	var body io.Reader = nil
 	params := make(url.Values)
@@ -375,6 +1162,12 @@ func (c *UnitsDeleteCall) Do() error {
 	googleapi.Expand(req.URL, map[string]string{
 		"unitName": c.unitName,
 	})
+	if v, ok := c.opt_["ifMatch"]; ok {
+		req.Header.Set("If-Match", fmt.Sprintf("%v", v))
+	}
+	if v, ok := c.opt_["ifNoneMatch"]; ok {
+		req.Header.Set("If-None-Match", fmt.Sprintf("%v", v))
+	}
 	req.Header.Set("User-Agent", "google-api-go-client/0.5")
 	res, err := c.s.client.Do(req)
 	if err != nil {
@@ -404,6 +1197,152 @@ func (c *UnitsDeleteCall) Do() error {
 
 }
 
+// method id "fleet.Unit.Events":
+
+type UnitsEventsCall struct {
+	s        *Service
+	unitName string
+	opt_     map[string]interface{}
+}
+
+// Events: Retrieve the scheduling events recorded for the referenced
+// Unit, oldest first.
+func (r *UnitsService) Events(unitName string) *UnitsEventsCall {
+	c := &UnitsEventsCall{s: r.s, opt_: make(map[string]interface{})}
+	c.unitName = unitName
+	return c
+}
+
+// Fields allows partial responses to be retrieved.
+// See https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
+// for more information.
+func (c *UnitsEventsCall) Fields(s ...googleapi.Field) *UnitsEventsCall {
+	c.opt_["fields"] = googleapi.CombineFields(s)
+	return c
+}
+
+func (c *UnitsEventsCall) Do() (*UnitHistory, error) {
+	var body io.Reader = nil
+	params := make(url.Values)
+	params.Set("alt", "json")
+	if v, ok := c.opt_["fields"]; ok {
+		params.Set("fields", fmt.Sprintf("%v", v))
+	}
+	urls := googleapi.ResolveRelative(c.s.BasePath, "units/{unitName}/events")
+	urls += "?" + params.Encode()
+	req, _ := http.NewRequest("GET", urls, body)
+	googleapi.Expand(req.URL, map[string]string{
+		"unitName": c.unitName,
+	})
+	req.Header.Set("User-Agent", "google-api-go-client/0.5")
+	res, err := c.s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer googleapi.CloseBody(res)
+	if err := googleapi.CheckResponse(res); err != nil {
+		return nil, err
+	}
+	var ret *UnitHistory
+	if err := json.NewDecoder(res.Body).Decode(&ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+	// {
+	//   "description": "Retrieve the scheduling events recorded for the referenced Unit, oldest first.",
+	//   "httpMethod": "GET",
+	//   "id": "fleet.Unit.Events",
+	//   "parameterOrder": [
+	//     "unitName"
+	//   ],
+	//   "parameters": {
+	//     "unitName": {
+	//       "location": "path",
+	//       "required": true,
+	//       "type": "string"
+	//     }
+	//   },
+	//   "path": "units/{unitName}/events",
+	//   "response": {
+	//     "$ref": "UnitHistory"
+	//   }
+	// }
+
+}
+
+// method id "fleet.Unit.Explain":
+
+type UnitsExplainCall struct {
+	s        *Service
+	unitName string
+	opt_     map[string]interface{}
+}
+
+// Explain: Explain whether the referenced Unit could be scheduled to each
+// Machine.
+func (r *UnitsService) Explain(unitName string) *UnitsExplainCall {
+	c := &UnitsExplainCall{s: r.s, opt_: make(map[string]interface{})}
+	c.unitName = unitName
+	return c
+}
+
+// Fields allows partial responses to be retrieved.
+// See https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
+// for more information.
+func (c *UnitsExplainCall) Fields(s ...googleapi.Field) *UnitsExplainCall {
+	c.opt_["fields"] = googleapi.CombineFields(s)
+	return c
+}
+
+func (c *UnitsExplainCall) Do() (*UnitExplanation, error) {
+	var body io.Reader = nil
+	params := make(url.Values)
+	params.Set("alt", "json")
+	if v, ok := c.opt_["fields"]; ok {
+		params.Set("fields", fmt.Sprintf("%v", v))
+	}
+	urls := googleapi.ResolveRelative(c.s.BasePath, "units/{unitName}/explanation")
+	urls += "?" + params.Encode()
+	req, _ := http.NewRequest("GET", urls, body)
+	googleapi.Expand(req.URL, map[string]string{
+		"unitName": c.unitName,
+	})
+	req.Header.Set("User-Agent", "google-api-go-client/0.5")
+	res, err := c.s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer googleapi.CloseBody(res)
+	if err := googleapi.CheckResponse(res); err != nil {
+		return nil, err
+	}
+	var ret *UnitExplanation
+	if err := json.NewDecoder(res.Body).Decode(&ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+	// {
+	//   "description": "Explain whether the referenced Unit could be scheduled to each Machine.",
+	//   "httpMethod": "GET",
+	//   "id": "fleet.Unit.Explain",
+	//   "parameterOrder": [
+	//     "unitName"
+	//   ],
+	//   "parameters": {
+	//     "unitName": {
+	//       "location": "path",
+	//       "required": true,
+	//       "type": "string"
+	//     }
+	//   },
+	//   "path": "units/{unitName}/explanation",
+	//   "response": {
+	//     "$ref": "UnitExplanation"
+	//   }
+	// }
+
+}
+
 // method id "fleet.Unit.Get":
 
 type UnitsGetCall struct {
@@ -449,7 +1388,12 @@ func (c *UnitsGetCall) Do() (*Unit, error) {
 	if err := googleapi.CheckResponse(res); err != nil {
 		return nil, err
 	}
-	var ret *Unit
+	ret := &Unit{
+		ServerResponse: googleapi.ServerResponse{
+			Header:         res.Header,
+			HTTPStatusCode: res.StatusCode,
+		},
+	}
 	if err := json.NewDecoder(res.Body).Decode(&ret); err != nil {
 		return nil, err
 	}
@@ -551,6 +1495,152 @@ func (c *UnitsListCall) Do() (*UnitPage, error) {
 
 }
 
+// method id "fleet.Unit.Revisions":
+
+type UnitsRevisionsCall struct {
+	s        *Service
+	unitName string
+	opt_     map[string]interface{}
+}
+
+// Revisions: Retrieve the unit file revisions recorded for the
+// referenced Unit, oldest first.
+func (r *UnitsService) Revisions(unitName string) *UnitsRevisionsCall {
+	c := &UnitsRevisionsCall{s: r.s, opt_: make(map[string]interface{})}
+	c.unitName = unitName
+	return c
+}
+
+// Fields allows partial responses to be retrieved.
+// See https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
+// for more information.
+func (c *UnitsRevisionsCall) Fields(s ...googleapi.Field) *UnitsRevisionsCall {
+	c.opt_["fields"] = googleapi.CombineFields(s)
+	return c
+}
+
+func (c *UnitsRevisionsCall) Do() (*UnitRevisions, error) {
+	var body io.Reader = nil
+	params := make(url.Values)
+	params.Set("alt", "json")
+	if v, ok := c.opt_["fields"]; ok {
+		params.Set("fields", fmt.Sprintf("%v", v))
+	}
+	urls := googleapi.ResolveRelative(c.s.BasePath, "units/{unitName}/revisions")
+	urls += "?" + params.Encode()
+	req, _ := http.NewRequest("GET", urls, body)
+	googleapi.Expand(req.URL, map[string]string{
+		"unitName": c.unitName,
+	})
+	req.Header.Set("User-Agent", "google-api-go-client/0.5")
+	res, err := c.s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer googleapi.CloseBody(res)
+	if err := googleapi.CheckResponse(res); err != nil {
+		return nil, err
+	}
+	var ret *UnitRevisions
+	if err := json.NewDecoder(res.Body).Decode(&ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+	// {
+	//   "description": "Retrieve the unit file revisions recorded for the referenced Unit, oldest first.",
+	//   "httpMethod": "GET",
+	//   "id": "fleet.Unit.Revisions",
+	//   "parameterOrder": [
+	//     "unitName"
+	//   ],
+	//   "parameters": {
+	//     "unitName": {
+	//       "location": "path",
+	//       "required": true,
+	//       "type": "string"
+	//     }
+	//   },
+	//   "path": "units/{unitName}/revisions",
+	//   "response": {
+	//     "$ref": "UnitRevisions"
+	//   }
+	// }
+
+}
+
+// method id "fleet.Unit.Rollout":
+
+type UnitsRolloutCall struct {
+	s        *Service
+	unitName string
+	opt_     map[string]interface{}
+}
+
+// Rollout: Retrieve the progress of rolling out the referenced global
+// Unit to each Machine.
+func (r *UnitsService) Rollout(unitName string) *UnitsRolloutCall {
+	c := &UnitsRolloutCall{s: r.s, opt_: make(map[string]interface{})}
+	c.unitName = unitName
+	return c
+}
+
+// Fields allows partial responses to be retrieved.
+// See https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
+// for more information.
+func (c *UnitsRolloutCall) Fields(s ...googleapi.Field) *UnitsRolloutCall {
+	c.opt_["fields"] = googleapi.CombineFields(s)
+	return c
+}
+
+func (c *UnitsRolloutCall) Do() (*UnitRollout, error) {
+	var body io.Reader = nil
+	params := make(url.Values)
+	params.Set("alt", "json")
+	if v, ok := c.opt_["fields"]; ok {
+		params.Set("fields", fmt.Sprintf("%v", v))
+	}
+	urls := googleapi.ResolveRelative(c.s.BasePath, "units/{unitName}/rollout")
+	urls += "?" + params.Encode()
+	req, _ := http.NewRequest("GET", urls, body)
+	googleapi.Expand(req.URL, map[string]string{
+		"unitName": c.unitName,
+	})
+	req.Header.Set("User-Agent", "google-api-go-client/0.5")
+	res, err := c.s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer googleapi.CloseBody(res)
+	if err := googleapi.CheckResponse(res); err != nil {
+		return nil, err
+	}
+	var ret *UnitRollout
+	if err := json.NewDecoder(res.Body).Decode(&ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+	// {
+	//   "description": "Retrieve the progress of rolling out the referenced global Unit to each Machine.",
+	//   "httpMethod": "GET",
+	//   "id": "fleet.Unit.Rollout",
+	//   "parameterOrder": [
+	//     "unitName"
+	//   ],
+	//   "parameters": {
+	//     "unitName": {
+	//       "location": "path",
+	//       "required": true,
+	//       "type": "string"
+	//     }
+	//   },
+	//   "path": "units/{unitName}/rollout",
+	//   "response": {
+	//     "$ref": "UnitRollout"
+	//   }
+	// }
+
+}
+
 // method id "fleet.Unit.Set":
 
 type UnitsSetCall struct {
@@ -576,6 +1666,21 @@ func (c *UnitsSetCall) Fields(s ...googleapi.Field) *UnitsSetCall {
 	return c
 }
 
+// IfMatch sets the optional parameter which makes the operation fail if
+// the ETag of the Unit does not match the given value.
+func (c *UnitsSetCall) IfMatch(entityTag string) *UnitsSetCall {
+	c.opt_["ifMatch"] = entityTag
+	return c
+}
+
+// IfNoneMatch sets the optional parameter which makes the operation fail if
+// the ETag of the Unit matches the given value, or with "*", if the Unit
+// exists.
+func (c *UnitsSetCall) IfNoneMatch(entityTag string) *UnitsSetCall {
+	c.opt_["ifNoneMatch"] = entityTag
+	return c
+}
+
 func (c *UnitsSetCall) Do() error {
 	var body io.Reader = nil
 	body, err := googleapi.WithoutDataWrapper.JSONReader(c.unit)
@@ -595,6 +1700,12 @@ func (c *UnitsSetCall) Do() error {
 		"unitName": c.unitName,
 	})
 	req.Header.Set("Content-Type", ctype)
+	if v, ok := c.opt_["ifMatch"]; ok {
+		req.Header.Set("If-Match", fmt.Sprintf("%v", v))
+	}
+	if v, ok := c.opt_["ifNoneMatch"]; ok {
+		req.Header.Set("If-None-Match", fmt.Sprintf("%v", v))
+	}
 	req.Header.Set("User-Agent", "google-api-go-client/0.5")
 	res, err := c.s.client.Do(req)
 	if err != nil {
@@ -52,12 +52,44 @@ const DiscoveryJSON = `{
         "primaryIP": {
           "type": "string"
         },
+        "cordoned": {
+          "type": "boolean"
+        },
         "metadata": {
           "type": "object",
           "properties": {},
           "additionalProperties": {
             "type": "string"
           }
+        },
+        "taints": {
+          "type": "object",
+          "properties": {},
+          "additionalProperties": {
+            "type": "string"
+          }
+        }
+      }
+    },
+    "MachineMetadataValue": {
+      "id": "MachineMetadataValue",
+      "type": "object",
+      "properties": {
+        "value": {
+          "type": "string"
+        }
+      }
+    },
+    "MachineTaints": {
+      "id": "MachineTaints",
+      "type": "object",
+      "properties": {
+        "taints": {
+          "type": "object",
+          "properties": {},
+          "additionalProperties": {
+            "type": "string"
+          }
         }
       }
     },
@@ -76,6 +108,33 @@ const DiscoveryJSON = `{
         }
       }
     },
+    "ReplicaSet": {
+      "id": "ReplicaSet",
+      "type": "object",
+      "properties": {
+        "template": {
+          "type": "string"
+        },
+        "replicas": {
+          "type": "integer"
+        }
+      }
+    },
+    "ReplicaSetPage": {
+      "id": "ReplicaSetPage",
+      "type": "object",
+      "properties": {
+        "replicaSets": {
+          "type": "array",
+          "items": {
+            "$ref": "ReplicaSet"
+          }
+        },
+        "nextPageToken": {
+          "type": "string"
+        }
+      }
+    },
     "UnitOption": {
       "id": "UnitOption",
       "type": "object",
@@ -123,6 +182,156 @@ const DiscoveryJSON = `{
         "machineID": {
           "type": "string",
           "required": true
+        },
+        "changeCause": {
+          "type": "string"
+        }
+      }
+    },
+    "UnitExplanation": {
+      "id": "UnitExplanation",
+      "type": "object",
+      "properties": {
+        "name": {
+          "type": "string"
+        },
+        "desiredState": {
+          "type": "string"
+        },
+        "machineID": {
+          "type": "string"
+        },
+        "machines": {
+          "type": "array",
+          "items": {
+            "$ref": "MachineExplanation"
+          }
+        }
+      }
+    },
+    "MachineExplanation": {
+      "id": "MachineExplanation",
+      "type": "object",
+      "properties": {
+        "machineID": {
+          "type": "string"
+        },
+        "schedulable": {
+          "type": "boolean"
+        },
+        "reason": {
+          "type": "string"
+        }
+      }
+    },
+    "UnitHistory": {
+      "id": "UnitHistory",
+      "type": "object",
+      "properties": {
+        "name": {
+          "type": "string"
+        },
+        "events": {
+          "type": "array",
+          "items": {
+            "$ref": "UnitEvent"
+          }
+        }
+      }
+    },
+    "UnitRevisions": {
+      "id": "UnitRevisions",
+      "type": "object",
+      "properties": {
+        "name": {
+          "type": "string"
+        },
+        "revisions": {
+          "type": "array",
+          "items": {
+            "$ref": "UnitRevision"
+          }
+        }
+      }
+    },
+    "UnitRevision": {
+      "id": "UnitRevision",
+      "type": "object",
+      "properties": {
+        "revision": {
+          "type": "integer"
+        },
+        "unitHash": {
+          "type": "string"
+        },
+        "time": {
+          "type": "string"
+        },
+        "cause": {
+          "type": "string"
+        },
+        "current": {
+          "type": "boolean"
+        },
+        "options": {
+          "type": "array",
+          "items": {
+            "$ref": "UnitOption"
+          }
+        }
+      }
+    },
+    "UnitEvent": {
+      "id": "UnitEvent",
+      "type": "object",
+      "properties": {
+        "time": {
+          "type": "string"
+        },
+        "machineID": {
+          "type": "string"
+        },
+        "type": {
+          "type": "string"
+        },
+        "reason": {
+          "type": "string"
+        }
+      }
+    },
+    "UnitRollout": {
+      "id": "UnitRollout",
+      "type": "object",
+      "properties": {
+        "name": {
+          "type": "string"
+        },
+        "hash": {
+          "type": "string"
+        },
+        "maxParallel": {
+          "type": "integer"
+        },
+        "healthGate": {
+          "type": "boolean"
+        },
+        "machines": {
+          "type": "array",
+          "items": {
+            "$ref": "MachineRollout"
+          }
+        }
+      }
+    },
+    "MachineRollout": {
+      "id": "MachineRollout",
+      "type": "object",
+      "properties": {
+        "machineID": {
+          "type": "string"
+        },
+        "status": {
+          "type": "string"
         }
       }
     },
@@ -198,6 +407,177 @@ const DiscoveryJSON = `{
           "response": {
             "$ref": "MachinePage"
           }
+        },
+        "Cordon": {
+          "id": "fleet.Machine.Cordon",
+          "description": "Prevent new Units from being scheduled to the referenced Machine.",
+          "httpMethod": "PUT",
+          "path": "machines/{machineID}/cordon",
+          "parameters": {
+            "machineID": {
+              "type": "string",
+              "location": "path",
+              "required": true
+            }
+          },
+          "parameterOrder": [
+            "machineID"
+          ]
+        },
+        "Uncordon": {
+          "id": "fleet.Machine.Uncordon",
+          "description": "Allow new Units to be scheduled to the referenced Machine again.",
+          "httpMethod": "DELETE",
+          "path": "machines/{machineID}/cordon",
+          "parameters": {
+            "machineID": {
+              "type": "string",
+              "location": "path",
+              "required": true
+            }
+          },
+          "parameterOrder": [
+            "machineID"
+          ]
+        },
+        "SetTaints": {
+          "id": "fleet.Machine.SetTaints",
+          "description": "Replace the taints of the referenced Machine set through the API.",
+          "httpMethod": "PUT",
+          "path": "machines/{machineID}/taints",
+          "parameters": {
+            "machineID": {
+              "type": "string",
+              "location": "path",
+              "required": true
+            }
+          },
+          "parameterOrder": [
+            "machineID"
+          ],
+          "request": {
+            "$ref": "MachineTaints"
+          }
+        },
+        "SetMetadata": {
+          "id": "fleet.Machine.SetMetadata",
+          "description": "Set a metadata value of the referenced Machine, overriding any value from its configuration.",
+          "httpMethod": "PUT",
+          "path": "machines/{machineID}/metadata/{key}",
+          "parameters": {
+            "machineID": {
+              "type": "string",
+              "location": "path",
+              "required": true
+            },
+            "key": {
+              "type": "string",
+              "location": "path",
+              "required": true
+            }
+          },
+          "parameterOrder": [
+            "machineID",
+            "key"
+          ],
+          "request": {
+            "$ref": "MachineMetadataValue"
+          }
+        },
+        "DeleteMetadata": {
+          "id": "fleet.Machine.DeleteMetadata",
+          "description": "Remove a metadata value of the referenced Machine set through the API.",
+          "httpMethod": "DELETE",
+          "path": "machines/{machineID}/metadata/{key}",
+          "parameters": {
+            "machineID": {
+              "type": "string",
+              "location": "path",
+              "required": true
+            },
+            "key": {
+              "type": "string",
+              "location": "path",
+              "required": true
+            }
+          },
+          "parameterOrder": [
+            "machineID",
+            "key"
+          ]
+        }
+      }
+    },
+    "ReplicaSets": {
+      "methods": {
+        "List": {
+          "id": "fleet.ReplicaSet.List",
+          "description": "Retrieve a page of ReplicaSet objects.",
+          "httpMethod": "GET",
+          "path": "replica-sets",
+          "parameters": {
+            "nextPageToken": {
+              "type": "string",
+              "location": "query"
+            }
+          },
+          "response": {
+            "$ref": "ReplicaSetPage"
+          }
+        },
+        "Get": {
+          "id": "fleet.ReplicaSet.Get",
+          "description": "Retrieve the ReplicaSet of a template Unit.",
+          "httpMethod": "GET",
+          "path": "replica-sets/{templateName}",
+          "parameters": {
+            "templateName": {
+              "type": "string",
+              "location": "path",
+              "required": true
+            }
+          },
+          "parameterOrder": [
+            "templateName"
+          ],
+          "response": {
+            "$ref": "ReplicaSet"
+          }
+        },
+        "Delete": {
+          "id": "fleet.ReplicaSet.Delete",
+          "description": "Delete the ReplicaSet of a template Unit.",
+          "httpMethod": "DELETE",
+          "path": "replica-sets/{templateName}",
+          "parameters": {
+            "templateName": {
+              "type": "string",
+              "location": "path",
+              "required": true
+            }
+          },
+          "parameterOrder": [
+            "templateName"
+          ]
+        },
+        "Set": {
+          "id": "fleet.ReplicaSet.Set",
+          "description": "Create or update the ReplicaSet of a template Unit.",
+          "httpMethod": "PUT",
+          "path": "replica-sets/{templateName}",
+          "parameters": {
+            "templateName": {
+              "type": "string",
+              "location": "path",
+              "required": true
+            }
+          },
+          "parameterOrder": [
+            "templateName"
+          ],
+          "request": {
+            "$ref": "ReplicaSet"
+          }
         }
       }
     },
@@ -237,6 +617,82 @@ const DiscoveryJSON = `{
             "$ref": "Unit"
           }
         },
+        "Explain": {
+          "id": "fleet.Unit.Explain",
+          "description": "Explain whether the referenced Unit could be scheduled to each Machine.",
+          "httpMethod": "GET",
+          "path": "units/{unitName}/explanation",
+          "parameters": {
+            "unitName": {
+              "type": "string",
+              "location": "path",
+              "required": true
+            }
+          },
+          "parameterOrder": [
+            "unitName"
+          ],
+          "response": {
+            "$ref": "UnitExplanation"
+          }
+        },
+        "Events": {
+          "id": "fleet.Unit.Events",
+          "description": "Retrieve the scheduling events recorded for the referenced Unit, oldest first.",
+          "httpMethod": "GET",
+          "path": "units/{unitName}/events",
+          "parameters": {
+            "unitName": {
+              "type": "string",
+              "location": "path",
+              "required": true
+            }
+          },
+          "parameterOrder": [
+            "unitName"
+          ],
+          "response": {
+            "$ref": "UnitHistory"
+          }
+        },
+        "Revisions": {
+          "id": "fleet.Unit.Revisions",
+          "description": "Retrieve the unit file revisions recorded for the referenced Unit, oldest first.",
+          "httpMethod": "GET",
+          "path": "units/{unitName}/revisions",
+          "parameters": {
+            "unitName": {
+              "type": "string",
+              "location": "path",
+              "required": true
+            }
+          },
+          "parameterOrder": [
+            "unitName"
+          ],
+          "response": {
+            "$ref": "UnitRevisions"
+          }
+        },
+        "Rollout": {
+          "id": "fleet.Unit.Rollout",
+          "description": "Retrieve the progress of rolling out the referenced global Unit to each Machine.",
+          "httpMethod": "GET",
+          "path": "units/{unitName}/rollout",
+          "parameters": {
+            "unitName": {
+              "type": "string",
+              "location": "path",
+              "required": true
+            }
+          },
+          "parameterOrder": [
+            "unitName"
+          ],
+          "response": {
+            "$ref": "UnitRollout"
+          }
+        },
         "Delete": {
           "id": "fleet.Unit.Delete",
           "description": "Delete the referenced Unit object.",
@@ -17,6 +17,7 @@ package server
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"sync"
@@ -34,6 +35,7 @@ import (
 	"github.com/nickswift/fleet/machine"
 	"github.com/nickswift/fleet/pkg"
 	"github.com/nickswift/fleet/pkg/lease"
+	"github.com/nickswift/fleet/pkg/localkv"
 	"github.com/nickswift/fleet/registry"
 	"github.com/nickswift/fleet/registry/rpc"
 	"github.com/nickswift/fleet/systemd"
@@ -81,31 +83,30 @@ func New(cfg config.Config, listeners []net.Listener) (*Server, error) {
 		return nil, err
 	}
 
-	mach, err := newMachineFromConfig(cfg, mgr)
+	sched, err := engine.NewScheduler(cfg.SchedulerStrategy)
 	if err != nil {
 		return nil, err
 	}
 
-	tlsConfig, err := pkg.ReadTLSConfigFiles(cfg.EtcdCAFile, cfg.EtcdCertFile, cfg.EtcdKeyFile)
-	if err != nil {
-		return nil, err
+	var rebal *engine.Rebalancer
+	if cfg.RebalanceThreshold > 0 || cfg.RebalanceResourceThreshold > 0 {
+		rebal = &engine.Rebalancer{
+			Threshold:         cfg.RebalanceThreshold,
+			ResourceThreshold: cfg.RebalanceResourceThreshold,
+			MaxMoves:          cfg.RebalanceMaxMoves,
+		}
 	}
 
-	eCfg := etcd.Config{
-		Transport:               &http.Transport{TLSClientConfig: tlsConfig},
-		Endpoints:               cfg.EtcdServers,
-		HeaderTimeoutPerRequest: (time.Duration(cfg.EtcdRequestTimeout*1000) * time.Millisecond),
-		Username:                cfg.EtcdUsername,
-		Password:                cfg.EtcdPassword,
+	mach, err := newMachineFromConfig(cfg, mgr)
+	if err != nil {
+		return nil, err
 	}
 
-	eClient, err := etcd.New(eCfg)
+	kAPI, err := newKeysAPI(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	kAPI := etcd.NewKeysAPI(eClient)
-
 	var (
 		reg        engine.CompleteRegistry
 		genericReg interface{}
@@ -139,10 +140,10 @@ func New(cfg config.Config, listeners []net.Listener) (*Server, error) {
 
 	var e *engine.Engine
 	if !cfg.EnableGRPC {
-		e = engine.New(reg, lManager, rStream, mach, nil)
+		e = engine.New(reg, lManager, rStream, mach, sched, rebal, nil)
 	} else {
 		regMux := genericReg.(*rpc.RegistryMux)
-		e = engine.New(reg, lManager, rStream, mach, regMux.EngineChanged)
+		e = engine.New(reg, lManager, rStream, mach, sched, rebal, regMux.EngineChanged)
 		if cfg.DisableEngine {
 			go regMux.ConnectToRegistry(e)
 		}
@@ -184,10 +185,36 @@ func New(cfg config.Config, listeners []net.Listener) (*Server, error) {
 	return &srv, nil
 }
 
+// newKeysAPI returns the KeysAPI storing fleet data in the configured
+// registry backend.
+func newKeysAPI(cfg config.Config) (etcd.KeysAPI, error) {
+	switch cfg.RegistryBackend {
+	case registry.BackendEtcd:
+		tlsConfig, err := pkg.ReadTLSConfigFiles(cfg.EtcdCAFile, cfg.EtcdCertFile, cfg.EtcdKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		eCfg := etcd.Config{
+			Transport:               &http.Transport{TLSClientConfig: tlsConfig},
+			Endpoints:               cfg.EtcdServers,
+			HeaderTimeoutPerRequest: (time.Duration(cfg.EtcdRequestTimeout*1000) * time.Millisecond),
+			Username:                cfg.EtcdUsername,
+			Password:                cfg.EtcdPassword,
+		}
+		return registry.NewKeysAPI(cfg.EtcdAPIVersion, eCfg)
+	case registry.BackendLocal:
+		return localkv.NewKeysAPI(cfg.LocalRegistryFile)
+	default:
+		return nil, fmt.Errorf("unknown registry backend %q, expected %q or %q", cfg.RegistryBackend, registry.BackendEtcd, registry.BackendLocal)
+	}
+}
+
 func newMachineFromConfig(cfg config.Config, mgr unit.UnitManager) (*machine.CoreOSMachine, error) {
 	state := machine.MachineState{
 		PublicIP:     cfg.PublicIP,
 		Metadata:     cfg.Metadata(),
+		Taints:       cfg.Taints(),
 		Capabilities: cfg.Capabilities(),
 		Version:      version.Version,
 	}